@@ -0,0 +1,335 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/octoblu/governator-swarm/deployer"
+	"github.com/octoblu/governator-swarm/metrics"
+	De "github.com/tj/go-debug"
+	"golang.org/x/net/context"
+)
+
+var debug = De.Debug("governator:api")
+
+// readyTimeout bounds the redis and docker checks of /healthz/ready
+const readyTimeout = 2 * time.Second
+
+// EventSource gives out the events of in progress deploys
+type EventSource interface {
+	SubscribeEvents(deploy string) (events <-chan deployer.DeployEvent, unsubscribe func(), ok bool)
+}
+
+// StatusSource reports the health of the deployer
+type StatusSource interface {
+	Status() *deployer.Status
+}
+
+// HealthSource reports whether the deployer is live and ready
+type HealthSource interface {
+	Live() error
+	Ready(ctx context.Context) error
+}
+
+// Planner plans deploys without running them
+type Planner interface {
+	TestDeploy(metadata *deployer.RequestMetadata) (*deployer.DeployPlan, error)
+}
+
+// Canceller cancels queued deploys
+type Canceller interface {
+	BulkCancel(ctx context.Context, filter func(*deployer.RequestMetadata) bool) (int, error)
+}
+
+// Pauser stops and starts queue processing
+type Pauser interface {
+	Pause()
+	Resume()
+	Paused() bool
+}
+
+// Deployer is the part of *deployer.Deployer the API serves
+type Deployer interface {
+	EventSource
+	StatusSource
+	HealthSource
+	Planner
+	Canceller
+	Pauser
+}
+
+// controlResponse is the body POST /control/pause and /control/resume respond with
+type controlResponse struct {
+	Paused bool `json:"paused"`
+}
+
+// bulkCancelFilter selects the deploys POST /deploys/bulk-cancel
+// cancels. A deploy must match every field that is set
+type bulkCancelFilter struct {
+	DockerURLPrefix string            `json:"dockerUrlPrefix"`
+	Annotations     map[string]string `json:"annotations"`
+}
+
+// bulkCancelResponse is the body POST /deploys/bulk-cancel responds with
+type bulkCancelResponse struct {
+	Cancelled int `json:"cancelled"`
+}
+
+// New constructs the HTTP API. It serves metrics on GET /metrics,
+// the deployer's status as JSON on GET /status, liveness and readiness
+// probes on GET /healthz/live and /healthz/ready, the events of an in
+// progress deploy as Server-Sent Events on GET /deploys/{deploy}/events,
+// the plan for the deploy metadata in the body on PUT /deploys/plan,
+// cancels the queued deploys matching the filter in the body on
+// POST /deploys/bulk-cancel, and pauses and resumes queue processing
+// on POST /control/pause and /control/resume
+func New(theDeployer Deployer) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler(metrics.DefaultRegistry))
+	mux.Handle("/status", &statusHandler{statusSource: theDeployer})
+	mux.Handle("/healthz/live", &healthHandler{check: func(ctx context.Context) error {
+		return theDeployer.Live()
+	}})
+	mux.Handle("/healthz/ready", &healthHandler{check: theDeployer.Ready})
+	mux.Handle("/deploys/", &deploysHandler{eventSource: theDeployer, planner: theDeployer, canceller: theDeployer})
+	mux.Handle("/control/", &controlHandler{pauser: theDeployer})
+	return mux
+}
+
+type statusHandler struct {
+	statusSource StatusSource
+}
+
+func (handler *statusHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	if request.Method != "GET" {
+		response.Header().Set("Allow", "GET")
+		http.Error(response, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	response.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(response).Encode(handler.statusSource.Status())
+	if err != nil {
+		debug("error writing status: %v", err)
+	}
+}
+
+// healthHandler responds 200 when check passes,
+// and 503 with the error when it doesn't
+type healthHandler struct {
+	check func(ctx context.Context) error
+}
+
+func (handler *healthHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	if request.Method != "GET" {
+		response.Header().Set("Allow", "GET")
+		http.Error(response, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), readyTimeout)
+	defer cancel()
+
+	err := handler.check(ctx)
+	if err != nil {
+		http.Error(response, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(response, "ok")
+}
+
+type controlHandler struct {
+	pauser Pauser
+}
+
+func (handler *controlHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	action := strings.TrimPrefix(request.URL.Path, "/control/")
+	if action != "pause" && action != "resume" {
+		http.NotFound(response, request)
+		return
+	}
+
+	if request.Method != "POST" {
+		response.Header().Set("Allow", "POST")
+		http.Error(response, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if action == "pause" {
+		handler.pauser.Pause()
+	} else {
+		handler.pauser.Resume()
+	}
+
+	response.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(response).Encode(&controlResponse{Paused: handler.pauser.Paused()})
+	if err != nil {
+		debug("error writing control response: %v", err)
+	}
+}
+
+type deploysHandler struct {
+	eventSource EventSource
+	planner     Planner
+	canceller   Canceller
+}
+
+func (handler *deploysHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	path := strings.TrimPrefix(request.URL.Path, "/deploys/")
+	if path == "plan" {
+		handler.plan(response, request)
+		return
+	}
+
+	if path == "bulk-cancel" {
+		handler.bulkCancel(response, request)
+		return
+	}
+
+	if !strings.HasSuffix(path, "/events") {
+		http.NotFound(response, request)
+		return
+	}
+
+	deploy := strings.TrimSuffix(path, "/events")
+	if deploy == "" {
+		http.NotFound(response, request)
+		return
+	}
+
+	if request.Method != "GET" {
+		response.Header().Set("Allow", "GET")
+		http.Error(response, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	handler.streamEvents(response, request, deploy)
+}
+
+// plan responds with the DeployPlan for the RequestMetadata in the
+// body, 400 when the metadata is invalid, and 500 for other errors
+func (handler *deploysHandler) plan(response http.ResponseWriter, request *http.Request) {
+	if request.Method != "PUT" {
+		response.Header().Set("Allow", "PUT")
+		http.Error(response, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var metadata deployer.RequestMetadata
+	err := json.NewDecoder(request.Body).Decode(&metadata)
+	if err != nil {
+		http.Error(response, fmt.Sprintf("Invalid deploy metadata: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	plan, err := handler.planner.TestDeploy(&metadata)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if _, ok := err.(*deployer.ValidationError); ok {
+			status = http.StatusBadRequest
+		}
+		http.Error(response, err.Error(), status)
+		return
+	}
+
+	response.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(response).Encode(plan)
+	if err != nil {
+		debug("error writing plan: %v", err)
+	}
+}
+
+// bulkCancel cancels the queued deploys matching the bulkCancelFilter in
+// the body, and responds with how many it cancelled. An empty filter is
+// refused with 400, rather than cancelling every deploy
+func (handler *deploysHandler) bulkCancel(response http.ResponseWriter, request *http.Request) {
+	if request.Method != "POST" {
+		response.Header().Set("Allow", "POST")
+		http.Error(response, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var filter bulkCancelFilter
+	err := json.NewDecoder(request.Body).Decode(&filter)
+	if err != nil {
+		http.Error(response, fmt.Sprintf("Invalid filter: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if filter.DockerURLPrefix == "" && len(filter.Annotations) == 0 {
+		http.Error(response, "Invalid filter: dockerUrlPrefix or annotations is required", http.StatusBadRequest)
+		return
+	}
+
+	cancelled, err := handler.canceller.BulkCancel(request.Context(), filter.matches)
+	if err != nil {
+		http.Error(response, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(response).Encode(&bulkCancelResponse{Cancelled: cancelled})
+	if err != nil {
+		debug("error writing bulk cancel response: %v", err)
+	}
+}
+
+func (filter *bulkCancelFilter) matches(metadata *deployer.RequestMetadata) bool {
+	if !strings.HasPrefix(metadata.DockerURL, filter.DockerURLPrefix) {
+		return false
+	}
+
+	for key, value := range filter.Annotations {
+		if metadata.Annotations[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// streamEvents writes each of the deploy's events as an SSE event
+// named after the event type, until the deploy completes or the
+// client goes away
+func (handler *deploysHandler) streamEvents(response http.ResponseWriter, request *http.Request, deploy string) {
+	flusher, ok := response.(http.Flusher)
+	if !ok {
+		http.Error(response, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe, ok := handler.eventSource.SubscribeEvents(deploy)
+	if !ok {
+		http.Error(response, fmt.Sprintf("Deploy '%v' is not in progress", deploy), http.StatusNotFound)
+		return
+	}
+	defer unsubscribe()
+
+	response.Header().Set("Content-Type", "text/event-stream")
+	response.Header().Set("Cache-Control", "no-cache")
+	response.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	done := request.Context().Done()
+	for {
+		select {
+		case <-done:
+			debug("client went away streaming %v", deploy)
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				debug("error encoding %v event: %v", event.Type, err)
+				continue
+			}
+			fmt.Fprintf(response, "event: %s\ndata: %s\n\n", event.Type, data)
+			flusher.Flush()
+		}
+	}
+}
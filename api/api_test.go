@@ -0,0 +1,337 @@
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/octoblu/governator-swarm/api"
+	"github.com/octoblu/governator-swarm/deployer"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("API", func() {
+	var theDeployer *fakeDeployer
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		theDeployer = &fakeDeployer{deploys: map[string][]deployer.DeployEvent{}}
+		server = httptest.NewServer(api.New(theDeployer))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("GET /deploys/{deploy}/events", func() {
+		Describe("When the deploy is in progress", func() {
+			var response *http.Response
+			var body string
+
+			BeforeEach(func() {
+				theDeployer.deploys["my-application:v1"] = []deployer.DeployEvent{
+					{Deploy: "my-application:v1", Type: deployer.EventLocked},
+					{Deploy: "my-application:v1", Type: deployer.EventSucceeded},
+				}
+
+				var err error
+				response, err = http.Get(server.URL + "/deploys/my-application:v1/events")
+				Expect(err).To(BeNil())
+				defer response.Body.Close()
+
+				bodyBytes, err := ioutil.ReadAll(response.Body)
+				Expect(err).To(BeNil())
+				body = string(bodyBytes)
+			})
+
+			It("Should stream the events as SSE", func() {
+				Expect(response.StatusCode).To(Equal(http.StatusOK))
+				Expect(response.Header.Get("Content-Type")).To(Equal("text/event-stream"))
+				Expect(body).To(ContainSubstring("event: locked\ndata: {\"deploy\":\"my-application:v1\",\"type\":\"locked\""))
+				Expect(body).To(ContainSubstring("event: succeeded\n"))
+			})
+		})
+
+		Describe("When the deploy is not in progress", func() {
+			It("Should return a 404", func() {
+				response, err := http.Get(server.URL + "/deploys/my-application:v1/events")
+				Expect(err).To(BeNil())
+				response.Body.Close()
+				Expect(response.StatusCode).To(Equal(http.StatusNotFound))
+			})
+		})
+	})
+
+	Describe("PUT /deploys/plan", func() {
+		var response *http.Response
+
+		put := func(body string) {
+			request, err := http.NewRequest("PUT", server.URL+"/deploys/plan", strings.NewReader(body))
+			Expect(err).To(BeNil())
+			response, err = http.DefaultClient.Do(request)
+			Expect(err).To(BeNil())
+		}
+
+		AfterEach(func() {
+			response.Body.Close()
+		})
+
+		It("Should serve the plan for the metadata as JSON", func() {
+			put(`{"dockerUrl":"octoblu/my-application:v2"}`)
+
+			var plan deployer.DeployPlan
+			Expect(json.NewDecoder(response.Body).Decode(&plan)).To(Succeed())
+			Expect(response.StatusCode).To(Equal(http.StatusOK))
+			Expect(response.Header.Get("Content-Type")).To(Equal("application/json"))
+			Expect(plan.ProposedImage).To(Equal("octoblu/my-application:v2"))
+		})
+
+		Describe("When the body isn't metadata", func() {
+			It("Should respond 400", func() {
+				put("not json")
+				Expect(response.StatusCode).To(Equal(http.StatusBadRequest))
+			})
+		})
+
+		Describe("When the metadata is invalid", func() {
+			It("Should respond 400", func() {
+				theDeployer.planErr = &deployer.ValidationError{Message: "Invalid networkMode"}
+				put(`{"dockerUrl":"octoblu/my-application:v2"}`)
+				Expect(response.StatusCode).To(Equal(http.StatusBadRequest))
+			})
+		})
+	})
+
+	Describe("POST /deploys/bulk-cancel", func() {
+		var response *http.Response
+
+		post := func(body string) {
+			var err error
+			response, err = http.Post(server.URL+"/deploys/bulk-cancel", "application/json", strings.NewReader(body))
+			Expect(err).To(BeNil())
+		}
+
+		BeforeEach(func() {
+			theDeployer.queued = []deployer.RequestMetadata{
+				{DockerURL: "octoblu/payments-api:v2", Annotations: map[string]string{"team": "payments"}},
+				{DockerURL: "octoblu/payments-worker:v2"},
+				{DockerURL: "octoblu/my-application:v2", Annotations: map[string]string{"team": "payments"}},
+			}
+		})
+
+		AfterEach(func() {
+			response.Body.Close()
+		})
+
+		It("Should cancel the deploys matching every field of the filter", func() {
+			post(`{"dockerUrlPrefix":"octoblu/payments-","annotations":{"team":"payments"}}`)
+
+			var body map[string]int
+			Expect(json.NewDecoder(response.Body).Decode(&body)).To(Succeed())
+			Expect(response.StatusCode).To(Equal(http.StatusOK))
+			Expect(body).To(Equal(map[string]int{"cancelled": 1}))
+		})
+
+		It("Should match on the docker url prefix alone", func() {
+			post(`{"dockerUrlPrefix":"octoblu/payments-"}`)
+
+			var body map[string]int
+			Expect(json.NewDecoder(response.Body).Decode(&body)).To(Succeed())
+			Expect(body).To(Equal(map[string]int{"cancelled": 2}))
+		})
+
+		Describe("When the filter is empty", func() {
+			It("Should respond 400 rather than cancel everything", func() {
+				post(`{}`)
+				Expect(response.StatusCode).To(Equal(http.StatusBadRequest))
+			})
+		})
+
+		Describe("When it isn't a POST", func() {
+			It("Should respond 405", func() {
+				var err error
+				response, err = http.Get(server.URL + "/deploys/bulk-cancel")
+				Expect(err).To(BeNil())
+				Expect(response.StatusCode).To(Equal(http.StatusMethodNotAllowed))
+			})
+		})
+	})
+
+	Describe("GET /status", func() {
+		It("Should serve the deployer's status as JSON", func() {
+			response, err := http.Get(server.URL + "/status")
+			Expect(err).To(BeNil())
+			defer response.Body.Close()
+
+			var status deployer.Status
+			Expect(json.NewDecoder(response.Body).Decode(&status)).To(Succeed())
+			Expect(response.Header.Get("Content-Type")).To(Equal("application/json"))
+			Expect(status.QueueDepth).To(Equal(3))
+			Expect(status.RedisOK).To(BeTrue())
+		})
+	})
+
+	Describe("POST /control/pause", func() {
+		It("Should pause the deployer", func() {
+			response, err := http.Post(server.URL+"/control/pause", "application/json", nil)
+			Expect(err).To(BeNil())
+			defer response.Body.Close()
+
+			body, err := ioutil.ReadAll(response.Body)
+			Expect(err).To(BeNil())
+			Expect(response.StatusCode).To(Equal(http.StatusOK))
+			Expect(string(body)).To(MatchJSON(`{"paused":true}`))
+			Expect(theDeployer.paused).To(BeTrue())
+		})
+
+		It("Should refuse a GET", func() {
+			response, err := http.Get(server.URL + "/control/pause")
+			Expect(err).To(BeNil())
+			response.Body.Close()
+			Expect(response.StatusCode).To(Equal(http.StatusMethodNotAllowed))
+			Expect(theDeployer.paused).To(BeFalse())
+		})
+	})
+
+	Describe("POST /control/resume", func() {
+		It("Should resume the deployer", func() {
+			theDeployer.paused = true
+			response, err := http.Post(server.URL+"/control/resume", "application/json", nil)
+			Expect(err).To(BeNil())
+			defer response.Body.Close()
+
+			body, err := ioutil.ReadAll(response.Body)
+			Expect(err).To(BeNil())
+			Expect(string(body)).To(MatchJSON(`{"paused":false}`))
+			Expect(theDeployer.paused).To(BeFalse())
+		})
+	})
+
+	Describe("POST /control/{unknown}", func() {
+		It("Should respond 404", func() {
+			response, err := http.Post(server.URL+"/control/stop", "application/json", nil)
+			Expect(err).To(BeNil())
+			response.Body.Close()
+			Expect(response.StatusCode).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Describe("GET /healthz/live", func() {
+		It("Should respond 200 when the deployer is live", func() {
+			response, err := http.Get(server.URL + "/healthz/live")
+			Expect(err).To(BeNil())
+			response.Body.Close()
+			Expect(response.StatusCode).To(Equal(http.StatusOK))
+		})
+
+		It("Should respond 503 when the deploy loop is stuck", func() {
+			theDeployer.liveErr = fmt.Errorf("Run has been going for 10m0s")
+			response, err := http.Get(server.URL + "/healthz/live")
+			Expect(err).To(BeNil())
+			response.Body.Close()
+			Expect(response.StatusCode).To(Equal(http.StatusServiceUnavailable))
+		})
+	})
+
+	Describe("GET /healthz/ready", func() {
+		It("Should respond 200 when redis and docker are reachable", func() {
+			response, err := http.Get(server.URL + "/healthz/ready")
+			Expect(err).To(BeNil())
+			response.Body.Close()
+			Expect(response.StatusCode).To(Equal(http.StatusOK))
+		})
+
+		It("Should respond 503 with the error when they aren't", func() {
+			theDeployer.readyErr = fmt.Errorf("redis: connection refused")
+			response, err := http.Get(server.URL + "/healthz/ready")
+			Expect(err).To(BeNil())
+			defer response.Body.Close()
+
+			body, err := ioutil.ReadAll(response.Body)
+			Expect(err).To(BeNil())
+			Expect(response.StatusCode).To(Equal(http.StatusServiceUnavailable))
+			Expect(string(body)).To(ContainSubstring("redis: connection refused"))
+		})
+	})
+
+	Describe("GET /metrics", func() {
+		It("Should serve the metrics", func() {
+			response, err := http.Get(server.URL + "/metrics")
+			Expect(err).To(BeNil())
+			response.Body.Close()
+			Expect(response.StatusCode).To(Equal(http.StatusOK))
+		})
+	})
+})
+
+type fakeDeployer struct {
+	deploys  map[string][]deployer.DeployEvent
+	liveErr  error
+	readyErr error
+	planErr  error
+
+	// queued are filtered by BulkCancel
+	queued []deployer.RequestMetadata
+
+	paused bool
+}
+
+func (theDeployer *fakeDeployer) Pause() {
+	theDeployer.paused = true
+}
+
+func (theDeployer *fakeDeployer) Resume() {
+	theDeployer.paused = false
+}
+
+func (theDeployer *fakeDeployer) Paused() bool {
+	return theDeployer.paused
+}
+
+func (theDeployer *fakeDeployer) Live() error {
+	return theDeployer.liveErr
+}
+
+func (theDeployer *fakeDeployer) Ready(ctx context.Context) error {
+	return theDeployer.readyErr
+}
+
+func (theDeployer *fakeDeployer) Status() *deployer.Status {
+	return &deployer.Status{RedisOK: true, QueueDepth: 3}
+}
+
+func (theDeployer *fakeDeployer) TestDeploy(metadata *deployer.RequestMetadata) (*deployer.DeployPlan, error) {
+	if theDeployer.planErr != nil {
+		return nil, theDeployer.planErr
+	}
+	return &deployer.DeployPlan{ProposedImage: metadata.DockerURL}, nil
+}
+
+func (theDeployer *fakeDeployer) BulkCancel(ctx context.Context, filter func(*deployer.RequestMetadata) bool) (int, error) {
+	cancelled := 0
+	for i := range theDeployer.queued {
+		if filter(&theDeployer.queued[i]) {
+			cancelled++
+		}
+	}
+	return cancelled, nil
+}
+
+func (theDeployer *fakeDeployer) SubscribeEvents(deploy string) (<-chan deployer.DeployEvent, func(), bool) {
+	events, ok := theDeployer.deploys[deploy]
+	if !ok {
+		return nil, nil, false
+	}
+
+	channel := make(chan deployer.DeployEvent, len(events))
+	for _, event := range events {
+		channel <- event
+	}
+	close(channel)
+	return channel, func() {}, true
+}
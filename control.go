@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/codegangsta/cli"
+)
+
+func pauseCommand() cli.Command {
+	return cli.Command{
+		Name:   "pause",
+		Usage:  "Stop the running daemon, from its --api-addr, from taking deploys off the queue, until resume or a restart",
+		Action: pause,
+	}
+}
+
+func resumeCommand() cli.Command {
+	return cli.Command{
+		Name:   "resume",
+		Usage:  "Let the running daemon, from its --api-addr, take deploys off the queue again",
+		Action: resume,
+	}
+}
+
+func pause(context *cli.Context) error {
+	return control(context, "pause")
+}
+
+func resume(context *cli.Context) error {
+	return control(context, "resume")
+}
+
+// control POSTs the action to the daemon's /control/{action}
+func control(context *cli.Context, action string) error {
+	apiAddr := context.GlobalString("api-addr")
+	if apiAddr == "" {
+		return cli.NewExitError(fmt.Sprintf("%v requires the daemon's --api-addr", action), 1)
+	}
+
+	controlURL := getAPIURL(apiAddr, "/control/"+action)
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+
+	response, err := httpClient.Post(controlURL, "application/json", nil)
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("Error sending %v to %v: %v", action, controlURL, err), 1)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return cli.NewExitError(fmt.Sprintf("Error sending %v to %v: unexpected response %v", action, controlURL, response.StatusCode), 1)
+	}
+
+	var state struct {
+		Paused bool `json:"paused"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&state)
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("Error reading the response from %v: %v", controlURL, err), 1)
+	}
+
+	if state.Paused {
+		fmt.Println("Paused, deploys stay on the queue until resume")
+	} else {
+		fmt.Println("Resumed, deploys are taken off the queue")
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package deployer
+
+import (
+	"strings"
+
+	"github.com/docker/engine-api/types/swarm"
+)
+
+const (
+	// AnnotationLabelPrefix is prepended to annotation keys
+	// when they are written as service labels
+	AnnotationLabelPrefix = "governator.annotation/"
+
+	maxAnnotations           = 10
+	maxAnnotationKeyLength   = 128
+	maxAnnotationValueLength = 1024
+)
+
+func validateAnnotations(annotations map[string]string) error {
+	if len(annotations) > maxAnnotations {
+		return newValidationError("Too many annotations, %v is more than %v", len(annotations), maxAnnotations)
+	}
+
+	for key, value := range annotations {
+		if key == "" {
+			return newValidationError("Invalid annotation, key must not be empty")
+		}
+		if len(key) > maxAnnotationKeyLength {
+			return newValidationError("Invalid annotation '%v', key is longer than %v characters", key, maxAnnotationKeyLength)
+		}
+		if len(value) > maxAnnotationValueLength {
+			return newValidationError("Invalid annotation '%v', value is longer than %v characters", key, maxAnnotationValueLength)
+		}
+	}
+	return nil
+}
+
+// setAnnotationLabels replaces the annotation labels
+// left by the last deploy with this deploy's annotations
+func setAnnotationLabels(spec *swarm.ServiceSpec, annotations map[string]string) {
+	for key := range spec.Labels {
+		if strings.HasPrefix(key, AnnotationLabelPrefix) {
+			delete(spec.Labels, key)
+		}
+	}
+
+	if len(annotations) == 0 {
+		return
+	}
+
+	if spec.Labels == nil {
+		spec.Labels = map[string]string{}
+	}
+	for key, value := range annotations {
+		spec.Labels[AnnotationLabelPrefix+key] = value
+	}
+}
@@ -0,0 +1,115 @@
+package deployer
+
+import (
+	"time"
+
+	"github.com/docker/engine-api/client"
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/container"
+	"github.com/docker/engine-api/types/network"
+	"github.com/docker/engine-api/types/swarm"
+	"golang.org/x/net/context"
+)
+
+// DefaultDockerAPICallTimeout is how long a single docker API call may take
+const DefaultDockerAPICallTimeout = 30 * time.Second
+
+// callTimeoutClient gives each docker API call its own timeout, on top of
+// whatever deadline the caller's context already has. The streaming
+// calls, Events, ImagePull and ContainerLogs, and ContainerWait, which
+// waits on the container rather than the daemon, aren't wrapped
+type callTimeoutClient struct {
+	client.APIClient
+
+	timeout time.Duration
+}
+
+// NewTimeoutClient wraps dockerClient so each request
+// to the daemon fails when it takes longer than timeout
+func NewTimeoutClient(dockerClient client.APIClient, timeout time.Duration) client.APIClient {
+	return &callTimeoutClient{APIClient: dockerClient, timeout: timeout}
+}
+
+func (timeoutClient *callTimeoutClient) ServerVersion(ctx context.Context) (types.Version, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeoutClient.timeout)
+	defer cancel()
+	return timeoutClient.APIClient.ServerVersion(ctx)
+}
+
+func (timeoutClient *callTimeoutClient) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, containerName string) (types.ContainerCreateResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeoutClient.timeout)
+	defer cancel()
+	return timeoutClient.APIClient.ContainerCreate(ctx, config, hostConfig, networkingConfig, containerName)
+}
+
+func (timeoutClient *callTimeoutClient) ContainerRemove(ctx context.Context, containerID string, options types.ContainerRemoveOptions) error {
+	ctx, cancel := context.WithTimeout(ctx, timeoutClient.timeout)
+	defer cancel()
+	return timeoutClient.APIClient.ContainerRemove(ctx, containerID, options)
+}
+
+func (timeoutClient *callTimeoutClient) ContainerStart(ctx context.Context, containerID string, options types.ContainerStartOptions) error {
+	ctx, cancel := context.WithTimeout(ctx, timeoutClient.timeout)
+	defer cancel()
+	return timeoutClient.APIClient.ContainerStart(ctx, containerID, options)
+}
+
+func (timeoutClient *callTimeoutClient) ImageInspectWithRaw(ctx context.Context, image string) (types.ImageInspect, []byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeoutClient.timeout)
+	defer cancel()
+	return timeoutClient.APIClient.ImageInspectWithRaw(ctx, image)
+}
+
+func (timeoutClient *callTimeoutClient) NetworkInspect(ctx context.Context, networkID string) (types.NetworkResource, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeoutClient.timeout)
+	defer cancel()
+	return timeoutClient.APIClient.NetworkInspect(ctx, networkID)
+}
+
+func (timeoutClient *callTimeoutClient) NodeList(ctx context.Context, options types.NodeListOptions) ([]swarm.Node, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeoutClient.timeout)
+	defer cancel()
+	return timeoutClient.APIClient.NodeList(ctx, options)
+}
+
+func (timeoutClient *callTimeoutClient) ServiceCreate(ctx context.Context, service swarm.ServiceSpec, options types.ServiceCreateOptions) (types.ServiceCreateResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeoutClient.timeout)
+	defer cancel()
+	return timeoutClient.APIClient.ServiceCreate(ctx, service, options)
+}
+
+func (timeoutClient *callTimeoutClient) ServiceInspectWithRaw(ctx context.Context, serviceID string) (swarm.Service, []byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeoutClient.timeout)
+	defer cancel()
+	return timeoutClient.APIClient.ServiceInspectWithRaw(ctx, serviceID)
+}
+
+func (timeoutClient *callTimeoutClient) ServiceList(ctx context.Context, options types.ServiceListOptions) ([]swarm.Service, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeoutClient.timeout)
+	defer cancel()
+	return timeoutClient.APIClient.ServiceList(ctx, options)
+}
+
+func (timeoutClient *callTimeoutClient) ServiceRemove(ctx context.Context, serviceID string) error {
+	ctx, cancel := context.WithTimeout(ctx, timeoutClient.timeout)
+	defer cancel()
+	return timeoutClient.APIClient.ServiceRemove(ctx, serviceID)
+}
+
+func (timeoutClient *callTimeoutClient) ServiceUpdate(ctx context.Context, serviceID string, version swarm.Version, service swarm.ServiceSpec, options types.ServiceUpdateOptions) error {
+	ctx, cancel := context.WithTimeout(ctx, timeoutClient.timeout)
+	defer cancel()
+	return timeoutClient.APIClient.ServiceUpdate(ctx, serviceID, version, service, options)
+}
+
+func (timeoutClient *callTimeoutClient) TaskList(ctx context.Context, options types.TaskListOptions) ([]swarm.Task, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeoutClient.timeout)
+	defer cancel()
+	return timeoutClient.APIClient.TaskList(ctx, options)
+}
+
+func (timeoutClient *callTimeoutClient) SwarmJoin(ctx context.Context, req swarm.JoinRequest) error {
+	ctx, cancel := context.WithTimeout(ctx, timeoutClient.timeout)
+	defer cancel()
+	return timeoutClient.APIClient.SwarmJoin(ctx, req)
+}
@@ -0,0 +1,75 @@
+package deployer
+
+import (
+	"time"
+
+	"github.com/docker/engine-api/client"
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/swarm"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("NewTimeoutClient", func() {
+	var dockerClient *slowDockerClient
+	var sut client.APIClient
+
+	BeforeEach(func() {
+		dockerClient = &slowDockerClient{delay: time.Second}
+		sut = NewTimeoutClient(dockerClient, 10*time.Millisecond)
+	})
+
+	It("Should fail a call that takes longer than the timeout", func() {
+		_, _, err := sut.ServiceInspectWithRaw(context.Background(), "my-application")
+		Expect(err).To(Equal(context.DeadlineExceeded))
+	})
+
+	It("Should time out each call on its own", func() {
+		dockerClient.delay = 5 * time.Millisecond
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		for i := 0; i < 5; i++ {
+			_, err := sut.TaskList(ctx, types.TaskListOptions{})
+			Expect(err).To(BeNil())
+		}
+	})
+
+	It("Should keep the caller's shorter deadline", func() {
+		sut = NewTimeoutClient(dockerClient, time.Minute)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		err := sut.ServiceUpdate(ctx, "my-application", swarm.Version{}, swarm.ServiceSpec{}, types.ServiceUpdateOptions{})
+		Expect(err).To(Equal(context.DeadlineExceeded))
+	})
+})
+
+// slowDockerClient answers after delay, unless the context is done first
+type slowDockerClient struct {
+	client.APIClient
+
+	delay time.Duration
+}
+
+func (fake *slowDockerClient) wait(ctx context.Context) error {
+	select {
+	case <-time.After(fake.delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (fake *slowDockerClient) ServiceInspectWithRaw(ctx context.Context, serviceID string) (swarm.Service, []byte, error) {
+	return swarm.Service{ID: serviceID}, nil, fake.wait(ctx)
+}
+
+func (fake *slowDockerClient) ServiceUpdate(ctx context.Context, serviceID string, version swarm.Version, spec swarm.ServiceSpec, options types.ServiceUpdateOptions) error {
+	return fake.wait(ctx)
+}
+
+func (fake *slowDockerClient) TaskList(ctx context.Context, options types.TaskListOptions) ([]swarm.Task, error) {
+	return nil, fake.wait(ctx)
+}
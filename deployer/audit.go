@@ -0,0 +1,76 @@
+package deployer
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"sync"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// auditLog appends DeployRecords to a file, one JSON object per line.
+// lumberjack renames the file with a timestamp and starts a new one
+// when it would grow past maxSize megabytes
+type auditLog struct {
+	path string
+
+	lock   sync.Mutex
+	logger *lumberjack.Logger
+}
+
+// newAuditLog constructs an auditLog, maxSize 0 never rotates the file
+func newAuditLog(path string, maxSize int) *auditLog {
+	if maxSize == 0 {
+		// lumberjack defaults 0 to 100MB
+		maxSize = math.MaxInt32
+	}
+	return &auditLog{
+		path:   path,
+		logger: &lumberjack.Logger{Filename: path, MaxSize: maxSize},
+	}
+}
+
+// Write appends the record and fsyncs the file
+func (writer *auditLog) Write(record *DeployRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	writer.lock.Lock()
+	defer writer.lock.Unlock()
+
+	_, err = writer.logger.Write(line)
+	if err != nil {
+		return err
+	}
+
+	return writer.sync()
+}
+
+// Close closes the file, a later Write opens it again
+func (writer *auditLog) Close() error {
+	writer.lock.Lock()
+	defer writer.lock.Unlock()
+
+	return writer.logger.Close()
+}
+
+// sync fsyncs the current file. lumberjack doesn't expose
+// its file, the fsync through another descriptor flushes
+// the same file all the same
+func (writer *auditLog) sync() error {
+	file, err := os.OpenFile(writer.path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	syncErr := file.Sync()
+	err = file.Close()
+	if syncErr != nil {
+		return syncErr
+	}
+	return err
+}
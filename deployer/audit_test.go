@@ -0,0 +1,83 @@
+package deployer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("auditLog", func() {
+	var dir string
+	var path string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "governator-audit")
+		Expect(err).To(BeNil())
+		path = filepath.Join(dir, "audit.jsonl")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	readLines := func(path string) []string {
+		data, err := ioutil.ReadFile(path)
+		Expect(err).To(BeNil())
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+
+	Describe("When a deploy fails", func() {
+		BeforeEach(func() {
+			dockerClient := &fakeDockerClient{}
+			dockerClient.service.Spec.TaskTemplate.ContainerSpec.Image = "octoblu/my-application:v1"
+			sut := New(dockerClient, nil, "redis-queue:name", "https://deploy-state.test", "super", WithAuditLog(path))
+
+			metadata := &RequestMetadata{
+				DockerURL:      "octoblu/my-application:v2",
+				TimeoutSeconds: -1,
+				Annotations:    map[string]string{"initiator": "sqrtofsaturn"},
+			}
+			sut.deploy("my-application:v2", metadata)
+		})
+
+		It("Should write a record of the attempt", func() {
+			lines := readLines(path)
+			Expect(lines).To(HaveLen(1))
+
+			var record DeployRecord
+			Expect(json.Unmarshal([]byte(lines[0]), &record)).To(Succeed())
+			Expect(record.Deploy).To(Equal("my-application:v2"))
+			Expect(record.Service).To(Equal("my-application"))
+			Expect(record.Image).To(Equal("octoblu/my-application:v2"))
+			Expect(record.Cluster).To(Equal("super"))
+			Expect(record.Initiator).To(Equal("sqrtofsaturn"))
+			Expect(record.Result).To(Equal("failed"))
+			Expect(record.Error).To(ContainSubstring("timeoutSeconds"))
+		})
+	})
+
+	Describe("When the file would grow past the max size", func() {
+		BeforeEach(func() {
+			writer := newAuditLog(path, 1)
+			for i := 0; i < 2; i++ {
+				err := writer.Write(&DeployRecord{Deploy: "my-application:v1", Result: "failed", Error: strings.Repeat("x", 600*1024), Timestamp: time.Now()})
+				Expect(err).To(BeNil())
+			}
+			Expect(writer.Close()).To(Succeed())
+		})
+
+		It("Should rotate the file", func() {
+			matches, err := filepath.Glob(filepath.Join(dir, "audit-*.jsonl"))
+			Expect(err).To(BeNil())
+			Expect(matches).To(HaveLen(1))
+			Expect(readLines(path)).To(HaveLen(1))
+		})
+	})
+})
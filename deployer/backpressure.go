@@ -0,0 +1,150 @@
+package deployer
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/docker/engine-api/client"
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/swarm"
+	"github.com/octoblu/governator-swarm/metrics"
+	"golang.org/x/net/context"
+)
+
+// Backpressure defaults, for the flags of the same names
+const (
+	DefaultDockerAPILatencyThreshold = 5 * time.Second
+	DefaultBackpressureSleep         = 30 * time.Second
+)
+
+// backpressureWindow is how far back the docker API latencies are kept
+const backpressureWindow = 60 * time.Second
+
+func init() {
+	metrics.DefaultRegistry.Help("governator_backpressure_active", "1 while the deployer stops taking deploys off the queue because the docker API is slow")
+	metrics.DefaultRegistry.Help("governator_docker_api_latency_p99", "P99 latency, in seconds, of the docker API calls made in the last minute")
+}
+
+type latencySample struct {
+	at      time.Time
+	latency time.Duration
+}
+
+// BackpressureController tracks the latency of the docker API calls
+// in a sliding window, and is throttled while their P99 exceeds the
+// threshold. It is safe for concurrent use
+type BackpressureController struct {
+	threshold time.Duration
+	window    time.Duration
+	now       func() time.Time
+
+	lock    sync.Mutex
+	samples []latencySample
+}
+
+// NewBackpressureController returns a controller that is throttled while
+// the P99 of the latencies observed in the last window exceeds threshold
+func NewBackpressureController(threshold, window time.Duration) *BackpressureController {
+	return &BackpressureController{threshold: threshold, window: window, now: time.Now}
+}
+
+// Observe records the latency of a docker API call
+func (controller *BackpressureController) Observe(latency time.Duration) {
+	controller.lock.Lock()
+	defer controller.lock.Unlock()
+	controller.samples = append(controller.samples, latencySample{at: controller.now(), latency: latency})
+}
+
+// P99 returns the 99th percentile of the latencies
+// in the window, or 0 when there are none
+func (controller *BackpressureController) P99() time.Duration {
+	controller.lock.Lock()
+	defer controller.lock.Unlock()
+
+	cutoff := controller.now().Add(-controller.window)
+	kept := controller.samples[:0]
+	for _, sample := range controller.samples {
+		if sample.at.After(cutoff) {
+			kept = append(kept, sample)
+		}
+	}
+	controller.samples = kept
+
+	if len(kept) == 0 {
+		return 0
+	}
+
+	latencies := make([]time.Duration, len(kept))
+	for i, sample := range kept {
+		latencies[i] = sample.latency
+	}
+	sort.Sort(byDuration(latencies))
+
+	// nearest rank
+	rank := (len(latencies)*99 + 99) / 100
+	return latencies[rank-1]
+}
+
+type byDuration []time.Duration
+
+func (durations byDuration) Len() int           { return len(durations) }
+func (durations byDuration) Less(i, j int) bool { return durations[i] < durations[j] }
+func (durations byDuration) Swap(i, j int)      { durations[i], durations[j] = durations[j], durations[i] }
+
+// Throttled is true while the P99 exceeds the threshold
+func (controller *BackpressureController) Throttled() bool {
+	return controller.P99() > controller.threshold
+}
+
+// checkBackpressure records whether the deployer is throttled, and
+// updates the backpressure gauges. RunOnce leaves the queue alone,
+// and PollInterval returns the backpressure sleep, while it is
+func (deployer *Deployer) checkBackpressure() bool {
+	if deployer.backpressure == nil {
+		return false
+	}
+
+	p99 := deployer.backpressure.P99()
+	throttled := p99 > deployer.backpressure.threshold
+	if throttled != deployer.throttled {
+		if throttled {
+			deployer.logger.Println("WARNING: docker API P99 latency is", p99, "pausing deploys")
+		} else {
+			deployer.logger.Println("docker API P99 latency is", p99, "resuming deploys")
+		}
+	}
+	deployer.throttled = throttled
+
+	active := 0.0
+	if throttled {
+		active = 1
+	}
+	deployer.metrics.SetGauge("governator_backpressure_active", active, nil)
+	deployer.metrics.SetGauge("governator_docker_api_latency_p99", p99.Seconds(), nil)
+	return throttled
+}
+
+// latencyTrackingClient reports how long the
+// service inspects and updates took to controller
+type latencyTrackingClient struct {
+	client.APIClient
+
+	controller *BackpressureController
+}
+
+func (trackingClient *latencyTrackingClient) ServiceInspectWithRaw(ctx context.Context, serviceID string) (swarm.Service, []byte, error) {
+	start := time.Now()
+	defer trackingClient.observe(start)
+	return trackingClient.APIClient.ServiceInspectWithRaw(ctx, serviceID)
+}
+
+func (trackingClient *latencyTrackingClient) ServiceUpdate(ctx context.Context, serviceID string, version swarm.Version, spec swarm.ServiceSpec, options types.ServiceUpdateOptions) error {
+	start := time.Now()
+	defer trackingClient.observe(start)
+	return trackingClient.APIClient.ServiceUpdate(ctx, serviceID, version, spec, options)
+}
+
+func (trackingClient *latencyTrackingClient) observe(start time.Time) {
+	trackingClient.controller.Observe(time.Since(start))
+}
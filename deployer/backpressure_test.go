@@ -0,0 +1,112 @@
+package deployer
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/docker/engine-api/types/swarm"
+	"github.com/octoblu/governator-swarm/metrics"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("BackpressureController", func() {
+	var sut *BackpressureController
+	var now time.Time
+
+	BeforeEach(func() {
+		now = time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+		sut = NewBackpressureController(5*time.Second, time.Minute)
+		sut.now = func() time.Time { return now }
+	})
+
+	Describe("When nothing was observed", func() {
+		It("Should not be throttled", func() {
+			Expect(sut.P99()).To(Equal(time.Duration(0)))
+			Expect(sut.Throttled()).To(BeFalse())
+		})
+	})
+
+	Describe("When one call in a hundred is slow", func() {
+		BeforeEach(func() {
+			for i := 0; i < 99; i++ {
+				sut.Observe(100 * time.Millisecond)
+			}
+			sut.Observe(10 * time.Second)
+		})
+
+		It("Should not be throttled", func() {
+			Expect(sut.P99()).To(Equal(100 * time.Millisecond))
+			Expect(sut.Throttled()).To(BeFalse())
+		})
+	})
+
+	Describe("When the P99 exceeds the threshold", func() {
+		BeforeEach(func() {
+			sut.Observe(100 * time.Millisecond)
+			sut.Observe(6 * time.Second)
+		})
+
+		It("Should be throttled", func() {
+			Expect(sut.P99()).To(Equal(6 * time.Second))
+			Expect(sut.Throttled()).To(BeTrue())
+		})
+
+		Describe("and the slow calls leave the window", func() {
+			It("Should resume", func() {
+				now = now.Add(61 * time.Second)
+				Expect(sut.Throttled()).To(BeFalse())
+			})
+		})
+	})
+})
+
+var _ = Describe("Backpressure", func() {
+	var sut *Deployer
+	var dockerClient *fakeDockerClient
+	var registry *metrics.Registry
+
+	BeforeEach(func() {
+		dockerClient = &fakeDockerClient{service: swarm.Service{ID: "service-id"}}
+		registry = metrics.NewRegistry()
+		sut = New(dockerClient, nil, "redis-queue:name", "https://deploy-state.test", "super",
+			WithPollInterval(time.Second),
+			WithBackpressure(5*time.Second, 30*time.Second),
+			WithMetrics(registry),
+		)
+	})
+
+	It("Should observe the service inspects", func() {
+		_, _, err := sut.dockerClient.ServiceInspectWithRaw(context.Background(), "service-id")
+		Expect(err).To(BeNil())
+		Expect(sut.backpressure.samples).To(HaveLen(1))
+	})
+
+	Describe("When the docker API is slow", func() {
+		var deployed bool
+		var err error
+
+		BeforeEach(func() {
+			sut.backpressure.Observe(10 * time.Second)
+			// the queue isn't touched, so no redis pool is needed
+			deployed, err = sut.RunOnce(context.Background())
+		})
+
+		It("Should not take a deploy off the queue", func() {
+			Expect(err).To(BeNil())
+			Expect(deployed).To(BeFalse())
+		})
+
+		It("Should sleep for the backpressure sleep", func() {
+			Expect(sut.PollInterval()).To(Equal(30 * time.Second))
+		})
+
+		It("Should set the gauges", func() {
+			var buffer bytes.Buffer
+			Expect(registry.WritePrometheus(&buffer)).To(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("governator_backpressure_active 1"))
+			Expect(buffer.String()).To(ContainSubstring("governator_docker_api_latency_p99 10"))
+		})
+	})
+})
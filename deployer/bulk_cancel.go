@@ -0,0 +1,134 @@
+package deployer
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"golang.org/x/net/context"
+)
+
+// BulkCancel cancels every queued deploy whose metadata filter is true
+// for, and returns how many it cancelled. Each one is given a
+// cancellation and taken off the queue in one transaction. Deploys
+// without valid metadata in redis, e.g. those deployed from a
+// manifest, are left queued
+func (deployer *Deployer) BulkCancel(ctx context.Context, filter func(*RequestMetadata) bool) (int, error) {
+	redisConn := deployer.redisPool.Get()
+	defer redisConn.Close()
+
+	cursor := "0"
+	cancelled := 0
+
+	for {
+		err := ctx.Err()
+		if err != nil {
+			return cancelled, err
+		}
+
+		scanResult, err := redis.Values(redisConn.Do("ZSCAN", deployer.keys.DeployKey(), cursor, "COUNT", 100))
+		if err != nil {
+			return cancelled, newRedisError(err)
+		}
+
+		cursor, err = redis.String(scanResult[0], nil)
+		if err != nil {
+			return cancelled, newRedisError(err)
+		}
+
+		// members and scores alternate
+		members, err := redis.Strings(scanResult[1], nil)
+		if err != nil {
+			return cancelled, newRedisError(err)
+		}
+		var deploys []string
+		for i := 0; i < len(members); i += 2 {
+			deploys = append(deploys, members[i])
+		}
+
+		metadatas, err := deployer.getMetadatas(redisConn, deploys)
+		if err != nil {
+			return cancelled, err
+		}
+
+		for i, deploy := range deploys {
+			if metadatas[i] == nil || !filter(metadatas[i]) {
+				continue
+			}
+
+			ok, err := deployer.cancelDeploy(redisConn, deploy)
+			if err != nil {
+				return cancelled, err
+			}
+			if ok {
+				deployer.logger.Println("Cancelled", deploy)
+				cancelled++
+			}
+		}
+
+		if cursor == "0" {
+			return cancelled, nil
+		}
+	}
+}
+
+// getMetadatas reads the deploys' metadata in one round trip. The
+// metadata of a deploy that has none, or has invalid metadata, is nil
+func (deployer *Deployer) getMetadatas(redisConn redis.Conn, deploys []string) ([]*RequestMetadata, error) {
+	for _, deploy := range deploys {
+		err := redisConn.Send("HGET", deployer.keys.MetadataKey(deploy), "request:metadata")
+		if err != nil {
+			return nil, newRedisError(err)
+		}
+	}
+
+	err := redisConn.Flush()
+	if err != nil {
+		return nil, newRedisError(err)
+	}
+
+	metadatas := make([]*RequestMetadata, len(deploys))
+	for i, deploy := range deploys {
+		value, err := redis.Bytes(redisConn.Receive())
+		if err == redis.ErrNil {
+			continue
+		}
+		if err != nil {
+			return nil, newRedisError(err)
+		}
+
+		decoded, err := decodeMetadata(value)
+		if err != nil {
+			debug("skipping %v, invalid compressed metadata: %v", deploy, err)
+			continue
+		}
+
+		var metadata RequestMetadata
+		err = json.Unmarshal(decoded, &metadata)
+		if err != nil {
+			debug("skipping %v, invalid metadata: %v", deploy, err)
+			continue
+		}
+		metadatas[i] = &metadata
+	}
+	return metadatas, nil
+}
+
+// cancelDeploy sets the deploy's cancellation and takes it off the
+// queue. It is false when the deploy had already left the queue
+func (deployer *Deployer) cancelDeploy(redisConn redis.Conn, deploy string) (bool, error) {
+	redisConn.Send("MULTI")
+	redisConn.Send("HSET", deployer.keys.MetadataKey(deploy), "cancellation", time.Now().Unix())
+	redisConn.Send("ZREM", deployer.keys.DeployKey(), deploy)
+
+	replies, err := redis.Values(redisConn.Do("EXEC"))
+	if err != nil {
+		return false, newRedisError(err)
+	}
+
+	removed, err := redis.Int(replies[1], nil)
+	if err != nil {
+		return false, newRedisError(err)
+	}
+	return removed == 1, nil
+}
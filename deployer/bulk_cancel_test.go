@@ -0,0 +1,83 @@
+package deployer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rafaeljusto/redigomock"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("BulkCancel", func() {
+	var sut *Deployer
+	var redisConn *redigomock.Conn
+
+	BeforeEach(func() {
+		redisConn = redigomock.NewConn()
+		redisPool := &redis.Pool{Dial: func() (redis.Conn, error) { return redisConn, nil }}
+		sut = New(nil, redisPool, "redis-queue:name", "https://deploy-state.test", "super", WithLogger(&fakeLogger{}))
+	})
+
+	isPayments := func(metadata *RequestMetadata) bool {
+		return strings.HasPrefix(metadata.DockerURL, "octoblu/payments-")
+	}
+
+	It("Should cancel the queued deploys the filter matches", func() {
+		redisConn.Command("ZSCAN", "redis-queue:name:governator:deploys", "0", "COUNT", 100).Expect([]interface{}{
+			[]byte("0"),
+			[]interface{}{
+				[]byte("payments-api:v2"), []byte("1"),
+				[]byte("my-application:v2"), []byte("1"),
+				[]byte("payments-worker:v2"), []byte("1"),
+				[]byte("broken:v2"), []byte("1"),
+			},
+		})
+		redisConn.Command("HGET", "redis-queue:name:payments-api:v2", "request:metadata").Expect([]byte(`{"dockerUrl":"octoblu/payments-api:v2"}`))
+		redisConn.Command("HGET", "redis-queue:name:my-application:v2", "request:metadata").Expect([]byte(`{"dockerUrl":"octoblu/my-application:v2"}`))
+		redisConn.Command("HGET", "redis-queue:name:payments-worker:v2", "request:metadata").Expect([]byte(`{"dockerUrl":"octoblu/payments-worker:v2"}`))
+		redisConn.Command("HGET", "redis-queue:name:broken:v2", "request:metadata").Expect([]byte(`not json`))
+
+		redisConn.Command("MULTI").Expect("OK")
+		cancelAPI := redisConn.Command("HSET", "redis-queue:name:payments-api:v2", "cancellation", redigomock.NewAnyInt()).Expect(int64(1))
+		cancelWorker := redisConn.Command("HSET", "redis-queue:name:payments-worker:v2", "cancellation", redigomock.NewAnyInt()).Expect(int64(1))
+		redisConn.Command("ZREM", "redis-queue:name:governator:deploys", "payments-api:v2").Expect(int64(1))
+		redisConn.Command("ZREM", "redis-queue:name:governator:deploys", "payments-worker:v2").Expect(int64(1))
+		redisConn.Command("EXEC").Expect([]interface{}{int64(1), int64(1)}).Expect([]interface{}{int64(1), int64(1)})
+
+		cancelled, err := sut.BulkCancel(context.Background(), isPayments)
+		Expect(err).To(BeNil())
+		Expect(cancelled).To(Equal(2))
+		Expect(redisConn.Stats(cancelAPI)).To(Equal(1))
+		Expect(redisConn.Stats(cancelWorker)).To(Equal(1))
+	})
+
+	Describe("When the deploy left the queue before it was cancelled", func() {
+		It("Should not count it", func() {
+			redisConn.Command("ZSCAN", "redis-queue:name:governator:deploys", "0", "COUNT", 100).Expect([]interface{}{
+				[]byte("0"),
+				[]interface{}{[]byte("payments-api:v2"), []byte("1")},
+			})
+			redisConn.Command("HGET", "redis-queue:name:payments-api:v2", "request:metadata").Expect([]byte(`{"dockerUrl":"octoblu/payments-api:v2"}`))
+			redisConn.GenericCommand("MULTI").Expect("OK")
+			redisConn.GenericCommand("HSET").Expect(int64(1))
+			redisConn.GenericCommand("ZREM").Expect(int64(0))
+			redisConn.Command("EXEC").Expect([]interface{}{int64(1), int64(0)})
+
+			cancelled, err := sut.BulkCancel(context.Background(), isPayments)
+			Expect(err).To(BeNil())
+			Expect(cancelled).To(Equal(0))
+		})
+	})
+
+	Describe("When the scan fails", func() {
+		It("Should return a RedisError", func() {
+			redisConn.Command("ZSCAN", "redis-queue:name:governator:deploys", "0", "COUNT", 100).ExpectError(fmt.Errorf("things went worse than expected"))
+
+			_, err := sut.BulkCancel(context.Background(), isPayments)
+			Expect(err).To(BeAssignableToTypeOf(&RedisError{}))
+		})
+	})
+})
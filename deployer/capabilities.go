@@ -0,0 +1,100 @@
+package deployer
+
+import "strings"
+
+// knownCapabilities are the Linux capabilities
+// RequestMetadata.CapAdd and CapDrop may name
+var knownCapabilities = map[string]bool{
+	"CAP_AUDIT_CONTROL":      true,
+	"CAP_AUDIT_READ":         true,
+	"CAP_AUDIT_WRITE":        true,
+	"CAP_BLOCK_SUSPEND":      true,
+	"CAP_BPF":                true,
+	"CAP_CHECKPOINT_RESTORE": true,
+	"CAP_CHOWN":              true,
+	"CAP_DAC_OVERRIDE":       true,
+	"CAP_DAC_READ_SEARCH":    true,
+	"CAP_FOWNER":             true,
+	"CAP_FSETID":             true,
+	"CAP_IPC_LOCK":           true,
+	"CAP_IPC_OWNER":          true,
+	"CAP_KILL":               true,
+	"CAP_LEASE":              true,
+	"CAP_LINUX_IMMUTABLE":    true,
+	"CAP_MAC_ADMIN":          true,
+	"CAP_MAC_OVERRIDE":       true,
+	"CAP_MKNOD":              true,
+	"CAP_NET_ADMIN":          true,
+	"CAP_NET_BIND_SERVICE":   true,
+	"CAP_NET_BROADCAST":      true,
+	"CAP_NET_RAW":            true,
+	"CAP_PERFMON":            true,
+	"CAP_SETFCAP":            true,
+	"CAP_SETGID":             true,
+	"CAP_SETPCAP":            true,
+	"CAP_SETUID":             true,
+	"CAP_SYSLOG":             true,
+	"CAP_SYS_ADMIN":          true,
+	"CAP_SYS_BOOT":           true,
+	"CAP_SYS_CHROOT":         true,
+	"CAP_SYS_MODULE":         true,
+	"CAP_SYS_NICE":           true,
+	"CAP_SYS_PACCT":          true,
+	"CAP_SYS_PTRACE":         true,
+	"CAP_SYS_RAWIO":          true,
+	"CAP_SYS_RESOURCE":       true,
+	"CAP_SYS_TIME":           true,
+	"CAP_SYS_TTY_CONFIG":     true,
+	"CAP_WAKE_ALARM":         true,
+}
+
+// normalizeCapability returns capability upper cased
+// and CAP_ prefixed, so "net_admin" is CAP_NET_ADMIN
+func normalizeCapability(capability string) string {
+	capability = strings.ToUpper(strings.TrimSpace(capability))
+	if capability == "ALL" || strings.HasPrefix(capability, "CAP_") {
+		return capability
+	}
+	return "CAP_" + capability
+}
+
+// validateCapabilities refuses unknown capabilities, and
+// any capability that is both added and dropped. ALL may
+// only be dropped, to start from an empty set
+func validateCapabilities(capAdd, capDrop []string) error {
+	dropped := map[string]bool{}
+	for _, capability := range capDrop {
+		name := normalizeCapability(capability)
+		if name != "ALL" && !knownCapabilities[name] {
+			return newValidationError("Invalid capDrop '%v', unknown capability", capability)
+		}
+		dropped[name] = true
+	}
+
+	for _, capability := range capAdd {
+		name := normalizeCapability(capability)
+		if !knownCapabilities[name] {
+			return newValidationError("Invalid capAdd '%v', unknown capability", capability)
+		}
+		if dropped[name] {
+			return newValidationError("Invalid capAdd '%v', it is also in capDrop", capability)
+		}
+	}
+	return nil
+}
+
+// applyCapabilities validates the deploy's capabilities. The
+// vendored docker client's ContainerSpec has no CapabilityAdd or
+// CapabilityDrop (they need API 1.41), so a deploy asking for them
+// is refused rather than silently deployed without them
+func applyCapabilities(metadata *RequestMetadata) error {
+	if len(metadata.CapAdd) == 0 && len(metadata.CapDrop) == 0 {
+		return nil
+	}
+
+	err := validateCapabilities(metadata.CapAdd, metadata.CapDrop)
+	if err != nil {
+		return err
+	}
+	return newValidationError("capAdd and capDrop need docker API 1.41, which this governator's docker client doesn't support")
+}
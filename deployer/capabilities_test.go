@@ -0,0 +1,87 @@
+package deployer
+
+import (
+	"github.com/docker/engine-api/types/swarm"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Capabilities", func() {
+	var sut *Deployer
+	var dockerClient *fakeDockerClient
+	var metadata *RequestMetadata
+	var err error
+
+	BeforeEach(func() {
+		dockerClient = &fakeDockerClient{service: swarm.Service{ID: "service-id"}}
+		dockerClient.service.Spec.Name = "my-application"
+		sut = New(dockerClient, nil, "redis-queue:name", deployState.URL, "super")
+		metadata = &RequestMetadata{DockerURL: "octoblu/my-application:v2"}
+	})
+
+	JustBeforeEach(func() {
+		err = sut.deploy("my-application:v2", metadata)
+	})
+
+	Describe("When neither is set", func() {
+		It("Should update the service", func() {
+			Expect(err).To(BeNil())
+			Expect(dockerClient.updates).To(HaveLen(1))
+		})
+	})
+
+	Describe("When only capAdd is set", func() {
+		BeforeEach(func() {
+			metadata.CapAdd = []string{"net_admin", "CAP_SYS_TIME"}
+		})
+
+		It("Should be valid", func() {
+			Expect(validateCapabilities(metadata.CapAdd, metadata.CapDrop)).To(BeNil())
+		})
+
+		It("Should refuse the deploy, since the docker client can't set it", func() {
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+			Expect(err.Error()).To(ContainSubstring("API 1.41"))
+			Expect(dockerClient.updates).To(BeEmpty())
+		})
+	})
+
+	Describe("When only capDrop is set", func() {
+		BeforeEach(func() {
+			metadata.CapDrop = []string{"ALL"}
+		})
+
+		It("Should be valid", func() {
+			Expect(validateCapabilities(metadata.CapAdd, metadata.CapDrop)).To(BeNil())
+		})
+
+		It("Should refuse the deploy, since the docker client can't set it", func() {
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+			Expect(dockerClient.updates).To(BeEmpty())
+		})
+	})
+
+	Describe("When a capability is both added and dropped", func() {
+		BeforeEach(func() {
+			metadata.CapAdd = []string{"NET_ADMIN"}
+			metadata.CapDrop = []string{"cap_net_admin"}
+		})
+
+		It("Should return a ValidationError naming the conflict", func() {
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+			Expect(err.Error()).To(ContainSubstring("also in capDrop"))
+			Expect(dockerClient.updates).To(BeEmpty())
+		})
+	})
+
+	Describe("When a capability is unknown", func() {
+		BeforeEach(func() {
+			metadata.CapAdd = []string{"CAP_FLY"}
+		})
+
+		It("Should return a ValidationError", func() {
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+			Expect(err.Error()).To(ContainSubstring("unknown capability"))
+		})
+	})
+})
@@ -0,0 +1,102 @@
+package deployer
+
+import (
+	"errors"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"golang.org/x/net/context"
+)
+
+// ErrClosed is returned by RunOnce once the deployer is closed
+var ErrClosed = errors.New("deployer is closed")
+
+// Close stops the deployer's background goroutines, waits for the
+// in flight deploy to finish, then closes the audit log and the redis
+// pool. When more than one of those fails, a *multierror.Error is returned
+func (deployer *Deployer) Close() error {
+	deployer.closeLock.Lock()
+	if deployer.closed {
+		deployer.closeLock.Unlock()
+		return nil
+	}
+	deployer.closed = true
+	deployer.closeLock.Unlock()
+
+	deployer.cancelClose()
+	deployer.inFlight.Wait()
+	deployer.background.Wait()
+
+	errs := &multierror.Error{ErrorFormat: closeErrorFormat}
+	if deployer.auditLog != nil {
+		errs = multierror.Append(errs, deployer.auditLog.Close())
+	}
+
+	if deployer.redisPool != nil {
+		errs = multierror.Append(errs, deployer.redisPool.Close())
+	}
+
+	if len(errs.Errors) == 1 {
+		return errs.Errors[0]
+	}
+	return errs.ErrorOrNil()
+}
+
+// StartGC runs GC every interval, in the
+// background, until the deployer is closed
+func (deployer *Deployer) StartGC(interval, maxAge time.Duration) {
+	deployer.goBackground(func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			deleted, err := deployer.GC(ctx, maxAge)
+			if err != nil && ctx.Err() == nil {
+				deployer.logger.Println("GC error", err)
+				continue
+			}
+			debug("GC deleted %v deploy hashes", deleted)
+		}
+	})
+}
+
+// StartNotifyRetryWorker runs the NotifyRetryWorker
+// in the background until the deployer is closed
+func (deployer *Deployer) StartNotifyRetryWorker() {
+	deployer.goBackground(deployer.NotifyRetryWorker)
+}
+
+// goBackground runs run in a goroutine Close waits for,
+// with a context that is done once Close is called
+func (deployer *Deployer) goBackground(run func(ctx context.Context)) {
+	deployer.closeLock.Lock()
+	defer deployer.closeLock.Unlock()
+	if deployer.closed {
+		return
+	}
+
+	deployer.background.Add(1)
+	go func() {
+		defer deployer.background.Done()
+		run(deployer.closeCtx)
+	}()
+}
+
+// startInFlight counts a Run Close has to wait
+// for, and is false once the deployer is closed
+func (deployer *Deployer) startInFlight() bool {
+	deployer.closeLock.Lock()
+	defer deployer.closeLock.Unlock()
+	if deployer.closed {
+		return false
+	}
+
+	deployer.inFlight.Add(1)
+	return true
+}
@@ -0,0 +1,90 @@
+package deployer
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/hashicorp/go-multierror"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rafaeljusto/redigomock"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Close", func() {
+	var sut *Deployer
+	var redisPool *redis.Pool
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "close")
+		Expect(err).To(BeNil())
+
+		redisConn := redigomock.NewConn()
+		redisPool = &redis.Pool{Dial: func() (redis.Conn, error) { return redisConn, nil }}
+		sut = New(nil, redisPool, "redis-queue:name", "https://deploy-state.test", "super", WithAuditLog(filepath.Join(dir, "audit.log")))
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("Should close the audit log and the redis pool", func() {
+		path := filepath.Join(dir, "audit.log")
+		Expect(sut.auditLog.Write(&DeployRecord{Deploy: "my-application:v1"})).To(Succeed())
+		Expect(sut.Close()).To(Succeed())
+
+		// a closed audit log opens the file again, rather than writing to the removed one
+		Expect(os.Remove(path)).To(Succeed())
+		Expect(sut.auditLog.Write(&DeployRecord{Deploy: "my-application:v1"})).To(Succeed())
+		Expect(path).To(BeAnExistingFile())
+
+		_, err := redisPool.Get().Do("PING")
+		Expect(err).To(MatchError(ContainSubstring("closed")))
+	})
+
+	It("Should stop the background goroutines", func() {
+		stopped := make(chan bool, 1)
+		sut.goBackground(func(ctx context.Context) {
+			<-ctx.Done()
+			stopped <- true
+		})
+
+		Expect(sut.Close()).To(Succeed())
+		Expect(stopped).To(Receive())
+	})
+
+	It("Should stop the GC goroutine", func() {
+		sut.StartGC(time.Hour, time.Hour)
+		done := make(chan error, 1)
+		go func() { done <- sut.Close() }()
+		Eventually(done).Should(Receive(BeNil()))
+	})
+
+	It("Should refuse to run once closed", func() {
+		Expect(sut.Close()).To(Succeed())
+		deployed, err := sut.RunOnce(context.Background())
+		Expect(err).To(Equal(ErrClosed))
+		Expect(deployed).To(BeFalse())
+	})
+
+	It("Should be safe to call twice", func() {
+		Expect(sut.Close()).To(Succeed())
+		Expect(sut.Close()).To(Succeed())
+	})
+
+	Describe("closeErrorFormat", func() {
+		It("Should list every error", func() {
+			err := &multierror.Error{
+				Errors:      []error{errors.New("audit log: disk full"), errors.New("redis: broken pipe")},
+				ErrorFormat: closeErrorFormat,
+			}
+			Expect(err.Error()).To(Equal("2 errors closing the deployer: audit log: disk full; redis: broken pipe"))
+		})
+	})
+})
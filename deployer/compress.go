@@ -0,0 +1,129 @@
+package deployer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// The compression algorithms WithRedisCompression takes
+const (
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// gzipMagic and zstdMagic start every gzip and zstd stream. JSON metadata
+// never starts with either, so stored metadata is decompressed only
+// when it has one
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// ValidateCompression returns an error unless
+// algorithm is one of gzip or zstd
+func ValidateCompression(algorithm string) error {
+	switch algorithm {
+	case CompressionGzip, CompressionZstd:
+		return nil
+	}
+	return fmt.Errorf("unknown compression '%v', expected %v or %v", algorithm, CompressionGzip, CompressionZstd)
+}
+
+// compressor compresses the request metadata stored in redis
+type compressor interface {
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+
+	_, err := writer.Write(data)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return ioutil.ReadAll(reader)
+}
+
+// zstdCompressor shares one encoder and decoder, which
+// are safe to use concurrently with EncodeAll and DecodeAll
+type zstdCompressor struct{}
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+func zstdCoders() (*zstd.Encoder, *zstd.Decoder, error) {
+	zstdOnce.Do(func() {
+		zstdEncoder, zstdErr = zstd.NewWriter(nil)
+		if zstdErr != nil {
+			return
+		}
+		zstdDecoder, zstdErr = zstd.NewReader(nil)
+	})
+	return zstdEncoder, zstdDecoder, zstdErr
+}
+
+func (zstdCompressor) Compress(data []byte) ([]byte, error) {
+	encoder, _, err := zstdCoders()
+	if err != nil {
+		return nil, err
+	}
+	return encoder.EncodeAll(data, nil), nil
+}
+
+func (zstdCompressor) Decompress(data []byte) ([]byte, error) {
+	_, decoder, err := zstdCoders()
+	if err != nil {
+		return nil, err
+	}
+	return decoder.DecodeAll(data, nil)
+}
+
+// encodeMetadata compresses the request metadata
+// before it is written, when WithRedisCompression is used
+func (deployer *Deployer) encodeMetadata(metadataBytes []byte) ([]byte, error) {
+	if deployer.metadataCompressor == nil {
+		return metadataBytes, nil
+	}
+	return deployer.metadataCompressor.Compress(metadataBytes)
+}
+
+// decodeMetadata decompresses request metadata that was written
+// gzipped or zstd compressed, and returns anything else as it is,
+// whether or not WithRedisCompression is used
+func decodeMetadata(value []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(value, gzipMagic):
+		return gzipCompressor{}.Decompress(value)
+	case bytes.HasPrefix(value, zstdMagic):
+		return zstdCompressor{}.Decompress(value)
+	}
+	return value, nil
+}
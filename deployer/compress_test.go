@@ -0,0 +1,118 @@
+package deployer
+
+import (
+	"github.com/garyburd/redigo/redis"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rafaeljusto/redigomock"
+)
+
+var _ = Describe("RedisCompression", func() {
+	var sut *Deployer
+	var redisConn *redigomock.Conn
+
+	BeforeEach(func() {
+		redisConn = redigomock.NewConn()
+		redisPool := &redis.Pool{Dial: func() (redis.Conn, error) { return redisConn, nil }}
+		sut = New(nil, redisPool, "redis-queue:name", "https://deploy-state.test", "super", WithRedisCompression(CompressionGzip))
+	})
+
+	It("Should gzip the metadata it writes", func() {
+		encoded, err := sut.encodeMetadata([]byte(`{"dockerUrl":"octoblu/my-application:v2"}`))
+		Expect(err).To(BeNil())
+		Expect(encoded[:2]).To(Equal(gzipMagic))
+
+		decoded, err := decodeMetadata(encoded)
+		Expect(err).To(BeNil())
+		Expect(string(decoded)).To(Equal(`{"dockerUrl":"octoblu/my-application:v2"}`))
+	})
+
+	It("Should read gzipped metadata", func() {
+		compressed, err := gzipCompressor{}.Compress([]byte(`{"dockerUrl":"octoblu/my-application:v2"}`))
+		Expect(err).To(BeNil())
+		redisConn.Command("HGET", "redis-queue:name:my-application:v2", "request:metadata").Expect(compressed)
+
+		metadata, err := sut.getMetadata("my-application:v2")
+		Expect(err).To(BeNil())
+		Expect(metadata.DockerURL).To(Equal("octoblu/my-application:v2"))
+	})
+
+	It("Should still read uncompressed metadata", func() {
+		redisConn.Command("HGET", "redis-queue:name:my-application:v2", "request:metadata").Expect([]byte(`{"dockerUrl":"octoblu/my-application:v2"}`))
+
+		metadata, err := sut.getMetadata("my-application:v2")
+		Expect(err).To(BeNil())
+		Expect(metadata.DockerURL).To(Equal("octoblu/my-application:v2"))
+	})
+
+	It("Should refuse corrupt gzipped metadata", func() {
+		redisConn.Command("HGET", "redis-queue:name:my-application:v2", "request:metadata").Expect([]byte("\x1f\x8bnot gzip"))
+
+		_, err := sut.getMetadata("my-application:v2")
+		Expect(err).To(BeAssignableToTypeOf(&MetadataError{}))
+	})
+
+	Describe("When zstd is used", func() {
+		BeforeEach(func() {
+			redisPool := &redis.Pool{Dial: func() (redis.Conn, error) { return redisConn, nil }}
+			sut = New(nil, redisPool, "redis-queue:name", "https://deploy-state.test", "super", WithRedisCompression(CompressionZstd))
+		})
+
+		It("Should zstd compress the metadata it writes", func() {
+			encoded, err := sut.encodeMetadata([]byte(`{"dockerUrl":"octoblu/my-application:v2"}`))
+			Expect(err).To(BeNil())
+			Expect(encoded[:4]).To(Equal(zstdMagic))
+
+			decoded, err := decodeMetadata(encoded)
+			Expect(err).To(BeNil())
+			Expect(string(decoded)).To(Equal(`{"dockerUrl":"octoblu/my-application:v2"}`))
+		})
+
+		It("Should still read gzipped metadata", func() {
+			compressed, err := gzipCompressor{}.Compress([]byte(`{"dockerUrl":"octoblu/my-application:v2"}`))
+			Expect(err).To(BeNil())
+			redisConn.Command("HGET", "redis-queue:name:my-application:v2", "request:metadata").Expect(compressed)
+
+			metadata, err := sut.getMetadata("my-application:v2")
+			Expect(err).To(BeNil())
+			Expect(metadata.DockerURL).To(Equal("octoblu/my-application:v2"))
+		})
+	})
+
+	It("Should read zstd compressed metadata", func() {
+		compressed, err := zstdCompressor{}.Compress([]byte(`{"dockerUrl":"octoblu/my-application:v2"}`))
+		Expect(err).To(BeNil())
+		redisConn.Command("HGET", "redis-queue:name:my-application:v2", "request:metadata").Expect(compressed)
+
+		metadata, err := sut.getMetadata("my-application:v2")
+		Expect(err).To(BeNil())
+		Expect(metadata.DockerURL).To(Equal("octoblu/my-application:v2"))
+	})
+
+	It("Should refuse corrupt zstd compressed metadata", func() {
+		redisConn.Command("HGET", "redis-queue:name:my-application:v2", "request:metadata").Expect([]byte("\x28\xb5\x2f\xfdnot zstd"))
+
+		_, err := sut.getMetadata("my-application:v2")
+		Expect(err).To(BeAssignableToTypeOf(&MetadataError{}))
+	})
+
+	Describe("ValidateCompression", func() {
+		It("Should allow gzip and zstd", func() {
+			Expect(ValidateCompression(CompressionGzip)).To(Succeed())
+			Expect(ValidateCompression(CompressionZstd)).To(Succeed())
+		})
+
+		It("Should refuse anything else", func() {
+			Expect(ValidateCompression("brotli")).To(MatchError("unknown compression 'brotli', expected gzip or zstd"))
+		})
+	})
+
+	Describe("When it isn't used", func() {
+		It("Should write the metadata as it is", func() {
+			sut = New(nil, nil, "redis-queue:name", "https://deploy-state.test", "super")
+			encoded, err := sut.encodeMetadata([]byte(`{}`))
+			Expect(err).To(BeNil())
+			Expect(string(encoded)).To(Equal(`{}`))
+		})
+	})
+})
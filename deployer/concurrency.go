@@ -0,0 +1,116 @@
+package deployer
+
+import (
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+const (
+	// concurrencyTTL expires the slot of a deploy whose deployer crashed
+	// without releasing it, the holder refreshes it every
+	// concurrencyHeartbeatInterval
+	concurrencyTTL               = 30 * time.Second
+	concurrencyHeartbeatInterval = 10 * time.Second
+
+	concurrencyPollInterval = 250 * time.Millisecond
+)
+
+// acquireConcurrency takes a slot in the semaphore shared by every
+// deployer on the queue, waiting up to concurrencyWait for one to free up.
+// The slots are a sorted set of holders scored by when they expire, so
+// the slot of a crashed deployer frees up after concurrencyTTL no matter
+// how busy the others are. The returned func releases the slot
+func (deployer *Deployer) acquireConcurrency(deploy string) (func(), error) {
+	if deployer.globalMaxParallelism <= 0 {
+		return func() {}, nil
+	}
+
+	redisConn := deployer.redisPool.Get()
+	defer redisConn.Close()
+
+	// not governator:concurrency, which was a counter before
+	key := deployer.getKey("governator:concurrency-slots")
+	holder := deployer.getMutexOwner() + ":" + deploy
+	giveUpAt := time.Now().Add(deployer.concurrencyWait)
+
+	for {
+		now := time.Now()
+		_, err := redisConn.Do("ZREMRANGEBYSCORE", key, "-inf", unixMillis(now))
+		if err != nil {
+			return nil, newRedisError(err)
+		}
+
+		_, err = redisConn.Do("ZADD", key, unixMillis(now.Add(concurrencyTTL)), holder)
+		if err != nil {
+			return nil, newRedisError(err)
+		}
+
+		count, err := redis.Int(redisConn.Do("ZCARD", key))
+		if err != nil {
+			return nil, newRedisError(err)
+		}
+
+		if count <= deployer.globalMaxParallelism {
+			done := make(chan struct{})
+			go deployer.refreshConcurrency(key, holder, done)
+
+			release := func() {
+				close(done)
+				deployer.releaseConcurrency(key, holder)
+			}
+			return release, nil
+		}
+
+		_, err = redisConn.Do("ZREM", key, holder)
+		if err != nil {
+			return nil, newRedisError(err)
+		}
+
+		remaining := giveUpAt.Sub(time.Now())
+		if remaining <= 0 {
+			return nil, &ConcurrencyLimitError{Limit: deployer.globalMaxParallelism, Wait: deployer.concurrencyWait}
+		}
+
+		debug("acquireConcurrency: %v deploys running, waiting", count-1)
+		if remaining > concurrencyPollInterval {
+			remaining = concurrencyPollInterval
+		}
+		time.Sleep(remaining)
+	}
+}
+
+// refreshConcurrency pushes back the expiry of the slot until done is
+// closed. XX keeps it from taking the slot again once it has expired
+func (deployer *Deployer) refreshConcurrency(key, holder string, done <-chan struct{}) {
+	ticker := time.NewTicker(concurrencyHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			redisConn := deployer.redisPool.Get()
+			_, err := redisConn.Do("ZADD", key, "XX", unixMillis(time.Now().Add(concurrencyTTL)), holder)
+			redisConn.Close()
+			if err != nil {
+				deployer.logger.Println("Error refreshing concurrency slot", err)
+			}
+		}
+	}
+}
+
+func (deployer *Deployer) releaseConcurrency(key, holder string) {
+	redisConn := deployer.redisPool.Get()
+	defer redisConn.Close()
+
+	_, err := redisConn.Do("ZREM", key, holder)
+	if err != nil {
+		deployer.logger.Println("Error releasing concurrency slot", err)
+	}
+}
+
+func unixMillis(at time.Time) int64 {
+	return at.UnixNano() / int64(time.Millisecond)
+}
@@ -0,0 +1,84 @@
+package deployer
+
+import (
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rafaeljusto/redigomock"
+)
+
+var _ = Describe("acquireConcurrency", func() {
+	var sut *Deployer
+	var redisConn *redigomock.Conn
+	var release func()
+	var err error
+	var zadd, zrem *redigomock.Cmd
+
+	BeforeEach(func() {
+		redisConn = redigomock.NewConn()
+		redisPool := &redis.Pool{Dial: func() (redis.Conn, error) { return redisConn, nil }}
+		sut = New(nil, redisPool, "redis-queue:name", "https://deploy-state.test", "super", WithGlobalMaxParallelism(2, 100*time.Millisecond))
+		sut.startedAt = time.Unix(1, 0)
+		zadd = redisConn.Command("ZADD", "redis-queue:name:governator:concurrency-slots", redigomock.NewAnyInt(), "deployer-1000000000:my-application:v2").Expect(int64(1)).Expect(int64(1)).Expect(int64(1))
+		zrem = redisConn.Command("ZREM", "redis-queue:name:governator:concurrency-slots", "deployer-1000000000:my-application:v2").Expect(int64(1)).Expect(int64(1)).Expect(int64(1))
+	})
+
+	Describe("When there is a free slot", func() {
+		BeforeEach(func() {
+			redisConn.Command("ZREMRANGEBYSCORE", "redis-queue:name:governator:concurrency-slots", "-inf", redigomock.NewAnyInt()).Expect(int64(0))
+			redisConn.Command("ZCARD", "redis-queue:name:governator:concurrency-slots").Expect(int64(2))
+			release, err = sut.acquireConcurrency("my-application:v2")
+		})
+
+		It("Should take the slot", func() {
+			Expect(err).To(BeNil())
+			Expect(redisConn.Stats(zadd)).To(Equal(1))
+			Expect(redisConn.Stats(zrem)).To(Equal(0))
+		})
+
+		It("Should give the slot back on release", func() {
+			release()
+			Expect(redisConn.Stats(zrem)).To(Equal(1))
+		})
+	})
+
+	Describe("When every slot stays taken", func() {
+		var zcard *redigomock.Cmd
+
+		BeforeEach(func() {
+			redisConn.Command("ZREMRANGEBYSCORE", "redis-queue:name:governator:concurrency-slots", "-inf", redigomock.NewAnyInt()).Expect(int64(0)).Expect(int64(0)).Expect(int64(0))
+			zcard = redisConn.Command("ZCARD", "redis-queue:name:governator:concurrency-slots").Expect(int64(3)).Expect(int64(3)).Expect(int64(3))
+			release, err = sut.acquireConcurrency("my-application:v2")
+		})
+
+		It("Should retry, then return a retryable ConcurrencyLimitError", func() {
+			limitErr, ok := err.(*ConcurrencyLimitError)
+			Expect(ok).To(BeTrue())
+			Expect(limitErr.Limit).To(Equal(2))
+			Expect(limitErr.IsRetryable()).To(BeTrue())
+			Expect(redisConn.Stats(zcard)).To(BeNumerically(">", 1))
+		})
+
+		It("Should give up the slot it added each time", func() {
+			Expect(redisConn.Stats(zrem)).To(Equal(redisConn.Stats(zadd)))
+		})
+	})
+
+	Describe("When a crashed deployer leaked its slot", func() {
+		var zremrangebyscore *redigomock.Cmd
+
+		BeforeEach(func() {
+			zremrangebyscore = redisConn.Command("ZREMRANGEBYSCORE", "redis-queue:name:governator:concurrency-slots", "-inf", redigomock.NewAnyInt()).Expect(int64(1))
+			redisConn.Command("ZCARD", "redis-queue:name:governator:concurrency-slots").Expect(int64(2))
+			release, err = sut.acquireConcurrency("my-application:v2")
+		})
+
+		It("Should drop the expired slot before counting, and take it", func() {
+			Expect(err).To(BeNil())
+			Expect(redisConn.Stats(zremrangebyscore)).To(Equal(1))
+			Expect(redisConn.Stats(zrem)).To(Equal(0))
+		})
+	})
+})
@@ -0,0 +1,73 @@
+package deployer
+
+import (
+	"github.com/octoblu/governator-swarm/metrics"
+	"github.com/octoblu/governator-swarm/notifier"
+)
+
+func init() {
+	metrics.DefaultRegistry.Help("governator_config_reload_total", "Reloads of the notify channel map and tag transforms files, by result")
+}
+
+// RefreshConfig reads the notify channel map and tag transforms files
+// the deployer was given with WithConfigFiles, and swaps them in
+// together. When either can't be read, the old config is kept
+func (deployer *Deployer) RefreshConfig() error {
+	if deployer.notifyChannelMapFile == "" && deployer.tagTransformsFile == "" {
+		return nil
+	}
+
+	var channels notifier.Channels
+	var tagTransforms []*TagTransform
+	var err error
+
+	if deployer.notifyChannelMapFile != "" {
+		channels, err = notifier.LoadChannels(deployer.notifyChannelMapFile)
+		if err != nil {
+			deployer.metrics.IncrCounter("governator_config_reload_total", map[string]string{"result": "error"})
+			return err
+		}
+	}
+
+	if deployer.tagTransformsFile != "" {
+		tagTransforms, err = LoadTagTransforms(deployer.tagTransformsFile)
+		if err != nil {
+			deployer.metrics.IncrCounter("governator_config_reload_total", map[string]string{"result": "error"})
+			return err
+		}
+	}
+
+	deployer.configLock.Lock()
+	if deployer.notifyChannelMapFile != "" {
+		deployer.notifyChannels = channels
+	}
+	if deployer.tagTransformsFile != "" {
+		deployer.fileTagTransforms = tagTransforms
+	}
+	deployer.configLock.Unlock()
+
+	deployer.metrics.IncrCounter("governator_config_reload_total", map[string]string{"result": "success"})
+	return nil
+}
+
+func (deployer *Deployer) getNotifyChannel(name string) (notifier.Notifier, bool) {
+	deployer.configLock.RLock()
+	defer deployer.configLock.RUnlock()
+	channelNotifier, ok := deployer.notifyChannels[name]
+	return channelNotifier, ok
+}
+
+// getTagTransforms returns the deployer's tag
+// transforms, then those of the tag transforms file
+func (deployer *Deployer) getTagTransforms() []*TagTransform {
+	deployer.configLock.RLock()
+	defer deployer.configLock.RUnlock()
+
+	if len(deployer.fileTagTransforms) == 0 {
+		return deployer.tagTransforms
+	}
+
+	tagTransforms := make([]*TagTransform, 0, len(deployer.tagTransforms)+len(deployer.fileTagTransforms))
+	tagTransforms = append(tagTransforms, deployer.tagTransforms...)
+	return append(tagTransforms, deployer.fileTagTransforms...)
+}
@@ -0,0 +1,85 @@
+package deployer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/octoblu/governator-swarm/metrics"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RefreshConfig", func() {
+	var sut *Deployer
+	var registry *metrics.Registry
+	var dir string
+	var channelMapFile, tagTransformsFile string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "config-refresh")
+		Expect(err).To(BeNil())
+
+		channelMapFile = filepath.Join(dir, "channels.yml")
+		tagTransformsFile = filepath.Join(dir, "tag-transforms")
+		Expect(ioutil.WriteFile(channelMapFile, []byte("payments-oncall: https://teams.test/payments\n"), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(tagTransformsFile, []byte("# dev builds\ns/^main-/dev-/\n"), 0644)).To(Succeed())
+
+		registry = metrics.NewRegistry()
+		sut = New(nil, nil, "redis-queue:name", "https://deploy-state.test", "super", WithMetrics(registry), WithConfigFiles(channelMapFile, tagTransformsFile))
+		Expect(sut.RefreshConfig()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("Should load the channels and tag transforms", func() {
+		_, ok := sut.getNotifyChannel("payments-oncall")
+		Expect(ok).To(BeTrue())
+
+		metadata := &RequestMetadata{DockerURL: "octoblu/my-application:main-abc1234"}
+		Expect(sut.transformImageTag(metadata)).To(Succeed())
+		Expect(metadata.DockerURL).To(Equal("octoblu/my-application:dev-abc1234"))
+	})
+
+	Describe("When the files change", func() {
+		BeforeEach(func() {
+			Expect(ioutil.WriteFile(channelMapFile, []byte("platform: https://teams.test/platform\n"), 0644)).To(Succeed())
+			Expect(sut.RefreshConfig()).To(Succeed())
+		})
+
+		It("Should swap in the new config", func() {
+			_, ok := sut.getNotifyChannel("payments-oncall")
+			Expect(ok).To(BeFalse())
+			_, ok = sut.getNotifyChannel("platform")
+			Expect(ok).To(BeTrue())
+		})
+	})
+
+	Describe("When a file can't be parsed", func() {
+		var err error
+
+		BeforeEach(func() {
+			Expect(ioutil.WriteFile(channelMapFile, []byte("platform: https://teams.test/platform\n"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(tagTransformsFile, []byte("s/(unclosed/dev-/\n"), 0644)).To(Succeed())
+			err = sut.RefreshConfig()
+		})
+
+		It("Should return the error and keep the old config", func() {
+			Expect(err).To(MatchError(ContainSubstring("line 1")))
+			_, ok := sut.getNotifyChannel("payments-oncall")
+			Expect(ok).To(BeTrue())
+			Expect(sut.getTagTransforms()).To(HaveLen(1))
+		})
+
+		It("Should count the reloads by result", func() {
+			var buffer bytes.Buffer
+			Expect(registry.WritePrometheus(&buffer)).To(Succeed())
+			Expect(buffer.String()).To(ContainSubstring(`governator_config_reload_total{result="success"} 1`))
+			Expect(buffer.String()).To(ContainSubstring(`governator_config_reload_total{result="error"} 1`))
+		})
+	})
+})
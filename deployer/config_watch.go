@@ -0,0 +1,227 @@
+package deployer
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// configAPIVersion is the first docker API version with swarm configs
+const configAPIVersion = "1.30"
+
+// ConfigVersionEnv is set on a service redeployed because its configs
+// changed, so swarm replaces its tasks even though the image is the same
+const ConfigVersionEnv = "GOVERNATOR_CONFIG_VERSION"
+
+// ServiceConfig is a swarm config attached to a service
+type ServiceConfig struct {
+	Name    string
+	ID      string
+	Version uint64
+}
+
+// ConfigSource lists the current version of the configs attached to a service
+type ConfigSource interface {
+	ServiceConfigs(ctx context.Context, service string) ([]ServiceConfig, error)
+}
+
+// CheckConfigs compares the configs attached to each of services with
+// the ones recorded when they were last checked, and queues a redeploy
+// of the service's current image when they changed. Services seen for
+// the first time are only recorded. It returns how many were queued
+func (deployer *Deployer) CheckConfigs(ctx context.Context, services []string) (int, error) {
+	if deployer.configSource == nil {
+		return 0, fmt.Errorf("no config source, use WithConfigSource")
+	}
+
+	redisConn := deployer.redisPool.Get()
+	defer redisConn.Close()
+
+	versionsKey := deployer.getKey("governator:config-versions")
+	queued := 0
+	for _, service := range services {
+		configs, err := deployer.configSource.ServiceConfigs(ctx, service)
+		if err != nil {
+			return queued, err
+		}
+
+		fingerprint := configFingerprint(configs)
+		previous, err := redis.String(redisConn.Do("HGET", versionsKey, service))
+		if err != nil && err != redis.ErrNil {
+			return queued, newRedisError(err)
+		}
+
+		if err == nil && previous != fingerprint {
+			deployer.logger.Println("Configs of", service, "changed, queueing a redeploy")
+			err = deployer.queueConfigRedeploy(ctx, redisConn, service, fingerprint)
+			if err != nil {
+				return queued, err
+			}
+			queued++
+		}
+
+		if previous != fingerprint {
+			_, err = redisConn.Do("HSET", versionsKey, service, fingerprint)
+			if err != nil {
+				return queued, newRedisError(err)
+			}
+		}
+	}
+	return queued, nil
+}
+
+// queueConfigRedeploy queues a deploy of the service's current image
+// with ConfigVersionEnv set to a hash of its configs
+func (deployer *Deployer) queueConfigRedeploy(ctx context.Context, redisConn redis.Conn, service, fingerprint string) error {
+	current, _, err := deployer.dockerClient.ServiceInspectWithRaw(ctx, service)
+	if err != nil {
+		return newDockerAPIError(err)
+	}
+
+	hash := sha1.Sum([]byte(fingerprint))
+	version := hex.EncodeToString(hash[:])[:12]
+	metadata := &RequestMetadata{
+		DockerURL:    current.Spec.TaskTemplate.ContainerSpec.Image,
+		EnvOverrides: map[string]string{ConfigVersionEnv: version},
+		Annotations:  map[string]string{"initiator": "config-watch"},
+	}
+	metadataBytes, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	metadataBytes, err = deployer.encodeMetadata(metadataBytes)
+	if err != nil {
+		return err
+	}
+
+	deploy := fmt.Sprintf("%s:config-%s", service, version)
+	_, err = redisConn.Do("HSET", deployer.keys.MetadataKey(deploy), "request:metadata", metadataBytes)
+	if err != nil {
+		return newRedisError(err)
+	}
+
+	_, err = redisConn.Do("ZADD", deployer.keys.DeployKey(), time.Now().Unix(), deploy)
+	if err != nil {
+		return newRedisError(err)
+	}
+
+	deployer.events.publish(deploy, EventQueued, "configs changed")
+	return nil
+}
+
+func configFingerprint(configs []ServiceConfig) string {
+	parts := make([]string, 0, len(configs))
+	for _, config := range configs {
+		parts = append(parts, fmt.Sprintf("%s=%s.%d", config.Name, config.ID, config.Version))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// httpConfigSource reads configs from the daemon directly,
+// the vendored docker client predates swarm configs
+type httpConfigSource struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewHTTPConfigSource constructs a ConfigSource for the
+// daemon at dockerURI, e.g. unix:///var/run/docker.sock
+func NewHTTPConfigSource(dockerURI string) (ConfigSource, error) {
+	baseURL, httpClient, err := newDaemonHTTPClient(dockerURI)
+	if err != nil {
+		return nil, err
+	}
+
+	return &httpConfigSource{
+		baseURL:    baseURL,
+		httpClient: httpClient,
+	}, nil
+}
+
+// ServiceConfigs looks up the configs attached to the service by name,
+// so a config replaced under the same name shows up with a new ID
+func (source *httpConfigSource) ServiceConfigs(ctx context.Context, service string) ([]ServiceConfig, error) {
+	var inspect struct {
+		Spec struct {
+			TaskTemplate struct {
+				ContainerSpec struct {
+					Configs []struct {
+						ConfigID   string
+						ConfigName string
+					}
+				}
+			}
+		}
+	}
+	err := source.get(ctx, "/services/"+service, &inspect)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []struct {
+		ID      string
+		Version struct {
+			Index uint64
+		}
+		Spec struct {
+			Name string
+		}
+	}
+	err = source.get(ctx, "/configs", &list)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := map[string]ServiceConfig{}
+	for _, config := range list {
+		byName[config.Spec.Name] = ServiceConfig{Name: config.Spec.Name, ID: config.ID, Version: config.Version.Index}
+	}
+
+	configs := []ServiceConfig{}
+	for _, attached := range inspect.Spec.TaskTemplate.ContainerSpec.Configs {
+		config, ok := byName[attached.ConfigName]
+		if !ok {
+			config = ServiceConfig{Name: attached.ConfigName, ID: attached.ConfigID}
+		}
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
+
+func (source *httpConfigSource) get(ctx context.Context, path string, value interface{}) error {
+	return daemonGet(ctx, source.httpClient, source.baseURL, configAPIVersion, path, value)
+}
+
+// daemonGet decodes the daemon's JSON response to path, at apiVersion
+func daemonGet(ctx context.Context, httpClient *http.Client, baseURL, apiVersion, path string, value interface{}) error {
+	fullURL := fmt.Sprintf("%s/v%s%s", baseURL, apiVersion, path)
+	request, err := http.NewRequest("GET", fullURL, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("User-Agent", "governator-swarm")
+
+	response, err := ctxhttp.Do(ctx, httpClient, request)
+	if err != nil {
+		return newDockerAPIError(err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(response.Body)
+		return newDockerAPIError(fmt.Errorf("Error response from daemon: %v %s", response.StatusCode, strings.TrimSpace(string(body))))
+	}
+	return json.NewDecoder(response.Body).Decode(value)
+}
@@ -0,0 +1,113 @@
+package deployer
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/docker/engine-api/types/swarm"
+	"github.com/garyburd/redigo/redis"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rafaeljusto/redigomock"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("CheckConfigs", func() {
+	var sut *Deployer
+	var redisConn *redigomock.Conn
+	var configSource *fakeConfigSource
+	var queued int
+	var err error
+
+	BeforeEach(func() {
+		dockerClient := &fakeDockerClient{service: swarm.Service{ID: "service-id"}}
+		dockerClient.service.Spec.Name = "my-application"
+		dockerClient.service.Spec.TaskTemplate.ContainerSpec.Image = "octoblu/my-application:v1"
+
+		configSource = &fakeConfigSource{configs: []ServiceConfig{{Name: "my-application-config", ID: "config-2", Version: 12}}}
+		redisConn = redigomock.NewConn()
+		redisPool := &redis.Pool{Dial: func() (redis.Conn, error) { return redisConn, nil }}
+		sut = New(dockerClient, redisPool, "redis-queue:name", "https://deploy-state.test", "super", WithConfigSource(configSource))
+	})
+
+	JustBeforeEach(func() {
+		queued, err = sut.CheckConfigs(context.Background(), []string{"my-application"})
+	})
+
+	Describe("When the service hasn't been checked before", func() {
+		var hset *redigomock.Cmd
+
+		BeforeEach(func() {
+			redisConn.Command("HGET", "redis-queue:name:governator:config-versions", "my-application").ExpectError(redis.ErrNil)
+			hset = redisConn.Command("HSET", "redis-queue:name:governator:config-versions", "my-application", "my-application-config=config-2.12").Expect(int64(1))
+		})
+
+		It("Should record the configs without queueing a redeploy", func() {
+			Expect(err).To(BeNil())
+			Expect(queued).To(Equal(0))
+			Expect(redisConn.Stats(hset)).To(Equal(1))
+		})
+	})
+
+	Describe("When the configs are unchanged", func() {
+		BeforeEach(func() {
+			redisConn.Command("HGET", "redis-queue:name:governator:config-versions", "my-application").Expect("my-application-config=config-2.12")
+		})
+
+		It("Should do nothing", func() {
+			Expect(err).To(BeNil())
+			Expect(queued).To(Equal(0))
+		})
+	})
+
+	Describe("When a config changed", func() {
+		var metadata *redigomock.Cmd
+		var zadd *redigomock.Cmd
+
+		BeforeEach(func() {
+			redisConn.Command("HGET", "redis-queue:name:governator:config-versions", "my-application").Expect("my-application-config=config-1.10")
+			redisConn.Command("HSET", "redis-queue:name:governator:config-versions", "my-application", "my-application-config=config-2.12").Expect(int64(0))
+			metadata = redisConn.GenericCommand("HSET").Expect(int64(1))
+			zadd = redisConn.Command("ZADD", "redis-queue:name:governator:deploys", redigomock.NewAnyInt(), redigomock.NewAnyData()).Expect(int64(1))
+		})
+
+		It("Should queue a redeploy of the current image", func() {
+			Expect(err).To(BeNil())
+			Expect(queued).To(Equal(1))
+			Expect(redisConn.Stats(metadata)).To(Equal(1))
+			Expect(redisConn.Stats(zadd)).To(Equal(1))
+		})
+	})
+})
+
+var _ = Describe("NewHTTPConfigSource", func() {
+	It("Should look up the service's configs by name", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			switch request.URL.Path {
+			case "/v1.30/services/my-application":
+				fmt.Fprint(response, `{"Spec":{"TaskTemplate":{"ContainerSpec":{"Configs":[{"ConfigID":"config-1","ConfigName":"my-application-config"}]}}}}`)
+			case "/v1.30/configs":
+				fmt.Fprint(response, `[{"ID":"config-2","Version":{"Index":12},"Spec":{"Name":"my-application-config"}}]`)
+			default:
+				response.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		source, err := NewHTTPConfigSource(server.URL)
+		Expect(err).To(BeNil())
+
+		configs, err := source.ServiceConfigs(context.Background(), "my-application")
+		Expect(err).To(BeNil())
+		Expect(configs).To(Equal([]ServiceConfig{{Name: "my-application-config", ID: "config-2", Version: 12}}))
+	})
+})
+
+type fakeConfigSource struct {
+	configs []ServiceConfig
+}
+
+func (source *fakeConfigSource) ServiceConfigs(ctx context.Context, service string) ([]ServiceConfig, error) {
+	return source.configs, nil
+}
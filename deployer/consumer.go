@@ -0,0 +1,188 @@
+package deployer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+const (
+	// HeartbeatInterval is how often a consumer should call Heartbeat
+	HeartbeatInterval = 10 * time.Second
+
+	heartbeatTTL       = 30 * time.Second
+	staleConsumerAfter = 60 * time.Second
+)
+
+// Heartbeat marks this consumer as alive. Deploys locked by a consumer
+// that stops sending heartbeats are requeued by RecoverStaleConsumers.
+// The time of the heartbeat is recorded in a sorted set, which outlives
+// the stale cutoff, the expiring key only marks the consumer as alive
+func (deployer *Deployer) Heartbeat() error {
+	if deployer.consumerID == "" {
+		return nil
+	}
+
+	redisConn := deployer.redisPool.Get()
+	defer redisConn.Close()
+
+	now := time.Now().Unix()
+	_, err := redisConn.Do("ZADD", deployer.getHeartbeatsKey(), now, deployer.consumerID)
+	if err != nil {
+		return newRedisError(err)
+	}
+
+	heartbeatKey := deployer.getConsumerKey(deployer.consumerID, "heartbeat")
+	_, err = redisConn.Do("SET", heartbeatKey, now, "EX", int(heartbeatTTL.Seconds()))
+	if err != nil {
+		return newRedisError(err)
+	}
+	return nil
+}
+
+// RecoverStaleConsumers puts the deploys being processed by consumers
+// that haven't sent a heartbeat in 60s back on the queue, and returns
+// how many were requeued
+func (deployer *Deployer) RecoverStaleConsumers() (int, error) {
+	redisConn := deployer.redisPool.Get()
+	defer redisConn.Close()
+
+	pattern := deployer.getConsumerKey("*", "processing")
+	consumerPrefix := deployer.getKey("consumer:")
+	cutoff := time.Now().Add(-staleConsumerAfter).Unix()
+	cursor := "0"
+	requeued := 0
+
+	for {
+		scanResult, err := redis.Values(redisConn.Do("SCAN", cursor, "MATCH", pattern, "COUNT", 100))
+		if err != nil {
+			return requeued, newRedisError(err)
+		}
+
+		cursor, err = redis.String(scanResult[0], nil)
+		if err != nil {
+			return requeued, newRedisError(err)
+		}
+
+		keys, err := redis.Strings(scanResult[1], nil)
+		if err != nil {
+			return requeued, newRedisError(err)
+		}
+
+		for _, key := range keys {
+			consumerID := strings.TrimSuffix(strings.TrimPrefix(key, consumerPrefix), ":processing")
+			if consumerID == deployer.consumerID {
+				continue
+			}
+
+			stale, err := deployer.isStaleConsumer(redisConn, consumerID, cutoff)
+			if err != nil {
+				return requeued, err
+			}
+			if !stale {
+				continue
+			}
+
+			count, err := deployer.requeueProcessing(redisConn, consumerID, key)
+			requeued += count
+			if err != nil {
+				return requeued, err
+			}
+
+			_, err = redisConn.Do("ZREM", deployer.getHeartbeatsKey(), consumerID)
+			if err != nil {
+				return requeued, newRedisError(err)
+			}
+		}
+
+		if cursor == "0" {
+			return requeued, nil
+		}
+	}
+}
+
+// isStaleConsumer checks the consumer's last heartbeat against cutoff.
+// Consumers missing from the sorted set, from before heartbeats were
+// recorded there, are stale once their heartbeat key has expired
+func (deployer *Deployer) isStaleConsumer(redisConn redis.Conn, consumerID string, cutoff int64) (bool, error) {
+	heartbeatAt, err := redis.Int64(redisConn.Do("ZSCORE", deployer.getHeartbeatsKey(), consumerID))
+	if err == redis.ErrNil {
+		alive, err := redis.Bool(redisConn.Do("EXISTS", deployer.getConsumerKey(consumerID, "heartbeat")))
+		if err != nil {
+			return false, newRedisError(err)
+		}
+		return !alive, nil
+	}
+	if err != nil {
+		return false, newRedisError(err)
+	}
+	return heartbeatAt < cutoff, nil
+}
+
+func (deployer *Deployer) requeueProcessing(redisConn redis.Conn, consumerID, processingKey string) (int, error) {
+	deploys, err := redis.Strings(redisConn.Do("SMEMBERS", processingKey))
+	if err != nil {
+		return 0, newRedisError(err)
+	}
+
+	now := time.Now().Unix()
+	for i, deploy := range deploys {
+		deployer.logger.Println("Requeueing deploy from stale consumer", consumerID, deploy)
+
+		_, err = redisConn.Do("HDEL", deployer.keys.LockKey(deploy), "deploy:timestamp")
+		if err != nil {
+			return i, newRedisError(err)
+		}
+
+		_, err = redisConn.Do("ZADD", deployer.keys.DeployKey(), now, deploy)
+		if err != nil {
+			return i, newRedisError(err)
+		}
+
+		_, err = redisConn.Do("SREM", processingKey, deploy)
+		if err != nil {
+			return i + 1, newRedisError(err)
+		}
+	}
+	return len(deploys), nil
+}
+
+// startProcessing records that this consumer holds the deploy
+func (deployer *Deployer) startProcessing(redisConn redis.Conn, deploy string) error {
+	if deployer.consumerID == "" {
+		return nil
+	}
+
+	_, err := redisConn.Do("SADD", deployer.getConsumerKey(deployer.consumerID, "processing"), deploy)
+	if err != nil {
+		return newRedisError(err)
+	}
+	return nil
+}
+
+// finishProcessing releases the deploy once Run is done with it
+func (deployer *Deployer) finishProcessing(deploy string) {
+	if deployer.consumerID == "" {
+		return
+	}
+
+	redisConn := deployer.redisPool.Get()
+	defer redisConn.Close()
+
+	_, err := redisConn.Do("SREM", deployer.getConsumerKey(deployer.consumerID, "processing"), deploy)
+	if err != nil {
+		deployer.logger.Println("Error releasing deploy", deploy, err)
+	}
+}
+
+// getHeartbeatsKey is the sorted set of consumers
+// by the unix time of their last heartbeat
+func (deployer *Deployer) getHeartbeatsKey() string {
+	return deployer.getKey("governator:heartbeats")
+}
+
+func (deployer *Deployer) getConsumerKey(consumerID, key string) string {
+	return deployer.getKey(fmt.Sprintf("consumer:%s:%s", consumerID, key))
+}
@@ -0,0 +1,130 @@
+package deployer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rafaeljusto/redigomock"
+)
+
+var _ = Describe("consumer heartbeats", func() {
+	var sut *Deployer
+	var redisConn *redigomock.Conn
+
+	BeforeEach(func() {
+		redisConn = redigomock.NewConn()
+		redisPool := &redis.Pool{Dial: func() (redis.Conn, error) { return redisConn, nil }}
+		sut = New(nil, redisPool, "redis-queue:name", "https://deploy-state.test", "super", WithConsumerID("deployer-1"))
+	})
+
+	Describe("Heartbeat", func() {
+		It("Should record the heartbeat time and set the heartbeat key with a 30s expiry", func() {
+			zadd := redisConn.Command("ZADD", "redis-queue:name:governator:heartbeats", redigomock.NewAnyInt(), "deployer-1").Expect(int64(1))
+			set := redisConn.Command("SET", "redis-queue:name:consumer:deployer-1:heartbeat", redigomock.NewAnyInt(), "EX", 30).Expect("OK")
+			Expect(sut.Heartbeat()).To(Succeed())
+			Expect(redisConn.Stats(zadd)).To(Equal(1))
+			Expect(redisConn.Stats(set)).To(Equal(1))
+		})
+
+		Describe("When the deployer has a key prefix", func() {
+			BeforeEach(func() {
+				redisPool := &redis.Pool{Dial: func() (redis.Conn, error) { return redisConn, nil }}
+				sut = New(nil, redisPool, "redis-queue:name", "https://deploy-state.test", "super", WithConsumerID("deployer-1"), WithKeyPrefix("tenant-a:"))
+			})
+
+			It("Should prefix the heartbeat keys", func() {
+				redisConn.Command("ZADD", "tenant-a:redis-queue:name:governator:heartbeats", redigomock.NewAnyInt(), "deployer-1").Expect(int64(1))
+				set := redisConn.Command("SET", "tenant-a:redis-queue:name:consumer:deployer-1:heartbeat", redigomock.NewAnyInt(), "EX", 30).Expect("OK")
+				Expect(sut.Heartbeat()).To(Succeed())
+				Expect(redisConn.Stats(set)).To(Equal(1))
+			})
+		})
+	})
+
+	Describe("RecoverStaleConsumers", func() {
+		var zadd, zrem *redigomock.Cmd
+		var requeued int
+		var err error
+
+		heartbeatAgo := func(ago time.Duration) []byte {
+			return []byte(fmt.Sprintf("%v", time.Now().Add(-ago).Unix()))
+		}
+
+		BeforeEach(func() {
+			redisConn.Command("SCAN", "0", "MATCH", "redis-queue:name:consumer:*:processing", "COUNT", 100).Expect([]interface{}{
+				[]byte("0"),
+				[]interface{}{
+					[]byte("redis-queue:name:consumer:deployer-1:processing"),
+					[]byte("redis-queue:name:consumer:deployer-2:processing"),
+				},
+			})
+
+			redisConn.Command("SMEMBERS", "redis-queue:name:consumer:deployer-2:processing").Expect([]interface{}{[]byte("my-application:v2")})
+			redisConn.Command("HDEL", "redis-queue:name:my-application:v2", "deploy:timestamp").Expect(int64(1))
+			zadd = redisConn.Command("ZADD", "redis-queue:name:governator:deploys", redigomock.NewAnyInt(), "my-application:v2").Expect(int64(1))
+			redisConn.Command("SREM", "redis-queue:name:consumer:deployer-2:processing", "my-application:v2").Expect(int64(1))
+			zrem = redisConn.Command("ZREM", "redis-queue:name:governator:heartbeats", "deployer-2").Expect(int64(1))
+		})
+
+		JustBeforeEach(func() {
+			requeued, err = sut.RecoverStaleConsumers()
+		})
+
+		Describe("When a consumer's last heartbeat is older than the cutoff", func() {
+			BeforeEach(func() {
+				redisConn.Command("ZSCORE", "redis-queue:name:governator:heartbeats", "deployer-2").Expect(heartbeatAgo(2 * time.Minute))
+			})
+
+			It("Should requeue its deploys and forget the consumer", func() {
+				Expect(err).To(BeNil())
+				Expect(requeued).To(Equal(1))
+				Expect(redisConn.Stats(zadd)).To(Equal(1))
+				Expect(redisConn.Stats(zrem)).To(Equal(1))
+			})
+		})
+
+		Describe("When a consumer's last heartbeat is 45s old", func() {
+			BeforeEach(func() {
+				// its heartbeat key has expired, but it is within the cutoff
+				redisConn.Command("ZSCORE", "redis-queue:name:governator:heartbeats", "deployer-2").Expect(heartbeatAgo(45 * time.Second))
+			})
+
+			It("Should not be stale", func() {
+				Expect(err).To(BeNil())
+				Expect(requeued).To(Equal(0))
+				Expect(redisConn.Stats(zadd)).To(Equal(0))
+			})
+		})
+
+		Describe("When a consumer has no recorded heartbeat time", func() {
+			BeforeEach(func() {
+				redisConn.Command("ZSCORE", "redis-queue:name:governator:heartbeats", "deployer-2").Expect(nil)
+			})
+
+			Describe("and its heartbeat key is set", func() {
+				BeforeEach(func() {
+					redisConn.Command("EXISTS", "redis-queue:name:consumer:deployer-2:heartbeat").Expect(int64(1))
+				})
+
+				It("Should not be stale", func() {
+					Expect(err).To(BeNil())
+					Expect(requeued).To(Equal(0))
+				})
+			})
+
+			Describe("and its heartbeat key has expired", func() {
+				BeforeEach(func() {
+					redisConn.Command("EXISTS", "redis-queue:name:consumer:deployer-2:heartbeat").Expect(int64(0))
+				})
+
+				It("Should requeue its deploys", func() {
+					Expect(err).To(BeNil())
+					Expect(requeued).To(Equal(1))
+				})
+			})
+		})
+	})
+})
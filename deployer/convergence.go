@@ -0,0 +1,105 @@
+package deployer
+
+import (
+	"time"
+
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/filters"
+	"github.com/docker/engine-api/types/swarm"
+	"golang.org/x/net/context"
+)
+
+const (
+	// defaultConvergencePollInterval is how often the service's
+	// tasks are listed while waiting for it to converge
+	defaultConvergencePollInterval = time.Second
+
+	// defaultConvergenceTimeout bounds the wait for
+	// deploys that don't have a deploy timeout
+	defaultConvergenceTimeout = 10 * time.Minute
+)
+
+// shouldWaitForConvergence is true when the deploy or the deployer asks for it
+func (deployer *Deployer) shouldWaitForConvergence(metadata *RequestMetadata) bool {
+	return metadata.WaitForConvergence || deployer.alwaysConverge
+}
+
+// convergeService waits for every replica of a replicated service to run
+// the new image. Global services are not waited for, the number of
+// tasks they should have depends on the nodes. Without a deploy timeout,
+// it waits up to the deployer's convergenceTimeout, so a service that
+// never converges doesn't hold up the queue forever
+func (deployer *Deployer) convergeService(ctx context.Context, deploy string, service swarm.Service, timeout time.Duration) error {
+	replicated := service.Spec.Mode.Replicated
+	if replicated == nil || replicated.Replicas == nil {
+		debug("convergeService: %v is not replicated, not waiting", service.Spec.Name)
+		return nil
+	}
+
+	if timeout <= 0 {
+		timeout = deployer.convergenceTimeout
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	deployer.events.publish(deploy, EventConverging, service.Spec.Name)
+	image := service.Spec.TaskTemplate.ContainerSpec.Image
+	running, err := deployer.waitForConvergence(ctx, service.ID, image, *replicated.Replicas)
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		deployer.logger.Println("Deploy did not converge", deploy, "service", service.Spec.Name, running, "of", *replicated.Replicas, "running")
+		return &ConvergenceTimeoutError{Service: service.Spec.Name, Expected: *replicated.Replicas, Running: running, Timeout: timeout}
+	}
+	return newDockerAPIError(err)
+}
+
+// waitForConvergence lists the service's tasks until expectedReplicas
+// of them are running image, or ctx is done. It returns how many were
+// running when it stopped
+func (deployer *Deployer) waitForConvergence(ctx context.Context, serviceID, image string, expectedReplicas uint64) (uint64, error) {
+	filter := filters.NewArgs()
+	filter.Add("service", serviceID)
+
+	ticker := time.NewTicker(deployer.convergencePollInterval)
+	defer ticker.Stop()
+
+	running := uint64(0)
+	for {
+		tasks, err := deployer.dockerClient.TaskList(ctx, types.TaskListOptions{Filter: filter})
+		if err != nil {
+			if ctx.Err() != nil {
+				return running, ctx.Err()
+			}
+			return running, err
+		}
+
+		running = countRunningTasks(tasks, image)
+		debug("waitForConvergence: %v of %v running %v", running, expectedReplicas, image)
+		if running >= expectedReplicas {
+			return running, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return running, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func countRunningTasks(tasks []swarm.Task, image string) uint64 {
+	running := uint64(0)
+	for _, task := range tasks {
+		if task.Spec.ContainerSpec.Image != image || task.Status.State != swarm.TaskStateRunning {
+			continue
+		}
+		if task.DesiredState != "" && task.DesiredState != swarm.TaskStateRunning {
+			continue
+		}
+		running++
+	}
+	return running
+}
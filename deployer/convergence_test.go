@@ -0,0 +1,120 @@
+package deployer
+
+import (
+	"time"
+
+	"github.com/docker/engine-api/types/swarm"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("waitForConvergence", func() {
+	var sut *Deployer
+	var dockerClient *fakeDockerClient
+	var metadata *RequestMetadata
+	var err error
+
+	BeforeEach(func() {
+		replicas := uint64(2)
+		dockerClient = &fakeDockerClient{
+			service: swarm.Service{ID: "service-id"},
+		}
+		dockerClient.service.Spec.Name = "my-application"
+		dockerClient.service.Spec.Mode.Replicated = &swarm.ReplicatedService{Replicas: &replicas}
+		dockerClient.service.Spec.TaskTemplate.ContainerSpec.Image = "octoblu/my-application:v1"
+
+		sut = New(dockerClient, nil, "redis-queue:name", deployState.URL, "super", WithDeployTimeout(100*time.Millisecond))
+		sut.convergencePollInterval = 5 * time.Millisecond
+		metadata = &RequestMetadata{DockerURL: "octoblu/my-application:v2", WaitForConvergence: true}
+	})
+
+	JustBeforeEach(func() {
+		err = sut.deploy("my-application:v2", metadata)
+	})
+
+	Describe("When every replica starts running the new image", func() {
+		BeforeEach(func() {
+			dockerClient.taskLists = [][]swarm.Task{
+				{watchTask("old-1", "octoblu/my-application:v1", swarm.TaskStateRunning), watchTask("old-2", "octoblu/my-application:v1", swarm.TaskStateRunning)},
+				{watchTask("old-2", "octoblu/my-application:v1", swarm.TaskStateRunning), watchTask("new-1", "octoblu/my-application:v2", swarm.TaskStateRunning)},
+				{watchTask("new-1", "octoblu/my-application:v2", swarm.TaskStateRunning), watchTask("new-2", "octoblu/my-application:v2", swarm.TaskStateRunning)},
+			}
+		})
+
+		It("Should pass once they are all running", func() {
+			Expect(err).To(BeNil())
+			Expect(dockerClient.taskLists).To(HaveLen(1))
+		})
+	})
+
+	Describe("When a replica never starts", func() {
+		BeforeEach(func() {
+			dockerClient.taskLists = [][]swarm.Task{
+				{watchTask("new-1", "octoblu/my-application:v2", swarm.TaskStateRunning), watchTask("new-2", "octoblu/my-application:v2", swarm.TaskStatePending)},
+			}
+		})
+
+		It("Should return a retryable ConvergenceTimeoutError", func() {
+			convergenceErr, ok := err.(*ConvergenceTimeoutError)
+			Expect(ok).To(BeTrue())
+			Expect(convergenceErr.Expected).To(Equal(uint64(2)))
+			Expect(convergenceErr.Running).To(Equal(uint64(1)))
+			Expect(convergenceErr.IsRetryable()).To(BeTrue())
+		})
+	})
+
+	Describe("When there is no deploy timeout and a replica never starts", func() {
+		BeforeEach(func() {
+			sut.deployTimeout = 0
+			sut.convergenceTimeout = 50 * time.Millisecond
+			dockerClient.taskLists = [][]swarm.Task{
+				{watchTask("new-1", "octoblu/my-application:v2", swarm.TaskStateRunning), watchTask("new-2", "octoblu/my-application:v2", swarm.TaskStatePending)},
+			}
+		})
+
+		It("Should give up after the convergence timeout", func() {
+			convergenceErr, ok := err.(*ConvergenceTimeoutError)
+			Expect(ok).To(BeTrue())
+			Expect(convergenceErr.Timeout).To(Equal(50 * time.Millisecond))
+			Expect(convergenceErr.Running).To(Equal(uint64(1)))
+		})
+	})
+
+	Describe("When the deploy doesn't ask to wait", func() {
+		BeforeEach(func() {
+			metadata.WaitForConvergence = false
+			dockerClient.taskLists = [][]swarm.Task{
+				{watchTask("new-1", "octoblu/my-application:v2", swarm.TaskStatePending)},
+			}
+		})
+
+		It("Should not list the tasks", func() {
+			Expect(err).To(BeNil())
+		})
+	})
+
+	Describe("When the deployer waits for every deploy", func() {
+		BeforeEach(func() {
+			metadata.WaitForConvergence = false
+			WithConvergence()(sut)
+			dockerClient.taskLists = [][]swarm.Task{
+				{watchTask("new-1", "octoblu/my-application:v2", swarm.TaskStatePending)},
+			}
+		})
+
+		It("Should wait anyway", func() {
+			_, ok := err.(*ConvergenceTimeoutError)
+			Expect(ok).To(BeTrue())
+		})
+	})
+
+	Describe("When the service is global", func() {
+		BeforeEach(func() {
+			dockerClient.service.Spec.Mode = swarm.ServiceMode{Global: &swarm.GlobalService{}}
+		})
+
+		It("Should not wait", func() {
+			Expect(err).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,49 @@
+package deployer
+
+import (
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// dependencyRetryDelay is how long a deploy waits in the
+// queue for the deploys it depends on
+const dependencyRetryDelay = 30 * time.Second
+
+// checkDependencies makes sure every deploy in metadata.DependsOn has
+// passed. A deploy whose dependencies are still pending is requeued,
+// one with a failed dependency is dead lettered
+func (deployer *Deployer) checkDependencies(deploy string, metadata *RequestMetadata) (bool, error) {
+	if len(metadata.DependsOn) == 0 {
+		return true, nil
+	}
+
+	redisConn := deployer.redisPool.Get()
+	defer redisConn.Close()
+
+	for _, dependency := range metadata.DependsOn {
+		result, err := redis.String(redisConn.Do("HGET", deployer.keys.MetadataKey(dependency), "result"))
+		if err != nil && err != redis.ErrNil {
+			return false, newRedisError(err)
+		}
+
+		switch result {
+		case DeployPassed:
+			continue
+		case DeployFailed:
+			dependencyErr := &DependencyFailedError{Deploy: deploy, Dependency: dependency}
+			return false, deployer.deadLetterDeploy(deploy, dependencyErr.Error())
+		}
+
+		dependencyErr := &DependencyPendingError{Deploy: deploy, Dependency: dependency}
+		deployer.logger.Println("Requeueing deploy", deploy, dependencyErr)
+		return false, deployer.requeueDeploy(deploy, time.Now().Add(dependencyRetryDelay))
+	}
+	return true, nil
+}
+
+// recordResult sets the deploy's result on its hash,
+// for the deploys that depend on it
+func (deployer *Deployer) recordResult(deploy, result string) {
+	deployer.telemetry.HSet(deployer.keys.MetadataKey(deploy), "result", result)
+}
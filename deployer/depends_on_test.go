@@ -0,0 +1,64 @@
+package deployer
+
+import (
+	"github.com/garyburd/redigo/redis"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rafaeljusto/redigomock"
+)
+
+var _ = Describe("checkDependencies", func() {
+	var sut *Deployer
+	var redisConn *redigomock.Conn
+	var metadata *RequestMetadata
+
+	BeforeEach(func() {
+		redisConn = redigomock.NewConn()
+		redisPool := &redis.Pool{Dial: func() (redis.Conn, error) { return redisConn, nil }}
+		sut = New(nil, redisPool, "redis-queue:name", "https://deploy-state.test", "super")
+		metadata = &RequestMetadata{DependsOn: []string{"my-database:v2"}}
+	})
+
+	Describe("When the deploy has no dependencies", func() {
+		It("Should go ahead", func() {
+			ok, err := sut.checkDependencies("my-application:v2", &RequestMetadata{})
+			Expect(err).To(BeNil())
+			Expect(ok).To(BeTrue())
+		})
+	})
+
+	Describe("When the dependency passed", func() {
+		It("Should go ahead", func() {
+			redisConn.Command("HGET", "redis-queue:name:my-database:v2", "result").Expect([]byte("passed"))
+
+			ok, err := sut.checkDependencies("my-application:v2", metadata)
+			Expect(err).To(BeNil())
+			Expect(ok).To(BeTrue())
+		})
+	})
+
+	Describe("When the dependency is still pending", func() {
+		It("Should requeue the deploy", func() {
+			redisConn.Command("HGET", "redis-queue:name:my-database:v2", "result").Expect(nil)
+			redisConn.Command("HDEL", "redis-queue:name:my-application:v2", "deploy:timestamp").Expect(int64(1))
+			zadd := redisConn.Command("ZADD", "redis-queue:name:governator:deploys", redigomock.NewAnyInt(), "my-application:v2").Expect(int64(1))
+
+			ok, err := sut.checkDependencies("my-application:v2", metadata)
+			Expect(err).To(BeNil())
+			Expect(ok).To(BeFalse())
+			Expect(redisConn.Stats(zadd)).To(Equal(1))
+		})
+	})
+
+	Describe("When the dependency failed", func() {
+		It("Should dead letter the deploy", func() {
+			redisConn.Command("HGET", "redis-queue:name:my-database:v2", "result").Expect([]byte("failed"))
+			zadd := redisConn.Command("ZADD", "redis-queue:name:governator:dead-letters", redigomock.NewAnyInt(), "my-application:v2").Expect(int64(1))
+
+			ok, err := sut.checkDependencies("my-application:v2", metadata)
+			Expect(err).To(BeNil())
+			Expect(ok).To(BeFalse())
+			Expect(redisConn.Stats(zadd)).To(Equal(1))
+		})
+	})
+})
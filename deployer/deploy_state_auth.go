@@ -0,0 +1,59 @@
+package deployer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Authentication schemes for WithDeployStateAuth
+const (
+	DeployStateAuthBearer = "Bearer"
+	DeployStateAuthBasic  = "Basic"
+	DeployStateAuthHMAC   = "HMAC"
+)
+
+// ValidateDeployStateAuthType returns an error unless
+// authType is one of Bearer, Basic or HMAC
+func ValidateDeployStateAuthType(authType string) error {
+	switch authType {
+	case DeployStateAuthBearer, DeployStateAuthBasic, DeployStateAuthHMAC:
+		return nil
+	}
+	return fmt.Errorf("unknown auth type '%v', expected %v, %v or %v", authType, DeployStateAuthBearer, DeployStateAuthBasic, DeployStateAuthHMAC)
+}
+
+// authorizeDeployStateRequest adds the deploy-state credentials to
+// the request. Basic sends the token, which should be user:password,
+// base64 encoded. HMAC signs the body followed by the unix timestamp
+// with the token, sending the timestamp as X-Timestamp and the hex
+// SHA-256 HMAC as X-Signature
+func (deployer *Deployer) authorizeDeployStateRequest(request *http.Request, body []byte) {
+	token := deployer.deployStateAuthToken
+	if token == "" {
+		return
+	}
+
+	switch deployer.deployStateAuthType {
+	case DeployStateAuthBasic:
+		request.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(token)))
+	case DeployStateAuthHMAC:
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		request.Header.Set("X-Timestamp", timestamp)
+		request.Header.Set("X-Signature", signDeployStateRequest(token, body, timestamp))
+	default:
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+func signDeployStateRequest(token string, body []byte, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write(body)
+	mac.Write([]byte(timestamp))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,78 @@
+package deployer
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDeployStateSRVName is the SRV record deploy-state
+// is looked up with when it is discovered through DNS
+const DefaultDeployStateSRVName = "_https._tcp.deploy-state.service.consul"
+
+// DefaultDeployStateSRVTTL is how long a looked up
+// deploy-state address is used before looking it up again
+const DefaultDeployStateSRVTTL = 30 * time.Second
+
+// srvResolver looks deploy-state up through a DNS SRV record,
+// caching the address for ttl
+type srvResolver struct {
+	name   string
+	ttl    time.Duration
+	lookup func(service, proto, name string) (string, []*net.SRV, error)
+
+	lock    sync.Mutex
+	cached  string
+	expires time.Time
+}
+
+func newSRVResolver(name string, ttl time.Duration) *srvResolver {
+	return &srvResolver{name: name, ttl: ttl, lookup: net.LookupSRV}
+}
+
+// baseURL returns the base URL of the first target of the SRV record.
+// The scheme is the record's service, e.g. https for _https._tcp
+func (resolver *srvResolver) baseURL() (string, error) {
+	resolver.lock.Lock()
+	defer resolver.lock.Unlock()
+
+	if resolver.cached != "" && time.Now().Before(resolver.expires) {
+		return resolver.cached, nil
+	}
+
+	_, records, err := resolver.lookup("", "", resolver.name)
+	if err != nil {
+		return "", err
+	}
+
+	for _, record := range records {
+		if record.Port == 0 || record.Target == "." {
+			continue
+		}
+
+		host := strings.TrimSuffix(record.Target, ".")
+		resolver.cached = fmt.Sprintf("%s://%s", srvScheme(resolver.name), net.JoinHostPort(host, fmt.Sprint(record.Port)))
+		resolver.expires = time.Now().Add(resolver.ttl)
+		debug("srvResolver: %v is %v", resolver.name, resolver.cached)
+		return resolver.cached, nil
+	}
+	return "", fmt.Errorf("no usable SRV records for %v", resolver.name)
+}
+
+func srvScheme(name string) string {
+	if strings.HasPrefix(name, "_http.") {
+		return "http"
+	}
+	return "https"
+}
+
+// getDeployStateURI is the --deploy-state-uri, or the
+// address discovered through DNS when that is enabled
+func (deployer *Deployer) getDeployStateURI() (string, error) {
+	if deployer.deployStateSRV == nil {
+		return deployer.deployStateURI, nil
+	}
+	return deployer.deployStateSRV.baseURL()
+}
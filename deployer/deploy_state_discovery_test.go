@@ -0,0 +1,64 @@
+package deployer
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("srvResolver", func() {
+	var sut *srvResolver
+	var lookups int
+	var records []*net.SRV
+	var lookupErr error
+
+	BeforeEach(func() {
+		lookups = 0
+		lookupErr = nil
+		records = []*net.SRV{
+			{Target: "node-1.deploy-state.service.consul.", Port: 8443},
+			{Target: "node-2.deploy-state.service.consul.", Port: 8443},
+		}
+		sut = newSRVResolver(DefaultDeployStateSRVName, time.Minute)
+		sut.lookup = func(service, proto, name string) (string, []*net.SRV, error) {
+			lookups++
+			Expect(name).To(Equal(DefaultDeployStateSRVName))
+			return "", records, lookupErr
+		}
+	})
+
+	It("Should use the first record", func() {
+		baseURL, err := sut.baseURL()
+		Expect(err).To(BeNil())
+		Expect(baseURL).To(Equal("https://node-1.deploy-state.service.consul:8443"))
+	})
+
+	It("Should cache the address for the ttl", func() {
+		sut.baseURL()
+		sut.baseURL()
+		Expect(lookups).To(Equal(1))
+
+		sut.expires = time.Now().Add(-time.Second)
+		sut.baseURL()
+		Expect(lookups).To(Equal(2))
+	})
+
+	Describe("When the lookup fails", func() {
+		It("Should return the error", func() {
+			lookupErr = errors.New("no such host")
+			_, err := sut.baseURL()
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Describe("When there are no usable records", func() {
+		It("Should return an error", func() {
+			records = []*net.SRV{{Target: ".", Port: 0}}
+			_, err := sut.baseURL()
+			Expect(err).NotTo(BeNil())
+		})
+	})
+})
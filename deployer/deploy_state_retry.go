@@ -0,0 +1,170 @@
+package deployer
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"golang.org/x/net/context"
+)
+
+// defaultDeployStateRetryBackoff is the first wait between
+// retries of a queued deploy-state notification, it doubles
+// on each failure up to maxDeployStateRetryBackoff
+const (
+	defaultDeployStateRetryBackoff = 1 * time.Second
+	maxDeployStateRetryBackoff     = 5 * time.Minute
+)
+
+// deployStateRetryPopTimeout is how long BRPOPLPUSH waits for
+// a notification, so the worker notices when it should stop
+const deployStateRetryPopTimeout = 1
+
+// deployStateRetry is a failed deploy-state
+// notification, as queued in the retry queue
+type deployStateRetry struct {
+	DockerURL   string            `json:"dockerUrl"`
+	Result      string            `json:"result"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Mirror      string            `json:"mirror,omitempty"`
+}
+
+func (deployer *Deployer) queueDeployStateRetry(metadata *RequestMetadata, result string) error {
+	retryBytes, err := json.Marshal(&deployStateRetry{
+		DockerURL:   metadata.DockerURL,
+		Result:      result,
+		Annotations: metadata.Annotations,
+		Mirror:      metadata.pulledMirror,
+	})
+	if err != nil {
+		return err
+	}
+
+	redisConn := deployer.redisPool.Get()
+	defer redisConn.Close()
+
+	_, err = redisConn.Do("LPUSH", deployer.deployStateRetryQueue, retryBytes)
+	if err != nil {
+		return newRedisError(err)
+	}
+	return nil
+}
+
+// NotifyRetryWorker sends the notifications in the deploy-state retry
+// queue until ctx is done. Each is moved to a processing list while it
+// is retried with exponential backoff, and dropped once it is sent or
+// fails in a way that can't be retried. Notifications left in the
+// processing list by a worker that stopped are retried first
+func (deployer *Deployer) NotifyRetryWorker(ctx context.Context) {
+	deployer.requeueDeployStateRetries()
+
+	for ctx.Err() == nil {
+		retryBytes, err := deployer.popDeployStateRetry()
+		if err != nil {
+			deployer.logger.Println("Error reading the deploy-state retry queue", err)
+			sleepContext(ctx, deployer.deployStateRetryBackoff)
+			continue
+		}
+		if retryBytes == nil {
+			continue
+		}
+
+		if deployer.retryDeployState(ctx, retryBytes) {
+			deployer.removeDeployStateRetry(retryBytes)
+		}
+	}
+}
+
+func (deployer *Deployer) getDeployStateRetryProcessingKey() string {
+	return deployer.deployStateRetryQueue + ":processing"
+}
+
+func (deployer *Deployer) popDeployStateRetry() ([]byte, error) {
+	redisConn := deployer.redisPool.Get()
+	defer redisConn.Close()
+
+	retryBytes, err := redis.Bytes(redisConn.Do("BRPOPLPUSH", deployer.deployStateRetryQueue, deployer.getDeployStateRetryProcessingKey(), deployStateRetryPopTimeout))
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	return retryBytes, err
+}
+
+// retryDeployState sends the notification until it succeeds, or fails
+// in a way that can't be retried. It returns false, leaving it in the
+// processing list, when ctx is done first
+func (deployer *Deployer) retryDeployState(ctx context.Context, retryBytes []byte) bool {
+	var retry deployStateRetry
+	err := json.Unmarshal(retryBytes, &retry)
+	if err != nil {
+		deployer.logger.Println("Dropping invalid deploy-state retry", err)
+		return true
+	}
+
+	metadata := &RequestMetadata{DockerURL: retry.DockerURL, Annotations: retry.Annotations, pulledMirror: retry.Mirror}
+	backoff := deployer.deployStateRetryBackoff
+	for {
+		err = deployer.sendDeployState(metadata, retry.Result)
+		if err == nil {
+			debug("retryDeployState: sent %v %v", retry.DockerURL, retry.Result)
+			return true
+		}
+
+		if notifyErr, ok := err.(*NotifyError); ok && !notifyErr.IsRetryable() {
+			deployer.logger.Println("Dropping deploy-state retry for", retry.DockerURL, err)
+			return true
+		}
+
+		debug("retryDeployState: %v, retrying in %v", err, backoff)
+		if !sleepContext(ctx, backoff) {
+			return false
+		}
+
+		backoff *= 2
+		if backoff > maxDeployStateRetryBackoff {
+			backoff = maxDeployStateRetryBackoff
+		}
+	}
+}
+
+func (deployer *Deployer) removeDeployStateRetry(retryBytes []byte) {
+	redisConn := deployer.redisPool.Get()
+	defer redisConn.Close()
+
+	_, err := redisConn.Do("LREM", deployer.getDeployStateRetryProcessingKey(), 1, retryBytes)
+	if err != nil {
+		deployer.logger.Println("Error removing deploy-state retry", err)
+	}
+}
+
+// requeueDeployStateRetries moves the notifications
+// in the processing list back onto the retry queue
+func (deployer *Deployer) requeueDeployStateRetries() {
+	redisConn := deployer.redisPool.Get()
+	defer redisConn.Close()
+
+	for {
+		_, err := redis.Bytes(redisConn.Do("RPOPLPUSH", deployer.getDeployStateRetryProcessingKey(), deployer.deployStateRetryQueue))
+		if err == redis.ErrNil {
+			return
+		}
+		if err != nil {
+			deployer.logger.Println("Error requeueing deploy-state retries", err)
+			return
+		}
+	}
+}
+
+// sleepContext waits for duration, returning
+// false when ctx is done before it has passed
+func sleepContext(ctx context.Context, duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
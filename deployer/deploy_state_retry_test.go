@@ -0,0 +1,143 @@
+package deployer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/docker/engine-api/types/swarm"
+	"github.com/garyburd/redigo/redis"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rafaeljusto/redigomock"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("DeployStateRetryQueue", func() {
+	var sut *Deployer
+	var redisConn *redigomock.Conn
+	var server *httptest.Server
+	var statusCodes []int
+	var paths []string
+
+	BeforeEach(func() {
+		statusCodes = nil
+		paths = nil
+		server = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			paths = append(paths, request.URL.Path)
+			statusCode := http.StatusOK
+			if len(statusCodes) > 0 {
+				statusCode, statusCodes = statusCodes[0], statusCodes[1:]
+			}
+			response.WriteHeader(statusCode)
+		}))
+
+		redisConn = redigomock.NewConn()
+		redisPool := &redis.Pool{Dial: func() (redis.Conn, error) { return redisConn, nil }}
+		sut = New(nil, redisPool, "redis-queue:name", server.URL, "super", WithDeployStateRetryQueue("governator:deploy-state-retries"), WithLogger(&fakeLogger{}))
+		sut.deployStateRetryBackoff = time.Millisecond
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("When the notification fails", func() {
+		var lpush *redigomock.Cmd
+		var err error
+
+		BeforeEach(func() {
+			statusCodes = []int{http.StatusBadGateway}
+			lpush = redisConn.GenericCommand("LPUSH").Expect(int64(1))
+			err = sut.notifyDeployState(&RequestMetadata{DockerURL: "octoblu/my-application:v1"}, DeployPassed)
+		})
+
+		It("Should queue it instead of returning the error", func() {
+			Expect(err).To(BeNil())
+			Expect(redisConn.Stats(lpush)).To(Equal(1))
+		})
+	})
+
+	Describe("When the notification of a passed deploy fails", func() {
+		var dockerClient *fakeDockerClient
+		var lpush *redigomock.Cmd
+		var err error
+
+		BeforeEach(func() {
+			dockerClient = &fakeDockerClient{service: swarm.Service{ID: "service-id"}}
+			dockerClient.service.Spec.Name = "my-application"
+			sut.dockerClient = dockerClient
+
+			statusCodes = []int{http.StatusServiceUnavailable}
+			retryBytes, _ := json.Marshal(&deployStateRetry{DockerURL: "octoblu/my-application:v2", Result: DeployPassed})
+			lpush = redisConn.Command("LPUSH", "governator:deploy-state-retries", retryBytes).Expect(int64(1))
+			err = sut.deploy("my-application:v2", &RequestMetadata{DockerURL: "octoblu/my-application:v2"})
+		})
+
+		It("Should pass the deploy and leave the notification on the retry queue", func() {
+			Expect(err).To(BeNil())
+			Expect(dockerClient.updates).To(HaveLen(1))
+			Expect(paths).To(Equal([]string{"/deployments/octoblu/my-application/v2/cluster/super/passed"}))
+			Expect(redisConn.Stats(lpush)).To(Equal(1))
+		})
+	})
+
+	Describe("When the notification can't be retried", func() {
+		BeforeEach(func() {
+			statusCodes = []int{http.StatusNotFound}
+		})
+
+		It("Should return the error without queueing it", func() {
+			err := sut.notifyDeployState(&RequestMetadata{DockerURL: "octoblu/my-application:v1"}, DeployPassed)
+			Expect(err).To(BeAssignableToTypeOf(&NotifyError{}))
+		})
+	})
+
+	Describe("retryDeployState", func() {
+		var retryBytes []byte
+
+		BeforeEach(func() {
+			retryBytes, _ = json.Marshal(&deployStateRetry{DockerURL: "octoblu/my-application:v1", Result: DeployFailed})
+		})
+
+		It("Should retry with backoff until the notification is sent", func() {
+			statusCodes = []int{http.StatusBadGateway, http.StatusServiceUnavailable}
+			Expect(sut.retryDeployState(context.Background(), retryBytes)).To(BeTrue())
+			Expect(paths).To(HaveLen(3))
+			Expect(paths[2]).To(Equal("/deployments/octoblu/my-application/v1/cluster/super/failed"))
+		})
+
+		It("Should drop it when it can't be retried", func() {
+			statusCodes = []int{http.StatusNotFound}
+			Expect(sut.retryDeployState(context.Background(), retryBytes)).To(BeTrue())
+			Expect(paths).To(HaveLen(1))
+		})
+
+		It("Should leave it for later when the context is done", func() {
+			statusCodes = []int{http.StatusBadGateway}
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			Expect(sut.retryDeployState(ctx, retryBytes)).To(BeFalse())
+		})
+	})
+
+	Describe("NotifyRetryWorker", func() {
+		It("Should send the queued notifications and remove them from the processing list", func() {
+			retryBytes, _ := json.Marshal(&deployStateRetry{DockerURL: "octoblu/my-application:v1", Result: DeployPassed})
+			ctx, cancel := context.WithCancel(context.Background())
+			server.Config.Handler = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				paths = append(paths, request.URL.Path)
+				cancel()
+			})
+
+			redisConn.Command("RPOPLPUSH", "governator:deploy-state-retries:processing", "governator:deploy-state-retries").Expect(nil)
+			redisConn.Command("BRPOPLPUSH", "governator:deploy-state-retries", "governator:deploy-state-retries:processing", deployStateRetryPopTimeout).Expect(retryBytes)
+			lrem := redisConn.Command("LREM", "governator:deploy-state-retries:processing", 1, retryBytes).Expect(int64(1))
+
+			sut.NotifyRetryWorker(ctx)
+			Expect(paths).To(Equal([]string{"/deployments/octoblu/my-application/v1/cluster/super/passed"}))
+			Expect(redisConn.Stats(lrem)).To(Equal(1))
+		})
+	})
+})
@@ -0,0 +1,132 @@
+package deployer
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ProxyConfig routes the deploy-state requests through a proxy,
+// in place of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY env
+type ProxyConfig struct {
+	HTTPProxy  *url.URL
+	HTTPSProxy *url.URL
+
+	// NoProxy are the hosts, host:ports and domains (.example.com
+	// or example.com, for it and its subdomains) that are requested
+	// directly. * requests every host directly
+	NoProxy []string
+}
+
+// ParseProxyConfig parses the proxy URLs, which default to http://
+// when they have no scheme, and the comma separated noProxy hosts
+func ParseProxyConfig(httpProxy, httpsProxy, noProxy string) (*ProxyConfig, error) {
+	config := &ProxyConfig{}
+
+	var err error
+	config.HTTPProxy, err = parseProxyURL(httpProxy)
+	if err != nil {
+		return nil, err
+	}
+
+	config.HTTPSProxy, err = parseProxyURL(httpsProxy)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, host := range strings.Split(noProxy, ",") {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host != "" {
+			config.NoProxy = append(config.NoProxy, host)
+		}
+	}
+	return config, nil
+}
+
+func parseProxyURL(proxy string) (*url.URL, error) {
+	if proxy == "" {
+		return nil, nil
+	}
+
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %v: %v", proxy, err)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %v: no host", proxy)
+	}
+	return proxyURL, nil
+}
+
+// proxy picks the proxy for request, like http.ProxyFromEnvironment
+// does. localhost and loopback addresses are always requested directly
+func (config *ProxyConfig) proxy(request *http.Request) (*url.URL, error) {
+	proxyURL := config.HTTPProxy
+	if request.URL.Scheme == "https" {
+		proxyURL = config.HTTPSProxy
+	}
+
+	if proxyURL == nil || config.bypass(request.URL) {
+		return nil, nil
+	}
+	return proxyURL, nil
+}
+
+func (config *ProxyConfig) bypass(requestURL *url.URL) bool {
+	hostname := strings.ToLower(requestURL.Hostname())
+	if hostname == "localhost" {
+		return true
+	}
+	if ip := net.ParseIP(hostname); ip != nil && ip.IsLoopback() {
+		return true
+	}
+
+	hostPort := hostname
+	if port := requestURL.Port(); port != "" {
+		hostPort = net.JoinHostPort(hostname, port)
+	}
+
+	for _, noProxy := range config.NoProxy {
+		if noProxy == "*" || noProxy == hostname || noProxy == hostPort {
+			return true
+		}
+
+		domain := strings.TrimPrefix(noProxy, ".")
+		if strings.HasSuffix(hostname, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// newDeployStateTransport builds the transport for deploy-state
+// requests. net/http negotiates HTTP/2 over TLS with servers that
+// support it, as long as the transport has no custom TLS config or
+// dialer. An empty TLSNextProto forces HTTP/1.1. Plain http://
+// URIs always use HTTP/1.1. Without a proxy config, the proxy
+// comes from the env
+func newDeployStateTransport(http2 bool, proxyConfig *ProxyConfig) *http.Transport {
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConnsPerHost: 2,
+		IdleConnTimeout:     90 * time.Second,
+	}
+
+	if proxyConfig != nil {
+		transport.Proxy = proxyConfig.proxy
+	}
+
+	if !http2 {
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return transport
+}
@@ -0,0 +1,158 @@
+package deployer
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("deploy-state HTTP/2", func() {
+	var server *httptest.Server
+	var protocol string
+
+	BeforeEach(func() {
+		server = newHTTP2TestServer(func(request *http.Request) {
+			protocol = request.Proto
+		})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	notify := func(options ...Option) error {
+		sut := New(nil, nil, "redis-queue:name", server.URL, "super", options...)
+		trustTestServer(sut, server)
+		return sut.notifyDeployState(&RequestMetadata{DockerURL: "octoblu/my-application:v1"}, DeployPassed)
+	}
+
+	It("Should use HTTP/2 with an https deploy-state", func() {
+		Expect(notify()).To(Succeed())
+		Expect(protocol).To(Equal("HTTP/2.0"))
+	})
+
+	Describe("When HTTP/2 is disabled", func() {
+		It("Should use HTTP/1.1", func() {
+			Expect(notify(WithDeployStateHTTP2(false))).To(Succeed())
+			Expect(protocol).To(Equal("HTTP/1.1"))
+		})
+	})
+})
+
+var _ = Describe("deploy-state proxy", func() {
+	var proxy *httptest.Server
+	var proxiedHost string
+
+	BeforeEach(func() {
+		proxiedHost = ""
+		proxy = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			proxiedHost = request.Host
+			response.WriteHeader(http.StatusOK)
+		}))
+	})
+
+	AfterEach(func() {
+		proxy.Close()
+	})
+
+	It("Should send deploy-state requests through the proxy", func() {
+		config, err := ParseProxyConfig(proxy.URL, "", "")
+		Expect(err).To(BeNil())
+
+		sut := New(nil, nil, "redis-queue:name", "http://deploy-state.test", "super", WithDeployStateProxy(config))
+		Expect(sut.notifyDeployState(&RequestMetadata{DockerURL: "octoblu/my-application:v1"}, DeployPassed)).To(Succeed())
+		Expect(proxiedHost).To(Equal("deploy-state.test"))
+	})
+
+	Describe("ProxyConfig", func() {
+		var config *ProxyConfig
+
+		BeforeEach(func() {
+			var err error
+			config, err = ParseProxyConfig("proxy.internal:3128", "https://secure-proxy.internal", "deploy-state.internal, .corp.example.com,10.0.0.1:8080")
+			Expect(err).To(BeNil())
+		})
+
+		proxyFor := func(rawURL string) string {
+			request, err := http.NewRequest("GET", rawURL, nil)
+			Expect(err).To(BeNil())
+			proxyURL, err := config.proxy(request)
+			Expect(err).To(BeNil())
+			if proxyURL == nil {
+				return ""
+			}
+			return proxyURL.String()
+		}
+
+		It("Should pick the proxy by scheme", func() {
+			Expect(proxyFor("http://deploy-state.example.com/deployments")).To(Equal("http://proxy.internal:3128"))
+			Expect(proxyFor("https://deploy-state.example.com/deployments")).To(Equal("https://secure-proxy.internal"))
+		})
+
+		It("Should bypass the proxy for the no proxy hosts and domains", func() {
+			Expect(proxyFor("https://deploy-state.internal/deployments")).To(Equal(""))
+			Expect(proxyFor("https://deploy-state.corp.example.com/deployments")).To(Equal(""))
+			Expect(proxyFor("http://10.0.0.1:8080/deployments")).To(Equal(""))
+			Expect(proxyFor("http://10.0.0.1:9090/deployments")).To(Equal("http://proxy.internal:3128"))
+		})
+
+		It("Should bypass the proxy for localhost", func() {
+			Expect(proxyFor("http://localhost:3000/deployments")).To(Equal(""))
+			Expect(proxyFor("http://127.0.0.1:3000/deployments")).To(Equal(""))
+		})
+
+		It("Should refuse a proxy without a host", func() {
+			_, err := ParseProxyConfig("http://", "", "")
+			Expect(err).NotTo(BeNil())
+		})
+	})
+})
+
+func BenchmarkNotifyDeployState(b *testing.B) {
+	server := newHTTP2TestServer(func(request *http.Request) {})
+	defer server.Close()
+
+	for _, http2 := range []bool{false, true} {
+		name := "HTTP/1.1"
+		if http2 {
+			name = "HTTP/2"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			sut := New(nil, nil, "redis-queue:name", server.URL, "super", WithDeployStateHTTP2(http2))
+			trustTestServer(sut, server)
+			metadata := &RequestMetadata{DockerURL: "octoblu/my-application:v1"}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				err := sut.notifyDeployState(metadata, DeployPassed)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func newHTTP2TestServer(onRequest func(request *http.Request)) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		onRequest(request)
+		response.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{NextProtos: []string{"h2", "http/1.1"}}
+	server.StartTLS()
+	return server
+}
+
+// trustTestServer makes the deployer trust the test server's
+// certificate. Setting a TLS config turns off net/http's automatic
+// HTTP/2, so it is forced back on, unless TLSNextProto disabled it
+func trustTestServer(deployer *Deployer, server *httptest.Server) {
+	transport := deployer.deployStateClient.Transport.(*http.Transport)
+	transport.TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	transport.ForceAttemptHTTP2 = true
+}
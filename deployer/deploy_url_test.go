@@ -0,0 +1,30 @@
+package deployer
+
+import (
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("parseDockerURL", func() {
+	table.DescribeTable("owner, repo and tag",
+		func(dockerURL, owner, repo, tag string) {
+			sut := New(nil, nil, "redis-queue:name", "https://deploy-state.test", "super")
+			actualOwner, actualRepo, actualTag := sut.parseDockerURL(dockerURL)
+			Expect([]string{actualOwner, actualRepo, actualTag}).To(Equal([]string{owner, repo, tag}))
+		},
+		table.Entry("empty string", "", "", "", ""),
+		table.Entry("no tag", "octoblu/my-application", "", "", ""),
+		table.Entry("empty tag", "octoblu/my-application:", "octoblu", "my-application", ""),
+		table.Entry("no owner", "my-application:v1", "", "", ""),
+		table.Entry("single-level path", "my-application", "", "", ""),
+		table.Entry("two-level path", "octoblu/my-application:v1", "octoblu", "my-application", "v1"),
+		table.Entry("three-level path", "quay.io/octoblu/my-application:v1", "octoblu", "my-application", "v1"),
+		table.Entry("four-level path", "quay.io/octoblu/apps/my-application:v1", "", "", ""),
+		table.Entry("registry with a port", "registry.example.com:5000/octoblu/my-application:v1", "octoblu", "my-application", "v1"),
+		table.Entry("registry with a port and no tag", "registry.example.com:5000/octoblu/my-application", "", "", ""),
+		table.Entry("digest", "octoblu/my-application@sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae", "octoblu", "my-application", "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"),
+		table.Entry("digest with a registry port", "registry.example.com:5000/octoblu/my-application@sha256:2c26b46b", "octoblu", "my-application", "sha256:2c26b46b"),
+		table.Entry("non-alphanumeric tag", "octoblu/my-application:v1.2.3-rc_1", "octoblu", "my-application", "v1.2.3-rc_1"),
+	)
+})
@@ -1,62 +1,399 @@
 package deployer
 
 import (
+	"bytes"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"io"
+	"math/rand"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/net/context"
+	"golang.org/x/time/rate"
 
 	"github.com/docker/engine-api/client"
 	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/swarm"
 	"github.com/garyburd/redigo/redis"
+	"github.com/octoblu/governator-swarm/manifest"
+	"github.com/octoblu/governator-swarm/metrics"
+	"github.com/octoblu/governator-swarm/monitor"
+	"github.com/octoblu/governator-swarm/notifier"
+	"github.com/octoblu/governator-swarm/telemetry"
 	De "github.com/tj/go-debug"
 )
 
 var debug = De.Debug("governator:deployer")
 
+const (
+	defaultMaxDeployAttempts = 5
+	defaultRetryDelay        = 30 * time.Second
+
+	// DefaultDeployStateTimeout is how long a deploy-state request
+	// may take when WithDeployStateTimeout isn't used
+	DefaultDeployStateTimeout = 30 * time.Second
+
+	// DefaultQueueScanLimit is how many due deploys are read from
+	// the queue at a time when WithQueueScanLimit isn't used
+	DefaultQueueScanLimit = 10
+)
+
+func init() {
+	metrics.DefaultRegistry.Help("governator_deploys_total", "Deploys taken off the queue, by result")
+	metrics.DefaultRegistry.Help("governator_preflight_failures_total", "Deploys left on the queue because they failed the preflight check")
+}
+
 // Deployer watches a redis queue
 // and deploys services using Etcd
 type Deployer struct {
 	dockerClient   client.APIClient
-	redisConn      redis.Conn
+	redisPool      *redis.Pool
 	queueName      string
 	deployStateURI string
 	cluster        string
+	manifestDir    string
+
+	dockerLabelFilter         map[string]string
+	defaultStopGracePeriod    time.Duration
+	registryMirror            string
+	registryMirrorPrefixStrip string
+	deployTimeout             time.Duration
+	auditLogPath              string
+	auditLogMaxSize           int
+	auditLog                  *auditLog
+	etcdClient                EtcdClient
+	deployStateTimeout        time.Duration
+	deployStateClient         *http.Client
+	deployStateHTTP2          bool
+	deployStateProxy          *ProxyConfig
+	clusterEnv                map[string]string
+	noClusterEnv              bool
+	events                    *eventBroker
+	startedAt                 time.Time
+	nativeRollback            ServiceRollbacker
+	consumerID                string
+	tagTransforms             []*TagTransform
+	monitor                   *monitor.Monitor
+	deployWindow              time.Duration
+	globalMaxParallelism      int
+	concurrencyWait           time.Duration
+	keyPrefix                 string
+	allowedRegistries         []string
+	allowServiceRecreation    bool
+	updateFailureAction       string
+	telemetry                 *telemetry.Writer
+	deployStateAuthType       string
+	deployStateAuthToken      string
+	runLock                   sync.Mutex
+	runningSince              time.Time
+	alwaysConverge            bool
+	convergencePollInterval   time.Duration
+	convergenceTimeout        time.Duration
+	conflictBackoff           time.Duration
+	conflictPollInterval      time.Duration
+	configSource              ConfigSource
+	minHealthyPeriod          time.Duration
+	keys                      KeyBuilder
+	captureFailureLogs        bool
+	notifyOnly                string
+	pollInterval              time.Duration
+	queueScanLimit            int
+	metadataCompressor        compressor
+	deployPollInterval        time.Duration
+	eventSubscriber           *monitor.EventSubscriber
+	notifyLimiter             *rate.Limiter
+	staggerMin                time.Duration
+	staggerMax                time.Duration
+	staggerRand               *rand.Rand
+	staggerDelay              time.Duration
+	secretLister              SecretLister
+	notifiers                 []notifier.Notifier
+	digestResolver            DigestResolver
+	logger                    Logger
+	metrics                   metrics.Recorder
+	maxDeployAttempts         int
+	retryDelay                time.Duration
+	nodeLabelSelector         map[string]string
+	notifyChannels            notifier.Channels
+	deployStateSRV            *srvResolver
+	maxImageAge               time.Duration
+	maxImageAgeSkipDigests    bool
+	requireSemverTag          bool
+	paused                    int32
+	semverPrefix              string
+	backpressure              *BackpressureController
+	backpressureSleep         time.Duration
+	throttled                 bool
+	enforceReadonlyRootfs     bool
+	deployStateRetryQueue     string
+	deployStateRetryBackoff   time.Duration
+	notifyChannelMapFile      string
+	tagTransformsFile         string
+	fileTagTransforms         []*TagTransform
+	configLock                sync.RWMutex
+	healthRoutingKey          string
+	healthRoutingValue        string
+	drainWait                 time.Duration
+	closeCtx                  context.Context
+	cancelClose               context.CancelFunc
+	closeLock                 sync.Mutex
+	closed                    bool
+	inFlight                  sync.WaitGroup
+	background                sync.WaitGroup
 }
 
 // RequestMetadata is the metadata of the request
 type RequestMetadata struct {
-	EtcdDir   string `json:"etcdDir"`
-	DockerURL string `json:"dockerUrl"`
+	EtcdDir                 string              `json:"etcdDir"`
+	DockerURL               string              `json:"dockerUrl"`
+	ScheduledWindow         ScheduledWindow     `json:"scheduledWindow"`
+	StopGracePeriod         *int                `json:"stopGracePeriod"`
+	TimeoutSeconds          int                 `json:"timeoutSeconds"`
+	Annotations             map[string]string   `json:"annotations"`
+	EnvOverrides            map[string]string   `json:"envOverrides"`
+	ImagePullPolicy         string              `json:"imagePullPolicy"`
+	NetworkMode             string              `json:"networkMode"`
+	ServiceMode             string              `json:"serviceMode"`
+	PublishedPorts          []PortConfig        `json:"publishedPorts"`
+	UpdateConfig            *UpdateConfig       `json:"updateConfig"`
+	RegistryMirrors         []string            `json:"registryMirrors"`
+	Mutex                   string              `json:"mutex"`
+	WaitForConvergence      bool                `json:"waitForConvergence"`
+	MinHealthyPeriodSeconds int                 `json:"minHealthyPeriodSeconds"`
+	PostDeployScale         *uint64             `json:"postDeployScale"`
+	PollInterval            *int                `json:"pollInterval"`
+	PreDeployPauseSecs      int                 `json:"preDeployPauseSecs"`
+	DependsOn               []string            `json:"dependsOn"`
+	RequiredSecrets         []string            `json:"requiredSecrets"`
+	ImageDigest             string              `json:"imageDigest"`
+	NotifyChannels          []string            `json:"notifyChannels"`
+	CapAdd                  []string            `json:"capAdd"`
+	CapDrop                 []string            `json:"capDrop"`
+	ReadonlyRootfs          *bool               `json:"readonlyRootfs"`
+	UserNS                  string              `json:"userNS"`
+	Ulimits                 []Ulimit            `json:"ulimits"`
+	PagerDutyService        string              `json:"pagerDutyService"`
+	PagerDutySeverity       string              `json:"pagerDutySeverity"`
+	InitContainers          []InitContainerSpec `json:"initContainers"`
+	EndpointMode            string              `json:"endpointMode"`
+	NodeAffinityLabels      map[string]string   `json:"nodeAffinityLabels"`
+	NodeAffinityStrategies  map[string]string   `json:"nodeAffinityStrategies"`
+	ServiceLabels           map[string]string   `json:"serviceLabels"`
+	ServiceLabelRemovals    []string            `json:"serviceLabelRemovals"`
+	ContainerLabels         map[string]string   `json:"containerLabels"`
+	DNSConfig               *DNSConfig          `json:"dnsConfig"`
+
+	// pulledMirror is the registry mirror the image was pulled from
+	pulledMirror string
 }
 
 // New constructs a new deployer instance
-func New(dockerClient client.APIClient, redisConn redis.Conn, queueName, deployStateURI, cluster string) *Deployer {
-	return &Deployer{
-		dockerClient:   dockerClient,
-		redisConn:      redisConn,
-		queueName:      queueName,
-		deployStateURI: deployStateURI,
-		cluster:        cluster,
+func New(dockerClient client.APIClient, redisPool *redis.Pool, queueName, deployStateURI, cluster string, options ...Option) *Deployer {
+	options = append([]Option{WithQueueName(queueName), WithDeployStateURI(deployStateURI), WithCluster(cluster)}, options...)
+	return NewDeployer(dockerClient, redisPool, options...)
+}
+
+// NewDeployer constructs a new deployer instance configured by options,
+// the queue, deploy-state and cluster included
+func NewDeployer(dockerClient client.APIClient, redisPool *redis.Pool, options ...Option) *Deployer {
+	deployer := &Deployer{
+		dockerClient: dockerClient,
+		redisPool:    redisPool,
+
+		deployStateTimeout: DefaultDeployStateTimeout,
+		events:             newEventBroker(),
+		startedAt:          time.Now(),
+		telemetry:          telemetry.NewWriter(),
+		deployStateHTTP2:   true,
+		logger:             stdLogger{},
+		metrics:            metrics.DefaultRecorder,
+		maxDeployAttempts:  defaultMaxDeployAttempts,
+		retryDelay:         defaultRetryDelay,
+
+		deployStateRetryBackoff: defaultDeployStateRetryBackoff,
+
+		convergencePollInterval: defaultConvergencePollInterval,
+		convergenceTimeout:      defaultConvergenceTimeout,
+		conflictPollInterval:    defaultConflictPollInterval,
+		pollInterval:            DefaultPollInterval,
+		queueScanLimit:          DefaultQueueScanLimit,
+		notifyLimiter:           rate.NewLimiter(DefaultNotifyRateLimit, DefaultNotifyBurst),
+	}
+
+	deployer.closeCtx, deployer.cancelClose = context.WithCancel(context.Background())
+
+	for _, option := range options {
+		option(deployer)
+	}
+
+	if deployer.deployStateClient == nil {
+		deployer.deployStateClient = &http.Client{
+			Timeout:   deployer.deployStateTimeout,
+			Transport: newDeployStateTransport(deployer.deployStateHTTP2, deployer.deployStateProxy),
+		}
+	}
+
+	if deployer.backpressure != nil {
+		deployer.dockerClient = &latencyTrackingClient{APIClient: deployer.dockerClient, controller: deployer.backpressure}
+	}
+
+	if deployer.keys == nil {
+		deployer.keys = &DefaultKeyBuilder{Prefix: deployer.keyPrefix, QueueName: deployer.queueName}
 	}
+
+	// deploys may ask for a min healthy period when the deployer has none
+	if deployer.monitor == nil {
+		deployer.monitor = monitor.New(deployer.dockerClient, defaultTaskPollInterval)
+	}
+	if deployer.eventSubscriber != nil {
+		deployer.monitor.UseEvents(deployer.eventSubscriber)
+	}
+
+	if deployer.auditLogPath != "" {
+		deployer.auditLog = newAuditLog(deployer.auditLogPath, deployer.auditLogMaxSize)
+	}
+
+	return deployer
 }
 
-// Run watches the redis queue and starts taking action
+// Run watches the redis queue and starts taking action.
+// A failed deploy is requeued when its error is retryable and
+// sent to the dead letter queue otherwise, only errors talking
+// to redis are returned
 func (deployer *Deployer) Run() error {
-	deploy, err := deployer.getNextValidDeploy()
+	_, err := deployer.RunOnce(context.Background())
+	return err
+}
+
+// RunOnce takes at most one deploy off the queue and processes it, like
+// Run. deployed is false, with a nil error, when the queue was empty
+// or the deployer is paused
+func (deployer *Deployer) RunOnce(ctx context.Context) (bool, error) {
+	err := ctx.Err()
 	if err != nil {
-		return err
+		return false, err
+	}
+
+	if deployer.Paused() {
+		debug("RunOnce: paused")
+		return false, nil
+	}
+
+	if !deployer.startInFlight() {
+		return false, ErrClosed
+	}
+	defer deployer.inFlight.Done()
+
+	deployer.startRun()
+	defer deployer.finishRun()
+	deployer.staggerDelay = 0
+	defer deployer.flushTelemetry()
+
+	if deployer.checkBackpressure() {
+		return false, nil
+	}
+
+	deploy, metadata, err := deployer.getNextValidDeploy()
+	if deploy == "" {
+		return false, err
 	}
+	defer deployer.events.close(deploy)
+	defer deployer.finishProcessing(deploy)
 
-	if deploy == nil {
+	if err != nil {
+		if _, ok := err.(*RedisError); ok {
+			return true, err
+		}
+		return true, deployer.handleDeployError(deploy, nil, err)
+	}
+
+	if metadata == nil {
+		return true, nil
+	}
+	return true, deployer.runDeploy(deploy, metadata)
+}
+
+// runDeploy takes the locked deploy through the pause,
+// concurrency limit and mutex, then deploys it
+func (deployer *Deployer) runDeploy(deploy string, metadata *RequestMetadata) error {
+	deployer.setDeployPollInterval(nil)
+
+	ok, err := deployer.pauseDeploy(deploy, metadata)
+	if err != nil {
+		return deployer.handleDeployError(deploy, metadata, err)
+	}
+	if !ok {
 		return nil
 	}
 
-	return deployer.deploy(deploy)
+	release, err := deployer.acquireConcurrency(deploy)
+	if _, ok := err.(*ConcurrencyLimitError); ok {
+		deployer.logger.Println("Requeueing deploy", deploy, err)
+		return deployer.requeueDeploy(deploy, time.Now())
+	}
+	if err != nil {
+		return deployer.handleDeployError(deploy, metadata, err)
+	}
+	defer release()
+
+	if metadata.Mutex != "" {
+		releaseMutex, acquired, err := deployer.acquireMutex(metadata.Mutex)
+		if err != nil {
+			return deployer.handleDeployError(deploy, metadata, err)
+		}
+		if !acquired {
+			deployer.logger.Println("Requeueing deploy, mutex is held", deploy, metadata.Mutex)
+			return deployer.requeueDeploy(deploy, time.Now().Add(mutexRetryDelay))
+		}
+		defer releaseMutex()
+	}
+
+	deployer.events.publish(deploy, EventDeploying, metadata.DockerURL)
+	err = deployer.deploy(deploy, metadata)
+	if err != nil {
+		deployer.metrics.IncrCounter("governator_deploys_total", map[string]string{"result": "failed"})
+		return deployer.handleDeployError(deploy, metadata, err)
+	}
+
+	deployer.metrics.IncrCounter("governator_deploys_total", map[string]string{"result": "passed"})
+	deployer.events.publish(deploy, EventSucceeded, "")
+	deployer.recordResult(deploy, DeployPassed)
+	deployer.setDeployPollInterval(metadata)
+	deployer.setStaggerDelay()
+	return nil
+}
+
+// handleDeployError requeues the deploy when its error is retryable and it
+// has attempts left. Otherwise it is dead lettered, and its deployment is
+// marked failed in deploy-state when its metadata could be read
+func (deployer *Deployer) handleDeployError(deploy string, metadata *RequestMetadata, deployErr error) error {
+	deployer.logger.Println("Deploy failed", deploy, deployErr)
+	deployer.events.publish(deploy, EventFailed, deployErr.Error())
+
+	deployError, ok := deployErr.(DeployError)
+	if ok && deployError.IsRetryable() {
+		attempts, err := deployer.incrementAttempts(deploy)
+		if err != nil {
+			return err
+		}
+
+		if attempts < deployer.maxDeployAttempts {
+			return deployer.requeueDeploy(deploy, time.Now().Add(deployer.retryDelay))
+		}
+	}
+
+	if metadata != nil {
+		err := deployer.notifyDeployState(metadata, DeployFailed)
+		if err != nil {
+			deployer.logger.Println("Error marking the deployment failed in deploy-state", deploy, err)
+		}
+	}
+	return deployer.deadLetterDeploy(deploy, deployErr.Error())
 }
 
 func (deployer *Deployer) getReleaseVersion(dockerURL string) string {
@@ -65,147 +402,491 @@ func (deployer *Deployer) getReleaseVersion(dockerURL string) string {
 }
 
 func (deployer *Deployer) getKey(key string) string {
-	return fmt.Sprintf("%s:%s", deployer.queueName, key)
+	return fmt.Sprintf("%s%s:%s", deployer.keyPrefix, deployer.queueName, key)
 }
 
-func (deployer *Deployer) deploy(metadata *RequestMetadata) error {
-	var err error
-	dockerClient := deployer.dockerClient
+func (deployer *Deployer) deploy(deploy string, metadata *RequestMetadata) error {
+	err := deployer.transformImageTag(metadata)
+	if err != nil {
+		return err
+	}
 
 	_, repo, _ := deployer.parseDockerURL(metadata.DockerURL)
+	return deployer.deployService(deploy, repo, metadata)
+}
+
+func (deployer *Deployer) deployService(deploy, repo string, metadata *RequestMetadata) (err error) {
+	start := time.Now()
+	defer func() {
+		deployer.recordDeploy(deploy, repo, metadata, start, err)
+	}()
+
+	debug("deploy: %v %v", deploy, sanitize(metadata))
+	dockerClient := deployer.dockerClient
+
+	timeout, err := deployer.getDeployTimeout(metadata)
+	if err != nil {
+		return err
+	}
 
 	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 	updateOpts := types.ServiceUpdateOptions{}
 
 	service, _, err := dockerClient.ServiceInspectWithRaw(ctx, repo)
 	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return deployer.deployTimedOut(deploy, repo, "inspecting the service", timeout)
+		}
+		return newDockerAPIError(err)
+	}
+
+	err = deployer.checkServiceOwnership(service)
+	if err != nil {
+		return err
+	}
+
+	previousSpec, err := copyServiceSpec(service.Spec)
+	if err != nil {
+		return err
+	}
+
+	err = deployer.updateServiceSpec(&service.Spec, metadata)
+	if err != nil {
+		return err
+	}
+	deployer.verifyImageDigest(ctx, metadata)
+
+	err = deployer.checkImageAge(ctx, service.Spec.TaskTemplate.ContainerSpec.Image)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return deployer.deployTimedOut(deploy, repo, "checking the image age", timeout)
+		}
+		return err
+	}
+
+	err = deployer.applyImagePullPolicy(ctx, &service.Spec, metadata)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return deployer.deployTimedOut(deploy, repo, "pulling the image", timeout)
+		}
+		return err
+	}
+
+	err = deployer.applyNetworkMode(ctx, &service.Spec, metadata)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return deployer.deployTimedOut(deploy, repo, "inspecting the networks", timeout)
+		}
+		return err
+	}
+
+	err = deployer.runInitContainers(ctx, deploy, metadata)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return deployer.deployTimedOut(deploy, repo, "running the init containers", timeout)
+		}
 		return err
 	}
 
-	service.Spec.TaskTemplate.ContainerSpec.Image = metadata.DockerURL
+	drained, err := deployer.drainService(ctx, &service, previousSpec)
+	if drained {
+		defer func() {
+			undrainErr := deployer.undrainService(repo, previousSpec)
+			if undrainErr != nil {
+				deployer.logger.Println("Error removing the health routing label from", repo, undrainErr)
+			}
+		}()
+	}
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return deployer.deployTimedOut(deploy, repo, "draining the service", timeout)
+		}
+		return err
+	}
 
 	err = dockerClient.ServiceUpdate(ctx, service.ID, service.Version, service.Spec, updateOpts)
+	err = deployer.retryUpdateConflict(ctx, &service, updateOpts, err)
+	if err != nil && ctx.Err() == nil && deployer.shouldRecreateService(previousSpec, service.Spec) {
+		deployer.logger.Println("Update of", repo, "was rejected", err)
+		err = deployer.recreateService(ctx, &service, previousSpec)
+	}
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			deployer.rollbackService(deploy, service, previousSpec, timeout/2)
+			return deployer.deployTimedOut(deploy, repo, "updating the service", timeout)
+		}
+		return newDockerAPIError(err)
+	}
+
+	err = deployer.watchDeploy(ctx, deploy, service, previousSpec, timeout)
+	if err != nil {
+		return err
+	}
+
+	if deployer.shouldWaitForConvergence(metadata) {
+		err = deployer.convergeService(ctx, deploy, service, timeout)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = deployer.watchHealthyPeriod(deploy, service, previousSpec, timeout, metadata)
 	if err != nil {
 		return err
 	}
 
-	// err = deployer.notifyDeployState(metadata.DockerURL)
-	// if err != nil {
-	// 	return err
-	// }
+	err = deployer.scaleService(ctx, deploy, service.ID, metadata, timeout)
+	if err != nil {
+		return err
+	}
+
+	err = deployer.updateEtcd(metadata)
+	if err != nil {
+		return err
+	}
+
+	return deployer.notifyDeployState(metadata, DeployPassed)
+}
+
+// getDeployTimeout returns the metadata's timeoutSeconds,
+// falling back to the deployer's timeout when it isn't set
+func (deployer *Deployer) getDeployTimeout(metadata *RequestMetadata) (time.Duration, error) {
+	if metadata.TimeoutSeconds < 0 {
+		return 0, newValidationError("Invalid timeoutSeconds %v, must not be negative", metadata.TimeoutSeconds)
+	}
+
+	if metadata.TimeoutSeconds > 0 {
+		return time.Duration(metadata.TimeoutSeconds) * time.Second, nil
+	}
+	return deployer.deployTimeout, nil
+}
+
+func (deployer *Deployer) deployTimedOut(deploy, service, stage string, timeout time.Duration) error {
+	deployer.logger.Println("Deploy timed out", deploy, "service", service, "while", stage, "after", timeout)
+	return &DeployTimeoutError{Deploy: deploy, Service: service, Stage: stage, Timeout: timeout}
+}
 
+func (deployer *Deployer) checkServiceOwnership(service swarm.Service) error {
+	for key, value := range deployer.dockerLabelFilter {
+		if actual, ok := service.Spec.Labels[key]; !ok || actual != value {
+			return &ServiceNotOwnedError{Service: service.Spec.Name, Label: fmt.Sprintf("%v=%v", key, value)}
+		}
+	}
 	return nil
 }
 
-func (deployer *Deployer) getNextDeploy() (string, error) {
+func (deployer *Deployer) getNextDeploy() (string, int64, error) {
+	redisConn := deployer.redisPool.Get()
+	defer redisConn.Close()
+
 	now := time.Now().Unix()
-	deploysResult, err := deployer.redisConn.Do("ZRANGEBYSCORE", deployer.getKey("governator:deploys"), 0, now)
+	deploysResult, err := redisConn.Do("ZRANGEBYSCORE", deployer.keys.DeployKey(), 0, now, "WITHSCORES", "LIMIT", 0, deployer.queueScanLimit)
 
 	if err != nil {
-		return "", err
+		return "", 0, newRedisError(err)
 	}
 
 	deploys := deploysResult.([]interface{})
-	if len(deploys) == 0 {
-		return "", nil
+	if len(deploys) < 2 {
+		return "", 0, nil
+	}
+
+	score, err := redis.Int64(deploys[1], nil)
+	if err != nil {
+		return "", 0, newRedisError(err)
 	}
 
-	return string(deploys[0].([]byte)), nil
+	return string(deploys[0].([]byte)), score, nil
 }
 
 func (deployer *Deployer) lockDeploy(deploy string) (bool, error) {
 	debug("lockDeploy: %v", deploy)
-	zremResult, err := deployer.redisConn.Do("ZREM", deployer.getKey("governator:deploys"), deploy)
+	redisConn := deployer.redisPool.Get()
+	defer redisConn.Close()
+
+	zremResult, err := redisConn.Do("ZREM", deployer.keys.DeployKey(), deploy)
 
 	if err != nil {
-		return false, err
+		return false, newRedisError(err)
 	}
 
 	result := zremResult.(int64)
+	if result == 0 {
+		return false, nil
+	}
+
+	_, err = redisConn.Do("HSET", deployer.keys.LockKey(deploy), "deploy:timestamp", time.Now().Unix())
+	if err != nil {
+		return false, newRedisError(err)
+	}
+
+	err = deployer.startProcessing(redisConn, deploy)
+	if err != nil {
+		return false, err
+	}
+
+	deployer.events.open(deploy)
+	deployer.events.publish(deploy, EventLocked, "")
+
+	return true, nil
+}
+
+func (deployer *Deployer) requeueDeploy(deploy string, deployAt time.Time) error {
+	debug("requeueDeploy: %v at %v", deploy, deployAt)
+	redisConn := deployer.redisPool.Get()
+	defer redisConn.Close()
+
+	_, err := redisConn.Do("HDEL", deployer.keys.LockKey(deploy), "deploy:timestamp")
+	if err != nil {
+		return newRedisError(err)
+	}
+
+	_, err = redisConn.Do("ZADD", deployer.keys.DeployKey(), deployAt.Unix(), deploy)
+	if err != nil {
+		return newRedisError(err)
+	}
+
+	deployer.events.publish(deploy, EventQueued, fmt.Sprintf("requeued for %v", deployAt.UTC().Format(time.RFC3339)))
+	return nil
+}
+
+func (deployer *Deployer) incrementAttempts(deploy string) (int, error) {
+	redisConn := deployer.redisPool.Get()
+	defer redisConn.Close()
+
+	attempts, err := redis.Int(redisConn.Do("HINCRBY", deployer.keys.MetadataKey(deploy), "deploy:attempts", 1))
+	if err != nil {
+		return 0, newRedisError(err)
+	}
+	return attempts, nil
+}
 
-	return (result != 0), nil
+func (deployer *Deployer) deadLetterDeploy(deploy, reason string) error {
+	debug("deadLetterDeploy: %v (%v)", deploy, reason)
+	redisConn := deployer.redisPool.Get()
+	defer redisConn.Close()
+
+	deployer.telemetry.HSet(deployer.keys.MetadataKey(deploy), "dead-letter:reason", reason)
+	deployer.recordResult(deploy, DeployFailed)
+
+	_, err := redisConn.Do("ZADD", deployer.keys.DLQKey(), time.Now().Unix(), deploy)
+	if err != nil {
+		return newRedisError(err)
+	}
+	return nil
 }
 
 func (deployer *Deployer) validateDeploy(deploy string) (bool, error) {
 	debug("validateDeploy: %v", deploy)
-	existsResult, err := deployer.redisConn.Do("HEXISTS", deployer.getKey(deploy), "cancellation")
+	deployer.events.publish(deploy, EventValidating, "")
 
+	cancelled, err := deployer.isCancelled(deploy)
 	if err != nil {
 		return false, err
 	}
+	return !cancelled, nil
+}
+
+func (deployer *Deployer) isCancelled(deploy string) (bool, error) {
+	redisConn := deployer.redisPool.Get()
+	defer redisConn.Close()
+
+	existsResult, err := redisConn.Do("HEXISTS", deployer.keys.MetadataKey(deploy), "cancellation")
+
+	if err != nil {
+		return false, newRedisError(err)
+	}
 
 	exists := existsResult.(int64)
-	return (exists == 0), nil
+	return (exists != 0), nil
 }
 
 func (deployer *Deployer) getMetadata(deploy string) (*RequestMetadata, error) {
+	metadata, err := deployer.readMetadata(deploy)
+	if err != nil {
+		return nil, err
+	}
+
+	err = deployer.checkSemverTag(metadata)
+	if err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+func (deployer *Deployer) readMetadata(deploy string) (*RequestMetadata, error) {
 	debug("getMetadata: %v", deploy)
 	var metadata RequestMetadata
 
-	metadataBytes, err := deployer.redisConn.Do("HGET", deployer.getKey(deploy), "request:metadata")
+	manifestPath := deployer.getManifestPath(deploy)
+	if manifestPath != "" {
+		debug("loading manifest: %v", manifestPath)
+		err := manifest.Load(manifestPath, &metadata)
+		if err != nil {
+			return nil, newMetadataError("Invalid deploy manifest for '%v': %v", deploy, err)
+		}
+		debug("metadata: %v", sanitize(metadata))
+		return &metadata, nil
+	}
+
+	redisConn := deployer.redisPool.Get()
+	defer redisConn.Close()
+
+	metadataBytes, err := redisConn.Do("HGET", deployer.keys.MetadataKey(deploy), "request:metadata")
 	if err != nil {
-		return nil, err
+		return nil, newRedisError(err)
 	}
 
 	if metadataBytes == nil {
-		return nil, fmt.Errorf("Deploy metadata not found for '%v'", deploy)
+		return nil, newMetadataError("Deploy metadata not found for '%v'", deploy)
+	}
+
+	decoded, err := decodeMetadata(metadataBytes.([]byte))
+	if err != nil {
+		return nil, newMetadataError("Invalid compressed deploy metadata for '%v': %v", deploy, err)
 	}
 
-	err = json.Unmarshal(metadataBytes.([]byte), &metadata)
+	err = json.Unmarshal(decoded, &metadata)
 
 	if err != nil {
-		return nil, err
+		return nil, newMetadataError("Invalid deploy metadata for '%v': %v", deploy, err)
 	}
 
+	debug("metadata: %v", sanitize(metadata))
 	return &metadata, nil
 }
 
-func (deployer *Deployer) getNextValidDeploy() (*RequestMetadata, error) {
-	deploy, err := deployer.getNextDeploy()
+func (deployer *Deployer) getManifestPath(deploy string) string {
+	if deployer.manifestDir == "" {
+		return ""
+	}
+
+	manifestPath := filepath.Join(deployer.manifestDir, deploy+".toml")
+	relativePath, err := filepath.Rel(deployer.manifestDir, manifestPath)
+	if err != nil || strings.HasPrefix(relativePath, "..") {
+		debug("ignoring manifest outside of manifest dir: %v", manifestPath)
+		return ""
+	}
+
+	_, err = os.Stat(manifestPath)
 	if err != nil {
-		return nil, err
+		return ""
+	}
+
+	return manifestPath
+}
+
+func (deployer *Deployer) getNextValidDeploy() (string, *RequestMetadata, error) {
+	deploy, score, err := deployer.getNextDeploy()
+	if err != nil {
+		return "", nil, err
 	}
 
 	if deploy == "" {
-		return nil, nil
+		return "", nil, nil
 	}
 
-	ok, err := deployer.lockDeploy(deploy)
+	ok, err := deployer.preflightDeploy(deploy)
+	if err != nil || !ok {
+		return "", nil, err
+	}
+
+	ok, err = deployer.lockDeploy(deploy)
 	if err != nil {
-		return nil, err
+		return "", nil, err
 	}
 
 	if !ok {
 		debug("Failed to obtain lock for: %v", deploy)
-		return nil, nil
+		return "", nil, nil
 	}
 
 	ok, err = deployer.validateDeploy(deploy)
 	if err != nil {
-		return nil, err
+		return deploy, nil, err
 	}
 
 	if !ok {
 		debug("Deploy was cancelled: %v", deploy)
-		return nil, nil
+		return deploy, nil, nil
 	}
 
-	return deployer.getMetadata(deploy)
+	metadata, err := deployer.getMetadata(deploy)
+	if err != nil {
+		return deploy, nil, err
+	}
+
+	ok, err = deployer.checkScheduledWindow(deploy, score, metadata)
+	if err != nil {
+		return deploy, nil, err
+	}
+
+	if !ok {
+		return deploy, nil, nil
+	}
+
+	ok, err = deployer.checkDependencies(deploy, metadata)
+	if err != nil {
+		return deploy, nil, err
+	}
+
+	if !ok {
+		return deploy, nil, nil
+	}
+
+	return deploy, metadata, nil
 }
 
-func (deployer *Deployer) parseDockerURL(dockerURL string) (string, string, string) {
-	var owner, repo, tag string
-	dockerURLParts := strings.Split(dockerURL, ":")
+// checkScheduledWindow returns true when the deploy may go out now.
+// Otherwise the deploy is requeued for the next window, or sent to the
+// dead letter queue when the window it was scheduled in has closed
+func (deployer *Deployer) checkScheduledWindow(deploy string, score int64, metadata *RequestMetadata) (bool, error) {
+	window := metadata.ScheduledWindow
+	if !window.IsSet() {
+		return true, nil
+	}
 
-	if len(dockerURLParts) != 2 {
-		return "", "", ""
+	err := window.Validate()
+	if err != nil {
+		return false, deployer.deadLetterDeploy(deploy, err.Error())
+	}
+
+	now := time.Now().UTC()
+	if window.Contains(now) {
+		return true, nil
+	}
+
+	if window.Contains(time.Unix(score, 0)) {
+		reason := fmt.Sprintf("WindowExpired: scheduled window %v-%v closed before the deploy went out", window.Start, window.End)
+		return false, deployer.deadLetterDeploy(deploy, reason)
 	}
 
-	if dockerURLParts[1] != "" {
-		tag = dockerURLParts[1]
+	return false, deployer.requeueDeploy(deploy, window.NextStart(now))
+}
+
+func (deployer *Deployer) parseDockerURL(dockerURL string) (string, string, string) {
+	var owner, repo, tag string
+	name := dockerURL
+
+	if at := strings.Index(dockerURL, "@"); at != -1 {
+		name = dockerURL[:at]
+		tag = dockerURL[at+1:]
+	} else {
+		// the registry may have a port, only a colon after the last slash starts the tag
+		colon := strings.LastIndex(dockerURL, ":")
+		if colon == -1 || colon < strings.LastIndex(dockerURL, "/") {
+			return "", "", ""
+		}
+		name = dockerURL[:colon]
+		tag = dockerURL[colon+1:]
 	}
 
-	projectParts := strings.Split(dockerURLParts[0], "/")
+	projectParts := strings.Split(name, "/")
 
 	if len(projectParts) == 2 {
 		owner = projectParts[0]
@@ -220,27 +901,83 @@ func (deployer *Deployer) parseDockerURL(dockerURL string) (string, string, stri
 	return owner, repo, tag
 }
 
-func (deployer *Deployer) notifyDeployState(dockerURL string) error {
-	owner, repo, tag := deployer.parseDockerURL(dockerURL)
+// notifyDeployState marks the deployment as passed or failed on the
+// cluster, unless the deployer only notifies on the other result.
+// With a retry queue, a notification that failed but can be retried
+// is queued for the NotifyRetryWorker instead of returning the error
+func (deployer *Deployer) notifyDeployState(metadata *RequestMetadata, result string) error {
+	if deployer.notifyOnly != "" && deployer.notifyOnly != result {
+		debug("notifyDeployState: skipping %v, only notifying on %v", result, deployer.notifyOnly)
+		return nil
+	}
 
-	uri := fmt.Sprintf("deployments/%s/%s/%s/cluster/%s/passed", owner, repo, tag, deployer.cluster)
-	fullURL := fmt.Sprintf("%s/%s", deployer.deployStateURI, uri)
+	err := deployer.sendDeployState(metadata, result)
+	if err == nil || deployer.deployStateRetryQueue == "" {
+		return err
+	}
 
-	debug("making request to %s", fullURL)
-	client := &http.Client{}
-	request, err := http.NewRequest("PUT", fullURL, nil)
-	if err != nil {
+	if notifyErr, ok := err.(*NotifyError); !ok || !notifyErr.IsRetryable() {
+		return err
+	}
+
+	queueErr := deployer.queueDeployStateRetry(metadata, result)
+	if queueErr != nil {
+		deployer.logger.Println("Error queueing deploy-state retry", queueErr)
 		return err
 	}
-	response, err := client.Do(request)
+
+	deployer.logger.Println("deploy-state notification failed, queued for retry:", err)
+	return nil
+}
+
+// sendDeployState makes the deploy-state request for the result
+func (deployer *Deployer) sendDeployState(metadata *RequestMetadata, result string) error {
+	err := deployer.waitToNotify()
 	if err != nil {
 		return err
 	}
+
+	owner, repo, tag := deployer.parseDockerURL(metadata.DockerURL)
+
+	uri := fmt.Sprintf("deployments/%s/%s/%s/cluster/%s/%s", owner, repo, tag, deployer.cluster, result)
+	deployStateURI, err := deployer.getDeployStateURI()
+	if err != nil {
+		return newNotifyError(0, "deploy-state discovery failed: %v", err)
+	}
+	fullURL := fmt.Sprintf("%s/%s", deployStateURI, uri)
+
+	debug("making request to %s", fullURL)
+	var body io.Reader
+	var bodyBytes []byte
+	if len(metadata.Annotations) > 0 {
+		var err error
+		bodyBytes, err = json.Marshal(map[string]interface{}{"annotations": metadata.Annotations})
+		if err != nil {
+			return newNotifyError(0, "invalid deploy-state request: %v", err)
+		}
+		body = bytes.NewReader(bodyBytes)
+	}
+
+	request, err := http.NewRequest("PUT", fullURL, body)
+	if err != nil {
+		return newNotifyError(0, "invalid deploy-state request: %v", err)
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if metadata.pulledMirror != "" {
+		request.Header.Set("X-Governator-Registry-Mirror", metadata.pulledMirror)
+	}
+	deployer.authorizeDeployStateRequest(request, bodyBytes)
+	response, err := deployer.deployStateClient.Do(request)
+	if err != nil {
+		return newNotifyError(0, "deploy-state request failed: %v", err)
+	}
 	debug("Response StatusCode %v", response.StatusCode)
 
 	response.Body.Close()
 	if response.StatusCode > 399 {
-		return errors.New("invalid response from deploy-state-service")
+		return newNotifyError(response.StatusCode, "invalid response from deploy-state-service")
 	}
 	return nil
 }
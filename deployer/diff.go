@@ -0,0 +1,160 @@
+package deployer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/engine-api/types/swarm"
+)
+
+// SpecChange is a field of a service's spec that a deploy would change.
+// Before and After are empty when the field is unset
+type SpecChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// DiffService returns what deploying metadata to the named service
+// would change in its spec, without updating the service
+func (deployer *Deployer) DiffService(service string, metadata *RequestMetadata) ([]SpecChange, error) {
+	err := deployer.transformImageTag(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	current, proposed, err := deployer.proposeServiceSpec(service, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffServiceSpecs(current.Spec, proposed), nil
+}
+
+// diffServiceSpecs compares the parts of the specs a deploy can change:
+// the image, env, labels, mode, placement constraints, resources,
+// networks and ports
+func diffServiceSpecs(before, after swarm.ServiceSpec) []SpecChange {
+	var changes []SpecChange
+	add := func(field, beforeValue, afterValue string) {
+		if beforeValue != afterValue {
+			changes = append(changes, SpecChange{Field: field, Before: beforeValue, After: afterValue})
+		}
+	}
+
+	beforeContainer := before.TaskTemplate.ContainerSpec
+	afterContainer := after.TaskTemplate.ContainerSpec
+
+	add("image", beforeContainer.Image, afterContainer.Image)
+	changes = append(changes, diffMaps("env.", envToMap(beforeContainer.Env), envToMap(afterContainer.Env))...)
+	changes = append(changes, diffMaps("labels.", before.Labels, after.Labels)...)
+	changes = append(changes, diffMaps("containerLabels.", beforeContainer.Labels, afterContainer.Labels)...)
+	add("mode", formatServiceMode(before.Mode), formatServiceMode(after.Mode))
+	add("placement.constraints", formatConstraints(before.TaskTemplate.Placement), formatConstraints(after.TaskTemplate.Placement))
+
+	beforeLimits, beforeReservations := getResources(before.TaskTemplate.Resources)
+	afterLimits, afterReservations := getResources(after.TaskTemplate.Resources)
+	add("resources.limits", formatResources(beforeLimits), formatResources(afterLimits))
+	add("resources.reservations", formatResources(beforeReservations), formatResources(afterReservations))
+
+	add("networks", formatNetworks(before.TaskTemplate.Networks), formatNetworks(after.TaskTemplate.Networks))
+	add("ports", formatPorts(before.EndpointSpec), formatPorts(after.EndpointSpec))
+	add("endpointMode", formatEndpointMode(before.EndpointSpec), formatEndpointMode(after.EndpointSpec))
+	return changes
+}
+
+func diffMaps(prefix string, before, after map[string]string) []SpecChange {
+	keys := map[string]bool{}
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+
+	var sortedKeys []string
+	for key := range keys {
+		sortedKeys = append(sortedKeys, key)
+	}
+	sort.Strings(sortedKeys)
+
+	var changes []SpecChange
+	for _, key := range sortedKeys {
+		if before[key] != after[key] {
+			changes = append(changes, SpecChange{Field: prefix + key, Before: before[key], After: after[key]})
+		}
+	}
+	return changes
+}
+
+func envToMap(env []string) map[string]string {
+	result := map[string]string{}
+	for _, entry := range env {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) == 2 {
+			result[parts[0]] = parts[1]
+		} else {
+			result[parts[0]] = ""
+		}
+	}
+	return result
+}
+
+func formatServiceMode(mode swarm.ServiceMode) string {
+	if mode.Global != nil {
+		return ServiceModeGlobal
+	}
+	if mode.Replicated != nil && mode.Replicated.Replicas != nil {
+		return fmt.Sprintf("%v (%v replicas)", ServiceModeReplicated, *mode.Replicated.Replicas)
+	}
+	return ServiceModeReplicated
+}
+
+func formatConstraints(placement *swarm.Placement) string {
+	if placement == nil {
+		return ""
+	}
+	return strings.Join(placement.Constraints, ", ")
+}
+
+func getResources(requirements *swarm.ResourceRequirements) (*swarm.Resources, *swarm.Resources) {
+	if requirements == nil {
+		return nil, nil
+	}
+	return requirements.Limits, requirements.Reservations
+}
+
+func formatResources(resources *swarm.Resources) string {
+	if resources == nil || (resources.NanoCPUs == 0 && resources.MemoryBytes == 0) {
+		return ""
+	}
+	return fmt.Sprintf("cpus=%v memory=%vB", float64(resources.NanoCPUs)/1e9, resources.MemoryBytes)
+}
+
+func formatNetworks(networks []swarm.NetworkAttachmentConfig) string {
+	targets := make([]string, len(networks))
+	for i, network := range networks {
+		targets[i] = network.Target
+	}
+	return strings.Join(targets, ", ")
+}
+
+func formatEndpointMode(endpointSpec *swarm.EndpointSpec) string {
+	if endpointSpec == nil {
+		return ""
+	}
+	return string(endpointSpec.Mode)
+}
+
+func formatPorts(endpointSpec *swarm.EndpointSpec) string {
+	if endpointSpec == nil {
+		return ""
+	}
+
+	ports := make([]string, len(endpointSpec.Ports))
+	for i, port := range endpointSpec.Ports {
+		ports[i] = fmt.Sprintf("%v:%v/%v", port.PublishedPort, port.TargetPort, port.Protocol)
+	}
+	return strings.Join(ports, ", ")
+}
@@ -0,0 +1,55 @@
+package deployer
+
+import (
+	"github.com/docker/engine-api/types/swarm"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DiffService", func() {
+	var sut *Deployer
+	var dockerClient *fakeDockerClient
+	var metadata *RequestMetadata
+	var changes []SpecChange
+	var err error
+
+	BeforeEach(func() {
+		dockerClient = &fakeDockerClient{service: swarm.Service{ID: "service-id"}}
+		dockerClient.service.Spec.TaskTemplate.ContainerSpec.Image = "octoblu/my-application:v1"
+		dockerClient.service.Spec.TaskTemplate.ContainerSpec.Env = []string{"GOVERNATOR_CLUSTER=super", "PORT=80"}
+		sut = New(dockerClient, nil, "redis-queue:name", "https://deploy-state.test", "super")
+		metadata = &RequestMetadata{DockerURL: "octoblu/my-application:v1"}
+	})
+
+	JustBeforeEach(func() {
+		changes, err = sut.DiffService("my-application", metadata)
+	})
+
+	Describe("When the deploy wouldn't change anything", func() {
+		It("Should return no changes", func() {
+			Expect(err).To(BeNil())
+			Expect(changes).To(BeEmpty())
+		})
+	})
+
+	Describe("When the deploy changes the image, env and ports", func() {
+		BeforeEach(func() {
+			metadata.DockerURL = "octoblu/my-application:v2"
+			metadata.EnvOverrides = map[string]string{"PORT": "8080"}
+			metadata.PublishedPorts = []PortConfig{{TargetPort: 8080, PublishedPort: 80}}
+		})
+
+		It("Should return each change", func() {
+			Expect(err).To(BeNil())
+			Expect(changes).To(Equal([]SpecChange{
+				{Field: "image", Before: "octoblu/my-application:v1", After: "octoblu/my-application:v2"},
+				{Field: "env.PORT", Before: "80", After: "8080"},
+				{Field: "ports", Before: "", After: "80:8080/tcp"},
+			}))
+		})
+
+		It("Should not update the service", func() {
+			Expect(dockerClient.updates).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,45 @@
+package deployer
+
+import "net"
+
+// maxSearchDomainLength is the longest search domain the resolver takes
+const maxSearchDomainLength = 256
+
+// DNSConfig is the resolver config of the service's containers,
+// with the fields of swarm's DNSConfig
+type DNSConfig struct {
+	Nameservers []string `json:"nameservers"`
+	Search      []string `json:"search"`
+	Options     []string `json:"options"`
+}
+
+func validateDNSConfig(config *DNSConfig) error {
+	for _, nameserver := range config.Nameservers {
+		ip := net.ParseIP(nameserver)
+		if ip == nil || ip.To4() == nil {
+			return newValidationError("Invalid dnsConfig nameserver '%v', expected an IPv4 address", nameserver)
+		}
+	}
+
+	for _, domain := range config.Search {
+		if domain == "" || len(domain) > maxSearchDomainLength {
+			return newValidationError("Invalid dnsConfig search domain '%v', expected 1 to %v characters", domain, maxSearchDomainLength)
+		}
+	}
+	return nil
+}
+
+// applyDNSConfig validates the deploy's DNS config. The vendored docker
+// client has no swarm DNSConfig (it needs API 1.25), so a deploy that
+// sets one is refused rather than deployed with the default resolver
+func applyDNSConfig(metadata *RequestMetadata) error {
+	if metadata.DNSConfig == nil {
+		return nil
+	}
+
+	err := validateDNSConfig(metadata.DNSConfig)
+	if err != nil {
+		return err
+	}
+	return newValidationError("dnsConfig needs docker API 1.25, which this governator's docker client doesn't support")
+}
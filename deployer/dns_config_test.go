@@ -0,0 +1,66 @@
+package deployer
+
+import (
+	"strings"
+
+	"github.com/docker/engine-api/types/swarm"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DNSConfig", func() {
+	var sut *Deployer
+	var dockerClient *fakeDockerClient
+	var metadata *RequestMetadata
+	var err error
+
+	BeforeEach(func() {
+		dockerClient = &fakeDockerClient{service: swarm.Service{ID: "service-id"}}
+		dockerClient.service.Spec.Name = "my-application"
+		sut = New(dockerClient, nil, "redis-queue:name", deployState.URL, "super")
+		metadata = &RequestMetadata{DockerURL: "octoblu/my-application:v2"}
+	})
+
+	JustBeforeEach(func() {
+		err = sut.deploy("my-application:v2", metadata)
+	})
+
+	Describe("When it is not set", func() {
+		It("Should update the service", func() {
+			Expect(err).To(BeNil())
+			Expect(dockerClient.updates).To(HaveLen(1))
+		})
+	})
+
+	Describe("When it is set", func() {
+		BeforeEach(func() {
+			metadata.DNSConfig = &DNSConfig{
+				Nameservers: []string{"10.0.0.2"},
+				Search:      []string{"service.consul"},
+				Options:     []string{"ndots:2"},
+			}
+		})
+
+		It("Should be valid", func() {
+			Expect(validateDNSConfig(metadata.DNSConfig)).To(BeNil())
+		})
+
+		It("Should refuse the deploy, since the docker client can't set it", func() {
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+			Expect(err.Error()).To(ContainSubstring("API 1.25"))
+			Expect(dockerClient.updates).To(BeEmpty())
+		})
+	})
+
+	table.DescribeTable("Should refuse invalid configs",
+		func(config *DNSConfig, message string) {
+			err := validateDNSConfig(config)
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+			Expect(err.Error()).To(ContainSubstring(message))
+		},
+		table.Entry("with a hostname nameserver", &DNSConfig{Nameservers: []string{"dns.example.com"}}, "expected an IPv4 address"),
+		table.Entry("with an IPv6 nameserver", &DNSConfig{Nameservers: []string{"fd00::2"}}, "expected an IPv4 address"),
+		table.Entry("with a long search domain", &DNSConfig{Search: []string{strings.Repeat("a", 257)}}, "expected 1 to 256 characters"),
+	)
+})
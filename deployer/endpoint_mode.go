@@ -0,0 +1,29 @@
+package deployer
+
+import "github.com/docker/engine-api/types/swarm"
+
+func validateEndpointMode(mode string) error {
+	switch swarm.ResolutionMode(mode) {
+	case "", swarm.ResolutionModeVIP, swarm.ResolutionModeDNSRR:
+		return nil
+	}
+	return newValidationError("Invalid endpointMode '%v', expected %v or %v", mode, swarm.ResolutionModeVIP, swarm.ResolutionModeDNSRR)
+}
+
+// setEndpointMode switches the spec's endpoint mode when the deploy
+// has one. It is called after setPublishedPorts, since dnsrr services
+// can't publish ports through the ingress network, which is the only
+// publish mode this deployer's docker client has
+func setEndpointMode(spec *swarm.ServiceSpec, mode string) error {
+	if mode != "" {
+		if spec.EndpointSpec == nil {
+			spec.EndpointSpec = &swarm.EndpointSpec{}
+		}
+		spec.EndpointSpec.Mode = swarm.ResolutionMode(mode)
+	}
+
+	if spec.EndpointSpec == nil || spec.EndpointSpec.Mode != swarm.ResolutionModeDNSRR || len(spec.EndpointSpec.Ports) == 0 {
+		return nil
+	}
+	return newValidationError("endpointMode %v can't be used with published ports (%v), use %v or remove them with an empty publishedPorts", swarm.ResolutionModeDNSRR, formatPorts(spec.EndpointSpec), swarm.ResolutionModeVIP)
+}
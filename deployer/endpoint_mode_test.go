@@ -0,0 +1,103 @@
+package deployer
+
+import (
+	"github.com/docker/engine-api/types/swarm"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("EndpointMode", func() {
+	var sut *Deployer
+	var dockerClient *fakeDockerClient
+	var metadata *RequestMetadata
+	var err error
+
+	BeforeEach(func() {
+		dockerClient = &fakeDockerClient{service: swarm.Service{ID: "service-id"}}
+		dockerClient.service.Spec.Name = "my-application"
+		sut = New(dockerClient, nil, "redis-queue:name", deployState.URL, "super")
+		metadata = &RequestMetadata{DockerURL: "octoblu/my-application:v2"}
+	})
+
+	JustBeforeEach(func() {
+		err = sut.deploy("my-application:v2", metadata)
+	})
+
+	Describe("When the metadata has no endpointMode", func() {
+		It("Should leave the service's endpoint spec alone", func() {
+			Expect(err).To(BeNil())
+			Expect(dockerClient.updates[0].EndpointSpec).To(BeNil())
+		})
+	})
+
+	Describe("When the metadata has endpointMode dnsrr", func() {
+		BeforeEach(func() {
+			metadata.EndpointMode = "dnsrr"
+		})
+
+		It("Should set it before updating the service", func() {
+			Expect(err).To(BeNil())
+			Expect(dockerClient.updates[0].EndpointSpec.Mode).To(Equal(swarm.ResolutionModeDNSRR))
+		})
+
+		Describe("When the service publishes ports", func() {
+			BeforeEach(func() {
+				dockerClient.service.Spec.EndpointSpec = &swarm.EndpointSpec{
+					Mode:  swarm.ResolutionModeVIP,
+					Ports: []swarm.PortConfig{{Protocol: swarm.PortConfigProtocolTCP, TargetPort: 80, PublishedPort: 8080}},
+				}
+			})
+
+			It("Should refuse the deploy", func() {
+				Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+				Expect(err.Error()).To(ContainSubstring("8080:80/tcp"))
+				Expect(dockerClient.updates).To(BeEmpty())
+			})
+
+			Describe("When the metadata removes the ports", func() {
+				BeforeEach(func() {
+					metadata.PublishedPorts = []PortConfig{}
+				})
+
+				It("Should switch the mode", func() {
+					Expect(err).To(BeNil())
+					Expect(dockerClient.updates[0].EndpointSpec.Mode).To(Equal(swarm.ResolutionModeDNSRR))
+					Expect(dockerClient.updates[0].EndpointSpec.Ports).To(BeEmpty())
+				})
+			})
+		})
+	})
+
+	Describe("When a dnsrr service is given publishedPorts", func() {
+		BeforeEach(func() {
+			dockerClient.service.Spec.EndpointSpec = &swarm.EndpointSpec{Mode: swarm.ResolutionModeDNSRR}
+			metadata.PublishedPorts = []PortConfig{{TargetPort: 80, PublishedPort: 8080}}
+		})
+
+		It("Should refuse the deploy", func() {
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+		})
+
+		Describe("When the metadata switches it to vip", func() {
+			BeforeEach(func() {
+				metadata.EndpointMode = "vip"
+			})
+
+			It("Should update the service", func() {
+				Expect(err).To(BeNil())
+				Expect(dockerClient.updates[0].EndpointSpec.Mode).To(Equal(swarm.ResolutionModeVIP))
+			})
+		})
+	})
+
+	Describe("When the endpointMode is unknown", func() {
+		BeforeEach(func() {
+			metadata.EndpointMode = "round-robin"
+		})
+
+		It("Should refuse the deploy", func() {
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+			Expect(dockerClient.updates).To(BeEmpty())
+		})
+	})
+})
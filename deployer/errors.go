@@ -0,0 +1,367 @@
+package deployer
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/docker/engine-api/client"
+)
+
+// DeployError is an error that stopped a deploy from going out.
+// IsRetryable reports whether the deploy may succeed if tried again
+type DeployError interface {
+	error
+	IsRetryable() bool
+}
+
+// DockerAPIError is returned when a call to the docker daemon fails
+type DockerAPIError struct {
+	StatusCode int
+	Err        error
+}
+
+func newDockerAPIError(err error) error {
+	statusCode := 0
+	message := err.Error()
+
+	switch {
+	case err == client.ErrConnectionFailed, strings.HasPrefix(message, "Cannot connect to the Docker daemon"):
+		statusCode = http.StatusServiceUnavailable
+	case strings.Contains(message, http.StatusText(http.StatusServiceUnavailable)):
+		statusCode = http.StatusServiceUnavailable
+	case strings.Contains(message, http.StatusText(http.StatusTooManyRequests)):
+		statusCode = http.StatusTooManyRequests
+	}
+
+	return &DockerAPIError{StatusCode: statusCode, Err: err}
+}
+
+func (err *DockerAPIError) Error() string {
+	return err.Err.Error()
+}
+
+// IsRetryable is true when the daemon was unavailable or rate limiting
+func (err *DockerAPIError) IsRetryable() bool {
+	return err.StatusCode == http.StatusTooManyRequests || err.StatusCode == http.StatusServiceUnavailable
+}
+
+// RedisError is returned when a redis command fails
+type RedisError struct {
+	Err error
+}
+
+func newRedisError(err error) error {
+	return &RedisError{Err: err}
+}
+
+func (err *RedisError) Error() string {
+	return err.Err.Error()
+}
+
+// IsRetryable is always true, redis errors are assumed to be transient
+func (err *RedisError) IsRetryable() bool {
+	return true
+}
+
+// EtcdError is returned when a key could not be set in etcd
+type EtcdError struct {
+	Key string
+	Err error
+}
+
+func (err *EtcdError) Error() string {
+	return err.Err.Error()
+}
+
+// IsRetryable is always true, etcd errors are assumed to be transient
+func (err *EtcdError) IsRetryable() bool {
+	return true
+}
+
+// MetadataError is returned when the deploy metadata is missing or malformed
+type MetadataError struct {
+	Message string
+}
+
+func newMetadataError(format string, args ...interface{}) error {
+	return &MetadataError{Message: fmt.Sprintf(format, args...)}
+}
+
+func (err *MetadataError) Error() string {
+	return err.Message
+}
+
+// IsRetryable is always false, the metadata won't fix itself
+func (err *MetadataError) IsRetryable() bool {
+	return false
+}
+
+// NotifyError is returned when the deploy-state service could not be notified
+type NotifyError struct {
+	StatusCode int
+	Message    string
+}
+
+func newNotifyError(statusCode int, format string, args ...interface{}) error {
+	return &NotifyError{StatusCode: statusCode, Message: fmt.Sprintf(format, args...)}
+}
+
+func (err *NotifyError) Error() string {
+	return err.Message
+}
+
+// IsRetryable is true when the request failed to send,
+// or the deploy-state service returned a 429 or 5xx
+func (err *NotifyError) IsRetryable() bool {
+	return err.StatusCode == 0 || err.StatusCode == http.StatusTooManyRequests || err.StatusCode >= 500
+}
+
+// ValidationError is returned when the deploy metadata describes
+// a deploy that can't be applied to the service
+type ValidationError struct {
+	Message string
+}
+
+func newValidationError(format string, args ...interface{}) error {
+	return &ValidationError{Message: fmt.Sprintf(format, args...)}
+}
+
+func (err *ValidationError) Error() string {
+	return err.Message
+}
+
+// IsRetryable is always false
+func (err *ValidationError) IsRetryable() bool {
+	return false
+}
+
+// PolicyError is returned when a deploy is refused by the deployer's configuration
+type PolicyError struct {
+	Message string
+}
+
+func newPolicyError(format string, args ...interface{}) error {
+	return &PolicyError{Message: fmt.Sprintf(format, args...)}
+}
+
+func (err *PolicyError) Error() string {
+	return err.Message
+}
+
+// IsRetryable is always false
+func (err *PolicyError) IsRetryable() bool {
+	return false
+}
+
+// MissingSecretError is returned when secrets
+// the deploy requires don't exist in the swarm
+type MissingSecretError struct {
+	Service string
+	Secrets []string
+}
+
+func (err *MissingSecretError) Error() string {
+	return fmt.Sprintf("MissingSecret: '%v' requires secrets that don't exist in the swarm: %v", err.Service, strings.Join(err.Secrets, ", "))
+}
+
+// IsRetryable is always false, the secrets need to be created first
+func (err *MissingSecretError) IsRetryable() bool {
+	return false
+}
+
+// StaleImageError is returned when the image was
+// built longer ago than the deployer allows
+type StaleImageError struct {
+	Image   string
+	Created time.Time
+	MaxAge  time.Duration
+}
+
+func (err *StaleImageError) Error() string {
+	return fmt.Sprintf("StaleImage: %v was built %v, more than %v ago", err.Image, err.Created.UTC().Format(time.RFC3339), err.MaxAge)
+}
+
+// IsRetryable is always false, the image needs to be rebuilt
+func (err *StaleImageError) IsRetryable() bool {
+	return false
+}
+
+// InitContainerFailedError is returned when one of the deploy's
+// init containers exited non-zero, or didn't finish in time
+type InitContainerFailedError struct {
+	Image    string
+	ExitCode int
+	Message  string
+}
+
+func (err *InitContainerFailedError) Error() string {
+	if err.Message != "" {
+		return fmt.Sprintf("InitContainerFailed: %v %v", err.Image, err.Message)
+	}
+	return fmt.Sprintf("InitContainerFailed: %v exited with %v", err.Image, err.ExitCode)
+}
+
+// IsRetryable is always false, running it
+// again would most likely fail the same way
+func (err *InitContainerFailedError) IsRetryable() bool {
+	return false
+}
+
+// closeErrorFormat formats the *multierror.Error
+// Close returns when more than one cleanup step failed
+func closeErrorFormat(errs []error) string {
+	messages := make([]string, len(errs))
+	for i, closeErr := range errs {
+		messages[i] = closeErr.Error()
+	}
+	return fmt.Sprintf("%v errors closing the deployer: %v", len(errs), strings.Join(messages, "; "))
+}
+
+// ServiceNotOwnedError is returned when the service is missing
+// one of the labels the deployer was told to require
+type ServiceNotOwnedError struct {
+	Service string
+	Label   string
+}
+
+func (err *ServiceNotOwnedError) Error() string {
+	return fmt.Sprintf("Service '%v' is not owned by this deployer, missing label %v", err.Service, err.Label)
+}
+
+// IsRetryable is always false
+func (err *ServiceNotOwnedError) IsRetryable() bool {
+	return false
+}
+
+// DeployTimeoutError is returned when a deploy took longer than its timeout.
+// Stage describes what the deploy was doing when it ran out of time
+type DeployTimeoutError struct {
+	Deploy  string
+	Service string
+	Stage   string
+	Timeout time.Duration
+}
+
+func (err *DeployTimeoutError) Error() string {
+	return fmt.Sprintf("Deploy '%v' of service '%v' timed out after %v while %v", err.Deploy, err.Service, err.Timeout, err.Stage)
+}
+
+// IsRetryable is always true, the daemon may be faster next time
+func (err *DeployTimeoutError) IsRetryable() bool {
+	return true
+}
+
+// ConvergenceTimeoutError is returned when the service's
+// tasks were not all running the new image within the timeout
+type ConvergenceTimeoutError struct {
+	Service  string
+	Expected uint64
+	Running  uint64
+	Timeout  time.Duration
+}
+
+func (err *ConvergenceTimeoutError) Error() string {
+	return fmt.Sprintf("Service '%v' did not converge after %v, %v of %v tasks running", err.Service, err.Timeout, err.Running, err.Expected)
+}
+
+// IsRetryable is always true, the tasks may start next time
+func (err *ConvergenceTimeoutError) IsRetryable() bool {
+	return true
+}
+
+// TagTransformError is returned when the image tag
+// transforms leave a deploy without a tag
+type TagTransformError struct {
+	DockerURL string
+}
+
+func (err *TagTransformError) Error() string {
+	return fmt.Sprintf("Image tag transforms left '%v' without a tag", err.DockerURL)
+}
+
+// IsRetryable is always false, the transforms will do the same thing again
+func (err *TagTransformError) IsRetryable() bool {
+	return false
+}
+
+// ConcurrencyLimitError is returned when every slot of
+// --global-max-parallelism stayed taken for the whole wait
+type ConcurrencyLimitError struct {
+	Limit int
+	Wait  time.Duration
+}
+
+func (err *ConcurrencyLimitError) Error() string {
+	return fmt.Sprintf("%v deploys were already running after waiting %v", err.Limit, err.Wait)
+}
+
+// IsRetryable is always true, a slot will free up eventually
+func (err *ConcurrencyLimitError) IsRetryable() bool {
+	return true
+}
+
+// DependencyPendingError is returned when a deploy
+// it depends on hasn't passed yet
+type DependencyPendingError struct {
+	Deploy     string
+	Dependency string
+}
+
+func (err *DependencyPendingError) Error() string {
+	return fmt.Sprintf("Deploy '%v' is waiting for '%v' to pass", err.Deploy, err.Dependency)
+}
+
+// IsRetryable is always true, the dependency may still pass
+func (err *DependencyPendingError) IsRetryable() bool {
+	return true
+}
+
+// DependencyFailedError is returned when a deploy it depends on failed
+type DependencyFailedError struct {
+	Deploy     string
+	Dependency string
+}
+
+func (err *DependencyFailedError) Error() string {
+	return fmt.Sprintf("Deploy '%v' depends on '%v', which failed", err.Deploy, err.Dependency)
+}
+
+// IsRetryable is always false, the dependency won't pass anymore
+func (err *DependencyFailedError) IsRetryable() bool {
+	return false
+}
+
+// TaskFailedError is returned when a task of the new
+// spec failed during the deploy window
+type TaskFailedError struct {
+	Service string
+	TaskID  string
+	Message string
+}
+
+func (err *TaskFailedError) Error() string {
+	return fmt.Sprintf("Task %v of service '%v' failed: %v", err.TaskID, err.Service, err.Message)
+}
+
+// IsRetryable is always false, the image is assumed to be broken
+func (err *TaskFailedError) IsRetryable() bool {
+	return false
+}
+
+// NonSemverTagError is returned when the deployer requires
+// semver image tags and the deploy's tag isn't one
+type NonSemverTagError struct {
+	DockerURL string
+	Tag       string
+}
+
+func (err *NonSemverTagError) Error() string {
+	return fmt.Sprintf("NonSemverTag: '%v' of %v is not a semver tag", err.Tag, err.DockerURL)
+}
+
+// IsRetryable is always false, the tag won't change
+func (err *NonSemverTagError) IsRetryable() bool {
+	return false
+}
@@ -0,0 +1,45 @@
+package deployer_test
+
+import (
+	"fmt"
+
+	"github.com/octoblu/governator-swarm/deployer"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DeployError", func() {
+	Describe("DockerAPIError", func() {
+		It("Should be retryable when the daemon is unavailable or rate limiting", func() {
+			Expect((&deployer.DockerAPIError{StatusCode: 503, Err: fmt.Errorf("down")}).IsRetryable()).To(BeTrue())
+			Expect((&deployer.DockerAPIError{StatusCode: 429, Err: fmt.Errorf("slow down")}).IsRetryable()).To(BeTrue())
+		})
+
+		It("Should not be retryable otherwise", func() {
+			Expect((&deployer.DockerAPIError{StatusCode: 404, Err: fmt.Errorf("service not found")}).IsRetryable()).To(BeFalse())
+			Expect((&deployer.DockerAPIError{Err: fmt.Errorf("unknown")}).IsRetryable()).To(BeFalse())
+		})
+	})
+
+	Describe("NotifyError", func() {
+		It("Should be retryable when the request failed or the server errored", func() {
+			Expect((&deployer.NotifyError{}).IsRetryable()).To(BeTrue())
+			Expect((&deployer.NotifyError{StatusCode: 502}).IsRetryable()).To(BeTrue())
+		})
+
+		It("Should not be retryable on a client error", func() {
+			Expect((&deployer.NotifyError{StatusCode: 401}).IsRetryable()).To(BeFalse())
+		})
+	})
+
+	It("Should never retry metadata or policy errors", func() {
+		var metadataError deployer.DeployError = &deployer.MetadataError{Message: "missing"}
+		var policyError deployer.DeployError = &deployer.PolicyError{Message: "refused"}
+		Expect(metadataError.IsRetryable()).To(BeFalse())
+		Expect(policyError.IsRetryable()).To(BeFalse())
+	})
+
+	It("Should always retry redis errors", func() {
+		Expect((&deployer.RedisError{Err: fmt.Errorf("EOF")}).IsRetryable()).To(BeTrue())
+	})
+})
@@ -0,0 +1,92 @@
+package deployer
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// EtcdClient sets keys in etcd
+type EtcdClient interface {
+	Set(key, value string) error
+}
+
+// httpEtcdClient talks to the etcd v2 keys API,
+// trying each endpoint in turn until one answers
+type httpEtcdClient struct {
+	endpoints  []string
+	httpClient *http.Client
+}
+
+// NewEtcdClient constructs an EtcdClient for the
+// etcd v2 keys API at the given endpoints
+func NewEtcdClient(endpoints []string) EtcdClient {
+	return &httpEtcdClient{
+		endpoints:  endpoints,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Set sets the key to value
+func (etcdClient *httpEtcdClient) Set(key, value string) error {
+	if len(etcdClient.endpoints) == 0 {
+		return fmt.Errorf("no etcd endpoints configured")
+	}
+
+	form := url.Values{"value": {value}}
+	var err error
+	for _, endpoint := range etcdClient.endpoints {
+		err = etcdClient.set(endpoint, key, form)
+		if err == nil {
+			return nil
+		}
+		debug("etcd set %v on %v failed: %v", key, endpoint, err)
+	}
+	return err
+}
+
+func (etcdClient *httpEtcdClient) set(endpoint, key string, form url.Values) error {
+	fullURL := fmt.Sprintf("%s/v2/keys/%s", strings.TrimSuffix(endpoint, "/"), strings.TrimPrefix(key, "/"))
+	request, err := http.NewRequest("PUT", fullURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := etcdClient.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+
+	if response.StatusCode > 299 {
+		return fmt.Errorf("etcd returned %v setting %v", response.StatusCode, key)
+	}
+	return nil
+}
+
+// updateEtcd writes the deploy to the service's etcdDir, for
+// services that still read their configuration from etcd
+func (deployer *Deployer) updateEtcd(metadata *RequestMetadata) error {
+	if deployer.etcdClient == nil || metadata.EtcdDir == "" {
+		return nil
+	}
+
+	dir := strings.TrimSuffix(metadata.EtcdDir, "/")
+	values := [][]string{
+		{dir + "/docker_url", metadata.DockerURL},
+		{dir + "/env/SENTRY_RELEASE", deployer.getReleaseVersion(metadata.DockerURL)},
+		{dir + "/restart", fmt.Sprintf("%v", time.Now().Unix())},
+	}
+
+	for _, value := range values {
+		debug("etcd set %v", value[0])
+		err := deployer.etcdClient.Set(value[0], value[1])
+		if err != nil {
+			return &EtcdError{Key: value[0], Err: err}
+		}
+	}
+	return nil
+}
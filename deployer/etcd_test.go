@@ -0,0 +1,100 @@
+package deployer
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/docker/engine-api/types/swarm"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("etcd", func() {
+	Describe("deploy with an etcdDir", func() {
+		var sut *Deployer
+		var etcdClient *fakeEtcdClient
+		var err error
+
+		BeforeEach(func() {
+			dockerClient := &fakeDockerClient{service: swarm.Service{ID: "service-id"}}
+			etcdClient = &fakeEtcdClient{}
+			sut = New(dockerClient, nil, "redis-queue:name", deployState.URL, "super", WithEtcdClient(etcdClient))
+		})
+
+		JustBeforeEach(func() {
+			err = sut.deploy("pending-deploy-1", &RequestMetadata{EtcdDir: "/octoblu/my-application", DockerURL: "octoblu/my-application:v1"})
+		})
+
+		It("Should update the application's docker url", func() {
+			Expect(err).To(BeNil())
+			Expect(etcdClient.SetCalls[0]).To(Equal([]string{"/octoblu/my-application/docker_url", "octoblu/my-application:v1"}))
+		})
+
+		It("Should update the application's sentry release", func() {
+			Expect(etcdClient.SetCalls[1]).To(Equal([]string{"/octoblu/my-application/env/SENTRY_RELEASE", "v1"}))
+		})
+
+		It("Should touch restart", func() {
+			Expect(etcdClient.SetCalls[2][0]).To(Equal("/octoblu/my-application/restart"))
+			Expect(etcdClient.SetCalls[2][1]).NotTo(BeEmpty())
+		})
+
+		Describe("When etcd Set returns an error", func() {
+			BeforeEach(func() {
+				etcdClient.SetError = fmt.Errorf("The server is gone, url is wrong, etc(d)...")
+			})
+
+			It("Should return a retryable error", func() {
+				Expect(err).To(MatchError("The server is gone, url is wrong, etc(d)..."))
+				Expect(err.(DeployError).IsRetryable()).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("NewEtcdClient", func() {
+		var server *httptest.Server
+		var requests []*http.Request
+		var values []string
+
+		BeforeEach(func() {
+			requests = nil
+			values = nil
+			server = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				request.ParseForm()
+				requests = append(requests, request)
+				values = append(values, request.Form.Get("value"))
+				response.WriteHeader(http.StatusCreated)
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("Should PUT the value to the v2 keys API", func() {
+			err := NewEtcdClient([]string{server.URL}).Set("/octoblu/my-application/docker_url", "octoblu/my-application:v1")
+			Expect(err).To(BeNil())
+			Expect(requests).To(HaveLen(1))
+			Expect(requests[0].Method).To(Equal("PUT"))
+			Expect(requests[0].URL.Path).To(Equal("/v2/keys/octoblu/my-application/docker_url"))
+			Expect(values[0]).To(Equal("octoblu/my-application:v1"))
+		})
+
+		It("Should try the next endpoint when one fails", func() {
+			err := NewEtcdClient([]string{"http://127.0.0.1:1", server.URL}).Set("/key", "value")
+			Expect(err).To(BeNil())
+			Expect(requests).To(HaveLen(1))
+		})
+	})
+})
+
+type fakeEtcdClient struct {
+	SetCalls [][]string
+	SetError error
+}
+
+func (etcdClient *fakeEtcdClient) Set(key, value string) error {
+	etcdClient.SetCalls = append(etcdClient.SetCalls, []string{key, value})
+	return etcdClient.SetError
+}
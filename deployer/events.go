@@ -0,0 +1,139 @@
+package deployer
+
+import (
+	"sync"
+	"time"
+)
+
+// Deploy event types, in the order a deploy goes through them
+const (
+	EventQueued         = "queued"
+	EventLocked         = "locked"
+	EventValidating     = "validating"
+	EventPaused         = "paused"
+	EventDeploying      = "deploying"
+	EventHealthchecking = "healthchecking"
+	EventConverging     = "converging"
+	EventSucceeded      = "succeeded"
+	EventFailed         = "failed"
+	EventRolledBack     = "rolled-back"
+)
+
+// DeployEvent is a step in the life of a deploy
+type DeployEvent struct {
+	Deploy    string    `json:"deploy"`
+	Type      string    `json:"type"`
+	Message   string    `json:"message,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// eventBroker fans out the events of in progress deploys to subscribers.
+// A deploy's events are kept from the time it is locked until it completes,
+// so late subscribers see everything that already happened
+type eventBroker struct {
+	lock    sync.Mutex
+	deploys map[string]*deployEvents
+}
+
+type deployEvents struct {
+	history     []DeployEvent
+	subscribers map[chan DeployEvent]bool
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{deploys: map[string]*deployEvents{}}
+}
+
+// open starts collecting events for the deploy
+func (broker *eventBroker) open(deploy string) {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	if _, ok := broker.deploys[deploy]; ok {
+		return
+	}
+	broker.deploys[deploy] = &deployEvents{subscribers: map[chan DeployEvent]bool{}}
+}
+
+// publish sends an event to the deploy's subscribers.
+// Events for deploys that aren't open are dropped
+func (broker *eventBroker) publish(deploy, eventType, message string) {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	events, ok := broker.deploys[deploy]
+	if !ok {
+		return
+	}
+
+	event := DeployEvent{Deploy: deploy, Type: eventType, Message: message, Timestamp: time.Now().UTC()}
+	events.history = append(events.history, event)
+	for subscriber := range events.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			debug("dropping %v event for slow subscriber of %v", eventType, deploy)
+		}
+	}
+}
+
+// close ends the deploy's event stream, closing
+// the channels of everyone subscribed to it
+func (broker *eventBroker) close(deploy string) {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	events, ok := broker.deploys[deploy]
+	if !ok {
+		return
+	}
+
+	for subscriber := range events.subscribers {
+		delete(events.subscribers, subscriber)
+		close(subscriber)
+	}
+	delete(broker.deploys, deploy)
+}
+
+// count returns the number of deploys in progress
+func (broker *eventBroker) count() int {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	return len(broker.deploys)
+}
+
+func (broker *eventBroker) subscribe(deploy string) (<-chan DeployEvent, func(), bool) {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	events, ok := broker.deploys[deploy]
+	if !ok {
+		return nil, nil, false
+	}
+
+	subscriber := make(chan DeployEvent, len(events.history)+16)
+	for _, event := range events.history {
+		subscriber <- event
+	}
+	events.subscribers[subscriber] = true
+
+	unsubscribe := func() {
+		broker.lock.Lock()
+		defer broker.lock.Unlock()
+
+		if events.subscribers[subscriber] {
+			delete(events.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+	return subscriber, unsubscribe, true
+}
+
+// SubscribeEvents returns the events of an in progress deploy, starting
+// with the ones that already happened. The channel is closed when the
+// deploy completes or unsubscribe is called. ok is false when the deploy
+// isn't in progress
+func (deployer *Deployer) SubscribeEvents(deploy string) (events <-chan DeployEvent, unsubscribe func(), ok bool) {
+	return deployer.events.subscribe(deploy)
+}
@@ -0,0 +1,57 @@
+package deployer
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("eventBroker", func() {
+	var broker *eventBroker
+
+	BeforeEach(func() {
+		broker = newEventBroker()
+	})
+
+	Describe("When the deploy isn't open", func() {
+		It("Should not allow subscribing", func() {
+			broker.publish("my-application:v1", EventLocked, "")
+			_, _, ok := broker.subscribe("my-application:v1")
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("When the deploy is open", func() {
+		BeforeEach(func() {
+			broker.open("my-application:v1")
+			broker.publish("my-application:v1", EventLocked, "")
+		})
+
+		It("Should replay earlier events, then stream new ones until closed", func() {
+			events, _, ok := broker.subscribe("my-application:v1")
+			Expect(ok).To(BeTrue())
+
+			broker.publish("my-application:v1", EventDeploying, "octoblu/my-application:v1")
+			broker.close("my-application:v1")
+
+			var types []string
+			for event := range events {
+				types = append(types, event.Type)
+			}
+			Expect(types).To(Equal([]string{EventLocked, EventDeploying}))
+		})
+
+		It("Should forget the deploy once it is closed", func() {
+			broker.close("my-application:v1")
+			_, _, ok := broker.subscribe("my-application:v1")
+			Expect(ok).To(BeFalse())
+		})
+
+		It("Should allow unsubscribing before the deploy closes", func() {
+			events, unsubscribe, _ := broker.subscribe("my-application:v1")
+			unsubscribe()
+			broker.close("my-application:v1")
+
+			Eventually(events).Should(BeClosed())
+		})
+	})
+})
@@ -0,0 +1,41 @@
+package deployer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+)
+
+// fakeDeployState stands in for the deploy-state service of the
+// deployers under test. It answers every request with 200 and
+// records the paths, deploys mark their deployment passed there
+type fakeDeployState struct {
+	*httptest.Server
+
+	lock  sync.Mutex
+	paths []string
+}
+
+func (fake *fakeDeployState) requests() []string {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return append([]string{}, fake.paths...)
+}
+
+var deployState *fakeDeployState
+
+var _ = BeforeEach(func() {
+	fake := &fakeDeployState{}
+	fake.Server = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		fake.lock.Lock()
+		fake.paths = append(fake.paths, request.Method+" "+request.URL.Path)
+		fake.lock.Unlock()
+	}))
+	deployState = fake
+})
+
+var _ = AfterEach(func() {
+	deployState.Close()
+})
@@ -0,0 +1,203 @@
+package deployer
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/docker/engine-api/client"
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/container"
+	"github.com/docker/engine-api/types/network"
+	"github.com/docker/engine-api/types/swarm"
+	"golang.org/x/net/context"
+)
+
+// fakeDockerClient serves a single service from memory.
+// Calls not overridden here panic on the nil APIClient
+type fakeDockerClient struct {
+	client.APIClient
+
+	service swarm.Service
+	updates []swarm.ServiceSpec
+
+	// rejectModeChanges makes ServiceUpdate refuse to change
+	// the service's mode, like docker does
+	rejectModeChanges bool
+	removed           []string
+	created           []swarm.ServiceSpec
+
+	// failingCreates makes that many ServiceCreates fail
+	failingCreates int
+
+	// conflictingUpdates makes that many ServiceUpdates fail with
+	// a conflict, updatingInspects makes that many inspects show
+	// the service being updated
+	conflictingUpdates int
+	updatingInspects   int
+
+	// serviceMissing makes ServiceInspectWithRaw return a not found error
+	serviceMissing bool
+
+	// hangUpdates makes ServiceUpdate apply the spec,
+	// then block until the context is done
+	hangUpdates bool
+
+	images     map[string]types.ImageInspect
+	pulls      []string
+	pullErrors map[string]error
+
+	apiVersion string
+
+	// taskLists are returned by successive calls to TaskList,
+	// the last one is repeated once the rest are used up
+	taskLists [][]swarm.Task
+
+	// taskListErr makes TaskList fail
+	taskListErr error
+
+	networks map[string]types.NetworkResource
+
+	// logs are returned by ContainerLogs, by container ID
+	logs map[string]string
+
+	// containers records the configs passed to ContainerCreate,
+	// the container IDs are their index, exitCodes are by image
+	containers        []container.Config
+	started           []string
+	removedContainers []string
+	exitCodes         map[string]int
+}
+
+func (fake *fakeDockerClient) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, containerName string) (types.ContainerCreateResponse, error) {
+	fake.containers = append(fake.containers, *config)
+	return types.ContainerCreateResponse{ID: strconv.Itoa(len(fake.containers) - 1)}, nil
+}
+
+func (fake *fakeDockerClient) ContainerStart(ctx context.Context, containerID string, options types.ContainerStartOptions) error {
+	fake.started = append(fake.started, containerID)
+	return nil
+}
+
+func (fake *fakeDockerClient) ContainerWait(ctx context.Context, containerID string) (int, error) {
+	index, err := strconv.Atoi(containerID)
+	if err != nil {
+		return 0, err
+	}
+	return fake.exitCodes[fake.containers[index].Image], nil
+}
+
+func (fake *fakeDockerClient) ContainerRemove(ctx context.Context, containerID string, options types.ContainerRemoveOptions) error {
+	fake.removedContainers = append(fake.removedContainers, containerID)
+	return nil
+}
+
+func (fake *fakeDockerClient) ContainerLogs(ctx context.Context, container string, options types.ContainerLogsOptions) (io.ReadCloser, error) {
+	return ioutil.NopCloser(strings.NewReader(fake.logs[container])), nil
+}
+
+func (fake *fakeDockerClient) NetworkInspect(ctx context.Context, networkID string) (types.NetworkResource, error) {
+	network, ok := fake.networks[networkID]
+	if !ok {
+		return network, fakeNotFoundError(fmt.Sprintf("Error: No such network: %v", networkID))
+	}
+	return network, nil
+}
+
+func (fake *fakeDockerClient) TaskList(ctx context.Context, options types.TaskListOptions) ([]swarm.Task, error) {
+	if fake.taskListErr != nil {
+		return nil, fake.taskListErr
+	}
+	if len(fake.taskLists) == 0 {
+		return nil, nil
+	}
+	tasks := fake.taskLists[0]
+	if len(fake.taskLists) > 1 {
+		fake.taskLists = fake.taskLists[1:]
+	}
+	return tasks, nil
+}
+
+func (fake *fakeDockerClient) ServerVersion(ctx context.Context) (types.Version, error) {
+	return types.Version{APIVersion: fake.apiVersion}, nil
+}
+
+func (fake *fakeDockerClient) ImagePull(ctx context.Context, ref string, options types.ImagePullOptions) (io.ReadCloser, error) {
+	fake.pulls = append(fake.pulls, ref)
+	if err, ok := fake.pullErrors[ref]; ok {
+		return nil, err
+	}
+	return ioutil.NopCloser(strings.NewReader(`{"status":"Downloaded newer image"}`)), nil
+}
+
+func (fake *fakeDockerClient) ImageInspectWithRaw(ctx context.Context, image string) (types.ImageInspect, []byte, error) {
+	inspect, ok := fake.images[image]
+	if !ok {
+		return inspect, nil, fakeNotFoundError(fmt.Sprintf("Error: No such image: %v", image))
+	}
+	return inspect, nil, nil
+}
+
+func (fake *fakeDockerClient) ServiceInspectWithRaw(ctx context.Context, serviceID string) (swarm.Service, []byte, error) {
+	if fake.serviceMissing {
+		return swarm.Service{}, nil, fakeNotFoundError(fmt.Sprintf("Error: No such service: %v", serviceID))
+	}
+
+	service := fake.service
+	if fake.updatingInspects > 0 {
+		fake.updatingInspects--
+		service.UpdateStatus.State = swarm.UpdateStateUpdating
+	}
+	return service, nil, nil
+}
+
+func (fake *fakeDockerClient) ServiceUpdate(ctx context.Context, serviceID string, version swarm.Version, spec swarm.ServiceSpec, options types.ServiceUpdateOptions) error {
+	if fake.rejectModeChanges && getServiceMode(spec) != getServiceMode(fake.service.Spec) {
+		return fmt.Errorf("Error response from daemon: rpc error: code = 12 desc = service mode change is not allowed")
+	}
+
+	if fake.conflictingUpdates > 0 {
+		fake.conflictingUpdates--
+		fake.service.Version.Index++
+		return fmt.Errorf("Error response from daemon: rpc error: code = 2 desc = update out of sequence")
+	}
+
+	fake.updates = append(fake.updates, spec)
+	fake.service.Spec = spec
+	fake.service.Version.Index++
+
+	if fake.hangUpdates {
+		fake.hangUpdates = false
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (fake *fakeDockerClient) ServiceRemove(ctx context.Context, serviceID string) error {
+	fake.removed = append(fake.removed, serviceID)
+	return nil
+}
+
+func (fake *fakeDockerClient) ServiceCreate(ctx context.Context, spec swarm.ServiceSpec, options types.ServiceCreateOptions) (types.ServiceCreateResponse, error) {
+	fake.created = append(fake.created, spec)
+	if fake.failingCreates > 0 {
+		fake.failingCreates--
+		return types.ServiceCreateResponse{}, fmt.Errorf("Error response from daemon: rpc error: code = 2 desc = name conflicts with an existing object")
+	}
+
+	fake.service = swarm.Service{ID: "recreated-service-id", Spec: spec}
+	return types.ServiceCreateResponse{ID: fake.service.ID}, nil
+}
+
+type fakeNotFoundError string
+
+func (err fakeNotFoundError) Error() string {
+	return string(err)
+}
+
+func (err fakeNotFoundError) NotFound() bool {
+	return true
+}
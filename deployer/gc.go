@@ -0,0 +1,103 @@
+package deployer
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/octoblu/governator-swarm/telemetry"
+	"golang.org/x/net/context"
+)
+
+// GC deletes the deploy hashes that were taken off the queue
+// more than maxAge ago and returns how many were deleted.
+// Deploys that are still pending are never deleted
+func (deployer *Deployer) GC(ctx context.Context, maxAge time.Duration) (int, error) {
+	redisConn := deployer.redisPool.Get()
+	defer redisConn.Close()
+
+	cutoff := time.Now().Add(-maxAge).Unix()
+	return deployer.deleteDeployKeys(ctx, redisConn, func(key string) (bool, error) {
+		return deployer.isExpiredDeploy(redisConn, key, cutoff)
+	})
+}
+
+// deleteDeployKeys scans the queue's keys, deleting each
+// one shouldDelete is true for, and returns how many it deleted
+func (deployer *Deployer) deleteDeployKeys(ctx context.Context, redisConn redis.Conn, shouldDelete func(key string) (bool, error)) (int, error) {
+	cursor := "0"
+	deleted := 0
+
+	for {
+		select {
+		case <-ctx.Done():
+			return deleted, ctx.Err()
+		default:
+		}
+
+		scanResult, err := redis.Values(redisConn.Do("SCAN", cursor, "MATCH", deployer.getKey("*"), "COUNT", 100))
+		if err != nil {
+			return deleted, err
+		}
+
+		cursor, err = redis.String(scanResult[0], nil)
+		if err != nil {
+			return deleted, err
+		}
+
+		keys, err := redis.Strings(scanResult[1], nil)
+		if err != nil {
+			return deleted, err
+		}
+
+		deletes := telemetry.NewWriter()
+		for _, key := range keys {
+			ok, err := shouldDelete(key)
+			if err != nil {
+				return deleted, err
+			}
+
+			if ok {
+				debug("deleting %v", key)
+				deletes.Del(key)
+			}
+		}
+
+		count := deletes.Len()
+		err = deletes.Flush(redisConn)
+		if err != nil {
+			return deleted, err
+		}
+		deleted += count
+
+		if cursor == "0" {
+			return deleted, nil
+		}
+	}
+}
+
+func (deployer *Deployer) isExpiredDeploy(redisConn redis.Conn, key string, cutoff int64) (bool, error) {
+	keyType, err := redis.String(redisConn.Do("TYPE", key))
+	if err != nil {
+		return false, err
+	}
+
+	if keyType != "hash" {
+		return false, nil
+	}
+
+	timestamp, err := redis.String(redisConn.Do("HGET", key, "deploy:timestamp"))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	deployedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false, nil
+	}
+
+	return deployedAt < cutoff, nil
+}
@@ -0,0 +1,109 @@
+package deployer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rafaeljusto/redigomock"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("GC", func() {
+	var sut *Deployer
+	var redisConn *redigomock.Conn
+	var deleted int
+	var err error
+
+	timestampAgo := func(ago time.Duration) []byte {
+		return []byte(fmt.Sprintf("%v", time.Now().Add(-ago).Unix()))
+	}
+
+	scan := func(cursor, next string, keys ...string) {
+		keyValues := []interface{}{}
+		for _, key := range keys {
+			keyValues = append(keyValues, []byte(key))
+		}
+		redisConn.Command("SCAN", cursor, "MATCH", "redis-queue:name:*", "COUNT", 100).Expect([]interface{}{[]byte(next), keyValues})
+	}
+
+	BeforeEach(func() {
+		redisConn = redigomock.NewConn()
+		redisPool := &redis.Pool{Dial: func() (redis.Conn, error) { return redisConn, nil }}
+		sut = New(nil, redisPool, "redis-queue:name", deployState.URL, "super")
+	})
+
+	JustBeforeEach(func() {
+		deleted, err = sut.GC(context.Background(), time.Hour)
+	})
+
+	Describe("When the queue has expired, fresh and pending deploys, and other keys", func() {
+		var expiredDel, freshDel, pendingDel, otherDel *redigomock.Cmd
+
+		BeforeEach(func() {
+			scan("0", "0",
+				"redis-queue:name:expired:v1",
+				"redis-queue:name:fresh:v1",
+				"redis-queue:name:pending:v1",
+				"redis-queue:name:governator:deploys",
+			)
+
+			redisConn.Command("TYPE", "redis-queue:name:expired:v1").Expect("hash")
+			redisConn.Command("HGET", "redis-queue:name:expired:v1", "deploy:timestamp").Expect(timestampAgo(2 * time.Hour))
+			redisConn.Command("TYPE", "redis-queue:name:fresh:v1").Expect("hash")
+			redisConn.Command("HGET", "redis-queue:name:fresh:v1", "deploy:timestamp").Expect(timestampAgo(time.Minute))
+			redisConn.Command("TYPE", "redis-queue:name:pending:v1").Expect("hash")
+			redisConn.Command("HGET", "redis-queue:name:pending:v1", "deploy:timestamp").Expect(nil)
+			redisConn.Command("TYPE", "redis-queue:name:governator:deploys").Expect("zset")
+
+			expiredDel = redisConn.Command("DEL", "redis-queue:name:expired:v1").Expect(int64(1))
+			freshDel = redisConn.Command("DEL", "redis-queue:name:fresh:v1").Expect(int64(1))
+			pendingDel = redisConn.Command("DEL", "redis-queue:name:pending:v1").Expect(int64(1))
+			otherDel = redisConn.Command("DEL", "redis-queue:name:governator:deploys").Expect(int64(1))
+		})
+
+		It("Should delete only the expired deploy", func() {
+			Expect(err).To(BeNil())
+			Expect(deleted).To(Equal(1))
+			Expect(redisConn.Stats(expiredDel)).To(Equal(1))
+		})
+
+		It("Should keep the deploy taken off the queue within maxAge", func() {
+			Expect(redisConn.Stats(freshDel)).To(Equal(0))
+		})
+
+		It("Should keep the deploy without a deploy:timestamp, which is still pending", func() {
+			Expect(redisConn.Stats(pendingDel)).To(Equal(0))
+		})
+
+		It("Should leave keys that aren't hashes alone", func() {
+			Expect(redisConn.Stats(otherDel)).To(Equal(0))
+		})
+	})
+
+	Describe("When the scan takes more than one page", func() {
+		var firstDel, secondDel *redigomock.Cmd
+
+		BeforeEach(func() {
+			scan("0", "17", "redis-queue:name:first:v1")
+			scan("17", "0", "redis-queue:name:second:v1")
+
+			redisConn.Command("TYPE", "redis-queue:name:first:v1").Expect("hash")
+			redisConn.Command("HGET", "redis-queue:name:first:v1", "deploy:timestamp").Expect(timestampAgo(2 * time.Hour))
+			redisConn.Command("TYPE", "redis-queue:name:second:v1").Expect("hash")
+			redisConn.Command("HGET", "redis-queue:name:second:v1", "deploy:timestamp").Expect(timestampAgo(3 * time.Hour))
+
+			firstDel = redisConn.Command("DEL", "redis-queue:name:first:v1").Expect(int64(1))
+			secondDel = redisConn.Command("DEL", "redis-queue:name:second:v1").Expect(int64(1))
+		})
+
+		It("Should follow the cursor and delete the expired deploys on every page", func() {
+			Expect(err).To(BeNil())
+			Expect(deleted).To(Equal(2))
+			Expect(redisConn.Stats(firstDel)).To(Equal(1))
+			Expect(redisConn.Stats(secondDel)).To(Equal(1))
+		})
+	})
+})
@@ -0,0 +1,72 @@
+package deployer
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// stuckRunGrace is added to the longest a Run could
+// legitimately take before Live reports it as stuck
+const stuckRunGrace = time.Minute
+
+// Live returns an error when a Run has been going for longer than
+// any deploy could take, which means the deploy loop is stuck.
+// Without a deploy timeout no Run is ever considered stuck
+func (deployer *Deployer) Live() error {
+	deployer.runLock.Lock()
+	runningSince := deployer.runningSince
+	deployer.runLock.Unlock()
+
+	if runningSince.IsZero() || deployer.deployTimeout <= 0 {
+		return nil
+	}
+
+	limit := deployer.deployTimeout + deployer.deployWindow + deployer.minHealthyPeriod + deployer.concurrencyWait + stuckRunGrace
+	running := time.Since(runningSince)
+	if running > limit {
+		return fmt.Errorf("Run has been going for %v, longer than %v", running, limit)
+	}
+	return nil
+}
+
+// Ready returns an error when redis or docker can't be reached
+// before ctx is done
+func (deployer *Deployer) Ready(ctx context.Context) error {
+	redisErrors := make(chan error, 1)
+	go func() {
+		redisConn := deployer.redisPool.Get()
+		defer redisConn.Close()
+
+		_, err := redisConn.Do("PING")
+		redisErrors <- err
+	}()
+
+	select {
+	case err := <-redisErrors:
+		if err != nil {
+			return fmt.Errorf("redis: %v", err)
+		}
+	case <-ctx.Done():
+		return fmt.Errorf("redis: %v", ctx.Err())
+	}
+
+	_, err := deployer.dockerClient.ServerVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("docker: %v", err)
+	}
+	return nil
+}
+
+func (deployer *Deployer) startRun() {
+	deployer.runLock.Lock()
+	defer deployer.runLock.Unlock()
+	deployer.runningSince = time.Now()
+}
+
+func (deployer *Deployer) finishRun() {
+	deployer.runLock.Lock()
+	defer deployer.runLock.Unlock()
+	deployer.runningSince = time.Time{}
+}
@@ -0,0 +1,107 @@
+package deployer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/swarm"
+	"golang.org/x/net/context"
+)
+
+// DefaultDrainWait is how long the load balancer is given to stop
+// sending traffic to a draining service, for the flag of the same name
+const DefaultDrainWait = 5 * time.Second
+
+// undrainTimeout bounds restoring the health routing label,
+// which happens after the deploy's own timeout may have passed
+const undrainTimeout = 30 * time.Second
+
+// ParseHealthRoutingLabel parses the key=value label that marks a
+// service as draining, e.g. traefik.enable=false
+func ParseHealthRoutingLabel(label string) (string, string, error) {
+	parts := strings.SplitN(label, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", "", fmt.Errorf("expected key=value, got '%v'", label)
+	}
+	return parts[0], parts[1], nil
+}
+
+// drainService sets the health routing label on the service, as it is
+// deployed now, so the load balancer stops sending it traffic, then
+// waits for the drain wait. service's version and spec are updated
+// so the deploy's ServiceUpdate keeps the label. drained is false
+// when the deployer has no health routing label
+func (deployer *Deployer) drainService(ctx context.Context, service *swarm.Service, previousSpec swarm.ServiceSpec) (bool, error) {
+	if deployer.healthRoutingKey == "" {
+		return false, nil
+	}
+
+	drainSpec, err := copyServiceSpec(previousSpec)
+	if err != nil {
+		return false, err
+	}
+	setServiceLabel(&drainSpec, deployer.healthRoutingKey, deployer.healthRoutingValue)
+
+	err = deployer.dockerClient.ServiceUpdate(ctx, service.ID, service.Version, drainSpec, types.ServiceUpdateOptions{})
+	if err != nil {
+		return false, newDockerAPIError(err)
+	}
+
+	drained, _, err := deployer.dockerClient.ServiceInspectWithRaw(ctx, service.ID)
+	if err != nil {
+		return true, newDockerAPIError(err)
+	}
+	service.Version = drained.Version
+	setServiceLabel(&service.Spec, deployer.healthRoutingKey, deployer.healthRoutingValue)
+
+	deployer.logger.Println("Draining", service.Spec.Name, "for", deployer.drainWait)
+	if !sleepContext(ctx, deployer.drainWait) {
+		return true, ctx.Err()
+	}
+	return true, nil
+}
+
+// undrainService puts the health routing label back the way it was
+// in previousSpec, once the deploy is done, whether it passed or not
+func (deployer *Deployer) undrainService(service string, previousSpec swarm.ServiceSpec) error {
+	ctx, cancel := context.WithTimeout(context.Background(), undrainTimeout)
+	defer cancel()
+
+	current, _, err := deployer.dockerClient.ServiceInspectWithRaw(ctx, service)
+	if err != nil {
+		return newDockerAPIError(err)
+	}
+
+	key := deployer.healthRoutingKey
+	previousValue, hadLabel := previousSpec.Labels[key]
+	currentValue, hasLabel := current.Spec.Labels[key]
+	if hadLabel == hasLabel && previousValue == currentValue {
+		return nil
+	}
+
+	labels := map[string]string{}
+	for labelKey, value := range current.Spec.Labels {
+		labels[labelKey] = value
+	}
+	if hadLabel {
+		labels[key] = previousValue
+	} else {
+		delete(labels, key)
+	}
+	current.Spec.Labels = labels
+
+	err = deployer.dockerClient.ServiceUpdate(ctx, current.ID, current.Version, current.Spec, types.ServiceUpdateOptions{})
+	if err != nil {
+		return newDockerAPIError(err)
+	}
+	return nil
+}
+
+func setServiceLabel(spec *swarm.ServiceSpec, key, value string) {
+	if spec.Labels == nil {
+		spec.Labels = map[string]string{}
+	}
+	spec.Labels[key] = value
+}
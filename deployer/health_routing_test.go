@@ -0,0 +1,97 @@
+package deployer
+
+import (
+	"time"
+
+	"github.com/docker/engine-api/types/swarm"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HealthRouting", func() {
+	var sut *Deployer
+	var dockerClient *fakeDockerClient
+	var metadata *RequestMetadata
+	var err error
+
+	BeforeEach(func() {
+		replicas := uint64(2)
+		dockerClient = &fakeDockerClient{service: swarm.Service{ID: "service-id"}}
+		dockerClient.service.Spec.Name = "my-application"
+		dockerClient.service.Spec.Labels = map[string]string{"traefik.enable": "true"}
+		dockerClient.service.Spec.Mode.Replicated = &swarm.ReplicatedService{Replicas: &replicas}
+		dockerClient.service.Spec.TaskTemplate.ContainerSpec.Image = "octoblu/my-application:v1"
+		sut = New(dockerClient, nil, "redis-queue:name", deployState.URL, "super", WithHealthRouting("traefik.enable", "false", time.Millisecond))
+		metadata = &RequestMetadata{DockerURL: "octoblu/my-application:v2"}
+	})
+
+	JustBeforeEach(func() {
+		err = sut.deploy("my-application:v2", metadata)
+	})
+
+	It("Should drain the service before updating it", func() {
+		Expect(err).To(BeNil())
+		Expect(dockerClient.updates).To(HaveLen(3))
+
+		drain := dockerClient.updates[0]
+		Expect(drain.Labels).To(HaveKeyWithValue("traefik.enable", "false"))
+		Expect(drain.TaskTemplate.ContainerSpec.Image).To(Equal("octoblu/my-application:v1"))
+
+		update := dockerClient.updates[1]
+		Expect(update.Labels).To(HaveKeyWithValue("traefik.enable", "false"))
+		Expect(update.TaskTemplate.ContainerSpec.Image).To(Equal("octoblu/my-application:v2"))
+	})
+
+	It("Should restore the label once the deploy is done", func() {
+		Expect(dockerClient.service.Spec.Labels).To(HaveKeyWithValue("traefik.enable", "true"))
+		Expect(dockerClient.service.Spec.TaskTemplate.ContainerSpec.Image).To(Equal("octoblu/my-application:v2"))
+	})
+
+	Describe("When the service didn't have the label", func() {
+		BeforeEach(func() {
+			dockerClient.service.Spec.Labels = nil
+		})
+
+		It("Should remove it once the deploy is done", func() {
+			Expect(err).To(BeNil())
+			Expect(dockerClient.service.Spec.Labels).NotTo(HaveKey("traefik.enable"))
+		})
+	})
+
+	Describe("When the update fails", func() {
+		BeforeEach(func() {
+			dockerClient.rejectModeChanges = true
+			metadata.ServiceMode = ServiceModeGlobal
+		})
+
+		It("Should revert the label", func() {
+			Expect(err).To(BeAssignableToTypeOf(&DockerAPIError{}))
+			Expect(dockerClient.service.Spec.Labels).To(HaveKeyWithValue("traefik.enable", "true"))
+		})
+	})
+
+	Describe("When health routing is off", func() {
+		BeforeEach(func() {
+			sut = New(dockerClient, nil, "redis-queue:name", deployState.URL, "super")
+		})
+
+		It("Should update the service once", func() {
+			Expect(err).To(BeNil())
+			Expect(dockerClient.updates).To(HaveLen(1))
+		})
+	})
+
+	Describe("ParseHealthRoutingLabel", func() {
+		It("Should parse key=value", func() {
+			key, value, err := ParseHealthRoutingLabel("health=draining")
+			Expect(err).To(BeNil())
+			Expect(key).To(Equal("health"))
+			Expect(value).To(Equal("draining"))
+		})
+
+		It("Should reject a label without a value", func() {
+			_, _, err := ParseHealthRoutingLabel("draining")
+			Expect(err).NotTo(BeNil())
+		})
+	})
+})
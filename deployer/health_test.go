@@ -0,0 +1,51 @@
+package deployer
+
+import (
+	"time"
+
+	"github.com/docker/engine-api/types/swarm"
+	"github.com/garyburd/redigo/redis"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rafaeljusto/redigomock"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("health", func() {
+	var sut *Deployer
+	var redisConn *redigomock.Conn
+
+	BeforeEach(func() {
+		redisConn = redigomock.NewConn()
+		redisPool := &redis.Pool{Dial: func() (redis.Conn, error) { return redisConn, nil }}
+		sut = New(&fakeDockerClient{service: swarm.Service{ID: "service-id"}}, redisPool, "redis-queue:name", "https://deploy-state.test", "super", WithDeployTimeout(time.Minute))
+	})
+
+	Describe("Live", func() {
+		It("Should be live when no Run is going", func() {
+			Expect(sut.Live()).To(Succeed())
+		})
+
+		It("Should be live while a Run is within the deploy timeout", func() {
+			sut.startRun()
+			Expect(sut.Live()).To(Succeed())
+		})
+
+		It("Should not be live when a Run has been going too long", func() {
+			sut.runningSince = time.Now().Add(-time.Hour)
+			Expect(sut.Live()).NotTo(Succeed())
+		})
+	})
+
+	Describe("Ready", func() {
+		It("Should be ready when redis answers PING", func() {
+			redisConn.Command("PING").Expect("PONG")
+			Expect(sut.Ready(context.Background())).To(Succeed())
+		})
+
+		It("Should not be ready when redis doesn't", func() {
+			redisConn.Command("PING").ExpectError(redis.ErrNil)
+			Expect(sut.Ready(context.Background())).To(MatchError(ContainSubstring("redis:")))
+		})
+	})
+})
@@ -0,0 +1,177 @@
+package deployer
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/octoblu/governator-swarm/notifier"
+)
+
+// maxHistory is how many DeployRecords are kept in redis
+const maxHistory = 100
+
+// Deploy results, as recorded in the history and sent to deploy-state
+const (
+	DeployPassed = "passed"
+	DeployFailed = "failed"
+)
+
+// DeployRecord describes a single deploy attempt
+type DeployRecord struct {
+	Deploy      string            `json:"deploy"`
+	Service     string            `json:"service"`
+	Image       string            `json:"image"`
+	Mirror      string            `json:"mirror,omitempty"`
+	Cluster     string            `json:"cluster"`
+	Initiator   string            `json:"initiator,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Result      string            `json:"result"`
+	Error       string            `json:"error,omitempty"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Duration    float64           `json:"durationSeconds"`
+}
+
+// recordDeploy writes the outcome of a deploy to the audit log, when
+// there is one, queues it for the deploy history, which is written at
+// the end of the Run, and sends it to the notifiers. Failing to write
+// is logged rather than failing the deploy
+func (deployer *Deployer) recordDeploy(deploy, service string, metadata *RequestMetadata, start time.Time, deployErr error) {
+	record := &DeployRecord{
+		Deploy:      deploy,
+		Service:     service,
+		Image:       metadata.DockerURL,
+		Mirror:      metadata.pulledMirror,
+		Cluster:     deployer.cluster,
+		Initiator:   metadata.Annotations["initiator"],
+		Annotations: metadata.Annotations,
+		Result:      DeployPassed,
+		Timestamp:   start.UTC(),
+		Duration:    time.Since(start).Seconds(),
+	}
+	if deployErr != nil {
+		record.Result = DeployFailed
+		record.Error = deployErr.Error()
+	}
+
+	if deployer.redisPool != nil {
+		err := deployer.pushHistory(record)
+		if err != nil {
+			deployer.logger.Println("Error writing deploy history", err)
+		}
+	}
+
+	if deployer.auditLog != nil {
+		err := deployer.auditLog.Write(record)
+		if err != nil {
+			deployer.logger.Println("Error writing audit log", err)
+		}
+	}
+
+	deployer.notify(record, metadata)
+}
+
+func (deployer *Deployer) notify(record *DeployRecord, metadata *RequestMetadata) {
+	if len(deployer.notifiers) == 0 && len(metadata.NotifyChannels) == 0 {
+		return
+	}
+
+	owner, repo, tag := deployer.parseDockerURL(metadata.DockerURL)
+	deployment := &notifier.Deployment{
+		Service:  record.Service,
+		Image:    record.Image,
+		Cluster:  record.Cluster,
+		Passed:   record.Result == DeployPassed,
+		Error:    record.Error,
+		Duration: time.Duration(record.Duration * float64(time.Second)),
+		URL:      fmt.Sprintf("%s/deployments/%s/%s/%s", deployer.deployStateURI, owner, repo, tag),
+
+		PagerDutyService:  metadata.PagerDutyService,
+		PagerDutySeverity: metadata.PagerDutySeverity,
+	}
+
+	for _, deployNotifier := range deployer.notifiers {
+		err := deployNotifier.Notify(deployment)
+		if err != nil {
+			deployer.logger.Println("Error sending deploy notification", err)
+		}
+	}
+
+	for _, channel := range metadata.NotifyChannels {
+		channelNotifier, ok := deployer.getNotifyChannel(channel)
+		if !ok {
+			deployer.logger.Println("WARNING: unknown notify channel", channel, "for", record.Deploy)
+			continue
+		}
+
+		err := channelNotifier.Notify(deployment)
+		if err != nil {
+			deployer.logger.Println("Error sending deploy notification to", channel, err)
+		}
+	}
+}
+
+func (deployer *Deployer) pushHistory(record *DeployRecord) error {
+	recordBytes, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	historyKey := deployer.getKey("governator:history")
+	deployer.telemetry.LPush(historyKey, recordBytes)
+	deployer.telemetry.LTrim(historyKey, 0, maxHistory-1)
+	return nil
+}
+
+// flushTelemetry sends the history and dead letter writes queued
+// during a Run in one round trip. Failing to send them is logged
+func (deployer *Deployer) flushTelemetry() {
+	if deployer.redisPool == nil || deployer.telemetry.Len() == 0 {
+		return
+	}
+
+	redisConn := deployer.redisPool.Get()
+	defer redisConn.Close()
+
+	err := deployer.telemetry.Flush(redisConn)
+	if err != nil {
+		deployer.logger.Println("Error writing telemetry", err)
+	}
+}
+
+// GetHistory returns up to count of the most recent deploys, newest first
+func (deployer *Deployer) GetHistory(count int) ([]DeployRecord, error) {
+	redisConn := deployer.redisPool.Get()
+	defer redisConn.Close()
+
+	return readHistory(redisConn, deployer.getKey("governator:history"), count)
+}
+
+// ReadHistory returns the whole deploy history of a queue, newest first,
+// for the subcommands that don't run a Deployer
+func ReadHistory(redisPool *redis.Pool, keys *DefaultKeyBuilder) ([]DeployRecord, error) {
+	redisConn := redisPool.Get()
+	defer redisConn.Close()
+
+	return readHistory(redisConn, keys.key("governator:history"), maxHistory)
+}
+
+func readHistory(redisConn redis.Conn, historyKey string, count int) ([]DeployRecord, error) {
+	values, err := redis.ByteSlices(redisConn.Do("LRANGE", historyKey, 0, count-1))
+	if err != nil {
+		return nil, newRedisError(err)
+	}
+
+	records := []DeployRecord{}
+	for _, value := range values {
+		var record DeployRecord
+		err = json.Unmarshal(value, &record)
+		if err != nil {
+			debug("skipping invalid history record: %v", err)
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
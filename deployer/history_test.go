@@ -0,0 +1,50 @@
+package deployer
+
+import (
+	"time"
+
+	"github.com/octoblu/governator-swarm/notifier"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("notify", func() {
+	var sut *Deployer
+	var payments *fakeNotifier
+	var logger *fakeLogger
+	var metadata *RequestMetadata
+
+	BeforeEach(func() {
+		payments = &fakeNotifier{}
+		logger = &fakeLogger{}
+		sut = New(nil, nil, "redis-queue:name", "https://deploy-state.test", "super", WithLogger(logger), WithNotifyChannels(notifier.Channels{"payments-oncall": payments}))
+		metadata = &RequestMetadata{DockerURL: "octoblu/my-application:v2", NotifyChannels: []string{"payments-oncall"}}
+	})
+
+	It("Should send the deploy to its channels", func() {
+		sut.recordDeploy("my-application:v2", "my-application", metadata, time.Now(), nil)
+		Expect(payments.deployments).To(HaveLen(1))
+		Expect(payments.deployments[0].Passed).To(BeTrue())
+		Expect(payments.deployments[0].URL).To(Equal("https://deploy-state.test/deployments/octoblu/my-application/v2"))
+	})
+
+	Describe("When a channel isn't in the map", func() {
+		It("Should warn and still send to the others", func() {
+			metadata.NotifyChannels = []string{"search-oncall", "payments-oncall"}
+			sut.recordDeploy("my-application:v2", "my-application", metadata, time.Now(), nil)
+			Expect(payments.deployments).To(HaveLen(1))
+			Expect(logger.lines).To(HaveLen(1))
+			Expect(logger.lines[0]).To(ContainSubstring("search-oncall"))
+		})
+	})
+})
+
+// fakeNotifier keeps the deployments it was sent
+type fakeNotifier struct {
+	deployments []*notifier.Deployment
+}
+
+func (fake *fakeNotifier) Notify(deployment *notifier.Deployment) error {
+	fake.deployments = append(fake.deployments, deployment)
+	return nil
+}
@@ -0,0 +1,75 @@
+package deployer
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/docker/engine-api/client"
+	"github.com/docker/engine-api/types"
+	"golang.org/x/net/context"
+)
+
+// checkImageAge returns a StaleImageError when the image was built
+// longer than maxImageAge ago. The image is pulled on the manager
+// when it isn't there already, to read its creation time
+func (deployer *Deployer) checkImageAge(ctx context.Context, image string) error {
+	if deployer.maxImageAge <= 0 {
+		return nil
+	}
+
+	if deployer.maxImageAgeSkipDigests && strings.Contains(image, "@") {
+		debug("checkImageAge: skipping digest pinned %v", image)
+		return nil
+	}
+
+	created, err := deployer.getImageCreated(ctx, image)
+	if err != nil {
+		return err
+	}
+
+	age := time.Since(created)
+	if age > deployer.maxImageAge {
+		return &StaleImageError{Image: image, Created: created, MaxAge: deployer.maxImageAge}
+	}
+	return nil
+}
+
+func (deployer *Deployer) getImageCreated(ctx context.Context, image string) (time.Time, error) {
+	inspect, err := deployer.inspectOrPullImage(ctx, image)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	created, err := time.Parse(time.RFC3339Nano, inspect.Created)
+	if err != nil {
+		return time.Time{}, newValidationError("Invalid creation time '%v' of %v", inspect.Created, image)
+	}
+	return created, nil
+}
+
+// inspectOrPullImage inspects the image on the manager,
+// pulling it first when it isn't there already
+func (deployer *Deployer) inspectOrPullImage(ctx context.Context, image string) (types.ImageInspect, error) {
+	inspect, _, err := deployer.dockerClient.ImageInspectWithRaw(ctx, image)
+	if client.IsErrImageNotFound(err) {
+		debug("inspectOrPullImage: pulling %v", image)
+		var reader io.ReadCloser
+		reader, err = deployer.dockerClient.ImagePull(ctx, image, types.ImagePullOptions{})
+		if err != nil {
+			return inspect, newDockerAPIError(err)
+		}
+		_, err = io.Copy(ioutil.Discard, reader)
+		reader.Close()
+		if err != nil {
+			return inspect, newDockerAPIError(err)
+		}
+
+		inspect, _, err = deployer.dockerClient.ImageInspectWithRaw(ctx, image)
+	}
+	if err != nil {
+		return inspect, newDockerAPIError(err)
+	}
+	return inspect, nil
+}
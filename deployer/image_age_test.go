@@ -0,0 +1,50 @@
+package deployer
+
+import (
+	"time"
+
+	"github.com/docker/engine-api/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("checkImageAge", func() {
+	var sut *Deployer
+	var dockerClient *fakeDockerClient
+
+	BeforeEach(func() {
+		dockerClient = &fakeDockerClient{images: map[string]types.ImageInspect{}}
+		sut = New(dockerClient, nil, "redis-queue:name", "https://deploy-state.test", "super", WithMaxImageAge(168*time.Hour, true))
+	})
+
+	setCreated := func(image string, age time.Duration) {
+		dockerClient.images[image] = types.ImageInspect{Created: time.Now().Add(-age).Format(time.RFC3339Nano)}
+	}
+
+	It("Should allow an image built within the max age", func() {
+		setCreated("octoblu/my-application:v2", 24*time.Hour)
+		Expect(sut.checkImageAge(context.Background(), "octoblu/my-application:v2")).To(Succeed())
+		Expect(dockerClient.pulls).To(BeEmpty())
+	})
+
+	It("Should refuse an image built before the max age", func() {
+		setCreated("octoblu/my-application:v2", 200*time.Hour)
+		err := sut.checkImageAge(context.Background(), "octoblu/my-application:v2")
+		Expect(err).To(BeAssignableToTypeOf(&StaleImageError{}))
+		Expect(err.(*StaleImageError).IsRetryable()).To(BeFalse())
+	})
+
+	It("Should skip digest pinned images", func() {
+		Expect(sut.checkImageAge(context.Background(), "octoblu/my-application@sha256:abc")).To(Succeed())
+		Expect(dockerClient.pulls).To(BeEmpty())
+	})
+
+	Describe("When the image isn't on the manager", func() {
+		It("Should pull it to read its creation time", func() {
+			err := sut.checkImageAge(context.Background(), "octoblu/my-application:v2")
+			Expect(err).NotTo(BeNil())
+			Expect(dockerClient.pulls).To(Equal([]string{"octoblu/my-application:v2"}))
+		})
+	})
+})
@@ -0,0 +1,89 @@
+package deployer
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// distributionAPIVersion is the first docker API
+// version with the distribution inspect endpoint
+const distributionAPIVersion = "1.30"
+
+var imageDigestPattern = regexp.MustCompile("^sha256:[a-f0-9]{64}$")
+
+func validateImageDigest(digest string) error {
+	if digest == "" || imageDigestPattern.MatchString(digest) {
+		return nil
+	}
+	return newValidationError("Invalid imageDigest '%v', expected sha256:<64 hex characters>", digest)
+}
+
+// pinImageDigest replaces the tag, or digest, of image with digest
+func pinImageDigest(image, digest string) string {
+	name := strings.SplitN(image, "@", 2)[0]
+	if colon := strings.LastIndex(name, ":"); colon > strings.LastIndex(name, "/") {
+		name = name[:colon]
+	}
+	return name + "@" + digest
+}
+
+// DigestResolver looks up the registry digest an image's tag points to
+type DigestResolver interface {
+	ResolveDigest(ctx context.Context, image string) (string, error)
+}
+
+// verifyImageDigest warns when the deploy's imageDigest isn't what the
+// tag of its dockerUrl points to. The digest is deployed either way
+func (deployer *Deployer) verifyImageDigest(ctx context.Context, metadata *RequestMetadata) {
+	if deployer.digestResolver == nil || metadata.ImageDigest == "" {
+		return
+	}
+
+	digest, err := deployer.digestResolver.ResolveDigest(ctx, metadata.DockerURL)
+	if err != nil {
+		deployer.logger.Println("Error verifying the digest of", metadata.DockerURL, err)
+		return
+	}
+
+	if digest != metadata.ImageDigest {
+		deployer.logger.Println("WARNING:", metadata.DockerURL, "points to", digest, "not the imageDigest", metadata.ImageDigest)
+	}
+}
+
+// httpDigestResolver asks the daemon to inspect the image in its
+// registry, the vendored docker client predates DistributionInspect
+type httpDigestResolver struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewHTTPDigestResolver constructs a DigestResolver for the
+// daemon at dockerURI, e.g. unix:///var/run/docker.sock
+func NewHTTPDigestResolver(dockerURI string) (DigestResolver, error) {
+	baseURL, httpClient, err := newDaemonHTTPClient(dockerURI)
+	if err != nil {
+		return nil, err
+	}
+
+	return &httpDigestResolver{
+		baseURL:    baseURL,
+		httpClient: httpClient,
+	}, nil
+}
+
+// ResolveDigest returns the digest of image in its registry
+func (resolver *httpDigestResolver) ResolveDigest(ctx context.Context, image string) (string, error) {
+	var inspect struct {
+		Descriptor struct {
+			Digest string
+		}
+	}
+	err := daemonGet(ctx, resolver.httpClient, resolver.baseURL, distributionAPIVersion, "/distribution/"+image+"/json", &inspect)
+	if err != nil {
+		return "", err
+	}
+	return inspect.Descriptor.Digest, nil
+}
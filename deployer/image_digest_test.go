@@ -0,0 +1,82 @@
+package deployer
+
+import (
+	"strings"
+
+	"github.com/docker/engine-api/types/swarm"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("imageDigest", func() {
+	var sut *Deployer
+	var spec *swarm.ServiceSpec
+	var metadata *RequestMetadata
+	var digest string
+
+	BeforeEach(func() {
+		sut = New(nil, nil, "redis-queue:name", "https://deploy-state.test", "super")
+		spec = &swarm.ServiceSpec{}
+		digest = "sha256:" + strings.Repeat("ab", 32)
+		metadata = &RequestMetadata{DockerURL: "registry.example.com:5000/octoblu/my-application:v2", ImageDigest: digest}
+	})
+
+	It("Should pin the image to the digest instead of the tag", func() {
+		err := sut.updateServiceSpec(spec, metadata)
+		Expect(err).To(BeNil())
+		Expect(spec.TaskTemplate.ContainerSpec.Image).To(Equal("registry.example.com:5000/octoblu/my-application@" + digest))
+	})
+
+	Describe("When the digest is invalid", func() {
+		It("Should return a validation error", func() {
+			metadata.ImageDigest = "sha256:nope"
+			err := sut.updateServiceSpec(spec, metadata)
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+		})
+	})
+
+	Describe("When the pull policy is always", func() {
+		It("Should not pull the pinned image", func() {
+			metadata.ImagePullPolicy = PullAlways
+			sut.dockerClient = &fakeDockerClient{}
+
+			err := sut.updateServiceSpec(spec, metadata)
+			Expect(err).To(BeNil())
+			err = sut.applyImagePullPolicy(context.Background(), spec, metadata)
+			Expect(err).To(BeNil())
+			Expect(spec.TaskTemplate.ContainerSpec.Image).To(Equal("registry.example.com:5000/octoblu/my-application@" + digest))
+		})
+	})
+
+	Describe("When verifying the digest", func() {
+		var resolver *fakeDigestResolver
+
+		BeforeEach(func() {
+			resolver = &fakeDigestResolver{digest: digest}
+			sut = New(nil, nil, "redis-queue:name", "https://deploy-state.test", "super", WithDigestVerification(resolver))
+		})
+
+		It("Should resolve the tag of the dockerUrl", func() {
+			sut.verifyImageDigest(context.Background(), metadata)
+			Expect(resolver.images).To(Equal([]string{"registry.example.com:5000/octoblu/my-application:v2"}))
+		})
+
+		It("Should not resolve deploys without a digest", func() {
+			metadata.ImageDigest = ""
+			sut.verifyImageDigest(context.Background(), metadata)
+			Expect(resolver.images).To(BeEmpty())
+		})
+	})
+})
+
+// fakeDigestResolver resolves every image to digest
+type fakeDigestResolver struct {
+	digest string
+	images []string
+}
+
+func (resolver *fakeDigestResolver) ResolveDigest(ctx context.Context, image string) (string, error) {
+	resolver.images = append(resolver.images, image)
+	return resolver.digest, nil
+}
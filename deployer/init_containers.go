@@ -0,0 +1,100 @@
+package deployer
+
+import (
+	"time"
+
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/container"
+	"golang.org/x/net/context"
+)
+
+// removeInitContainerTimeout bounds removing a finished init container,
+// which happens after the init container's own timeout may have passed
+const removeInitContainerTimeout = 30 * time.Second
+
+// InitContainerSpec is a one-off container, e.g. a database
+// migration, that must exit 0 before the service is updated
+type InitContainerSpec struct {
+	Image          string            `json:"image"`
+	Command        []string          `json:"command"`
+	EnvVars        map[string]string `json:"envVars"`
+	TimeoutSeconds int               `json:"timeoutSeconds"`
+}
+
+func validateInitContainers(initContainers []InitContainerSpec) error {
+	for i, initContainer := range initContainers {
+		if initContainer.Image == "" {
+			return newValidationError("Invalid initContainers[%v], image is required", i)
+		}
+		if initContainer.TimeoutSeconds < 0 {
+			return newValidationError("Invalid initContainers[%v].timeoutSeconds %v, must not be negative", i, initContainer.TimeoutSeconds)
+		}
+	}
+	return nil
+}
+
+// runInitContainers runs the deploy's init containers on the
+// manager, in order, stopping at the first one that fails
+func (deployer *Deployer) runInitContainers(ctx context.Context, deploy string, metadata *RequestMetadata) error {
+	for _, initContainer := range metadata.InitContainers {
+		err := deployer.runInitContainer(ctx, deploy, initContainer)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (deployer *Deployer) runInitContainer(ctx context.Context, deploy string, initContainer InitContainerSpec) error {
+	if initContainer.TimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(initContainer.TimeoutSeconds)*time.Second)
+		defer cancel()
+	}
+
+	_, err := deployer.inspectOrPullImage(ctx, initContainer.Image)
+	if err != nil {
+		return err
+	}
+
+	config := &container.Config{
+		Image:  initContainer.Image,
+		Cmd:    initContainer.Command,
+		Env:    overrideEnv(nil, initContainer.EnvVars),
+		Labels: map[string]string{"governator.init-container": deploy},
+	}
+	created, err := deployer.dockerClient.ContainerCreate(ctx, config, &container.HostConfig{}, nil, "")
+	if err != nil {
+		return newDockerAPIError(err)
+	}
+	defer deployer.removeInitContainer(created.ID)
+
+	deployer.logger.Println("Running init container", initContainer.Image, "for", deploy)
+	err = deployer.dockerClient.ContainerStart(ctx, created.ID, types.ContainerStartOptions{})
+	if err != nil {
+		return newDockerAPIError(err)
+	}
+
+	exitCode, err := deployer.dockerClient.ContainerWait(ctx, created.ID)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return &InitContainerFailedError{Image: initContainer.Image, Message: "timed out after " + (time.Duration(initContainer.TimeoutSeconds) * time.Second).String()}
+		}
+		return newDockerAPIError(err)
+	}
+
+	if exitCode != 0 {
+		return &InitContainerFailedError{Image: initContainer.Image, ExitCode: exitCode}
+	}
+	return nil
+}
+
+func (deployer *Deployer) removeInitContainer(containerID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), removeInitContainerTimeout)
+	defer cancel()
+
+	err := deployer.dockerClient.ContainerRemove(ctx, containerID, types.ContainerRemoveOptions{Force: true})
+	if err != nil {
+		deployer.logger.Println("Error removing init container", containerID, err)
+	}
+}
@@ -0,0 +1,82 @@
+package deployer
+
+import (
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/swarm"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("InitContainers", func() {
+	var sut *Deployer
+	var dockerClient *fakeDockerClient
+	var metadata *RequestMetadata
+	var err error
+
+	BeforeEach(func() {
+		dockerClient = &fakeDockerClient{
+			service:   swarm.Service{ID: "service-id"},
+			images:    map[string]types.ImageInspect{"octoblu/my-migrations:v2": {}},
+			exitCodes: map[string]int{},
+		}
+		dockerClient.service.Spec.Name = "my-application"
+		dockerClient.service.Spec.TaskTemplate.ContainerSpec.Image = "octoblu/my-application:v1"
+		sut = New(dockerClient, nil, "redis-queue:name", deployState.URL, "super")
+		metadata = &RequestMetadata{
+			DockerURL: "octoblu/my-application:v2",
+			InitContainers: []InitContainerSpec{{
+				Image:   "octoblu/my-migrations:v2",
+				Command: []string{"npm", "run", "migrate"},
+				EnvVars: map[string]string{"DATABASE_URL": "mongodb://db"},
+			}},
+		}
+	})
+
+	JustBeforeEach(func() {
+		err = sut.deploy("my-application:v2", metadata)
+	})
+
+	It("Should run the init container before updating the service", func() {
+		Expect(err).To(BeNil())
+		Expect(dockerClient.containers).To(HaveLen(1))
+		Expect(dockerClient.containers[0].Image).To(Equal("octoblu/my-migrations:v2"))
+		Expect([]string(dockerClient.containers[0].Cmd)).To(Equal([]string{"npm", "run", "migrate"}))
+		Expect(dockerClient.containers[0].Env).To(Equal([]string{"DATABASE_URL=mongodb://db"}))
+		Expect(dockerClient.containers[0].Labels).To(HaveKeyWithValue("governator.init-container", "my-application:v2"))
+		Expect(dockerClient.started).To(Equal([]string{"0"}))
+		Expect(dockerClient.updates).To(HaveLen(1))
+	})
+
+	It("Should remove the init container", func() {
+		Expect(dockerClient.removedContainers).To(Equal([]string{"0"}))
+	})
+
+	Describe("When the init container exits non-zero", func() {
+		BeforeEach(func() {
+			dockerClient.exitCodes["octoblu/my-migrations:v2"] = 3
+		})
+
+		It("Should abort the deploy without updating the service", func() {
+			Expect(err).To(BeAssignableToTypeOf(&InitContainerFailedError{}))
+			Expect(err.(*InitContainerFailedError).ExitCode).To(Equal(3))
+			Expect(err.(*InitContainerFailedError).IsRetryable()).To(BeFalse())
+			Expect(dockerClient.updates).To(BeEmpty())
+		})
+
+		It("Should still remove the init container", func() {
+			Expect(dockerClient.removedContainers).To(Equal([]string{"0"}))
+		})
+	})
+
+	Describe("When an init container has no image", func() {
+		BeforeEach(func() {
+			metadata.InitContainers[0].Image = ""
+		})
+
+		It("Should refuse the deploy before running anything", func() {
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+			Expect(dockerClient.containers).To(BeEmpty())
+			Expect(dockerClient.updates).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,194 @@
+package deployer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/docker/engine-api/client"
+	"github.com/docker/engine-api/types/swarm"
+	"github.com/garyburd/redigo/redis"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rafaeljusto/redigomock"
+	"golang.org/x/net/context"
+)
+
+// fakeDaemon serves the service and task endpoints of the docker API
+// a deploy uses, so Run is tested through the real docker client
+type fakeDaemon struct {
+	lock          sync.Mutex
+	service       swarm.Service
+	updates       []swarm.ServiceSpec
+	rejectUpdates bool
+}
+
+func (daemon *fakeDaemon) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	daemon.lock.Lock()
+	defer daemon.lock.Unlock()
+
+	path := strings.TrimPrefix(request.URL.Path, "/v1.24")
+	switch {
+	case request.Method == "GET" && path == "/services/"+daemon.service.Spec.Name:
+		json.NewEncoder(response).Encode(daemon.service)
+
+	case request.Method == "POST" && path == "/services/"+daemon.service.ID+"/update":
+		if daemon.rejectUpdates {
+			http.Error(response, `{"message":"Service Unavailable"}`, http.StatusServiceUnavailable)
+			return
+		}
+		var spec swarm.ServiceSpec
+		json.NewDecoder(request.Body).Decode(&spec)
+		daemon.updates = append(daemon.updates, spec)
+		daemon.service.Spec = spec
+		daemon.service.Version.Index++
+		response.WriteHeader(http.StatusOK)
+
+	case request.Method == "GET" && path == "/tasks":
+		response.Write([]byte("[]"))
+
+	default:
+		http.NotFound(response, request)
+	}
+}
+
+func (daemon *fakeDaemon) updatedImages() []string {
+	daemon.lock.Lock()
+	defer daemon.lock.Unlock()
+
+	images := []string{}
+	for _, update := range daemon.updates {
+		images = append(images, update.TaskTemplate.ContainerSpec.Image)
+	}
+	return images
+}
+
+var _ = Describe("Run, against a docker API", func() {
+	var daemon *fakeDaemon
+	var server *httptest.Server
+	var dockerClient client.APIClient
+
+	BeforeEach(func() {
+		daemon = &fakeDaemon{service: swarm.Service{
+			ID:   "service-id",
+			Meta: swarm.Meta{Version: swarm.Version{Index: 1}},
+			Spec: swarm.ServiceSpec{
+				Annotations:  swarm.Annotations{Name: "my-application"},
+				TaskTemplate: swarm.TaskSpec{ContainerSpec: swarm.ContainerSpec{Image: "octoblu/my-application:v1"}},
+			},
+		}}
+		server = httptest.NewServer(daemon)
+
+		var err error
+		dockerClient, err = client.NewClient("tcp://"+strings.TrimPrefix(server.URL, "http://"), "1.24", nil, nil)
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	newDeployer := func(redisConn *redigomock.Conn) *Deployer {
+		redisPool := &redis.Pool{Dial: func() (redis.Conn, error) { return redisConn, nil }}
+		return New(dockerClient, redisPool, "redis-queue:name", deployState.URL, "super")
+	}
+
+	queueDeploy := func(redisConn *redigomock.Conn, zremResult int64) {
+		redisConn.Command("ZRANGEBYSCORE", "redis-queue:name:governator:deploys", 0, redigomock.NewAnyInt(), "WITHSCORES", "LIMIT", 0, DefaultQueueScanLimit).Expect([]interface{}{[]byte("my-application:v2"), []byte("1")})
+		redisConn.Command("HGET", "redis-queue:name:my-application:v2", "request:metadata").Expect([]byte(`{"dockerUrl":"octoblu/my-application:v2"}`)).Expect([]byte(`{"dockerUrl":"octoblu/my-application:v2"}`))
+		redisConn.Command("ZREM", "redis-queue:name:governator:deploys", "my-application:v2").Expect(zremResult)
+		redisConn.Command("HSET", "redis-queue:name:my-application:v2", "deploy:timestamp", redigomock.NewAnyInt()).Expect(int64(1))
+		redisConn.Command("HEXISTS", "redis-queue:name:my-application:v2", "cancellation").Expect(int64(0))
+		redisConn.GenericCommand("LPUSH").Expect(int64(1))
+		redisConn.GenericCommand("LTRIM").Expect("OK")
+		redisConn.GenericCommand("HSET").Expect(int64(1))
+	}
+
+	Describe("When the queue is empty", func() {
+		It("Should not touch the service", func() {
+			redisConn := redigomock.NewConn()
+			redisConn.Command("ZRANGEBYSCORE", "redis-queue:name:governator:deploys", 0, redigomock.NewAnyInt(), "WITHSCORES", "LIMIT", 0, DefaultQueueScanLimit).Expect([]interface{}{})
+
+			deployed, err := newDeployer(redisConn).RunOnce(context.Background())
+			Expect(err).To(BeNil())
+			Expect(deployed).To(BeFalse())
+			Expect(daemon.updatedImages()).To(BeEmpty())
+		})
+	})
+
+	Describe("When a deploy is queued", func() {
+		It("Should update the service to the new image", func() {
+			redisConn := redigomock.NewConn()
+			queueDeploy(redisConn, 1)
+
+			deployed, err := newDeployer(redisConn).RunOnce(context.Background())
+			Expect(err).To(BeNil())
+			Expect(deployed).To(BeTrue())
+			Expect(daemon.updatedImages()).To(Equal([]string{"octoblu/my-application:v2"}))
+		})
+	})
+
+	Describe("When the context is already done", func() {
+		It("Should not look at the queue", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			deployed, err := newDeployer(redigomock.NewConn()).RunOnce(ctx)
+			Expect(err).To(Equal(context.Canceled))
+			Expect(deployed).To(BeFalse())
+		})
+	})
+
+	Describe("When the deploy was cancelled", func() {
+		It("Should not touch the service", func() {
+			redisConn := redigomock.NewConn()
+			queueDeploy(redisConn, 1)
+			redisConn.Command("HEXISTS", "redis-queue:name:my-application:v2", "cancellation").Expect(int64(1))
+
+			err := newDeployer(redisConn).Run()
+			Expect(err).To(BeNil())
+			Expect(daemon.updatedImages()).To(BeEmpty())
+		})
+	})
+
+	Describe("When the daemon rejects the update", func() {
+		It("Should requeue the deploy", func() {
+			daemon.rejectUpdates = true
+			redisConn := redigomock.NewConn()
+			queueDeploy(redisConn, 1)
+			redisConn.Command("HINCRBY", "redis-queue:name:my-application:v2", "deploy:attempts", 1).Expect(int64(1))
+			redisConn.Command("HDEL", "redis-queue:name:my-application:v2", "deploy:timestamp").Expect(int64(1))
+			zadd := redisConn.Command("ZADD", "redis-queue:name:governator:deploys", redigomock.NewAnyInt(), "my-application:v2").Expect(int64(1))
+
+			err := newDeployer(redisConn).Run()
+			Expect(err).To(BeNil())
+			Expect(redisConn.Stats(zadd)).To(Equal(1))
+		})
+	})
+
+	Describe("When two deployers race for the same deploy", func() {
+		It("Should only deploy it once", func() {
+			winner := redigomock.NewConn()
+			queueDeploy(winner, 1)
+			loser := redigomock.NewConn()
+			queueDeploy(loser, 0)
+
+			var wait sync.WaitGroup
+			errs := make([]error, 2)
+			for i, redisConn := range []*redigomock.Conn{winner, loser} {
+				wait.Add(1)
+				go func(i int, redisConn *redigomock.Conn) {
+					defer GinkgoRecover()
+					defer wait.Done()
+					errs[i] = newDeployer(redisConn).Run()
+				}(i, redisConn)
+			}
+			wait.Wait()
+
+			Expect(errs).To(Equal([]error{nil, nil}))
+			Expect(daemon.updatedImages()).To(Equal([]string{"octoblu/my-application:v2"}))
+		})
+	})
+})
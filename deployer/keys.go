@@ -0,0 +1,50 @@
+package deployer
+
+import "fmt"
+
+// KeyBuilder names the redis keys of the deploy queue
+type KeyBuilder interface {
+	// DeployKey is the sorted set of queued deploys
+	DeployKey() string
+
+	// MetadataKey is the hash holding a deploy's request
+	MetadataKey(deployID string) string
+
+	// LockKey is the hash whose deploy:timestamp
+	// marks a deploy as taken off the queue
+	LockKey(deployID string) string
+
+	// DLQKey is the sorted set of deploys that won't be retried
+	DLQKey() string
+}
+
+// DefaultKeyBuilder names the keys governator has always used,
+// each one prefixed by Prefix and the queue name
+type DefaultKeyBuilder struct {
+	Prefix    string
+	QueueName string
+}
+
+// DeployKey is <prefix><queue>:governator:deploys
+func (keys *DefaultKeyBuilder) DeployKey() string {
+	return keys.key("governator:deploys")
+}
+
+// MetadataKey is <prefix><queue>:<deployID>
+func (keys *DefaultKeyBuilder) MetadataKey(deployID string) string {
+	return keys.key(deployID)
+}
+
+// LockKey is the deploy's MetadataKey
+func (keys *DefaultKeyBuilder) LockKey(deployID string) string {
+	return keys.key(deployID)
+}
+
+// DLQKey is <prefix><queue>:governator:dead-letters
+func (keys *DefaultKeyBuilder) DLQKey() string {
+	return keys.key("governator:dead-letters")
+}
+
+func (keys *DefaultKeyBuilder) key(key string) string {
+	return fmt.Sprintf("%s%s:%s", keys.Prefix, keys.QueueName, key)
+}
@@ -0,0 +1,44 @@
+package deployer
+
+import (
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rafaeljusto/redigomock"
+)
+
+var _ = Describe("KeyBuilder", func() {
+	Describe("DefaultKeyBuilder", func() {
+		It("Should prefix every key with the prefix and queue name", func() {
+			keys := &DefaultKeyBuilder{Prefix: "staging:", QueueName: "redis-queue:name"}
+			Expect(keys.DeployKey()).To(Equal("staging:redis-queue:name:governator:deploys"))
+			Expect(keys.MetadataKey("my-application:v2")).To(Equal("staging:redis-queue:name:my-application:v2"))
+			Expect(keys.LockKey("my-application:v2")).To(Equal("staging:redis-queue:name:my-application:v2"))
+			Expect(keys.DLQKey()).To(Equal("staging:redis-queue:name:governator:dead-letters"))
+		})
+	})
+
+	Describe("WithKeyBuilder", func() {
+		It("Should use the builder's keys", func() {
+			redisConn := redigomock.NewConn()
+			redisPool := &redis.Pool{Dial: func() (redis.Conn, error) { return redisConn, nil }}
+			sut := New(nil, redisPool, "redis-queue:name", "https://deploy-state.test", "super", WithKeyBuilder(&fakeKeyBuilder{}))
+
+			hdel := redisConn.Command("HDEL", "lock/my-application:v2", "deploy:timestamp").Expect(int64(1))
+			zadd := redisConn.Command("ZADD", "deploys", redigomock.NewAnyInt(), "my-application:v2").Expect(int64(1))
+
+			Expect(sut.requeueDeploy("my-application:v2", time.Now())).To(Succeed())
+			Expect(redisConn.Stats(hdel)).To(Equal(1))
+			Expect(redisConn.Stats(zadd)).To(Equal(1))
+		})
+	})
+})
+
+type fakeKeyBuilder struct{}
+
+func (keys *fakeKeyBuilder) DeployKey() string                  { return "deploys" }
+func (keys *fakeKeyBuilder) MetadataKey(deployID string) string { return "metadata/" + deployID }
+func (keys *fakeKeyBuilder) LockKey(deployID string) string     { return "lock/" + deployID }
+func (keys *fakeKeyBuilder) DLQKey() string                     { return "dead-letters" }
@@ -0,0 +1,134 @@
+package deployer
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/octoblu/governator-swarm/metrics"
+	"golang.org/x/net/context"
+)
+
+// DefaultKeyspaceFallbackInterval is how long to wait for a keyspace
+// notification before checking the queue anyway
+const DefaultKeyspaceFallbackInterval = 5 * time.Second
+
+func init() {
+	metrics.DefaultRegistry.Help("governator_keyspace_trigger_connected", "1 while the keyspace notification subscription is up, 0 while the deployer is polling")
+}
+
+// KeyspaceTrigger wakes the deployer when a deploy is added to its
+// queue, from the zadd keyevent notifications of redis. The server
+// must have notify-keyspace-events including E and z
+type KeyspaceTrigger struct {
+	redisPool        *redis.Pool
+	channel          string
+	deployKey        string
+	fallbackInterval time.Duration
+	notified         chan struct{}
+}
+
+// NewKeyspaceTrigger constructs a KeyspaceTrigger for the deployer's
+// queue in redis database db. Wait returns after fallbackInterval without
+// a notification, so the queue is still polled while the subscription is
+// down, and for deploys that were queued to go out later
+func (deployer *Deployer) NewKeyspaceTrigger(db int, fallbackInterval time.Duration) *KeyspaceTrigger {
+	return &KeyspaceTrigger{
+		redisPool:        deployer.redisPool,
+		channel:          fmt.Sprintf("__keyevent@%d__:zadd", db),
+		deployKey:        deployer.keys.DeployKey(),
+		fallbackInterval: fallbackInterval,
+		notified:         make(chan struct{}, 1),
+	}
+}
+
+// Run subscribes to the notifications until ctx is done,
+// subscribing again a fallback interval after the subscription drops
+func (trigger *KeyspaceTrigger) Run(ctx context.Context) {
+	for {
+		err := trigger.listen(ctx)
+		metrics.DefaultRecorder.SetGauge("governator_keyspace_trigger_connected", 0, nil)
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Println("WARNING: keyspace notification subscription dropped, polling every", trigger.fallbackInterval, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(trigger.fallbackInterval):
+		}
+	}
+}
+
+// Wait returns when a deploy was added to the queue since the
+// last Wait, or after the fallback interval, whichever is first
+func (trigger *KeyspaceTrigger) Wait() {
+	select {
+	case <-trigger.notified:
+	case <-time.After(trigger.fallbackInterval):
+	}
+}
+
+// listen reads the notifications until the subscription fails
+func (trigger *KeyspaceTrigger) listen(ctx context.Context) error {
+	redisConn := trigger.redisPool.Get()
+	defer redisConn.Close()
+
+	trigger.checkNotifyConfig(redisConn)
+
+	pubSubConn := redis.PubSubConn{Conn: redisConn}
+	err := pubSubConn.Subscribe(trigger.channel)
+	if err != nil {
+		return newRedisError(err)
+	}
+
+	// Receive blocks, closing the connection is the only way to stop it
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			redisConn.Close()
+		case <-done:
+		}
+	}()
+
+	for {
+		switch message := pubSubConn.Receive().(type) {
+		case redis.Subscription:
+			debug("keyspace trigger: %v %v", message.Kind, message.Channel)
+			metrics.DefaultRecorder.SetGauge("governator_keyspace_trigger_connected", 1, nil)
+		case redis.Message:
+			if string(message.Data) == trigger.deployKey {
+				trigger.notify()
+			}
+		case error:
+			return newRedisError(message)
+		}
+	}
+}
+
+func (trigger *KeyspaceTrigger) notify() {
+	select {
+	case trigger.notified <- struct{}{}:
+	default:
+	}
+}
+
+// checkNotifyConfig warns when redis won't send the zadd notifications.
+// Servers that don't allow CONFIG are assumed to be set up right
+func (trigger *KeyspaceTrigger) checkNotifyConfig(redisConn redis.Conn) {
+	values, err := redis.Strings(redisConn.Do("CONFIG", "GET", "notify-keyspace-events"))
+	if err != nil || len(values) != 2 {
+		debug("checkNotifyConfig: %v", err)
+		return
+	}
+
+	events := values[1]
+	if !strings.Contains(events, "E") || !strings.ContainsAny(events, "zA") {
+		log.Printf("WARNING: redis notify-keyspace-events is '%v', it needs E and z for --trigger keyspace, polling every %v meanwhile", events, trigger.fallbackInterval)
+	}
+}
@@ -0,0 +1,55 @@
+package deployer
+
+import (
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rafaeljusto/redigomock"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("KeyspaceTrigger", func() {
+	var sut *KeyspaceTrigger
+	var redisConn *redigomock.Conn
+
+	BeforeEach(func() {
+		redisConn = redigomock.NewConn()
+		redisPool := &redis.Pool{Dial: func() (redis.Conn, error) { return redisConn, nil }}
+		theDeployer := New(nil, redisPool, "redis-queue:name", "https://deploy-state.test", "super")
+		sut = theDeployer.NewKeyspaceTrigger(2, 50*time.Millisecond)
+
+		redisConn.Command("CONFIG", "GET", "notify-keyspace-events").Expect([]interface{}{[]byte("notify-keyspace-events"), []byte("Ez")})
+		redisConn.Command("SUBSCRIBE", "__keyevent@2__:zadd").Expect([]interface{}{[]byte("subscribe"), []byte("__keyevent@2__:zadd"), int64(1)})
+	})
+
+	Describe("When a deploy is added to the queue", func() {
+		It("Should wake the waiter right away, once", func() {
+			redisConn.AddSubscriptionMessage([]interface{}{[]byte("message"), []byte("__keyevent@2__:zadd"), []byte("redis-queue:name:governator:deploys")})
+			redisConn.AddSubscriptionMessage([]interface{}{[]byte("message"), []byte("__keyevent@2__:zadd"), []byte("redis-queue:name:governator:deploys")})
+
+			err := sut.listen(context.Background())
+			Expect(err).To(BeAssignableToTypeOf(&RedisError{}))
+
+			start := time.Now()
+			sut.Wait()
+			Expect(time.Since(start)).To(BeNumerically("<", 50*time.Millisecond))
+
+			sut.Wait()
+			Expect(time.Since(start)).To(BeNumerically(">=", 50*time.Millisecond))
+		})
+	})
+
+	Describe("When something else is added to a sorted set", func() {
+		It("Should wait for the fallback interval", func() {
+			redisConn.AddSubscriptionMessage([]interface{}{[]byte("message"), []byte("__keyevent@2__:zadd"), []byte("redis-queue:name:governator:dead-letters")})
+
+			sut.listen(context.Background())
+
+			start := time.Now()
+			sut.Wait()
+			Expect(time.Since(start)).To(BeNumerically(">=", 50*time.Millisecond))
+		})
+	})
+})
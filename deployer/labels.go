@@ -0,0 +1,71 @@
+package deployer
+
+import (
+	"strings"
+
+	"github.com/docker/engine-api/types/swarm"
+)
+
+// validateServiceLabels refuses changes to the labels governator
+// relies on: the annotation labels, which come from the annotations,
+// and the --docker-label-filter labels, which mark the service as
+// this deployer's to deploy
+func (deployer *Deployer) validateServiceLabels(metadata *RequestMetadata) error {
+	removals := map[string]bool{}
+	for _, key := range metadata.ServiceLabelRemovals {
+		removals[key] = true
+	}
+
+	keys := append([]string{}, metadata.ServiceLabelRemovals...)
+	for key := range metadata.ServiceLabels {
+		if removals[key] {
+			return newValidationError("Invalid serviceLabels, '%v' is also in serviceLabelRemovals", key)
+		}
+		keys = append(keys, key)
+	}
+
+	for _, key := range keys {
+		if key == "" {
+			return newValidationError("Invalid serviceLabels, key must not be empty")
+		}
+		if strings.HasPrefix(key, AnnotationLabelPrefix) {
+			return newValidationError("Invalid serviceLabels '%v', %v labels are set from the annotations", key, AnnotationLabelPrefix)
+		}
+		if _, ok := deployer.dockerLabelFilter[key]; ok {
+			return newValidationError("Invalid serviceLabels '%v', it is one of the --docker-label-filter labels", key)
+		}
+	}
+
+	for key := range metadata.ContainerLabels {
+		if key == "" {
+			return newValidationError("Invalid containerLabels, key must not be empty")
+		}
+	}
+	return nil
+}
+
+// setServiceLabels merges the deploy's labels into the service's labels
+// and its container labels, and removes its serviceLabelRemovals
+func setServiceLabels(spec *swarm.ServiceSpec, metadata *RequestMetadata) {
+	spec.Labels = mergeLabels(spec.Labels, metadata.ServiceLabels)
+	for _, key := range metadata.ServiceLabelRemovals {
+		delete(spec.Labels, key)
+	}
+
+	containerSpec := &spec.TaskTemplate.ContainerSpec
+	containerSpec.Labels = mergeLabels(containerSpec.Labels, metadata.ContainerLabels)
+}
+
+func mergeLabels(labels, overrides map[string]string) map[string]string {
+	if len(overrides) == 0 {
+		return labels
+	}
+
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	for key, value := range overrides {
+		labels[key] = value
+	}
+	return labels
+}
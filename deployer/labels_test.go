@@ -0,0 +1,113 @@
+package deployer
+
+import (
+	"github.com/docker/engine-api/types/swarm"
+	"github.com/garyburd/redigo/redis"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/rafaeljusto/redigomock"
+)
+
+var _ = Describe("ServiceLabels", func() {
+	var sut *Deployer
+	var dockerClient *fakeDockerClient
+	var metadata *RequestMetadata
+	var err error
+
+	BeforeEach(func() {
+		dockerClient = &fakeDockerClient{service: swarm.Service{ID: "service-id"}}
+		dockerClient.service.Spec.Name = "my-application"
+		dockerClient.service.Spec.Labels = map[string]string{
+			"traefik.frontend.rule": "Host:old.example.com",
+			"traefik.backend":       "my-application",
+			"octoblu.deployer":      "governator",
+		}
+		dockerClient.service.Spec.TaskTemplate.ContainerSpec.Labels = map[string]string{"logging": "json"}
+		sut = New(dockerClient, nil, "redis-queue:name", deployState.URL, "super", WithDockerLabelFilter(map[string]string{"octoblu.deployer": "governator"}))
+		metadata = &RequestMetadata{DockerURL: "octoblu/my-application:v2"}
+	})
+
+	JustBeforeEach(func() {
+		err = sut.deploy("my-application:v2", metadata)
+	})
+
+	Describe("When they are not set", func() {
+		It("Should leave the labels alone", func() {
+			Expect(err).To(BeNil())
+			Expect(dockerClient.updates[0].Labels).To(HaveLen(3))
+			Expect(dockerClient.updates[0].TaskTemplate.ContainerSpec.Labels).To(Equal(map[string]string{"logging": "json"}))
+		})
+	})
+
+	Describe("When service and container labels are both set", func() {
+		BeforeEach(func() {
+			metadata.ServiceLabels = map[string]string{"traefik.frontend.rule": "Host:new.example.com", "traefik.port": "8080"}
+			metadata.ServiceLabelRemovals = []string{"traefik.backend"}
+			metadata.ContainerLabels = map[string]string{"logging": "gelf"}
+		})
+
+		It("Should merge and remove the service labels", func() {
+			Expect(err).To(BeNil())
+			Expect(dockerClient.updates[0].Labels).To(Equal(map[string]string{
+				"traefik.frontend.rule": "Host:new.example.com",
+				"traefik.port":          "8080",
+				"octoblu.deployer":      "governator",
+			}))
+		})
+
+		It("Should set the container labels separately", func() {
+			Expect(dockerClient.updates[0].TaskTemplate.ContainerSpec.Labels).To(Equal(map[string]string{"logging": "gelf"}))
+			Expect(dockerClient.updates[0].Labels).NotTo(HaveKey("logging"))
+		})
+	})
+
+	table.DescribeTable("Should refuse changes to the labels governator relies on",
+		func(labels map[string]string, removals []string, message string) {
+			metadata := &RequestMetadata{ServiceLabels: labels, ServiceLabelRemovals: removals}
+			err := sut.validateServiceLabels(metadata)
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+			Expect(err.Error()).To(ContainSubstring(message))
+		},
+		table.Entry("removing a --docker-label-filter label", nil, []string{"octoblu.deployer"}, "--docker-label-filter"),
+		table.Entry("setting an annotation label", map[string]string{AnnotationLabelPrefix + "initiator": "me"}, nil, "set from the annotations"),
+		table.Entry("setting and removing a label", map[string]string{"traefik.port": "80"}, []string{"traefik.port"}, "also in serviceLabelRemovals"),
+	)
+})
+
+var _ = Describe("Service ownership", func() {
+	var sut *Deployer
+	var dockerClient *fakeDockerClient
+	var redisConn *redigomock.Conn
+	var dlq *redigomock.Cmd
+	var err error
+
+	BeforeEach(func() {
+		dockerClient = &fakeDockerClient{service: swarm.Service{ID: "service-id"}}
+		dockerClient.service.Spec.Name = "my-application"
+		dockerClient.service.Spec.Labels = map[string]string{"octoblu.deployer": "someone-else"}
+
+		redisConn = redigomock.NewConn()
+		redisPool := &redis.Pool{Dial: func() (redis.Conn, error) { return redisConn, nil }}
+		dlq = redisConn.Command("ZADD", "redis-queue:name:governator:dead-letters", redigomock.NewAnyInt(), "my-application:v2").Expect(int64(1))
+
+		sut = New(dockerClient, redisPool, "redis-queue:name", deployState.URL, "super", WithDockerLabelFilter(map[string]string{"octoblu.deployer": "governator"}), WithLogger(&fakeLogger{}))
+	})
+
+	Describe("When the service doesn't have the --docker-label-filter label", func() {
+		It("Should refuse to deploy to it", func() {
+			err = sut.deploy("my-application:v2", &RequestMetadata{DockerURL: "octoblu/my-application:v2"})
+			Expect(err).To(BeAssignableToTypeOf(&ServiceNotOwnedError{}))
+			Expect(dockerClient.updates).To(BeEmpty())
+		})
+
+		It("Should dead letter the deploy rather than update the service", func() {
+			err = sut.runDeploy("my-application:v2", &RequestMetadata{DockerURL: "octoblu/my-application:v2"})
+			Expect(err).To(BeNil())
+			Expect(redisConn.Stats(dlq)).To(Equal(1))
+			Expect(dockerClient.updates).To(BeEmpty())
+			Expect(dockerClient.created).To(BeEmpty())
+			Expect(deployState.requests()).To(Equal([]string{"PUT /deployments/octoblu/my-application/v2/cluster/super/failed"}))
+		})
+	})
+})
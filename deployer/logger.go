@@ -0,0 +1,15 @@
+package deployer
+
+import "log"
+
+// Logger is where the deployer logs what it does, a *log.Logger works
+type Logger interface {
+	Println(v ...interface{})
+}
+
+// stdLogger logs to the standard logger
+type stdLogger struct{}
+
+func (stdLogger) Println(v ...interface{}) {
+	log.Println(v...)
+}
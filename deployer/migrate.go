@@ -0,0 +1,74 @@
+package deployer
+
+import (
+	"fmt"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// MigrateQueue moves every queued deploy from one queue to another,
+// keeping its score and copying its hash. The hashes in the old queue
+// are left in place. progress is called after each deploy is moved.
+// It returns how many deploys were moved
+func MigrateQueue(redisPool *redis.Pool, from, to KeyBuilder, progress func(deploy string)) (int, error) {
+	if from.DeployKey() == to.DeployKey() {
+		return 0, fmt.Errorf("can't migrate %v to itself", from.DeployKey())
+	}
+
+	redisConn := redisPool.Get()
+	defer redisConn.Close()
+
+	values, err := redis.Values(redisConn.Do("ZRANGEBYSCORE", from.DeployKey(), 0, "+inf", "WITHSCORES"))
+	if err != nil {
+		return 0, newRedisError(err)
+	}
+
+	migrated := 0
+	for i := 0; i+1 < len(values); i += 2 {
+		deploy, err := redis.String(values[i], nil)
+		if err != nil {
+			return migrated, newRedisError(err)
+		}
+		score, err := redis.Int64(values[i+1], nil)
+		if err != nil {
+			return migrated, newRedisError(err)
+		}
+
+		err = migrateDeploy(redisConn, from, to, deploy, score)
+		if err != nil {
+			return migrated, err
+		}
+
+		migrated++
+		if progress != nil {
+			progress(deploy)
+		}
+	}
+	return migrated, nil
+}
+
+func migrateDeploy(redisConn redis.Conn, from, to KeyBuilder, deploy string, score int64) error {
+	fields, err := redis.Values(redisConn.Do("HGETALL", from.MetadataKey(deploy)))
+	if err != nil {
+		return newRedisError(err)
+	}
+
+	if len(fields) > 0 {
+		args := append([]interface{}{to.MetadataKey(deploy)}, fields...)
+		_, err = redisConn.Do("HMSET", args...)
+		if err != nil {
+			return newRedisError(err)
+		}
+	}
+
+	_, err = redisConn.Do("ZADD", to.DeployKey(), score, deploy)
+	if err != nil {
+		return newRedisError(err)
+	}
+
+	_, err = redisConn.Do("ZREM", from.DeployKey(), deploy)
+	if err != nil {
+		return newRedisError(err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package deployer
+
+import (
+	"github.com/garyburd/redigo/redis"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rafaeljusto/redigomock"
+)
+
+var _ = Describe("MigrateQueue", func() {
+	var redisConn *redigomock.Conn
+	var redisPool *redis.Pool
+	var from, to KeyBuilder
+
+	BeforeEach(func() {
+		redisConn = redigomock.NewConn()
+		redisPool = &redis.Pool{Dial: func() (redis.Conn, error) { return redisConn, nil }}
+		from = &DefaultKeyBuilder{QueueName: "old-queue"}
+		to = &DefaultKeyBuilder{QueueName: "new-queue"}
+	})
+
+	It("Should move each deploy with its score and hash", func() {
+		redisConn.Command("ZRANGEBYSCORE", "old-queue:governator:deploys", 0, "+inf", "WITHSCORES").Expect([]interface{}{[]byte("my-application:v2"), []byte("1500000000")})
+		redisConn.Command("HGETALL", "old-queue:my-application:v2").Expect([]interface{}{[]byte("request:metadata"), []byte(`{"dockerUrl":"octoblu/my-application:v2"}`)})
+		hmset := redisConn.Command("HMSET", "new-queue:my-application:v2", []byte("request:metadata"), []byte(`{"dockerUrl":"octoblu/my-application:v2"}`)).Expect("OK")
+		zadd := redisConn.Command("ZADD", "new-queue:governator:deploys", int64(1500000000), "my-application:v2").Expect(int64(1))
+		zrem := redisConn.Command("ZREM", "old-queue:governator:deploys", "my-application:v2").Expect(int64(1))
+
+		moved := []string{}
+		migrated, err := MigrateQueue(redisPool, from, to, func(deploy string) { moved = append(moved, deploy) })
+		Expect(err).To(BeNil())
+		Expect(migrated).To(Equal(1))
+		Expect(moved).To(Equal([]string{"my-application:v2"}))
+		Expect(redisConn.Stats(hmset)).To(Equal(1))
+		Expect(redisConn.Stats(zadd)).To(Equal(1))
+		Expect(redisConn.Stats(zrem)).To(Equal(1))
+	})
+
+	It("Should refuse to migrate a queue to itself", func() {
+		_, err := MigrateQueue(redisPool, from, from, nil)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,43 @@
+package deployer
+
+import "strings"
+
+// mirrorImage rewrites the image to be pulled from the registry mirror.
+// When the image starts with registryMirrorPrefixStrip, that prefix is
+// replaced by the mirror. Otherwise an explicit registry hostname is
+// replaced, and docker hub images get the mirror prepended
+func (deployer *Deployer) mirrorImage(image string) string {
+	if deployer.registryMirror == "" {
+		return image
+	}
+
+	mirror := strings.TrimSuffix(deployer.registryMirror, "/")
+	prefix := deployer.registryMirrorPrefixStrip
+	if prefix != "" && strings.HasPrefix(image, prefix) {
+		return mirror + "/" + strings.TrimPrefix(strings.TrimPrefix(image, prefix), "/")
+	}
+
+	return rewriteRegistry(mirror, image)
+}
+
+// rewriteRegistry replaces the image's registry hostname with
+// mirror, or prepends mirror to docker hub images
+func rewriteRegistry(mirror, image string) string {
+	_, remainder := splitRegistry(image)
+	return strings.TrimSuffix(mirror, "/") + "/" + remainder
+}
+
+// splitRegistry splits the registry hostname off an image,
+// returning an empty registry for docker hub images
+func splitRegistry(image string) (string, string) {
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 1 {
+		return "", image
+	}
+
+	host := parts[0]
+	if host == "localhost" || strings.ContainsAny(host, ".:") {
+		return host, parts[1]
+	}
+	return "", image
+}
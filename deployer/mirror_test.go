@@ -0,0 +1,44 @@
+package deployer
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("mirrorImage", func() {
+	var sut *Deployer
+
+	BeforeEach(func() {
+		sut = New(nil, nil, "redis-queue:name", "https://deploy-state.test", "super", WithRegistryMirror("registry.internal.example.com", ""))
+	})
+
+	It("Should prepend the mirror to docker hub images", func() {
+		Expect(sut.mirrorImage("octoblu/my-application:v1")).To(Equal("registry.internal.example.com/octoblu/my-application:v1"))
+	})
+
+	It("Should replace an explicit registry", func() {
+		Expect(sut.mirrorImage("quay.io/octoblu/my-application:v1")).To(Equal("registry.internal.example.com/octoblu/my-application:v1"))
+		Expect(sut.mirrorImage("registry.example.com:5000/octoblu/my-application:v1")).To(Equal("registry.internal.example.com/octoblu/my-application:v1"))
+	})
+
+	Describe("When a prefix to strip is configured", func() {
+		BeforeEach(func() {
+			sut = New(nil, nil, "redis-queue:name", "https://deploy-state.test", "super", WithRegistryMirror("registry.internal.example.com/", "quay.io/team/"))
+		})
+
+		It("Should replace the prefix with the mirror", func() {
+			Expect(sut.mirrorImage("quay.io/team/octoblu/my-application:v1")).To(Equal("registry.internal.example.com/octoblu/my-application:v1"))
+		})
+
+		It("Should fall back to replacing the registry when the prefix doesn't match", func() {
+			Expect(sut.mirrorImage("gcr.io/octoblu/my-application:v1")).To(Equal("registry.internal.example.com/octoblu/my-application:v1"))
+		})
+	})
+
+	Describe("When no mirror is configured", func() {
+		It("Should leave the image alone", func() {
+			sut = New(nil, nil, "redis-queue:name", "https://deploy-state.test", "super")
+			Expect(sut.mirrorImage("octoblu/my-application:v1")).To(Equal("octoblu/my-application:v1"))
+		})
+	})
+})
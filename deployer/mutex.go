@@ -0,0 +1,108 @@
+package deployer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+const (
+	// mutexTTL expires a deploy mutex whose holder crashed,
+	// the holder refreshes it every mutexHeartbeatInterval
+	mutexTTL               = 30 * time.Second
+	mutexHeartbeatInterval = 10 * time.Second
+
+	// mutexRetryDelay is how long a deploy waits in the
+	// queue when another deploy holds its mutex
+	mutexRetryDelay = 15 * time.Second
+)
+
+// refreshMutexScript extends the mutex only while this deployer holds it
+const refreshMutexScriptSource = `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`
+
+// releaseMutexScript deletes the mutex only while this deployer holds it
+const releaseMutexScriptSource = `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`
+
+var (
+	refreshMutexScript = redis.NewScript(1, refreshMutexScriptSource)
+	releaseMutexScript = redis.NewScript(1, releaseMutexScriptSource)
+)
+
+// acquireMutex takes the deploy's mutex, so no other deployer on
+// the queue deploys with the same mutex at the same time. It returns
+// false when another deployer holds it. While held, the mutex is
+// refreshed in the background until release is called
+func (deployer *Deployer) acquireMutex(mutex string) (func(), bool, error) {
+	redisConn := deployer.redisPool.Get()
+	defer redisConn.Close()
+
+	key := deployer.getKey("mutex:" + mutex)
+	owner := deployer.getMutexOwner()
+
+	_, err := redis.String(redisConn.Do("SET", key, owner, "NX", "PX", durationMillis(mutexTTL)))
+	if err == redis.ErrNil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, newRedisError(err)
+	}
+
+	done := make(chan struct{})
+	go deployer.refreshMutex(key, owner, done)
+
+	release := func() {
+		close(done)
+		deployer.releaseMutex(key, owner)
+	}
+	return release, true, nil
+}
+
+func (deployer *Deployer) refreshMutex(key, owner string, done <-chan struct{}) {
+	ticker := time.NewTicker(mutexHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			redisConn := deployer.redisPool.Get()
+			_, err := refreshMutexScript.Do(redisConn, key, owner, durationMillis(mutexTTL))
+			redisConn.Close()
+			if err != nil {
+				deployer.logger.Println("Error refreshing mutex", key, err)
+			}
+		}
+	}
+}
+
+func (deployer *Deployer) releaseMutex(key, owner string) {
+	redisConn := deployer.redisPool.Get()
+	defer redisConn.Close()
+
+	_, err := releaseMutexScript.Do(redisConn, key, owner)
+	if err != nil {
+		deployer.logger.Println("Error releasing mutex", key, err)
+	}
+}
+
+// getMutexOwner identifies this deployer as the holder of a mutex
+func (deployer *Deployer) getMutexOwner() string {
+	if deployer.consumerID != "" {
+		return deployer.consumerID
+	}
+	return fmt.Sprintf("deployer-%v", deployer.startedAt.UnixNano())
+}
+
+func durationMillis(duration time.Duration) int64 {
+	return int64(duration / time.Millisecond)
+}
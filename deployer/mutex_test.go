@@ -0,0 +1,58 @@
+package deployer
+
+import (
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rafaeljusto/redigomock"
+)
+
+var _ = Describe("acquireMutex", func() {
+	var sut *Deployer
+	var redisConn *redigomock.Conn
+	var release func()
+	var acquired bool
+	var err error
+
+	BeforeEach(func() {
+		redisConn = redigomock.NewConn()
+		redisPool := &redis.Pool{Dial: func() (redis.Conn, error) { return redisConn, nil }}
+		sut = New(nil, redisPool, "redis-queue:name", "https://deploy-state.test", "super")
+		sut.startedAt = time.Unix(1, 0)
+	})
+
+	Describe("When the mutex is free", func() {
+		var releaseScript *redigomock.Cmd
+
+		BeforeEach(func() {
+			redisConn.Command("SET", "redis-queue:name:mutex:database", "deployer-1000000000", "NX", "PX", int64(30000)).Expect("OK")
+			releaseScript = redisConn.Script([]byte(releaseMutexScriptSource), 1, "redis-queue:name:mutex:database", "deployer-1000000000").Expect(int64(1))
+			release, acquired, err = sut.acquireMutex("database")
+		})
+
+		It("Should take the mutex", func() {
+			Expect(err).To(BeNil())
+			Expect(acquired).To(BeTrue())
+		})
+
+		It("Should delete the mutex on release", func() {
+			release()
+			Expect(redisConn.Stats(releaseScript)).To(Equal(1))
+		})
+	})
+
+	Describe("When another deployer holds the mutex", func() {
+		BeforeEach(func() {
+			redisConn.Command("SET", "redis-queue:name:mutex:database", "deployer-1000000000", "NX", "PX", int64(30000)).Expect(nil)
+			release, acquired, err = sut.acquireMutex("database")
+		})
+
+		It("Should not take the mutex", func() {
+			Expect(err).To(BeNil())
+			Expect(acquired).To(BeFalse())
+			Expect(release).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,65 @@
+package deployer
+
+import (
+	"github.com/docker/engine-api/client"
+	"github.com/docker/engine-api/types/swarm"
+	"golang.org/x/net/context"
+)
+
+// Network modes for RequestMetadata.NetworkMode
+const (
+	NetworkModeBridge  = "bridge"
+	NetworkModeHost    = "host"
+	NetworkModeOverlay = "overlay"
+)
+
+func validateNetworkMode(mode string) error {
+	switch mode {
+	case "", NetworkModeBridge, NetworkModeHost, NetworkModeOverlay:
+		return nil
+	}
+	return newValidationError("Invalid networkMode '%v', expected %v, %v or %v", mode, NetworkModeBridge, NetworkModeHost, NetworkModeOverlay)
+}
+
+// applyNetworkMode sets the service's network attachments the way the
+// deploy asked. bridge detaches the service from every network, so its
+// tasks use each node's default bridge. host replaces the attachments
+// with swarm's predefined host network. overlay keeps the attachments,
+// but refuses the deploy unless they are all existing overlay networks
+func (deployer *Deployer) applyNetworkMode(ctx context.Context, spec *swarm.ServiceSpec, metadata *RequestMetadata) error {
+	switch metadata.NetworkMode {
+	case NetworkModeBridge:
+		spec.Networks = nil
+		spec.TaskTemplate.Networks = []swarm.NetworkAttachmentConfig{}
+	case NetworkModeHost:
+		spec.Networks = nil
+		spec.TaskTemplate.Networks = []swarm.NetworkAttachmentConfig{{Target: NetworkModeHost}}
+	case NetworkModeOverlay:
+		return deployer.validateOverlayNetworks(ctx, spec)
+	}
+	return nil
+}
+
+func (deployer *Deployer) validateOverlayNetworks(ctx context.Context, spec *swarm.ServiceSpec) error {
+	var networks []swarm.NetworkAttachmentConfig
+	networks = append(networks, spec.TaskTemplate.Networks...)
+	networks = append(networks, spec.Networks...)
+	if len(networks) == 0 {
+		return newValidationError("networkMode overlay requires service '%v' to be attached to an overlay network", spec.Name)
+	}
+
+	for _, network := range networks {
+		resource, err := deployer.dockerClient.NetworkInspect(ctx, network.Target)
+		if client.IsErrNetworkNotFound(err) {
+			return newValidationError("Network '%v' of service '%v' does not exist", network.Target, spec.Name)
+		}
+		if err != nil {
+			return newDockerAPIError(err)
+		}
+
+		if resource.Driver != NetworkModeOverlay {
+			return newValidationError("Network '%v' of service '%v' uses the %v driver, expected overlay", network.Target, spec.Name, resource.Driver)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package deployer
+
+import (
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/swarm"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NetworkMode", func() {
+	var sut *Deployer
+	var dockerClient *fakeDockerClient
+	var metadata *RequestMetadata
+	var err error
+
+	BeforeEach(func() {
+		dockerClient = &fakeDockerClient{
+			service: swarm.Service{ID: "service-id"},
+			networks: map[string]types.NetworkResource{
+				"octoblu-overlay": {Name: "octoblu-overlay", Driver: "overlay"},
+				"local-bridge":    {Name: "local-bridge", Driver: "bridge"},
+			},
+		}
+		dockerClient.service.Spec.Name = "my-application"
+		dockerClient.service.Spec.TaskTemplate.Networks = []swarm.NetworkAttachmentConfig{{Target: "octoblu-overlay"}}
+		sut = New(dockerClient, nil, "redis-queue:name", deployState.URL, "super")
+		metadata = &RequestMetadata{DockerURL: "octoblu/my-application:v2"}
+	})
+
+	JustBeforeEach(func() {
+		err = sut.deploy("my-application:v2", metadata)
+	})
+
+	networks := func() []swarm.NetworkAttachmentConfig {
+		return dockerClient.service.Spec.TaskTemplate.Networks
+	}
+
+	Describe("When it is not set", func() {
+		It("Should leave the networks alone", func() {
+			Expect(err).To(BeNil())
+			Expect(networks()).To(Equal([]swarm.NetworkAttachmentConfig{{Target: "octoblu-overlay"}}))
+		})
+	})
+
+	Describe("When it is host", func() {
+		BeforeEach(func() {
+			metadata.NetworkMode = NetworkModeHost
+		})
+
+		It("Should attach the service to the host network only", func() {
+			Expect(err).To(BeNil())
+			Expect(networks()).To(Equal([]swarm.NetworkAttachmentConfig{{Target: "host"}}))
+		})
+	})
+
+	Describe("When it is bridge", func() {
+		BeforeEach(func() {
+			metadata.NetworkMode = NetworkModeBridge
+		})
+
+		It("Should detach the service from its networks", func() {
+			Expect(err).To(BeNil())
+			Expect(networks()).To(BeEmpty())
+		})
+	})
+
+	Describe("When it is overlay", func() {
+		BeforeEach(func() {
+			metadata.NetworkMode = NetworkModeOverlay
+		})
+
+		It("Should deploy when the networks are overlays", func() {
+			Expect(err).To(BeNil())
+			Expect(dockerClient.updates).To(HaveLen(1))
+		})
+
+		Describe("and a network doesn't exist", func() {
+			BeforeEach(func() {
+				dockerClient.service.Spec.TaskTemplate.Networks = []swarm.NetworkAttachmentConfig{{Target: "missing"}}
+			})
+
+			It("Should return a validation error", func() {
+				Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+				Expect(dockerClient.updates).To(BeEmpty())
+			})
+		})
+
+		Describe("and a network isn't an overlay", func() {
+			BeforeEach(func() {
+				dockerClient.service.Spec.TaskTemplate.Networks = []swarm.NetworkAttachmentConfig{{Target: "local-bridge"}}
+			})
+
+			It("Should return a validation error", func() {
+				Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+			})
+		})
+
+		Describe("and the service has no networks", func() {
+			BeforeEach(func() {
+				dockerClient.service.Spec.TaskTemplate.Networks = nil
+			})
+
+			It("Should return a validation error", func() {
+				Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+			})
+		})
+	})
+
+	Describe("When it is unknown", func() {
+		BeforeEach(func() {
+			metadata.NetworkMode = "macvlan"
+		})
+
+		It("Should return a validation error", func() {
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+		})
+	})
+})
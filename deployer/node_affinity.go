@@ -0,0 +1,41 @@
+package deployer
+
+import "strings"
+
+// NodeAffinityStrategySpread spreads the service's tasks evenly over
+// the values of a node label. It is the only strategy swarm has
+const NodeAffinityStrategySpread = "spread"
+
+func validateNodeAffinity(labels, strategies map[string]string) error {
+	for key := range labels {
+		if key == "" || strings.ContainsAny(key, " =") {
+			return newValidationError("Invalid nodeAffinityLabels key '%v'", key)
+		}
+	}
+
+	for key, strategy := range strategies {
+		if _, ok := labels[key]; !ok {
+			return newValidationError("Invalid nodeAffinityStrategies, '%v' is not one of the nodeAffinityLabels", key)
+		}
+		if strategy != NodeAffinityStrategySpread {
+			return newValidationError("Invalid nodeAffinityStrategies '%v' for '%v', expected %v", strategy, key, NodeAffinityStrategySpread)
+		}
+	}
+	return nil
+}
+
+// applyNodeAffinity validates the deploy's placement preferences. The
+// vendored docker client's Placement has no Preferences (they need API
+// 1.27), so a deploy that sets them is refused rather than deployed
+// without, which would silently drop the spread
+func applyNodeAffinity(metadata *RequestMetadata) error {
+	if metadata.NodeAffinityLabels == nil && metadata.NodeAffinityStrategies == nil {
+		return nil
+	}
+
+	err := validateNodeAffinity(metadata.NodeAffinityLabels, metadata.NodeAffinityStrategies)
+	if err != nil {
+		return err
+	}
+	return newValidationError("nodeAffinityLabels need docker API 1.27, which this governator's docker client doesn't support, use placement constraints instead")
+}
@@ -0,0 +1,62 @@
+package deployer
+
+import (
+	"github.com/docker/engine-api/types/swarm"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NodeAffinity", func() {
+	var sut *Deployer
+	var dockerClient *fakeDockerClient
+	var metadata *RequestMetadata
+	var err error
+
+	BeforeEach(func() {
+		dockerClient = &fakeDockerClient{service: swarm.Service{ID: "service-id"}}
+		dockerClient.service.Spec.Name = "my-application"
+		dockerClient.service.Spec.TaskTemplate.Placement = &swarm.Placement{Constraints: []string{"node.role==worker"}}
+		sut = New(dockerClient, nil, "redis-queue:name", deployState.URL, "super")
+		metadata = &RequestMetadata{DockerURL: "octoblu/my-application:v2"}
+	})
+
+	JustBeforeEach(func() {
+		err = sut.deploy("my-application:v2", metadata)
+	})
+
+	Describe("When it is not set", func() {
+		It("Should leave the placement unchanged", func() {
+			Expect(err).To(BeNil())
+			Expect(dockerClient.updates[0].TaskTemplate.Placement.Constraints).To(Equal([]string{"node.role==worker"}))
+		})
+	})
+
+	Describe("When it is set", func() {
+		BeforeEach(func() {
+			metadata.NodeAffinityLabels = map[string]string{"zone": ""}
+			metadata.NodeAffinityStrategies = map[string]string{"zone": "spread"}
+		})
+
+		It("Should be valid", func() {
+			Expect(validateNodeAffinity(metadata.NodeAffinityLabels, metadata.NodeAffinityStrategies)).To(BeNil())
+		})
+
+		It("Should refuse the deploy, since the docker client can't set preferences", func() {
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+			Expect(err.Error()).To(ContainSubstring("API 1.27"))
+			Expect(dockerClient.updates).To(BeEmpty())
+		})
+	})
+
+	table.DescribeTable("Should refuse invalid preferences",
+		func(labels, strategies map[string]string, message string) {
+			err := validateNodeAffinity(labels, strategies)
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+			Expect(err.Error()).To(ContainSubstring(message))
+		},
+		table.Entry("with an empty label", map[string]string{"": ""}, nil, "Invalid nodeAffinityLabels key"),
+		table.Entry("with a strategy for another label", map[string]string{"zone": ""}, map[string]string{"rack": "spread"}, "not one of the nodeAffinityLabels"),
+		table.Entry("with an unknown strategy", map[string]string{"zone": ""}, map[string]string{"zone": "pack"}, "expected spread"),
+	)
+})
@@ -0,0 +1,37 @@
+package deployer
+
+import (
+	"time"
+
+	"github.com/octoblu/governator-swarm/metrics"
+	"golang.org/x/net/context"
+)
+
+const (
+	// DefaultNotifyRateLimit is how many deploy-state requests
+	// are sent per second when WithNotifyRateLimit isn't used
+	DefaultNotifyRateLimit = 10
+
+	// DefaultNotifyBurst is how many deploy-state requests may be
+	// sent at once when WithNotifyRateLimit isn't used
+	DefaultNotifyBurst = 20
+)
+
+func init() {
+	metrics.DefaultRegistry.Help("governator_notify_wait_seconds", "Time deploy-state notifications waited for the rate limit")
+}
+
+// waitToNotify waits until the rate limit allows another deploy-state
+// request, for no longer than the deploy-state timeout
+func (deployer *Deployer) waitToNotify() error {
+	ctx, cancel := context.WithTimeout(context.Background(), deployer.deployStateTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := deployer.notifyLimiter.Wait(ctx)
+	deployer.metrics.ObserveHistogram("governator_notify_wait_seconds", time.Since(start).Seconds(), nil)
+	if err != nil {
+		return newNotifyError(0, "deploy-state rate limit: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package deployer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/docker/engine-api/types/swarm"
+	"github.com/garyburd/redigo/redis"
+	"github.com/octoblu/governator-swarm/metrics"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rafaeljusto/redigomock"
+)
+
+var _ = Describe("notifyDeployState", func() {
+	var server *httptest.Server
+	var delay time.Duration
+	var statusCode int
+	var path string
+	var header http.Header
+	var body []byte
+
+	BeforeEach(func() {
+		delay = 0
+		statusCode = http.StatusOK
+		server = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			path = request.URL.Path
+			header = request.Header
+			body, _ = ioutil.ReadAll(request.Body)
+			time.Sleep(delay)
+			response.WriteHeader(statusCode)
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	notifyResult := func(result string, options ...Option) error {
+		path = ""
+		sut := New(nil, nil, "redis-queue:name", server.URL, "super", options...)
+		return sut.notifyDeployState(&RequestMetadata{DockerURL: "octoblu/my-application:v1", Annotations: map[string]string{"ticket": "OPS-123"}}, result)
+	}
+
+	notify := func(options ...Option) error {
+		return notifyResult(DeployPassed, options...)
+	}
+
+	It("Should mark the deployment as passed on the cluster", func() {
+		Expect(notify()).To(Succeed())
+		Expect(path).To(Equal("/deployments/octoblu/my-application/v1/cluster/super/passed"))
+	})
+
+	It("Should mark a failed deployment as failed", func() {
+		Expect(notifyResult(DeployFailed)).To(Succeed())
+		Expect(path).To(Equal("/deployments/octoblu/my-application/v1/cluster/super/failed"))
+	})
+
+	Describe("When the deployer only notifies on failure", func() {
+		It("Should not make the request for a passed deploy", func() {
+			Expect(notifyResult(DeployPassed, WithNotifyOnly(DeployFailed))).To(Succeed())
+			Expect(path).To(BeEmpty())
+		})
+
+		It("Should make it for a failed deploy", func() {
+			Expect(notifyResult(DeployFailed, WithNotifyOnly(DeployFailed))).To(Succeed())
+			Expect(path).To(Equal("/deployments/octoblu/my-application/v1/cluster/super/failed"))
+		})
+	})
+
+	Describe("When the deployer only notifies on success", func() {
+		It("Should not make the request for a failed deploy", func() {
+			Expect(notifyResult(DeployFailed, WithNotifyOnly(DeployPassed))).To(Succeed())
+			Expect(path).To(BeEmpty())
+		})
+	})
+
+	It("Should not send credentials", func() {
+		Expect(notify()).To(Succeed())
+		Expect(header.Get("Authorization")).To(BeEmpty())
+	})
+
+	Describe("When there is a deploy-state auth token", func() {
+		It("Should send it as a bearer token", func() {
+			Expect(notify(WithDeployStateAuth(DeployStateAuthBearer, "secret"))).To(Succeed())
+			Expect(header.Get("Authorization")).To(Equal("Bearer secret"))
+		})
+
+		It("Should send it base64 encoded for Basic", func() {
+			Expect(notify(WithDeployStateAuth(DeployStateAuthBasic, "governator:secret"))).To(Succeed())
+			Expect(header.Get("Authorization")).To(Equal("Basic Z292ZXJuYXRvcjpzZWNyZXQ="))
+		})
+
+		It("Should sign the body and timestamp for HMAC", func() {
+			Expect(notify(WithDeployStateAuth(DeployStateAuthHMAC, "secret"))).To(Succeed())
+			Expect(header.Get("Authorization")).To(BeEmpty())
+			Expect(header.Get("X-Timestamp")).NotTo(BeEmpty())
+			Expect(header.Get("X-Signature")).To(Equal(signDeployStateRequest("secret", body, header.Get("X-Timestamp"))))
+		})
+	})
+
+	Describe("When deploy-state is slower than the timeout", func() {
+		BeforeEach(func() {
+			delay = 500 * time.Millisecond
+		})
+
+		It("Should time out with a retryable error", func() {
+			start := time.Now()
+			err := notify(WithDeployStateTimeout(100 * time.Millisecond))
+			Expect(err).To(BeAssignableToTypeOf(&NotifyError{}))
+			Expect(err.(*NotifyError).IsRetryable()).To(BeTrue())
+			Expect(time.Since(start)).To(BeNumerically("<", delay))
+		})
+	})
+
+	Describe("When notifications are rate limited", func() {
+		It("Should wait for the limit between requests", func() {
+			sut := New(nil, nil, "redis-queue:name", server.URL, "super", WithNotifyRateLimit(10, 1))
+			metadata := &RequestMetadata{DockerURL: "octoblu/my-application:v1"}
+
+			start := time.Now()
+			Expect(sut.notifyDeployState(metadata, DeployPassed)).To(Succeed())
+			Expect(sut.notifyDeployState(metadata, DeployPassed)).To(Succeed())
+			Expect(time.Since(start)).To(BeNumerically(">=", 90*time.Millisecond))
+		})
+
+		It("Should fail with a retryable error when the wait is longer than the timeout", func() {
+			sut := New(nil, nil, "redis-queue:name", server.URL, "super", WithNotifyRateLimit(0.1, 1), WithDeployStateTimeout(100*time.Millisecond))
+			metadata := &RequestMetadata{DockerURL: "octoblu/my-application:v1"}
+
+			Expect(sut.notifyDeployState(metadata, DeployPassed)).To(Succeed())
+			err := sut.notifyDeployState(metadata, DeployPassed)
+			Expect(err).To(BeAssignableToTypeOf(&NotifyError{}))
+			Expect(err.(*NotifyError).IsRetryable()).To(BeTrue())
+		})
+	})
+
+	Describe("When deploy-state responds with an error", func() {
+		BeforeEach(func() {
+			statusCode = http.StatusBadRequest
+		})
+
+		It("Should return a NotifyError with the status code", func() {
+			err := notify()
+			Expect(err).To(BeAssignableToTypeOf(&NotifyError{}))
+			Expect(err.(*NotifyError).StatusCode).To(Equal(http.StatusBadRequest))
+		})
+	})
+})
+
+var _ = Describe("Deploy-state notification of deploys", func() {
+	var dockerClient *fakeDockerClient
+	var redisConn *redigomock.Conn
+	var redisPool *redis.Pool
+
+	BeforeEach(func() {
+		dockerClient = &fakeDockerClient{service: swarm.Service{ID: "service-id"}}
+		dockerClient.service.Spec.Name = "my-application"
+		redisConn = redigomock.NewConn()
+		redisPool = &redis.Pool{Dial: func() (redis.Conn, error) { return redisConn, nil }}
+	})
+
+	deploy := func(options ...Option) error {
+		sut := New(dockerClient, redisPool, "redis-queue:name", deployState.URL, "super", options...)
+		return sut.deploy("my-application:v2", &RequestMetadata{DockerURL: "octoblu/my-application:v2"})
+	}
+
+	deadLetter := func(options ...Option) error {
+		sut := New(dockerClient, redisPool, "redis-queue:name", deployState.URL, "super", options...)
+		metadata := &RequestMetadata{DockerURL: "octoblu/my-application:v2"}
+		return sut.handleDeployError("my-application:v2", metadata, newValidationError("Invalid timeoutSeconds -1, must not be negative"))
+	}
+
+	BeforeEach(func() {
+		redisConn.Command("ZADD", "redis-queue:name:governator:dead-letters", redigomock.NewAnyInt(), "my-application:v2").Expect(int64(1))
+	})
+
+	It("Should mark a passed deploy as passed", func() {
+		Expect(deploy()).To(Succeed())
+		Expect(deployState.requests()).To(Equal([]string{"PUT /deployments/octoblu/my-application/v2/cluster/super/passed"}))
+	})
+
+	It("Should mark a dead lettered deploy as failed", func() {
+		Expect(deadLetter()).To(Succeed())
+		Expect(deployState.requests()).To(Equal([]string{"PUT /deployments/octoblu/my-application/v2/cluster/super/failed"}))
+	})
+
+	Describe("When notifications are rate limited", func() {
+		It("Should wait for the limit between the deploys' requests", func() {
+			registry := metrics.NewRegistry()
+			sut := New(dockerClient, redisPool, "redis-queue:name", deployState.URL, "super", WithNotifyRateLimit(10, 1), WithMetrics(registry))
+
+			start := time.Now()
+			Expect(sut.deploy("my-application:v2", &RequestMetadata{DockerURL: "octoblu/my-application:v2"})).To(Succeed())
+			Expect(sut.deploy("my-application:v3", &RequestMetadata{DockerURL: "octoblu/my-application:v3"})).To(Succeed())
+			Expect(time.Since(start)).To(BeNumerically(">=", 90*time.Millisecond))
+			Expect(deployState.requests()).To(HaveLen(2))
+
+			var output bytes.Buffer
+			Expect(registry.WritePrometheus(&output)).To(Succeed())
+			Expect(output.String()).To(ContainSubstring("governator_notify_wait_seconds_count 2"))
+		})
+	})
+
+	Describe("When the deployer only notifies on failure", func() {
+		It("Should not make a request for a passed deploy", func() {
+			Expect(deploy(WithNotifyOnly(DeployFailed))).To(Succeed())
+			Expect(dockerClient.updates).To(HaveLen(1))
+			Expect(deployState.requests()).To(BeEmpty())
+		})
+	})
+
+	Describe("When the deployer only notifies on success", func() {
+		It("Should not make a request for a dead lettered deploy", func() {
+			Expect(deadLetter(WithNotifyOnly(DeployPassed))).To(Succeed())
+			Expect(deployState.requests()).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,491 @@
+package deployer
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/octoblu/governator-swarm/metrics"
+	"github.com/octoblu/governator-swarm/monitor"
+	"github.com/octoblu/governator-swarm/notifier"
+	"golang.org/x/time/rate"
+)
+
+// Option configures optional behaviour of a Deployer
+type Option func(*Deployer)
+
+// WithManifestDir makes the deployer read deploy metadata from
+// <dir>/<deploy>.toml when that file exists, instead of redis
+func WithManifestDir(dir string) Option {
+	return func(deployer *Deployer) {
+		deployer.manifestDir = dir
+	}
+}
+
+// WithDockerLabelFilter restricts the deployer to services
+// whose labels contain every one of the given labels
+func WithDockerLabelFilter(labels map[string]string) Option {
+	return func(deployer *Deployer) {
+		deployer.dockerLabelFilter = labels
+	}
+}
+
+// WithNodeLabelSelector constrains every deployed service to nodes
+// with all of the given labels, on top of its own constraints
+func WithNodeLabelSelector(labels map[string]string) Option {
+	return func(deployer *Deployer) {
+		deployer.nodeLabelSelector = labels
+	}
+}
+
+// WithDefaultStopGracePeriod sets the stop grace period used when
+// the deploy metadata has none. Zero leaves the service's setting alone
+func WithDefaultStopGracePeriod(stopGracePeriod time.Duration) Option {
+	return func(deployer *Deployer) {
+		deployer.defaultStopGracePeriod = stopGracePeriod
+	}
+}
+
+// WithRegistryMirror rewrites deployed images to be pulled from mirror.
+// prefixStrip, when set, is the part of the image that the mirror replaces
+func WithRegistryMirror(mirror, prefixStrip string) Option {
+	return func(deployer *Deployer) {
+		deployer.registryMirror = mirror
+		deployer.registryMirrorPrefixStrip = prefixStrip
+	}
+}
+
+// WithDeployTimeout limits how long a deploy may take, unless the
+// deploy metadata sets its own timeoutSeconds. Zero means no limit
+func WithDeployTimeout(timeout time.Duration) Option {
+	return func(deployer *Deployer) {
+		deployer.deployTimeout = timeout
+	}
+}
+
+// WithGlobalMaxParallelism limits how many deploys may run at once
+// across every deployer on the queue. A deploy waits up to wait for a
+// slot before being requeued
+func WithGlobalMaxParallelism(limit int, wait time.Duration) Option {
+	return func(deployer *Deployer) {
+		deployer.globalMaxParallelism = limit
+		deployer.concurrencyWait = wait
+	}
+}
+
+// WithKeyPrefix prepends prefix to every redis key, so deployers
+// can share a redis instance. Whatever queues the deploys
+// must use the same prefix
+func WithKeyPrefix(prefix string) Option {
+	return func(deployer *Deployer) {
+		deployer.keyPrefix = prefix
+	}
+}
+
+// WithKeyBuilder names the deploy queue's keys with keys
+// instead of the DefaultKeyBuilder. WithKeyPrefix is not
+// applied to them
+func WithKeyBuilder(keys KeyBuilder) Option {
+	return func(deployer *Deployer) {
+		deployer.keys = keys
+	}
+}
+
+// WithAllowedRegistries only lets deploys of images from the
+// registries through. Docker hub images are from docker.io
+func WithAllowedRegistries(registries ...string) Option {
+	return func(deployer *Deployer) {
+		deployer.allowedRegistries = registries
+	}
+}
+
+// WithDeployStateAuth authenticates requests to the deploy-state
+// service with token, using the Bearer, Basic or HMAC scheme
+func WithDeployStateAuth(authType, token string) Option {
+	return func(deployer *Deployer) {
+		deployer.deployStateAuthType = authType
+		deployer.deployStateAuthToken = token
+	}
+}
+
+// WithServiceRecreation removes and recreates a service
+// when docker refuses to change its mode in place
+func WithServiceRecreation() Option {
+	return func(deployer *Deployer) {
+		deployer.allowServiceRecreation = true
+	}
+}
+
+// WithUpdateFailureAction sets what swarm does when an update fails,
+// for deploys that don't set updateConfig.failureAction
+func WithUpdateFailureAction(action string) Option {
+	return func(deployer *Deployer) {
+		deployer.updateFailureAction = action
+	}
+}
+
+// WithConvergence waits for every replica to run the new image
+// before a deploy passes, for all deploys
+func WithConvergence() Option {
+	return func(deployer *Deployer) {
+		deployer.alwaysConverge = true
+	}
+}
+
+// WithConfigSource is where CheckConfigs looks up the configs of services
+func WithConfigSource(source ConfigSource) Option {
+	return func(deployer *Deployer) {
+		deployer.configSource = source
+	}
+}
+
+// WithAuditLog appends a JSON record of every deploy attempt to path
+func WithAuditLog(path string) Option {
+	return func(deployer *Deployer) {
+		deployer.auditLogPath = path
+	}
+}
+
+// WithAuditLogMaxSize rotates the audit log once
+// it reaches megabytes. Zero never rotates it
+func WithAuditLogMaxSize(megabytes int) Option {
+	return func(deployer *Deployer) {
+		deployer.auditLogMaxSize = megabytes
+	}
+}
+
+// WithEtcdClient makes deploys with an etcdDir also write
+// their docker url and release to etcd
+func WithEtcdClient(etcdClient EtcdClient) Option {
+	return func(deployer *Deployer) {
+		deployer.etcdClient = etcdClient
+	}
+}
+
+// WithDeployStateTimeout limits how long a request to the
+// deploy-state service may take. Zero means no limit
+func WithDeployStateTimeout(timeout time.Duration) Option {
+	return func(deployer *Deployer) {
+		deployer.deployStateTimeout = timeout
+	}
+}
+
+// WithDeployStateHTTP2 enables or disables HTTP/2 for https://
+// deploy-state URIs. It is enabled by default
+func WithDeployStateHTTP2(enabled bool) Option {
+	return func(deployer *Deployer) {
+		deployer.deployStateHTTP2 = enabled
+	}
+}
+
+// WithDeployStateProxy sends deploy-state requests through the
+// proxies in config, rather than those in the env
+func WithDeployStateProxy(config *ProxyConfig) Option {
+	return func(deployer *Deployer) {
+		deployer.deployStateProxy = config
+	}
+}
+
+// WithClusterEnv sets env on every deployed service,
+// alongside GOVERNATOR_CLUSTER=<cluster>
+func WithClusterEnv(env map[string]string) Option {
+	return func(deployer *Deployer) {
+		deployer.clusterEnv = env
+	}
+}
+
+// WithNoClusterEnv stops the deployer from setting GOVERNATOR_CLUSTER
+// and the WithClusterEnv env on deployed services
+func WithNoClusterEnv() Option {
+	return func(deployer *Deployer) {
+		deployer.noClusterEnv = true
+	}
+}
+
+// WithNativeRollback makes rollbacks use docker's own rollback
+// through rollbacker, when the daemon's API is new enough for it
+func WithNativeRollback(rollbacker ServiceRollbacker) Option {
+	return func(deployer *Deployer) {
+		deployer.nativeRollback = rollbacker
+	}
+}
+
+// WithConsumerID names this deployer in the queue, so the deploys
+// it holds can be requeued if it stops sending heartbeats
+func WithConsumerID(consumerID string) Option {
+	return func(deployer *Deployer) {
+		deployer.consumerID = consumerID
+	}
+}
+
+// WithImageTagTransforms rewrites the tag of every
+// deployed image with the transforms, in order
+func WithImageTagTransforms(transforms ...*TagTransform) Option {
+	return func(deployer *Deployer) {
+		deployer.tagTransforms = transforms
+	}
+}
+
+// WithDeployWindow watches the service's tasks for window after each
+// deploy, polling every interval, and rolls back if a new task fails
+func WithDeployWindow(window, interval time.Duration) Option {
+	return func(deployer *Deployer) {
+		deployer.deployWindow = window
+		deployer.monitor = monitor.New(deployer.dockerClient, interval)
+	}
+}
+
+// WithMinHealthyPeriod watches the service's tasks for period once
+// a deploy is otherwise done, polling every interval, and fails and
+// rolls back the deploy if a new task fails in that time
+func WithMinHealthyPeriod(period, interval time.Duration) Option {
+	return func(deployer *Deployer) {
+		deployer.minHealthyPeriod = period
+		deployer.monitor = monitor.New(deployer.dockerClient, interval)
+	}
+}
+
+// WithFailureLogs logs the last log line of tasks that fail while a
+// deploy is watching them
+func WithFailureLogs() Option {
+	return func(deployer *Deployer) {
+		deployer.captureFailureLogs = true
+	}
+}
+
+// WithNotifyOnly only notifies deploy-state of deploys
+// with result, DeployPassed or DeployFailed
+func WithNotifyOnly(result string) Option {
+	return func(deployer *Deployer) {
+		deployer.notifyOnly = result
+	}
+}
+
+// WithPollInterval waits interval between Runs, for
+// deploys that don't set their own pollInterval
+func WithPollInterval(interval time.Duration) Option {
+	return func(deployer *Deployer) {
+		deployer.pollInterval = interval
+	}
+}
+
+// WithStaggerDelay waits a random delay between min and max, on top of
+// the poll interval, after each successful deploy. A seed of 0 seeds
+// the delays randomly
+func WithStaggerDelay(min, max time.Duration, seed int64) Option {
+	return func(deployer *Deployer) {
+		deployer.staggerMin = min
+		deployer.staggerMax = max
+		deployer.staggerRand = newStaggerRand(seed)
+	}
+}
+
+// WithBackpressure stops taking deploys off the queue, sleeping for
+// sleep between checks instead, while the P99 latency of the docker
+// service inspects and updates in the last minute exceeds threshold
+func WithBackpressure(threshold, sleep time.Duration) Option {
+	return func(deployer *Deployer) {
+		deployer.backpressure = NewBackpressureController(threshold, backpressureWindow)
+		deployer.backpressureSleep = sleep
+	}
+}
+
+// WithSecretLister checks that the secrets a deploy
+// requires exist in the swarm during its preflight check
+func WithSecretLister(lister SecretLister) Option {
+	return func(deployer *Deployer) {
+		deployer.secretLister = lister
+	}
+}
+
+// WithNotifier sends each finished deploy to deployNotifier,
+// on top of deploy-state. It can be given more than once
+func WithNotifier(deployNotifier notifier.Notifier) Option {
+	return func(deployer *Deployer) {
+		deployer.notifiers = append(deployer.notifiers, deployNotifier)
+	}
+}
+
+// WithDigestVerification warns when a deploy's imageDigest
+// isn't what its tag points to, as reported by resolver
+func WithDigestVerification(resolver DigestResolver) Option {
+	return func(deployer *Deployer) {
+		deployer.digestResolver = resolver
+	}
+}
+
+// WithNotifyChannels sends each finished deploy to the
+// channels its notifyChannels name, looked up in channels
+func WithNotifyChannels(channels notifier.Channels) Option {
+	return func(deployer *Deployer) {
+		deployer.notifyChannels = channels
+	}
+}
+
+// WithRequireSemverTag refuses to deploy images whose tag isn't
+// semver, after stripping prefix, e.g. v, when the tag starts with it
+func WithRequireSemverTag(prefix string) Option {
+	return func(deployer *Deployer) {
+		deployer.requireSemverTag = true
+		deployer.semverPrefix = prefix
+	}
+}
+
+// WithMaxImageAge refuses to deploy images built longer than maxAge
+// ago. With skipDigests, digest pinned images aren't checked
+func WithMaxImageAge(maxAge time.Duration, skipDigests bool) Option {
+	return func(deployer *Deployer) {
+		deployer.maxImageAge = maxAge
+		deployer.maxImageAgeSkipDigests = skipDigests
+	}
+}
+
+// WithEnforceReadonlyRootfs refuses deploys that
+// set readonlyRootfs to false with a ValidationError
+func WithEnforceReadonlyRootfs() Option {
+	return func(deployer *Deployer) {
+		deployer.enforceReadonlyRootfs = true
+	}
+}
+
+// WithDeployStateRetryQueue pushes deploy-state notifications that
+// failed, but can be retried, onto the redis list key, for the
+// NotifyRetryWorker to send, instead of failing the deploy
+func WithDeployStateRetryQueue(key string) Option {
+	return func(deployer *Deployer) {
+		deployer.deployStateRetryQueue = key
+	}
+}
+
+// WithConfigFiles reads the notify channel map and the tag transforms
+// from files, either may be empty, on each RefreshConfig. The file's tag
+// transforms are applied after those of WithImageTagTransforms
+func WithConfigFiles(notifyChannelMap, tagTransforms string) Option {
+	return func(deployer *Deployer) {
+		deployer.notifyChannelMapFile = notifyChannelMap
+		deployer.tagTransformsFile = tagTransforms
+	}
+}
+
+// WithHealthRouting sets the key=value label on the service, and waits
+// for drainWait, before updating it, so the load balancer stops sending
+// it traffic. The label is restored once the deploy is done
+func WithHealthRouting(key, value string, drainWait time.Duration) Option {
+	return func(deployer *Deployer) {
+		deployer.healthRoutingKey = key
+		deployer.healthRoutingValue = value
+		deployer.drainWait = drainWait
+	}
+}
+
+// WithConflictRetry retries a service update once when docker refuses
+// it because of a conflicting update, after waiting up to backoff for
+// that update to finish
+func WithConflictRetry(backoff time.Duration) Option {
+	return func(deployer *Deployer) {
+		deployer.conflictBackoff = backoff
+	}
+}
+
+// WithRedisCompression compresses the request metadata the deployer
+// writes to redis with algorithm, CompressionGzip or CompressionZstd.
+// Compressed metadata is read whether or not this is used
+func WithRedisCompression(algorithm string) Option {
+	return func(deployer *Deployer) {
+		if algorithm == CompressionZstd {
+			deployer.metadataCompressor = zstdCompressor{}
+			return
+		}
+		deployer.metadataCompressor = gzipCompressor{}
+	}
+}
+
+// WithQueueScanLimit limits how many due deploys are read from the
+// queue at a time, so a deep queue doesn't send its whole backlog on
+// every poll. Limits below 1 are ignored
+func WithQueueScanLimit(limit int) Option {
+	return func(deployer *Deployer) {
+		if limit > 0 {
+			deployer.queueScanLimit = limit
+		}
+	}
+}
+
+// WithQueueName reads deploys from the redis queue name
+func WithQueueName(name string) Option {
+	return func(deployer *Deployer) {
+		deployer.queueName = name
+	}
+}
+
+// WithDeployStateURI notifies the deploy-state service at uri
+func WithDeployStateURI(uri string) Option {
+	return func(deployer *Deployer) {
+		deployer.deployStateURI = uri
+	}
+}
+
+// WithDeployStateSRV looks the deploy-state address up through the DNS
+// SRV record name when notifying, instead of using the deploy-state
+// URI. The address is cached for ttl
+func WithDeployStateSRV(name string, ttl time.Duration) Option {
+	return func(deployer *Deployer) {
+		deployer.deployStateSRV = newSRVResolver(name, ttl)
+	}
+}
+
+// WithCluster deploys as the cluster, as known to deploy-state
+func WithCluster(cluster string) Option {
+	return func(deployer *Deployer) {
+		deployer.cluster = cluster
+	}
+}
+
+// WithLogger logs to logger instead of the standard logger
+func WithLogger(logger Logger) Option {
+	return func(deployer *Deployer) {
+		deployer.logger = logger
+	}
+}
+
+// WithMetrics records the deployer's metrics to recorder
+// instead of metrics.DefaultRecorder
+func WithMetrics(recorder metrics.Recorder) Option {
+	return func(deployer *Deployer) {
+		deployer.metrics = recorder
+	}
+}
+
+// WithHTTPClient sends deploy-state requests with httpClient. It
+// replaces the client built from WithDeployStateTimeout,
+// WithDeployStateHTTP2 and WithDeployStateProxy, which are then ignored
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(deployer *Deployer) {
+		deployer.deployStateClient = httpClient
+	}
+}
+
+// WithRetryPolicy retries a deploy that failed with a retryable error
+// after delay, until it has been attempted maxAttempts times
+func WithRetryPolicy(maxAttempts int, delay time.Duration) Option {
+	return func(deployer *Deployer) {
+		deployer.maxDeployAttempts = maxAttempts
+		deployer.retryDelay = delay
+	}
+}
+
+// WithEventSubscriber lists a watched service's tasks as soon as
+// subscriber sees an event for it, instead of waiting for the next poll.
+// The subscriber must be Run separately
+func WithEventSubscriber(subscriber *monitor.EventSubscriber) Option {
+	return func(deployer *Deployer) {
+		deployer.eventSubscriber = subscriber
+	}
+}
+
+// WithNotifyRateLimit sends at most requestsPerSecond deploy-state
+// requests, with bursts of up to burst
+func WithNotifyRateLimit(requestsPerSecond float64, burst int) Option {
+	return func(deployer *Deployer) {
+		deployer.notifyLimiter = rate.NewLimiter(rate.Limit(requestsPerSecond), burst)
+	}
+}
@@ -0,0 +1,74 @@
+package deployer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rafaeljusto/redigomock"
+)
+
+var _ = Describe("NewDeployer", func() {
+	It("Should be configured like New", func() {
+		sut := NewDeployer(nil, nil, WithQueueName("redis-queue:name"), WithDeployStateURI("https://deploy-state.test"), WithCluster("super"))
+		Expect(sut.queueName).To(Equal("redis-queue:name"))
+		Expect(sut.deployStateURI).To(Equal("https://deploy-state.test"))
+		Expect(sut.cluster).To(Equal("super"))
+		Expect(sut.keys.DeployKey()).To(Equal("redis-queue:name:governator:deploys"))
+	})
+
+	Describe("WithRetryPolicy", func() {
+		var sut *Deployer
+		var redisConn *redigomock.Conn
+		var logger *fakeLogger
+
+		BeforeEach(func() {
+			redisConn = redigomock.NewConn()
+			redisPool := &redis.Pool{Dial: func() (redis.Conn, error) { return redisConn, nil }}
+			logger = &fakeLogger{}
+			sut = NewDeployer(nil, redisPool, WithQueueName("redis-queue:name"), WithRetryPolicy(2, time.Minute), WithLogger(logger))
+		})
+
+		It("Should dead letter the deploy after maxAttempts", func() {
+			hincrby := redisConn.Command("HINCRBY", "redis-queue:name:my-application:v2", "deploy:attempts", 1).Expect(int64(2))
+			dlq := redisConn.Command("ZADD", "redis-queue:name:governator:dead-letters", redigomock.NewAnyInt(), "my-application:v2").Expect(int64(1))
+
+			err := sut.handleDeployError("my-application:v2", nil, &DependencyPendingError{Deploy: "my-application:v2", Dependency: "my-database:v2"})
+			Expect(err).To(BeNil())
+			Expect(redisConn.Stats(hincrby)).To(Equal(1))
+			Expect(redisConn.Stats(dlq)).To(Equal(1))
+			Expect(logger.lines).To(HaveLen(1))
+		})
+	})
+
+	Describe("WithQueueScanLimit", func() {
+		It("Should limit how many due deploys are read", func() {
+			redisConn := redigomock.NewConn()
+			redisPool := &redis.Pool{Dial: func() (redis.Conn, error) { return redisConn, nil }}
+			sut := NewDeployer(nil, redisPool, WithQueueName("redis-queue:name"), WithQueueScanLimit(3))
+			zrange := redisConn.Command("ZRANGEBYSCORE", "redis-queue:name:governator:deploys", 0, redigomock.NewAnyInt(), "WITHSCORES", "LIMIT", 0, 3).Expect([]interface{}{[]byte("my-application:v2"), []byte("1")})
+
+			deploy, score, err := sut.getNextDeploy()
+			Expect(err).To(BeNil())
+			Expect(deploy).To(Equal("my-application:v2"))
+			Expect(score).To(Equal(int64(1)))
+			Expect(redisConn.Stats(zrange)).To(Equal(1))
+		})
+
+		It("Should ignore limits below 1", func() {
+			sut := NewDeployer(nil, nil, WithQueueScanLimit(0))
+			Expect(sut.queueScanLimit).To(Equal(DefaultQueueScanLimit))
+		})
+	})
+})
+
+// fakeLogger keeps what was logged
+type fakeLogger struct {
+	lines []string
+}
+
+func (logger *fakeLogger) Println(v ...interface{}) {
+	logger.lines = append(logger.lines, fmt.Sprintln(v...))
+}
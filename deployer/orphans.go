@@ -0,0 +1,81 @@
+package deployer
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/octoblu/governator-swarm/metrics"
+	"golang.org/x/net/context"
+)
+
+func init() {
+	metrics.DefaultRegistry.Help("governator_startup_orphans_cleaned", "Orphaned deploy hashes deleted by --cleanup-orphan-metadata at startup")
+}
+
+// CleanupOrphanMetadata deletes the deploy hashes that are neither
+// queued nor dead lettered, and are older than maxAge, and returns how
+// many were deleted. A hash's age is since its deploy:timestamp or,
+// when the deploy was never locked, since redis last read it
+func (deployer *Deployer) CleanupOrphanMetadata(ctx context.Context, maxAge time.Duration) (int, error) {
+	redisConn := deployer.redisPool.Get()
+	defer redisConn.Close()
+
+	deleted, err := deployer.deleteDeployKeys(ctx, redisConn, func(key string) (bool, error) {
+		return deployer.isOrphanedDeploy(redisConn, key, maxAge)
+	})
+	if err != nil {
+		return deleted, newRedisError(err)
+	}
+
+	deployer.metrics.SetGauge("governator_startup_orphans_cleaned", float64(deleted), nil)
+	return deleted, nil
+}
+
+func (deployer *Deployer) isOrphanedDeploy(redisConn redis.Conn, key string, maxAge time.Duration) (bool, error) {
+	keyType, err := redis.String(redisConn.Do("TYPE", key))
+	if err != nil {
+		return false, err
+	}
+
+	if keyType != "hash" {
+		return false, nil
+	}
+
+	// read before anything else touches the key and resets it
+	idleSeconds, err := redis.Int64(redisConn.Do("OBJECT", "IDLETIME", key))
+	if err != nil {
+		return false, err
+	}
+
+	isDeploy, err := redis.Bool(redisConn.Do("HEXISTS", key, "request:metadata"))
+	if err != nil || !isDeploy {
+		return false, err
+	}
+
+	deploy := strings.TrimPrefix(key, deployer.getKey(""))
+	for _, setKey := range []string{deployer.keys.DeployKey(), deployer.keys.DLQKey()} {
+		_, err = redis.String(redisConn.Do("ZSCORE", setKey, deploy))
+		if err == nil {
+			return false, nil
+		}
+		if err != redis.ErrNil {
+			return false, err
+		}
+	}
+
+	timestamp, err := redis.String(redisConn.Do("HGET", key, "deploy:timestamp"))
+	if err == redis.ErrNil {
+		return time.Duration(idleSeconds)*time.Second > maxAge, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	deployedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false, nil
+	}
+	return deployedAt < time.Now().Add(-maxAge).Unix(), nil
+}
@@ -0,0 +1,95 @@
+package deployer
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/octoblu/governator-swarm/metrics"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rafaeljusto/redigomock"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("CleanupOrphanMetadata", func() {
+	var sut *Deployer
+	var redisConn *redigomock.Conn
+	var registry *metrics.Registry
+
+	BeforeEach(func() {
+		redisConn = redigomock.NewConn()
+		redisPool := &redis.Pool{Dial: func() (redis.Conn, error) { return redisConn, nil }}
+		registry = metrics.NewRegistry()
+		sut = New(nil, redisPool, "redis-queue:name", "https://deploy-state.test", "super", WithMetrics(registry))
+	})
+
+	deployHash := func(deploy string, idle time.Duration, timestamp string, set string) {
+		key := "redis-queue:name:" + deploy
+		redisConn.Command("TYPE", key).Expect("hash")
+		redisConn.Command("OBJECT", "IDLETIME", key).Expect(int64(idle.Seconds()))
+		redisConn.Command("HEXISTS", key, "request:metadata").Expect(int64(1))
+
+		for _, setKey := range []string{"redis-queue:name:governator:deploys", "redis-queue:name:governator:dead-letters"} {
+			if setKey == set {
+				redisConn.Command("ZSCORE", setKey, deploy).Expect([]byte("1500000000"))
+				return
+			}
+			redisConn.Command("ZSCORE", setKey, deploy).Expect(nil)
+		}
+
+		if timestamp == "" {
+			redisConn.Command("HGET", key, "deploy:timestamp").Expect(nil)
+			return
+		}
+		redisConn.Command("HGET", key, "deploy:timestamp").Expect([]byte(timestamp))
+	}
+
+	It("Should delete old hashes that are neither queued nor dead lettered", func() {
+		old := fmt.Sprintf("%v", time.Now().Add(-8*24*time.Hour).Unix())
+		recent := fmt.Sprintf("%v", time.Now().Add(-time.Hour).Unix())
+
+		redisConn.Command("SCAN", "0", "MATCH", "redis-queue:name:*", "COUNT", 100).Expect([]interface{}{
+			[]byte("0"),
+			[]interface{}{
+				[]byte("redis-queue:name:queued:v1"),
+				[]byte("redis-queue:name:dead:v1"),
+				[]byte("redis-queue:name:old:v1"),
+				[]byte("redis-queue:name:recent:v1"),
+				[]byte("redis-queue:name:never-locked:v1"),
+				[]byte("redis-queue:name:governator:history"),
+				[]byte("redis-queue:name:governator:config-versions"),
+			},
+		})
+		deployHash("queued:v1", 30*24*time.Hour, "", "redis-queue:name:governator:deploys")
+		deployHash("dead:v1", 30*24*time.Hour, old, "redis-queue:name:governator:dead-letters")
+		deployHash("old:v1", time.Minute, old, "")
+		deployHash("recent:v1", 30*24*time.Hour, recent, "")
+		deployHash("never-locked:v1", 30*24*time.Hour, "", "")
+		redisConn.Command("TYPE", "redis-queue:name:governator:history").Expect("list")
+		redisConn.Command("TYPE", "redis-queue:name:governator:config-versions").Expect("hash")
+		redisConn.Command("OBJECT", "IDLETIME", "redis-queue:name:governator:config-versions").Expect(int64(0))
+		redisConn.Command("HEXISTS", "redis-queue:name:governator:config-versions", "request:metadata").Expect(int64(0))
+
+		delOld := redisConn.Command("DEL", "redis-queue:name:old:v1").Expect(int64(1))
+		delNeverLocked := redisConn.Command("DEL", "redis-queue:name:never-locked:v1").Expect(int64(1))
+
+		cleaned, err := sut.CleanupOrphanMetadata(context.Background(), 7*24*time.Hour)
+		Expect(err).To(BeNil())
+		Expect(cleaned).To(Equal(2))
+		Expect(redisConn.Stats(delOld)).To(Equal(1))
+		Expect(redisConn.Stats(delNeverLocked)).To(Equal(1))
+
+		var buffer bytes.Buffer
+		Expect(registry.WritePrometheus(&buffer)).To(Succeed())
+		Expect(buffer.String()).To(ContainSubstring("governator_startup_orphans_cleaned 2"))
+	})
+
+	It("Should return a RedisError when the scan fails", func() {
+		redisConn.Command("SCAN", "0", "MATCH", "redis-queue:name:*", "COUNT", 100).ExpectError(fmt.Errorf("things went worse than expected"))
+
+		_, err := sut.CleanupOrphanMetadata(context.Background(), 7*24*time.Hour)
+		Expect(err).To(BeAssignableToTypeOf(&RedisError{}))
+	})
+})
@@ -0,0 +1,46 @@
+package deployer
+
+import (
+	"fmt"
+	"time"
+)
+
+// pauseCheckInterval is how often a paused
+// deploy checks whether it was cancelled
+var pauseCheckInterval = time.Second
+
+// pauseDeploy waits for the deploy's preDeployPauseSecs before it goes
+// out, so it can still be cancelled. It returns false if it was
+func (deployer *Deployer) pauseDeploy(deploy string, metadata *RequestMetadata) (bool, error) {
+	if metadata.PreDeployPauseSecs <= 0 {
+		return true, nil
+	}
+
+	pause := time.Duration(metadata.PreDeployPauseSecs) * time.Second
+	deployer.events.publish(deploy, EventPaused, fmt.Sprintf("deploying at %v", time.Now().Add(pause).UTC().Format(time.RFC3339)))
+	deployer.logger.Println("Pausing deploy", deploy, "for", pause)
+
+	ticker := time.NewTicker(pauseCheckInterval)
+	defer ticker.Stop()
+	deadline := time.After(pause)
+
+	for {
+		select {
+		case <-deadline:
+			return true, nil
+		case <-ticker.C:
+		}
+
+		// the deploy loop isn't stuck while it waits
+		deployer.startRun()
+
+		cancelled, err := deployer.isCancelled(deploy)
+		if err != nil {
+			return false, err
+		}
+		if cancelled {
+			deployer.logger.Println("Deploy was cancelled during its pause", deploy)
+			return false, nil
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package deployer
+
+import (
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rafaeljusto/redigomock"
+)
+
+var _ = Describe("pauseDeploy", func() {
+	var sut *Deployer
+	var redisConn *redigomock.Conn
+	var metadata *RequestMetadata
+
+	BeforeEach(func() {
+		pauseCheckInterval = 10 * time.Millisecond
+		redisConn = redigomock.NewConn()
+		redisPool := &redis.Pool{Dial: func() (redis.Conn, error) { return redisConn, nil }}
+		sut = New(nil, redisPool, "redis-queue:name", "https://deploy-state.test", "super")
+		metadata = &RequestMetadata{PreDeployPauseSecs: 1}
+	})
+
+	AfterEach(func() {
+		pauseCheckInterval = time.Second
+	})
+
+	Describe("When the deploy doesn't pause", func() {
+		It("Should go ahead without checking for a cancellation", func() {
+			ok, err := sut.pauseDeploy("my-application:v2", &RequestMetadata{})
+			Expect(err).To(BeNil())
+			Expect(ok).To(BeTrue())
+		})
+	})
+
+	Describe("When the deploy is cancelled during the pause", func() {
+		It("Should stop waiting and not go ahead", func() {
+			redisConn.Command("HEXISTS", "redis-queue:name:my-application:v2", "cancellation").Expect(int64(0)).Expect(int64(1))
+
+			start := time.Now()
+			ok, err := sut.pauseDeploy("my-application:v2", metadata)
+			Expect(err).To(BeNil())
+			Expect(ok).To(BeFalse())
+			Expect(time.Since(start)).To(BeNumerically("<", 500*time.Millisecond))
+		})
+	})
+
+	Describe("When the deploy isn't cancelled", func() {
+		It("Should go ahead after the pause", func() {
+			hexists := redisConn.Command("HEXISTS", "redis-queue:name:my-application:v2", "cancellation")
+			for i := 0; i < 200; i++ {
+				hexists.Expect(int64(0))
+			}
+
+			start := time.Now()
+			ok, err := sut.pauseDeploy("my-application:v2", metadata)
+			Expect(err).To(BeNil())
+			Expect(ok).To(BeTrue())
+			Expect(time.Since(start)).To(BeNumerically(">=", time.Second))
+		})
+	})
+})
@@ -0,0 +1,40 @@
+package deployer
+
+import (
+	"sort"
+
+	"github.com/docker/engine-api/types/swarm"
+)
+
+// addNodeLabelConstraints adds a node.labels.<key>==<value> placement
+// constraint for each of the labels, keeping the service's own
+// constraints and not repeating ones it already has
+func addNodeLabelConstraints(spec *swarm.ServiceSpec, labels map[string]string) {
+	if len(labels) == 0 {
+		return
+	}
+
+	if spec.TaskTemplate.Placement == nil {
+		spec.TaskTemplate.Placement = &swarm.Placement{}
+	}
+	placement := spec.TaskTemplate.Placement
+
+	existing := map[string]bool{}
+	for _, constraint := range placement.Constraints {
+		existing[constraint] = true
+	}
+
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		constraint := "node.labels." + key + "==" + labels[key]
+		if existing[constraint] {
+			continue
+		}
+		placement.Constraints = append(placement.Constraints, constraint)
+	}
+}
@@ -0,0 +1,37 @@
+package deployer
+
+import (
+	"github.com/docker/engine-api/types/swarm"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("addNodeLabelConstraints", func() {
+	var sut *Deployer
+	var spec *swarm.ServiceSpec
+	var metadata *RequestMetadata
+
+	BeforeEach(func() {
+		sut = New(nil, nil, "redis-queue:name", "https://deploy-state.test", "super", WithNodeLabelSelector(map[string]string{"gpu": "true", "disk": "ssd"}))
+		spec = &swarm.ServiceSpec{}
+		metadata = &RequestMetadata{DockerURL: "octoblu/my-application:v2"}
+	})
+
+	It("Should constrain the service to the labelled nodes", func() {
+		err := sut.updateServiceSpec(spec, metadata)
+		Expect(err).To(BeNil())
+		Expect(spec.TaskTemplate.Placement.Constraints).To(Equal([]string{"node.labels.disk==ssd", "node.labels.gpu==true"}))
+	})
+
+	Describe("When the service has constraints of its own", func() {
+		BeforeEach(func() {
+			spec.TaskTemplate.Placement = &swarm.Placement{Constraints: []string{"node.role==worker", "node.labels.gpu==true"}}
+		})
+
+		It("Should add to them without repeating any", func() {
+			err := sut.updateServiceSpec(spec, metadata)
+			Expect(err).To(BeNil())
+			Expect(spec.TaskTemplate.Placement.Constraints).To(Equal([]string{"node.role==worker", "node.labels.gpu==true", "node.labels.disk==ssd"}))
+		})
+	})
+})
@@ -0,0 +1,138 @@
+package deployer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/engine-api/types/swarm"
+	"golang.org/x/net/context"
+)
+
+// dockerDefaultStopGracePeriod is how long docker waits for a task
+// to stop when the service doesn't set a stop grace period
+const dockerDefaultStopGracePeriod = 10 * time.Second
+
+// DeployPlan describes what a deploy would do to its service,
+// for CI to review before queueing the deploy
+type DeployPlan struct {
+	Service           string       `json:"service"`
+	CurrentImage      string       `json:"currentImage"`
+	ProposedImage     string       `json:"proposedImage"`
+	EnvChanges        []SpecChange `json:"envChanges"`
+	PlacementChanges  []SpecChange `json:"placementChanges"`
+	ResourceChanges   []SpecChange `json:"resourceChanges"`
+	EstimatedDowntime float64      `json:"estimatedDowntimeSeconds"`
+	Warnings          []string     `json:"warnings"`
+}
+
+// TestDeploy returns the plan for deploying metadata, without
+// updating the service. It fails the way the deploy would when
+// the metadata is invalid or the service isn't the deployer's
+func (deployer *Deployer) TestDeploy(metadata *RequestMetadata) (*DeployPlan, error) {
+	err := deployer.transformImageTag(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	_, repo, _ := deployer.parseDockerURL(metadata.DockerURL)
+	current, proposed, err := deployer.proposeServiceSpec(repo, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	err = deployer.checkServiceOwnership(current)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &DeployPlan{
+		Service:          repo,
+		CurrentImage:     current.Spec.TaskTemplate.ContainerSpec.Image,
+		ProposedImage:    proposed.TaskTemplate.ContainerSpec.Image,
+		EnvChanges:       []SpecChange{},
+		PlacementChanges: []SpecChange{},
+		ResourceChanges:  []SpecChange{},
+		Warnings:         []string{},
+	}
+
+	for _, change := range diffServiceSpecs(current.Spec, proposed) {
+		switch {
+		case strings.HasPrefix(change.Field, "env."):
+			plan.EnvChanges = append(plan.EnvChanges, change)
+		case strings.HasPrefix(change.Field, "placement."):
+			plan.PlacementChanges = append(plan.PlacementChanges, change)
+		case strings.HasPrefix(change.Field, "resources."):
+			plan.ResourceChanges = append(plan.ResourceChanges, change)
+		case change.Field == "mode":
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("service mode will change from %v to %v, which swarm can't do in place", change.Before, change.After))
+		case change.Field == "networks":
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("networks will change from '%v' to '%v'", change.Before, change.After))
+		}
+	}
+
+	if plan.CurrentImage == plan.ProposedImage {
+		plan.Warnings = append(plan.Warnings, "image is unchanged")
+	}
+
+	if replicated := proposed.Mode.Replicated; replicated != nil && replicated.Replicas != nil && *replicated.Replicas == 0 {
+		plan.Warnings = append(plan.Warnings, "service has no replicas, no task will run the new image")
+	}
+
+	downtime := estimateDowntime(proposed)
+	plan.EstimatedDowntime = downtime.Seconds()
+	if downtime > 0 {
+		plan.Warnings = append(plan.Warnings, "every task is replaced at once, the service will be down while they restart")
+	}
+	return plan, nil
+}
+
+// proposeServiceSpec inspects the named service, and returns
+// it with the spec deploying metadata would update it to
+func (deployer *Deployer) proposeServiceSpec(service string, metadata *RequestMetadata) (swarm.Service, swarm.ServiceSpec, error) {
+	current, err := deployer.inspectService(service)
+	if err != nil {
+		return current, swarm.ServiceSpec{}, err
+	}
+
+	proposed, err := copyServiceSpec(current.Spec)
+	if err != nil {
+		return current, proposed, err
+	}
+
+	err = deployer.updateServiceSpec(&proposed, metadata)
+	if err != nil {
+		return current, proposed, err
+	}
+
+	err = deployer.applyNetworkMode(context.Background(), &proposed, metadata)
+	return current, proposed, err
+}
+
+// estimateDowntime returns how long the service is expected to have no
+// running task. Swarm stops a task before starting its replacement, so
+// when the update parallelism covers every replica, or is 0 (all at once),
+// every task is down for up to its stop grace period. Otherwise some
+// tasks keep running and there is no downtime
+func estimateDowntime(spec swarm.ServiceSpec) time.Duration {
+	var parallelism uint64
+	if spec.UpdateConfig != nil {
+		parallelism = spec.UpdateConfig.Parallelism
+	}
+
+	if replicated := spec.Mode.Replicated; replicated != nil && replicated.Replicas != nil {
+		replicas := *replicated.Replicas
+		if replicas == 0 || (parallelism != 0 && parallelism < replicas) {
+			return 0
+		}
+	} else if parallelism != 0 {
+		// global services run a task per node, which isn't known here
+		return 0
+	}
+
+	stopGracePeriod := spec.TaskTemplate.ContainerSpec.StopGracePeriod
+	if stopGracePeriod != nil {
+		return *stopGracePeriod
+	}
+	return dockerDefaultStopGracePeriod
+}
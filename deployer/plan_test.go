@@ -0,0 +1,85 @@
+package deployer
+
+import (
+	"time"
+
+	"github.com/docker/engine-api/types/swarm"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TestDeploy", func() {
+	var sut *Deployer
+	var dockerClient *fakeDockerClient
+	var metadata *RequestMetadata
+	var plan *DeployPlan
+	var err error
+
+	BeforeEach(func() {
+		replicas := uint64(3)
+		dockerClient = &fakeDockerClient{service: swarm.Service{ID: "service-id"}}
+		dockerClient.service.Spec.Name = "my-application"
+		dockerClient.service.Spec.Mode.Replicated = &swarm.ReplicatedService{Replicas: &replicas}
+		dockerClient.service.Spec.UpdateConfig = &swarm.UpdateConfig{Parallelism: 1}
+		dockerClient.service.Spec.TaskTemplate.ContainerSpec.Image = "octoblu/my-application:v1"
+		dockerClient.service.Spec.TaskTemplate.ContainerSpec.Env = []string{"GOVERNATOR_CLUSTER=super", "PORT=80"}
+		sut = New(dockerClient, nil, "redis-queue:name", "https://deploy-state.test", "super", WithNodeLabelSelector(map[string]string{"zone": "east"}))
+		metadata = &RequestMetadata{DockerURL: "octoblu/my-application:v2", EnvOverrides: map[string]string{"PORT": "8080"}}
+	})
+
+	JustBeforeEach(func() {
+		plan, err = sut.TestDeploy(metadata)
+	})
+
+	It("Should plan the image, env and placement changes", func() {
+		Expect(err).To(BeNil())
+		Expect(plan.Service).To(Equal("my-application"))
+		Expect(plan.CurrentImage).To(Equal("octoblu/my-application:v1"))
+		Expect(plan.ProposedImage).To(Equal("octoblu/my-application:v2"))
+		Expect(plan.EnvChanges).To(Equal([]SpecChange{{Field: "env.PORT", Before: "80", After: "8080"}}))
+		Expect(plan.PlacementChanges).To(HaveLen(1))
+		Expect(plan.ResourceChanges).To(BeEmpty())
+	})
+
+	It("Should estimate no downtime when the update rolls", func() {
+		Expect(plan.EstimatedDowntime).To(Equal(0.0))
+		Expect(plan.Warnings).To(BeEmpty())
+	})
+
+	It("Should not update the service", func() {
+		Expect(dockerClient.updates).To(BeEmpty())
+	})
+
+	Describe("When every replica is updated at once", func() {
+		BeforeEach(func() {
+			stopGracePeriod := 30 * time.Second
+			dockerClient.service.Spec.UpdateConfig = nil
+			dockerClient.service.Spec.TaskTemplate.ContainerSpec.StopGracePeriod = &stopGracePeriod
+		})
+
+		It("Should estimate the stop grace period as downtime, and warn", func() {
+			Expect(plan.EstimatedDowntime).To(Equal(30.0))
+			Expect(plan.Warnings).To(ContainElement(ContainSubstring("replaced at once")))
+		})
+	})
+
+	Describe("When the image is unchanged", func() {
+		BeforeEach(func() {
+			metadata.DockerURL = "octoblu/my-application:v1"
+		})
+
+		It("Should warn", func() {
+			Expect(plan.Warnings).To(ContainElement("image is unchanged"))
+		})
+	})
+
+	Describe("When the metadata is invalid", func() {
+		BeforeEach(func() {
+			metadata.NetworkMode = "carrier-pigeon"
+		})
+
+		It("Should return the deploy's error", func() {
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+		})
+	})
+})
@@ -0,0 +1,46 @@
+package deployer
+
+import "time"
+
+// DefaultPollInterval is how long to wait between Runs
+const DefaultPollInterval = time.Second
+
+// PollInterval is how long to wait before the next Run. After a deploy
+// that set pollInterval succeeds, its interval is used until the next
+// deploy is taken off the queue. The stagger delay of the last
+// successful deploy is added on top. While the docker API is too slow,
+// the backpressure sleep is used instead
+func (deployer *Deployer) PollInterval() time.Duration {
+	if deployer.throttled {
+		return deployer.backpressureSleep
+	}
+	if deployer.deployPollInterval > 0 {
+		return deployer.deployPollInterval + deployer.staggerDelay
+	}
+	return deployer.pollInterval + deployer.staggerDelay
+}
+
+// TriggerDelay is how long to wait before waiting on a KeyspaceTrigger,
+// the stagger delay of the last successful deploy, or the backpressure
+// sleep while the docker API is too slow. Poll intervals don't apply
+func (deployer *Deployer) TriggerDelay() time.Duration {
+	if deployer.throttled {
+		return deployer.backpressureSleep
+	}
+	return deployer.staggerDelay
+}
+
+// setDeployPollInterval records the poll interval of the deploy
+// that just ran, or clears it when the deploy didn't set one
+func (deployer *Deployer) setDeployPollInterval(metadata *RequestMetadata) {
+	deployer.deployPollInterval = 0
+	if metadata == nil || metadata.PollInterval == nil {
+		return
+	}
+
+	if *metadata.PollInterval <= 0 {
+		debug("setDeployPollInterval: ignoring pollInterval %v", *metadata.PollInterval)
+		return
+	}
+	deployer.deployPollInterval = time.Duration(*metadata.PollInterval) * time.Second
+}
@@ -0,0 +1,44 @@
+package deployer
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PollInterval", func() {
+	var sut *Deployer
+
+	BeforeEach(func() {
+		sut = New(nil, nil, "redis-queue:name", "https://deploy-state.test", "super", WithPollInterval(2*time.Second))
+	})
+
+	It("Should default to the deployer's interval", func() {
+		Expect(sut.PollInterval()).To(Equal(2 * time.Second))
+	})
+
+	Describe("When the last deploy set pollInterval", func() {
+		BeforeEach(func() {
+			pollInterval := 30
+			sut.setDeployPollInterval(&RequestMetadata{PollInterval: &pollInterval})
+		})
+
+		It("Should use the deploy's interval", func() {
+			Expect(sut.PollInterval()).To(Equal(30 * time.Second))
+		})
+
+		It("Should go back to the deployer's interval after a deploy without one", func() {
+			sut.setDeployPollInterval(&RequestMetadata{})
+			Expect(sut.PollInterval()).To(Equal(2 * time.Second))
+		})
+	})
+
+	Describe("When the last deploy set a pollInterval that isn't positive", func() {
+		It("Should ignore it", func() {
+			pollInterval := 0
+			sut.setDeployPollInterval(&RequestMetadata{PollInterval: &pollInterval})
+			Expect(sut.PollInterval()).To(Equal(2 * time.Second))
+		})
+	})
+})
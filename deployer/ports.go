@@ -0,0 +1,78 @@
+package deployer
+
+import "github.com/docker/engine-api/types/swarm"
+
+// PortConfig is a port the service publishes. Protocol
+// defaults to tcp, and Mode to ingress
+type PortConfig struct {
+	Protocol      string `json:"protocol"`
+	TargetPort    uint32 `json:"targetPort"`
+	PublishedPort uint32 `json:"publishedPort"`
+	Mode          string `json:"mode"`
+}
+
+func validatePublishedPorts(ports []PortConfig) error {
+	type publishedPort struct {
+		protocol string
+		port     uint32
+	}
+	seen := map[publishedPort]bool{}
+
+	for _, port := range ports {
+		protocol := getPortProtocol(port)
+		if protocol != string(swarm.PortConfigProtocolTCP) && protocol != string(swarm.PortConfigProtocolUDP) {
+			return newValidationError("Invalid publishedPorts protocol '%v', expected tcp or udp", port.Protocol)
+		}
+
+		switch port.Mode {
+		case "", "ingress":
+		case "host":
+			return newValidationError("publishedPorts mode host is not supported by this deployer's docker client, use ingress")
+		default:
+			return newValidationError("Invalid publishedPorts mode '%v', expected ingress", port.Mode)
+		}
+
+		if port.TargetPort == 0 {
+			return newValidationError("publishedPorts entries require a targetPort")
+		}
+
+		if port.PublishedPort == 0 {
+			continue
+		}
+
+		key := publishedPort{protocol: protocol, port: port.PublishedPort}
+		if seen[key] {
+			return newValidationError("publishedPort %v/%v is used by more than one entry", port.PublishedPort, protocol)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// setPublishedPorts replaces the service's ports when the deploy has
+// publishedPorts, so they change in the same update as the image
+func setPublishedPorts(spec *swarm.ServiceSpec, ports []PortConfig) {
+	if ports == nil {
+		return
+	}
+
+	if spec.EndpointSpec == nil {
+		spec.EndpointSpec = &swarm.EndpointSpec{}
+	}
+
+	spec.EndpointSpec.Ports = make([]swarm.PortConfig, len(ports))
+	for i, port := range ports {
+		spec.EndpointSpec.Ports[i] = swarm.PortConfig{
+			Protocol:      swarm.PortConfigProtocol(getPortProtocol(port)),
+			TargetPort:    port.TargetPort,
+			PublishedPort: port.PublishedPort,
+		}
+	}
+}
+
+func getPortProtocol(port PortConfig) string {
+	if port.Protocol == "" {
+		return string(swarm.PortConfigProtocolTCP)
+	}
+	return port.Protocol
+}
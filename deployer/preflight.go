@@ -0,0 +1,103 @@
+package deployer
+
+import (
+	"github.com/docker/engine-api/client"
+	"github.com/garyburd/redigo/redis"
+	"golang.org/x/net/context"
+)
+
+// dockerHubRegistry is how docker hub is named in WithAllowedRegistries
+const dockerHubRegistry = "docker.io"
+
+// PreflightCheck cheaply validates a deploy before it is taken off the
+// queue. It checks that the dockerUrl parses, that its registry is
+// allowed, that the service exists and that its required secrets do
+func (deployer *Deployer) PreflightCheck(metadata *RequestMetadata) error {
+	_, repo, _ := deployer.parseDockerURL(metadata.DockerURL)
+	if repo == "" {
+		return newValidationError("Invalid dockerUrl '%v'", metadata.DockerURL)
+	}
+
+	err := deployer.checkAllowedRegistry(metadata.DockerURL)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if deployer.deployTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, deployer.deployTimeout)
+		defer cancel()
+	}
+
+	_, _, err = deployer.dockerClient.ServiceInspectWithRaw(ctx, repo)
+	if client.IsErrNotFound(err) {
+		return newValidationError("Service '%v' does not exist", repo)
+	}
+	if err != nil {
+		return newDockerAPIError(err)
+	}
+
+	return deployer.checkRequiredSecrets(ctx, repo, metadata)
+}
+
+func (deployer *Deployer) checkAllowedRegistry(dockerURL string) error {
+	if len(deployer.allowedRegistries) == 0 {
+		return nil
+	}
+
+	registry, _ := splitRegistry(dockerURL)
+	if registry == "" {
+		registry = dockerHubRegistry
+	}
+
+	for _, allowed := range deployer.allowedRegistries {
+		if registry == allowed {
+			return nil
+		}
+	}
+	return newPolicyError("Registry '%v' of '%v' is not allowed", registry, dockerURL)
+}
+
+// preflightDeploy runs PreflightCheck before the deploy is locked. It
+// returns false when the deploy should stay on the queue, untouched,
+// to be checked again on the next Run. Deploys whose metadata can't be
+// read are let through, so they are dead lettered once locked. Deploys
+// missing secrets are dead lettered right away
+func (deployer *Deployer) preflightDeploy(deploy string) (bool, error) {
+	metadata, err := deployer.getMetadata(deploy)
+	if _, ok := err.(*RedisError); ok {
+		return false, err
+	}
+	if err != nil {
+		return true, nil
+	}
+
+	err = deployer.PreflightCheck(metadata)
+	if err != nil {
+		deployer.logger.Println("Preflight failed", deploy, err)
+		deployer.metrics.IncrCounter("governator_preflight_failures_total", nil)
+		if _, ok := err.(*MissingSecretError); ok {
+			return false, deployer.deadLetterQueuedDeploy(deploy, err.Error())
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// deadLetterQueuedDeploy takes a deploy that isn't locked off the queue
+// and dead letters it, unless another deployer took it first
+func (deployer *Deployer) deadLetterQueuedDeploy(deploy, reason string) error {
+	redisConn := deployer.redisPool.Get()
+	defer redisConn.Close()
+
+	removed, err := redis.Int(redisConn.Do("ZREM", deployer.keys.DeployKey(), deploy))
+	if err != nil {
+		return newRedisError(err)
+	}
+
+	if removed == 0 {
+		return nil
+	}
+	return deployer.deadLetterDeploy(deploy, reason)
+}
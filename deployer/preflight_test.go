@@ -0,0 +1,149 @@
+package deployer
+
+import (
+	"github.com/docker/engine-api/types/swarm"
+	"github.com/garyburd/redigo/redis"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rafaeljusto/redigomock"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("PreflightCheck", func() {
+	var sut *Deployer
+	var dockerClient *fakeDockerClient
+	var metadata *RequestMetadata
+	var err error
+
+	BeforeEach(func() {
+		dockerClient = &fakeDockerClient{service: swarm.Service{ID: "service-id"}}
+		sut = New(dockerClient, nil, "redis-queue:name", "https://deploy-state.test", "super", WithAllowedRegistries("docker.io", "quay.io"))
+		metadata = &RequestMetadata{DockerURL: "octoblu/my-application:v2"}
+	})
+
+	JustBeforeEach(func() {
+		err = sut.PreflightCheck(metadata)
+	})
+
+	It("Should pass a docker hub image of an existing service", func() {
+		Expect(err).To(BeNil())
+	})
+
+	Describe("When the image is from an allowed registry", func() {
+		BeforeEach(func() {
+			metadata.DockerURL = "quay.io/octoblu/my-application:v2"
+		})
+
+		It("Should pass", func() {
+			Expect(err).To(BeNil())
+		})
+	})
+
+	Describe("When the image is from another registry", func() {
+		BeforeEach(func() {
+			metadata.DockerURL = "registry.example.com/octoblu/my-application:v2"
+		})
+
+		It("Should return a policy error", func() {
+			Expect(err).To(BeAssignableToTypeOf(&PolicyError{}))
+		})
+	})
+
+	Describe("When the dockerUrl is invalid", func() {
+		BeforeEach(func() {
+			metadata.DockerURL = "my-application"
+		})
+
+		It("Should return a validation error", func() {
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+		})
+	})
+
+	Describe("When the service doesn't exist", func() {
+		BeforeEach(func() {
+			dockerClient.serviceMissing = true
+		})
+
+		It("Should return a validation error", func() {
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+		})
+	})
+
+	Describe("When the deploy requires secrets", func() {
+		BeforeEach(func() {
+			sut.secretLister = fakeSecretLister{"database-password", "api-key"}
+			metadata.RequiredSecrets = []string{"database-password", "tls-cert"}
+		})
+
+		It("Should return the missing ones", func() {
+			Expect(err).To(Equal(&MissingSecretError{Service: "my-application", Secrets: []string{"tls-cert"}}))
+		})
+	})
+})
+
+// fakeSecretLister lists the secrets it was constructed with
+type fakeSecretLister []string
+
+func (lister fakeSecretLister) SecretNames(ctx context.Context) ([]string, error) {
+	return lister, nil
+}
+
+var _ = Describe("getNextValidDeploy", func() {
+	var sut *Deployer
+	var dockerClient *fakeDockerClient
+	var redisConn *redigomock.Conn
+	var zrem *redigomock.Cmd
+	var deploy string
+	var err error
+
+	BeforeEach(func() {
+		dockerClient = &fakeDockerClient{service: swarm.Service{ID: "service-id"}}
+		redisConn = redigomock.NewConn()
+		redisPool := &redis.Pool{Dial: func() (redis.Conn, error) { return redisConn, nil }}
+		sut = New(dockerClient, redisPool, "redis-queue:name", "https://deploy-state.test", "super")
+
+		redisConn.Command("ZRANGEBYSCORE", "redis-queue:name:governator:deploys", 0, redigomock.NewAnyInt(), "WITHSCORES", "LIMIT", 0, DefaultQueueScanLimit).Expect([]interface{}{[]byte("my-application:v2"), []byte("1")})
+		redisConn.Command("HGET", "redis-queue:name:my-application:v2", "request:metadata").Expect([]byte(`{"dockerUrl":"octoblu/my-application:v2"}`))
+		zrem = redisConn.Command("ZREM", "redis-queue:name:governator:deploys", "my-application:v2").Expect(int64(0))
+	})
+
+	JustBeforeEach(func() {
+		deploy, _, err = sut.getNextValidDeploy()
+	})
+
+	Describe("When the preflight check fails", func() {
+		BeforeEach(func() {
+			dockerClient.serviceMissing = true
+		})
+
+		It("Should leave the deploy on the queue", func() {
+			Expect(err).To(BeNil())
+			Expect(deploy).To(BeEmpty())
+			Expect(redisConn.Stats(zrem)).To(Equal(0))
+		})
+	})
+
+	Describe("When the deploy is missing secrets", func() {
+		var dlq *redigomock.Cmd
+
+		BeforeEach(func() {
+			sut.secretLister = fakeSecretLister{}
+			redisConn.Command("HGET", "redis-queue:name:my-application:v2", "request:metadata").Expect([]byte(`{"dockerUrl":"octoblu/my-application:v2","requiredSecrets":["tls-cert"]}`))
+			zrem = redisConn.Command("ZREM", "redis-queue:name:governator:deploys", "my-application:v2").Expect(int64(1))
+			dlq = redisConn.Command("ZADD", "redis-queue:name:governator:dead-letters", redigomock.NewAnyInt(), "my-application:v2").Expect(int64(1))
+		})
+
+		It("Should dead letter the deploy", func() {
+			Expect(err).To(BeNil())
+			Expect(deploy).To(BeEmpty())
+			Expect(redisConn.Stats(dlq)).To(Equal(1))
+		})
+	})
+
+	Describe("When the preflight check passes", func() {
+		It("Should try to lock the deploy", func() {
+			Expect(err).To(BeNil())
+			Expect(redisConn.Stats(zrem)).To(Equal(1))
+		})
+	})
+})
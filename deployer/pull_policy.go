@@ -0,0 +1,125 @@
+package deployer
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/docker/engine-api/client"
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/swarm"
+	"golang.org/x/net/context"
+)
+
+// Image pull policies for RequestMetadata.ImagePullPolicy
+const (
+	PullAlways       = "always"
+	PullIfNotPresent = "if-not-present"
+	PullNever        = "never"
+)
+
+func validateImagePullPolicy(policy string) error {
+	switch policy {
+	case "", PullAlways, PullIfNotPresent, PullNever:
+		return nil
+	}
+	return newValidationError("Invalid imagePullPolicy '%v', expected %v, %v or %v", policy, PullAlways, PullIfNotPresent, PullNever)
+}
+
+// applyImagePullPolicy pins the service image so nodes pull it the
+// way the deploy asked. always pulls the image on the manager and pins
+// it to the registry digest, so every node fetches the newest build of
+// the tag, trying the deploy's registryMirrors first. never pins it to
+// the manager's local image ID, which nodes can't pull. if-not-present
+// leaves the tag for swarm to resolve. An imageDigest is already
+// pinned, so always doesn't pull it again
+func (deployer *Deployer) applyImagePullPolicy(ctx context.Context, spec *swarm.ServiceSpec, metadata *RequestMetadata) error {
+	containerSpec := &spec.TaskTemplate.ContainerSpec
+	image := containerSpec.Image
+
+	switch metadata.ImagePullPolicy {
+	case PullAlways:
+		if metadata.ImageDigest != "" {
+			break
+		}
+
+		pulledImage, digest, err := deployer.pullFromMirrors(ctx, image, metadata)
+		if err != nil {
+			return err
+		}
+		containerSpec.Image = pulledImage + "@" + digest
+
+	case PullNever:
+		inspect, _, err := deployer.dockerClient.ImageInspectWithRaw(ctx, image)
+		if err != nil {
+			if client.IsErrImageNotFound(err) {
+				return newValidationError("imagePullPolicy is never, but %v is not present on the manager", image)
+			}
+			return newDockerAPIError(err)
+		}
+		containerSpec.Image = inspect.ID
+	}
+
+	return nil
+}
+
+// pullFromMirrors pulls the image from each of the deploy's
+// registryMirrors in order, and from its own registry if they all
+// fail. It returns the image that was pulled and its digest, and
+// records the mirror that worked on the metadata
+func (deployer *Deployer) pullFromMirrors(ctx context.Context, image string, metadata *RequestMetadata) (string, string, error) {
+	for _, mirror := range metadata.RegistryMirrors {
+		mirrored := rewriteRegistry(mirror, image)
+		digest, err := deployer.pullImageDigest(ctx, mirrored)
+		if err == nil {
+			metadata.pulledMirror = mirror
+			return mirrored, digest, nil
+		}
+		if ctx.Err() != nil {
+			return "", "", err
+		}
+		deployer.logger.Println("Error pulling from mirror", mirrored, err)
+	}
+
+	digest, err := deployer.pullImageDigest(ctx, image)
+	return image, digest, err
+}
+
+func (deployer *Deployer) pullImageDigest(ctx context.Context, image string) (string, error) {
+	debug("pulling %v", image)
+	reader, err := deployer.dockerClient.ImagePull(ctx, image, types.ImagePullOptions{})
+	if err != nil {
+		return "", newDockerAPIError(err)
+	}
+	_, err = io.Copy(ioutil.Discard, reader)
+	reader.Close()
+	if err != nil {
+		return "", newDockerAPIError(err)
+	}
+
+	inspect, _, err := deployer.dockerClient.ImageInspectWithRaw(ctx, image)
+	if err != nil {
+		return "", newDockerAPIError(err)
+	}
+
+	name := image
+	if colon := strings.LastIndex(image, ":"); colon > strings.LastIndex(image, "/") {
+		name = image[:colon]
+	}
+
+	digest := ""
+	for _, repoDigest := range inspect.RepoDigests {
+		parts := strings.SplitN(repoDigest, "@", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if digest == "" || parts[0] == name {
+			digest = parts[1]
+		}
+	}
+
+	if digest == "" {
+		return "", newValidationError("No registry digest found for %v after pulling it", image)
+	}
+	return digest, nil
+}
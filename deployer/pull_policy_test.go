@@ -0,0 +1,122 @@
+package deployer
+
+import (
+	"fmt"
+
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/swarm"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ImagePullPolicy", func() {
+	var sut *Deployer
+	var dockerClient *fakeDockerClient
+	var metadata *RequestMetadata
+	var err error
+
+	BeforeEach(func() {
+		dockerClient = &fakeDockerClient{
+			service: swarm.Service{ID: "service-id"},
+			images: map[string]types.ImageInspect{
+				"octoblu/my-application:v2": {
+					ID:          "sha256:1d2c3b4a",
+					RepoDigests: []string{"quay.io/octoblu/my-application@sha256:ffff", "octoblu/my-application@sha256:abcd"},
+				},
+			},
+		}
+		sut = New(dockerClient, nil, "redis-queue:name", deployState.URL, "super")
+		metadata = &RequestMetadata{DockerURL: "octoblu/my-application:v2"}
+	})
+
+	JustBeforeEach(func() {
+		err = sut.deploy("my-application:v2", metadata)
+	})
+
+	image := func() string {
+		return dockerClient.service.Spec.TaskTemplate.ContainerSpec.Image
+	}
+
+	Describe("When it is not set", func() {
+		It("Should deploy the tag without pulling", func() {
+			Expect(err).To(BeNil())
+			Expect(image()).To(Equal("octoblu/my-application:v2"))
+			Expect(dockerClient.pulls).To(BeEmpty())
+		})
+	})
+
+	Describe("When it is always", func() {
+		BeforeEach(func() {
+			metadata.ImagePullPolicy = PullAlways
+		})
+
+		It("Should pull the image and pin it to its digest", func() {
+			Expect(err).To(BeNil())
+			Expect(dockerClient.pulls).To(Equal([]string{"octoblu/my-application:v2"}))
+			Expect(image()).To(Equal("octoblu/my-application:v2@sha256:abcd"))
+		})
+
+		Describe("and the deploy has registryMirrors", func() {
+			BeforeEach(func() {
+				metadata.RegistryMirrors = []string{"cache.local:5000", "mirror.local"}
+				dockerClient.pullErrors = map[string]error{"cache.local:5000/octoblu/my-application:v2": fmt.Errorf("connection refused")}
+				dockerClient.images["mirror.local/octoblu/my-application:v2"] = types.ImageInspect{
+					RepoDigests: []string{"mirror.local/octoblu/my-application@sha256:beef"},
+				}
+			})
+
+			It("Should pull from the first mirror that works", func() {
+				Expect(err).To(BeNil())
+				Expect(dockerClient.pulls).To(Equal([]string{"cache.local:5000/octoblu/my-application:v2", "mirror.local/octoblu/my-application:v2"}))
+				Expect(image()).To(Equal("mirror.local/octoblu/my-application:v2@sha256:beef"))
+				Expect(metadata.pulledMirror).To(Equal("mirror.local"))
+			})
+		})
+
+		Describe("and every registryMirror fails", func() {
+			BeforeEach(func() {
+				metadata.RegistryMirrors = []string{"cache.local:5000"}
+				dockerClient.pullErrors = map[string]error{"cache.local:5000/octoblu/my-application:v2": fmt.Errorf("connection refused")}
+			})
+
+			It("Should fall back to the original image", func() {
+				Expect(err).To(BeNil())
+				Expect(image()).To(Equal("octoblu/my-application:v2@sha256:abcd"))
+				Expect(metadata.pulledMirror).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("When it is never", func() {
+		BeforeEach(func() {
+			metadata.ImagePullPolicy = PullNever
+		})
+
+		It("Should pin the image to the local image ID", func() {
+			Expect(err).To(BeNil())
+			Expect(image()).To(Equal("sha256:1d2c3b4a"))
+			Expect(dockerClient.pulls).To(BeEmpty())
+		})
+
+		Describe("and the image isn't on the manager", func() {
+			BeforeEach(func() {
+				metadata.DockerURL = "octoblu/my-application:v3"
+			})
+
+			It("Should return a validation error", func() {
+				Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+				Expect(dockerClient.updates).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("When it is unknown", func() {
+		BeforeEach(func() {
+			metadata.ImagePullPolicy = "sometimes"
+		})
+
+		It("Should return a validation error", func() {
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+		})
+	})
+})
@@ -0,0 +1,34 @@
+package deployer
+
+import (
+	"sync/atomic"
+
+	"github.com/octoblu/governator-swarm/metrics"
+)
+
+func init() {
+	metrics.DefaultRegistry.Help("governator_paused", "1 while queue processing is paused through the API, 0 otherwise")
+}
+
+// Pause stops RunOnce from taking deploys off the queue until Resume.
+// A deploy that is already running finishes. It only lasts as long
+// as the process, a restarted deployer starts unpaused
+func (deployer *Deployer) Pause() {
+	if atomic.SwapInt32(&deployer.paused, 1) == 0 {
+		deployer.logger.Println("Queue processing paused")
+	}
+	deployer.metrics.SetGauge("governator_paused", 1, nil)
+}
+
+// Resume lets RunOnce take deploys off the queue again
+func (deployer *Deployer) Resume() {
+	if atomic.SwapInt32(&deployer.paused, 0) == 1 {
+		deployer.logger.Println("Queue processing resumed")
+	}
+	deployer.metrics.SetGauge("governator_paused", 0, nil)
+}
+
+// Paused is true between Pause and Resume
+func (deployer *Deployer) Paused() bool {
+	return atomic.LoadInt32(&deployer.paused) == 1
+}
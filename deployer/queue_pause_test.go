@@ -0,0 +1,50 @@
+package deployer
+
+import (
+	"bytes"
+
+	"github.com/octoblu/governator-swarm/metrics"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Pause", func() {
+	var sut *Deployer
+	var registry *metrics.Registry
+
+	BeforeEach(func() {
+		registry = metrics.NewRegistry()
+		sut = New(nil, nil, "redis-queue:name", "https://deploy-state.test", "super", WithLogger(&fakeLogger{}), WithMetrics(registry))
+		sut.Pause()
+	})
+
+	It("Should not take a deploy off the queue", func() {
+		// the queue isn't touched, so no redis pool is needed
+		deployed, err := sut.RunOnce(context.Background())
+		Expect(err).To(BeNil())
+		Expect(deployed).To(BeFalse())
+	})
+
+	It("Should report it in the status and gauge", func() {
+		Expect(sut.Paused()).To(BeTrue())
+
+		var buffer bytes.Buffer
+		Expect(registry.WritePrometheus(&buffer)).To(Succeed())
+		Expect(buffer.String()).To(ContainSubstring("governator_paused 1"))
+	})
+
+	Describe("When it is resumed", func() {
+		BeforeEach(func() {
+			sut.Resume()
+		})
+
+		It("Should clear the flag and gauge", func() {
+			Expect(sut.Paused()).To(BeFalse())
+
+			var buffer bytes.Buffer
+			Expect(registry.WritePrometheus(&buffer)).To(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("governator_paused 0"))
+		})
+	})
+})
@@ -0,0 +1,16 @@
+package deployer
+
+// applyReadonlyRootfs refuses deploys that set the root filesystem's
+// mode. The vendored docker client's ContainerSpec has no ReadOnly
+// (it needs API 1.28), so the deploy fails rather than running
+// with a writable root filesystem. With enforceReadonlyRootfs, a
+// deploy asking for a writable one is refused for that instead
+func (deployer *Deployer) applyReadonlyRootfs(metadata *RequestMetadata) error {
+	if metadata.ReadonlyRootfs == nil {
+		return nil
+	}
+	if deployer.enforceReadonlyRootfs && !*metadata.ReadonlyRootfs {
+		return newValidationError("readonlyRootfs: false isn't allowed, governator enforces read only root filesystems")
+	}
+	return newValidationError("readonlyRootfs needs docker API 1.28, which this governator's docker client doesn't support")
+}
@@ -0,0 +1,69 @@
+package deployer
+
+import (
+	"github.com/docker/engine-api/types/swarm"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ReadonlyRootfs", func() {
+	var sut *Deployer
+	var dockerClient *fakeDockerClient
+	var metadata *RequestMetadata
+	var err error
+
+	BeforeEach(func() {
+		dockerClient = &fakeDockerClient{service: swarm.Service{ID: "service-id"}}
+		dockerClient.service.Spec.Name = "my-application"
+		sut = New(dockerClient, nil, "redis-queue:name", deployState.URL, "super")
+		metadata = &RequestMetadata{DockerURL: "octoblu/my-application:v2"}
+	})
+
+	JustBeforeEach(func() {
+		err = sut.deploy("my-application:v2", metadata)
+	})
+
+	Describe("When it is not set", func() {
+		It("Should update the service", func() {
+			Expect(err).To(BeNil())
+			Expect(dockerClient.updates).To(HaveLen(1))
+		})
+	})
+
+	Describe("When it is true", func() {
+		BeforeEach(func() {
+			readonly := true
+			metadata.ReadonlyRootfs = &readonly
+		})
+
+		It("Should refuse the deploy, since the docker client can't set it", func() {
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+			Expect(err.Error()).To(ContainSubstring("API 1.28"))
+			Expect(dockerClient.updates).To(BeEmpty())
+		})
+	})
+
+	Describe("When it is enforced", func() {
+		BeforeEach(func() {
+			sut = New(dockerClient, nil, "redis-queue:name", deployState.URL, "super", WithEnforceReadonlyRootfs())
+		})
+
+		It("Should update a service whose deploy doesn't set it", func() {
+			Expect(err).To(BeNil())
+			Expect(dockerClient.updates).To(HaveLen(1))
+		})
+
+		Describe("and the deploy sets it to false", func() {
+			BeforeEach(func() {
+				readonly := false
+				metadata.ReadonlyRootfs = &readonly
+			})
+
+			It("Should refuse the deploy", func() {
+				Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+				Expect(err.Error()).To(ContainSubstring("readonlyRootfs: false"))
+				Expect(dockerClient.updates).To(BeEmpty())
+			})
+		})
+	})
+})
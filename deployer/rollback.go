@@ -0,0 +1,183 @@
+package deployer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/swarm"
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// nativeRollbackAPIVersion is the first docker API
+// version that can roll a service back by itself
+const nativeRollbackAPIVersion = "1.31"
+
+// ServiceRollbacker asks docker to roll a service back to its previous spec
+type ServiceRollbacker interface {
+	RollbackService(ctx context.Context, service swarm.Service) error
+}
+
+// rollbackService puts back the spec the service had before the deploy.
+// It is skipped when the service's version shows the update never landed.
+// Docker's native rollback is used when it is configured and the daemon
+// supports it, otherwise previousSpec is sent with ServiceUpdate
+func (deployer *Deployer) rollbackService(deploy string, service swarm.Service, previousSpec swarm.ServiceSpec, timeout time.Duration) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	current, _, err := deployer.dockerClient.ServiceInspectWithRaw(ctx, service.ID)
+	if err != nil {
+		deployer.logger.Println("Rollback failed", service.Spec.Name, err)
+		return
+	}
+
+	if current.Version.Index == service.Version.Index {
+		debug("rollbackService: %v was not updated", service.Spec.Name)
+		return
+	}
+
+	if deployer.supportsNativeRollback(ctx) {
+		err = deployer.nativeRollback.RollbackService(ctx, current)
+	} else {
+		err = deployer.dockerClient.ServiceUpdate(ctx, current.ID, current.Version, previousSpec, types.ServiceUpdateOptions{})
+	}
+	if err != nil {
+		deployer.logger.Println("Rollback failed", service.Spec.Name, err)
+		return
+	}
+
+	deployer.logger.Println("Rolled back", service.Spec.Name)
+	deployer.events.publish(deploy, EventRolledBack, service.Spec.Name)
+}
+
+func (deployer *Deployer) supportsNativeRollback(ctx context.Context) bool {
+	if deployer.nativeRollback == nil {
+		return false
+	}
+
+	version, err := deployer.dockerClient.ServerVersion(ctx)
+	if err != nil {
+		debug("supportsNativeRollback: %v", err)
+		return false
+	}
+	return apiVersionAtLeast(version.APIVersion, nativeRollbackAPIVersion)
+}
+
+// apiVersionAtLeast compares docker API versions like 1.24
+func apiVersionAtLeast(version, minimum string) bool {
+	versionParts := strings.Split(version, ".")
+	minimumParts := strings.Split(minimum, ".")
+
+	for i, minimumPart := range minimumParts {
+		minimumNumber, _ := strconv.Atoi(minimumPart)
+		versionNumber := 0
+		if i < len(versionParts) {
+			var err error
+			versionNumber, err = strconv.Atoi(versionParts[i])
+			if err != nil {
+				return false
+			}
+		}
+
+		if versionNumber != minimumNumber {
+			return versionNumber > minimumNumber
+		}
+	}
+	return true
+}
+
+// httpRollbacker calls the service update endpoint with rollback=previous.
+// The vendored docker client predates the rollback option, so the
+// request is made directly against the daemon
+type httpRollbacker struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewHTTPRollbacker constructs a ServiceRollbacker for the
+// daemon at dockerURI, e.g. unix:///var/run/docker.sock
+func NewHTTPRollbacker(dockerURI string) (ServiceRollbacker, error) {
+	baseURL, httpClient, err := newDaemonHTTPClient(dockerURI)
+	if err != nil {
+		return nil, err
+	}
+
+	return &httpRollbacker{
+		baseURL:    baseURL,
+		httpClient: httpClient,
+	}, nil
+}
+
+// newDaemonHTTPClient returns the base URL and an http.Client for
+// calling the daemon at dockerURI without the vendored docker client
+func newDaemonHTTPClient(dockerURI string) (string, *http.Client, error) {
+	parsed, err := url.Parse(dockerURI)
+	if err != nil {
+		return "", nil, err
+	}
+
+	transport := &http.Transport{}
+	baseURL := ""
+	switch parsed.Scheme {
+	case "unix":
+		socket := parsed.Path
+		transport.Dial = func(network, addr string) (net.Conn, error) {
+			return net.Dial("unix", socket)
+		}
+		baseURL = "http://docker"
+	case "tcp", "http":
+		baseURL = fmt.Sprintf("http://%s%s", parsed.Host, strings.TrimSuffix(parsed.Path, "/"))
+	case "https":
+		baseURL = fmt.Sprintf("https://%s%s", parsed.Host, strings.TrimSuffix(parsed.Path, "/"))
+	default:
+		return "", nil, fmt.Errorf("unsupported docker uri scheme: %v", parsed.Scheme)
+	}
+
+	return baseURL, &http.Client{Transport: transport}, nil
+}
+
+// RollbackService rolls the service back to its previous spec
+func (rollbacker *httpRollbacker) RollbackService(ctx context.Context, service swarm.Service) error {
+	specBytes, err := json.Marshal(service.Spec)
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	query.Set("version", strconv.FormatUint(service.Version.Index, 10))
+	query.Set("rollback", "previous")
+	fullURL := fmt.Sprintf("%s/v%s/services/%s/update?%s", rollbacker.baseURL, nativeRollbackAPIVersion, service.ID, query.Encode())
+
+	request, err := http.NewRequest("POST", fullURL, bytes.NewReader(specBytes))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "governator-swarm")
+
+	response, err := ctxhttp.Do(ctx, rollbacker.httpClient, request)
+	if err != nil {
+		return newDockerAPIError(err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(response.Body)
+		return newDockerAPIError(fmt.Errorf("Error response from daemon: %v %s", response.StatusCode, strings.TrimSpace(string(body))))
+	}
+	return nil
+}
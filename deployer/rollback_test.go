@@ -0,0 +1,59 @@
+package deployer
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/docker/engine-api/types/swarm"
+	"golang.org/x/net/context"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("rollback", func() {
+	table.DescribeTable("apiVersionAtLeast",
+		func(version string, expected bool) {
+			Expect(apiVersionAtLeast(version, "1.31")).To(Equal(expected))
+		},
+		table.Entry("older minor", "1.24", false),
+		table.Entry("same", "1.31", true),
+		table.Entry("newer minor", "1.40", true),
+		table.Entry("newer major", "2.0", true),
+		table.Entry("garbage", "latest", false),
+	)
+
+	Describe("NewHTTPRollbacker", func() {
+		It("Should POST the spec to the update endpoint with rollback=previous", func() {
+			var request *http.Request
+			var body string
+			server := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, r *http.Request) {
+				request = r
+				bodyBytes, _ := ioutil.ReadAll(r.Body)
+				body = string(bodyBytes)
+			}))
+			defer server.Close()
+
+			rollbacker, err := NewHTTPRollbacker(server.URL)
+			Expect(err).To(BeNil())
+
+			service := swarm.Service{ID: "service-id"}
+			service.Version.Index = 12
+			service.Spec.Name = "my-application"
+			Expect(rollbacker.RollbackService(context.Background(), service)).To(Succeed())
+
+			Expect(request.Method).To(Equal("POST"))
+			Expect(request.URL.Path).To(Equal("/v1.31/services/service-id/update"))
+			Expect(request.URL.Query().Get("rollback")).To(Equal("previous"))
+			Expect(request.URL.Query().Get("version")).To(Equal("12"))
+			Expect(body).To(ContainSubstring(`"Name":"my-application"`))
+		})
+
+		It("Should reject unknown schemes", func() {
+			_, err := NewHTTPRollbacker("ftp://docker")
+			Expect(err).NotTo(BeNil())
+		})
+	})
+})
@@ -0,0 +1,114 @@
+package deployer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// redacted replaces sensitive values in debug output
+const redacted = "***REDACTED***"
+
+var sensitiveKeyParts = []string{"SECRET", "PASSWORD", "TOKEN", "KEY", "DSN"}
+
+// sanitize returns a copy of v that is safe to debug log.
+// Structs and maps are walked recursively and the value of any
+// field or key whose name looks like it holds a secret is redacted.
+// Structs come back as maps keyed by field name
+func sanitize(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return sanitizeValue(reflect.ValueOf(v))
+}
+
+// SanitizeJSON returns data with the values of sensitive keys redacted,
+// like sanitize, when data is a JSON object or array, such as the deploy
+// metadata stored in redis. ok is false when data isn't one
+func SanitizeJSON(data []byte) (sanitized []byte, ok bool) {
+	trimmed := bytes.TrimSpace(data)
+	if !bytes.HasPrefix(trimmed, []byte("{")) && !bytes.HasPrefix(trimmed, []byte("[")) {
+		return nil, false
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	decoder.UseNumber()
+	var value interface{}
+	err := decoder.Decode(&value)
+	if err != nil {
+		return nil, false
+	}
+
+	sanitized, err = json.Marshal(sanitize(value))
+	if err != nil {
+		return nil, false
+	}
+	return sanitized, true
+}
+
+func sanitizeValue(value reflect.Value) interface{} {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
+		return sanitizeValue(value.Elem())
+
+	case reflect.Struct:
+		result := map[string]interface{}{}
+		valueType := value.Type()
+		for i := 0; i < value.NumField(); i++ {
+			field := valueType.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			result[field.Name] = sanitizeEntry(field.Name, value.Field(i))
+		}
+		return result
+
+	case reflect.Map:
+		if value.IsNil() {
+			return nil
+		}
+		result := map[string]interface{}{}
+		for _, key := range value.MapKeys() {
+			name := fmt.Sprint(key.Interface())
+			result[name] = sanitizeEntry(name, value.MapIndex(key))
+		}
+		return result
+
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			return nil
+		}
+		result := make([]interface{}, value.Len())
+		for i := range result {
+			result[i] = sanitizeValue(value.Index(i))
+		}
+		return result
+	}
+
+	if !value.CanInterface() {
+		return nil
+	}
+	return value.Interface()
+}
+
+func sanitizeEntry(name string, value reflect.Value) interface{} {
+	if isSensitiveKey(name) {
+		return redacted
+	}
+	return sanitizeValue(value)
+}
+
+func isSensitiveKey(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(upper, part) {
+			return true
+		}
+	}
+	return false
+}
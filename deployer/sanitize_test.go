@@ -0,0 +1,78 @@
+package deployer
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("sanitize", func() {
+	It("Should redact sensitive EnvOverrides", func() {
+		metadata := &RequestMetadata{
+			DockerURL: "octoblu/my-application:v1",
+			EnvOverrides: map[string]string{
+				"DATABASE_PASSWORD": "hunter2",
+				"api_token":         "abc123",
+				"AWS_SECRET_ACCESS": "shh",
+				"SENTRY_DSN":        "https://key@sentry.test/1",
+				"PRIVATE_KEY":       "-----BEGIN",
+				"LOG_LEVEL":         "debug",
+			},
+		}
+
+		result := sanitize(metadata).(map[string]interface{})
+		Expect(result["DockerURL"]).To(Equal("octoblu/my-application:v1"))
+		Expect(result["EnvOverrides"]).To(Equal(map[string]interface{}{
+			"DATABASE_PASSWORD": redacted,
+			"api_token":         redacted,
+			"AWS_SECRET_ACCESS": redacted,
+			"SENTRY_DSN":        redacted,
+			"PRIVATE_KEY":       redacted,
+			"LOG_LEVEL":         "debug",
+		}))
+	})
+
+	It("Should redact sensitive struct fields", func() {
+		type credentials struct {
+			Username string
+			Password string
+		}
+
+		result := sanitize(credentials{Username: "octoblu", Password: "hunter2"})
+		Expect(result).To(Equal(map[string]interface{}{
+			"Username": "octoblu",
+			"Password": redacted,
+		}))
+	})
+
+	It("Should walk nested maps and slices", func() {
+		result := sanitize(map[string]interface{}{
+			"services": []interface{}{
+				map[string]string{"name": "my-application", "token": "abc123"},
+			},
+		})
+		Expect(result).To(Equal(map[string]interface{}{
+			"services": []interface{}{
+				map[string]interface{}{"name": "my-application", "token": redacted},
+			},
+		}))
+	})
+
+	It("Should not change the value it was given", func() {
+		overrides := map[string]string{"SECRET": "shh"}
+		sanitize(overrides)
+		Expect(overrides["SECRET"]).To(Equal("shh"))
+	})
+
+	Describe("SanitizeJSON", func() {
+		It("Should redact the sensitive keys of a JSON object", func() {
+			sanitized, ok := SanitizeJSON([]byte(`{"envOverrides":{"API_TOKEN":"s3cr3t","REPLICAS":3}}`))
+			Expect(ok).To(BeTrue())
+			Expect(string(sanitized)).To(Equal(`{"envOverrides":{"API_TOKEN":"***REDACTED***","REPLICAS":3}}`))
+		})
+
+		It("Should leave anything else alone", func() {
+			_, ok := SanitizeJSON([]byte("octoblu/my-application:v2"))
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
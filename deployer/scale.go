@@ -0,0 +1,51 @@
+package deployer
+
+import (
+	"time"
+
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/swarm"
+	"golang.org/x/net/context"
+)
+
+// validatePostDeployScale checks the spec can be scaled
+// once the deploy is done, global services can't be
+func validatePostDeployScale(spec *swarm.ServiceSpec, metadata *RequestMetadata) error {
+	if metadata.PostDeployScale == nil {
+		return nil
+	}
+	if spec.Mode.Global != nil {
+		return newValidationError("Invalid postDeployScale %v, global services can't be scaled", *metadata.PostDeployScale)
+	}
+	return nil
+}
+
+// scaleService sets the service's replicas to the deploy's
+// postDeployScale in an update of its own, once the deploy is done
+func (deployer *Deployer) scaleService(ctx context.Context, deploy string, serviceID string, metadata *RequestMetadata, timeout time.Duration) error {
+	if metadata.PostDeployScale == nil {
+		return nil
+	}
+
+	service, _, err := deployer.dockerClient.ServiceInspectWithRaw(ctx, serviceID)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return deployer.deployTimedOut(deploy, serviceID, "inspecting the service to scale it", timeout)
+		}
+		return newDockerAPIError(err)
+	}
+
+	replicas := *metadata.PostDeployScale
+	service.Spec.Mode = swarm.ServiceMode{Replicated: &swarm.ReplicatedService{Replicas: &replicas}}
+
+	err = deployer.dockerClient.ServiceUpdate(ctx, service.ID, service.Version, service.Spec, types.ServiceUpdateOptions{})
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return deployer.deployTimedOut(deploy, service.Spec.Name, "scaling the service", timeout)
+		}
+		return newDockerAPIError(err)
+	}
+
+	deployer.logger.Println("Scaled", service.Spec.Name, "to", replicas, "replicas")
+	return nil
+}
@@ -0,0 +1,66 @@
+package deployer
+
+import (
+	"github.com/docker/engine-api/types/swarm"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("scaleService", func() {
+	var sut *Deployer
+	var dockerClient *fakeDockerClient
+	var metadata *RequestMetadata
+	var err error
+
+	BeforeEach(func() {
+		replicas := uint64(1)
+		dockerClient = &fakeDockerClient{
+			service: swarm.Service{ID: "service-id"},
+		}
+		dockerClient.service.Spec.Name = "my-application"
+		dockerClient.service.Spec.Mode.Replicated = &swarm.ReplicatedService{Replicas: &replicas}
+		dockerClient.service.Spec.TaskTemplate.ContainerSpec.Image = "octoblu/my-application:v1"
+
+		sut = New(dockerClient, nil, "redis-queue:name", deployState.URL, "super")
+		metadata = &RequestMetadata{DockerURL: "octoblu/my-application:v2"}
+	})
+
+	JustBeforeEach(func() {
+		err = sut.deploy("my-application:v2", metadata)
+	})
+
+	Describe("When the deploy sets postDeployScale", func() {
+		BeforeEach(func() {
+			scale := uint64(5)
+			metadata.PostDeployScale = &scale
+		})
+
+		It("Should deploy the image, then scale in a second update", func() {
+			Expect(err).To(BeNil())
+			Expect(dockerClient.updates).To(HaveLen(2))
+			Expect(*dockerClient.updates[0].Mode.Replicated.Replicas).To(Equal(uint64(1)))
+			Expect(dockerClient.updates[0].TaskTemplate.ContainerSpec.Image).To(Equal("octoblu/my-application:v2"))
+			Expect(*dockerClient.updates[1].Mode.Replicated.Replicas).To(Equal(uint64(5)))
+		})
+	})
+
+	Describe("When the deploy doesn't set postDeployScale", func() {
+		It("Should only deploy the image", func() {
+			Expect(err).To(BeNil())
+			Expect(dockerClient.updates).To(HaveLen(1))
+		})
+	})
+
+	Describe("When the service is global", func() {
+		BeforeEach(func() {
+			scale := uint64(5)
+			metadata.PostDeployScale = &scale
+			dockerClient.service.Spec.Mode = swarm.ServiceMode{Global: &swarm.GlobalService{}}
+		})
+
+		It("Should return a ValidationError without updating", func() {
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+			Expect(dockerClient.updates).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,90 @@
+package deployer
+
+import (
+	"net/http"
+
+	"golang.org/x/net/context"
+)
+
+// secretAPIVersion is the first docker API version with swarm secrets
+const secretAPIVersion = "1.25"
+
+// SecretLister lists the names of the secrets in the swarm
+type SecretLister interface {
+	SecretNames(ctx context.Context) ([]string, error)
+}
+
+// checkRequiredSecrets makes sure every secret in
+// metadata.RequiredSecrets exists in the swarm
+func (deployer *Deployer) checkRequiredSecrets(ctx context.Context, service string, metadata *RequestMetadata) error {
+	if len(metadata.RequiredSecrets) == 0 {
+		return nil
+	}
+
+	if deployer.secretLister == nil {
+		debug("checkRequiredSecrets: no secret lister, skipping %v", metadata.RequiredSecrets)
+		return nil
+	}
+
+	names, err := deployer.secretLister.SecretNames(ctx)
+	if err != nil {
+		return err
+	}
+
+	existing := map[string]bool{}
+	for _, name := range names {
+		existing[name] = true
+	}
+
+	missing := []string{}
+	for _, secret := range metadata.RequiredSecrets {
+		if !existing[secret] {
+			missing = append(missing, secret)
+		}
+	}
+
+	if len(missing) > 0 {
+		return &MissingSecretError{Service: service, Secrets: missing}
+	}
+	return nil
+}
+
+// httpSecretLister reads secrets from the daemon directly,
+// the vendored docker client predates swarm secrets
+type httpSecretLister struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewHTTPSecretLister constructs a SecretLister for the
+// daemon at dockerURI, e.g. unix:///var/run/docker.sock
+func NewHTTPSecretLister(dockerURI string) (SecretLister, error) {
+	baseURL, httpClient, err := newDaemonHTTPClient(dockerURI)
+	if err != nil {
+		return nil, err
+	}
+
+	return &httpSecretLister{
+		baseURL:    baseURL,
+		httpClient: httpClient,
+	}, nil
+}
+
+// SecretNames lists the secrets in the swarm by name
+func (lister *httpSecretLister) SecretNames(ctx context.Context) ([]string, error) {
+	var list []struct {
+		Spec struct {
+			Name string
+		}
+	}
+	err := daemonGet(ctx, lister.httpClient, lister.baseURL, secretAPIVersion, "/secrets", &list)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(list))
+	for _, secret := range list {
+		names = append(names, secret.Spec.Name)
+	}
+	return names, nil
+}
@@ -0,0 +1,23 @@
+package deployer
+
+import (
+	"strings"
+
+	"github.com/coreos/go-semver/semver"
+)
+
+// checkSemverTag returns a NonSemverTagError when the deployer requires
+// semver tags and the deploy's tag, less the semver prefix when it has one, isn't one
+func (deployer *Deployer) checkSemverTag(metadata *RequestMetadata) error {
+	if !deployer.requireSemverTag {
+		return nil
+	}
+
+	_, _, tag := deployer.parseDockerURL(metadata.DockerURL)
+	_, err := semver.NewVersion(strings.TrimPrefix(tag, deployer.semverPrefix))
+	if err != nil {
+		debug("checkSemverTag: %v: %v", tag, err)
+		return &NonSemverTagError{DockerURL: metadata.DockerURL, Tag: tag}
+	}
+	return nil
+}
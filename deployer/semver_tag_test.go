@@ -0,0 +1,54 @@
+package deployer
+
+import (
+	"github.com/garyburd/redigo/redis"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/rafaeljusto/redigomock"
+)
+
+var _ = Describe("RequireSemverTag", func() {
+	var sut *Deployer
+	var redisConn *redigomock.Conn
+
+	BeforeEach(func() {
+		redisConn = redigomock.NewConn()
+		redisPool := &redis.Pool{Dial: func() (redis.Conn, error) { return redisConn, nil }}
+		sut = New(nil, redisPool, "redis-queue:name", "https://deploy-state.test", "super", WithRequireSemverTag("v"))
+	})
+
+	table.DescribeTable("checkSemverTag",
+		func(dockerURL string, allowed bool) {
+			err := sut.checkSemverTag(&RequestMetadata{DockerURL: dockerURL})
+			if allowed {
+				Expect(err).To(BeNil())
+			} else {
+				Expect(err).To(BeAssignableToTypeOf(&NonSemverTagError{}))
+			}
+		},
+		table.Entry("a v prefixed tag", "octoblu/my-application:v1.2.3", true),
+		table.Entry("a tag without the prefix", "octoblu/my-application:1.2.3", true),
+		table.Entry("a pre-release", "octoblu/my-application:v1.2.3-rc.1", true),
+		table.Entry("a registry with a port", "registry.test:5000/octoblu/my-application:v1.2.3", true),
+		table.Entry("a branch tag", "octoblu/my-application:main-abc123", false),
+		table.Entry("a partial version", "octoblu/my-application:v1.2", false),
+		table.Entry("no tag", "octoblu/my-application", false),
+	)
+
+	It("Should refuse the deploy when its metadata is read", func() {
+		redisConn.Command("HGET", "redis-queue:name:my-application:main-abc123", "request:metadata").Expect([]byte(`{"dockerUrl":"octoblu/my-application:main-abc123"}`))
+
+		_, err := sut.getMetadata("my-application:main-abc123")
+		Expect(err).To(BeAssignableToTypeOf(&NonSemverTagError{}))
+		Expect(err.(DeployError).IsRetryable()).To(BeFalse())
+		Expect(err.Error()).To(ContainSubstring("main-abc123"))
+	})
+
+	Describe("When it isn't used", func() {
+		It("Should allow any tag", func() {
+			sut = New(nil, nil, "redis-queue:name", "https://deploy-state.test", "super")
+			Expect(sut.checkSemverTag(&RequestMetadata{DockerURL: "octoblu/my-application:main-abc123"})).To(BeNil())
+		})
+	})
+})
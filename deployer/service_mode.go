@@ -0,0 +1,92 @@
+package deployer
+
+import (
+	"fmt"
+
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/swarm"
+	"golang.org/x/net/context"
+)
+
+// Service modes for RequestMetadata.ServiceMode
+const (
+	ServiceModeReplicated = "replicated"
+	ServiceModeGlobal     = "global"
+)
+
+func validateServiceMode(mode string) error {
+	switch mode {
+	case "", ServiceModeReplicated, ServiceModeGlobal:
+		return nil
+	}
+	return newValidationError("Invalid serviceMode '%v', expected %v or %v", mode, ServiceModeReplicated, ServiceModeGlobal)
+}
+
+func getServiceMode(spec swarm.ServiceSpec) string {
+	if spec.Mode.Global != nil {
+		return ServiceModeGlobal
+	}
+	return ServiceModeReplicated
+}
+
+// setServiceMode switches the spec to mode, unless it is already in
+// it. A service switched to replicated mode gets a single replica
+func setServiceMode(spec *swarm.ServiceSpec, mode string) {
+	if mode == "" || getServiceMode(*spec) == mode {
+		return
+	}
+
+	switch mode {
+	case ServiceModeGlobal:
+		spec.Mode = swarm.ServiceMode{Global: &swarm.GlobalService{}}
+	case ServiceModeReplicated:
+		replicas := uint64(1)
+		spec.Mode = swarm.ServiceMode{Replicated: &swarm.ReplicatedService{Replicas: &replicas}}
+	}
+}
+
+// shouldRecreateService is true when docker may have refused the update
+// because it changes the service's mode, and recreation is allowed
+func (deployer *Deployer) shouldRecreateService(previousSpec, spec swarm.ServiceSpec) bool {
+	return deployer.allowServiceRecreation && getServiceMode(previousSpec) != getServiceMode(spec)
+}
+
+// recreateService removes the service and creates it again from its
+// spec, for changes docker won't make in place. service.ID is set to
+// the ID of the new service. When the create fails, the service is
+// created again from previousSpec, so the swarm isn't left without it
+func (deployer *Deployer) recreateService(ctx context.Context, service *swarm.Service, previousSpec swarm.ServiceSpec) error {
+	deployer.logger.Println("Recreating", service.Spec.Name, "in", getServiceMode(service.Spec), "mode")
+
+	err := deployer.dockerClient.ServiceRemove(ctx, service.ID)
+	if err != nil {
+		return err
+	}
+
+	response, err := deployer.dockerClient.ServiceCreate(ctx, service.Spec, types.ServiceCreateOptions{})
+	if err != nil {
+		return deployer.restoreService(service, previousSpec, err)
+	}
+
+	service.ID = response.ID
+	return nil
+}
+
+// restoreService creates the removed service again from previousSpec
+// after createErr, with its own context as the deploy's may be done.
+// createErr is returned either way
+func (deployer *Deployer) restoreService(service *swarm.Service, previousSpec swarm.ServiceSpec, createErr error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDockerAPICallTimeout)
+	defer cancel()
+
+	response, err := deployer.dockerClient.ServiceCreate(ctx, previousSpec, types.ServiceCreateOptions{})
+	if err != nil {
+		deployer.logger.Println("Error restoring", previousSpec.Name, "after its recreation failed, it has been removed from the swarm", err)
+		return fmt.Errorf("%v, and restoring the removed service failed: %v", createErr, err)
+	}
+
+	deployer.logger.Println("Restored", previousSpec.Name, "after its recreation failed")
+	service.ID = response.ID
+	service.Spec = previousSpec
+	return createErr
+}
@@ -0,0 +1,109 @@
+package deployer
+
+import (
+	"github.com/docker/engine-api/types/swarm"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ServiceMode", func() {
+	var sut *Deployer
+	var dockerClient *fakeDockerClient
+	var metadata *RequestMetadata
+	var err error
+
+	BeforeEach(func() {
+		replicas := uint64(3)
+		dockerClient = &fakeDockerClient{
+			service:           swarm.Service{ID: "service-id"},
+			rejectModeChanges: true,
+		}
+		dockerClient.service.Spec.Name = "my-application"
+		dockerClient.service.Spec.Mode.Replicated = &swarm.ReplicatedService{Replicas: &replicas}
+		sut = New(dockerClient, nil, "redis-queue:name", deployState.URL, "super")
+		metadata = &RequestMetadata{DockerURL: "octoblu/my-application:v2"}
+	})
+
+	JustBeforeEach(func() {
+		err = sut.deploy("my-application:v2", metadata)
+	})
+
+	Describe("When it is the service's current mode", func() {
+		BeforeEach(func() {
+			metadata.ServiceMode = ServiceModeReplicated
+		})
+
+		It("Should update the service in place, keeping its replicas", func() {
+			Expect(err).To(BeNil())
+			Expect(dockerClient.updates).To(HaveLen(1))
+			Expect(*dockerClient.service.Spec.Mode.Replicated.Replicas).To(Equal(uint64(3)))
+			Expect(dockerClient.removed).To(BeEmpty())
+		})
+	})
+
+	Describe("When it changes the mode", func() {
+		BeforeEach(func() {
+			metadata.ServiceMode = ServiceModeGlobal
+		})
+
+		It("Should return docker's error", func() {
+			Expect(err).To(BeAssignableToTypeOf(&DockerAPIError{}))
+			Expect(dockerClient.removed).To(BeEmpty())
+		})
+
+		Describe("and service recreation is allowed", func() {
+			BeforeEach(func() {
+				sut = New(dockerClient, nil, "redis-queue:name", deployState.URL, "super", WithServiceRecreation())
+			})
+
+			It("Should recreate the service in global mode", func() {
+				Expect(err).To(BeNil())
+				Expect(dockerClient.removed).To(Equal([]string{"service-id"}))
+				Expect(dockerClient.created).To(HaveLen(1))
+				Expect(dockerClient.created[0].Mode.Global).NotTo(BeNil())
+				Expect(dockerClient.created[0].TaskTemplate.ContainerSpec.Image).To(Equal("octoblu/my-application:v2"))
+			})
+
+			Describe("and creating the new service fails", func() {
+				BeforeEach(func() {
+					dockerClient.failingCreates = 1
+				})
+
+				It("Should return docker's error", func() {
+					Expect(err).To(BeAssignableToTypeOf(&DockerAPIError{}))
+					Expect(err.Error()).To(ContainSubstring("name conflicts with an existing object"))
+				})
+
+				It("Should create the removed service again from its previous spec", func() {
+					Expect(dockerClient.removed).To(Equal([]string{"service-id"}))
+					Expect(dockerClient.created).To(HaveLen(2))
+					Expect(dockerClient.created[1].Mode.Replicated).NotTo(BeNil())
+					Expect(*dockerClient.created[1].Mode.Replicated.Replicas).To(Equal(uint64(3)))
+					Expect(dockerClient.service.Spec.Name).To(Equal("my-application"))
+				})
+			})
+
+			Describe("and restoring the removed service fails too", func() {
+				BeforeEach(func() {
+					dockerClient.failingCreates = 2
+				})
+
+				It("Should return both errors", func() {
+					Expect(err).To(BeAssignableToTypeOf(&DockerAPIError{}))
+					Expect(err.Error()).To(ContainSubstring("restoring the removed service failed"))
+					Expect(dockerClient.created).To(HaveLen(2))
+				})
+			})
+		})
+	})
+
+	Describe("When it is unknown", func() {
+		BeforeEach(func() {
+			metadata.ServiceMode = "daemonset"
+		})
+
+		It("Should return a validation error", func() {
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+		})
+	})
+})
@@ -0,0 +1,194 @@
+package deployer
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/engine-api/types/swarm"
+	"github.com/octoblu/governator-swarm/notifier"
+)
+
+// updateServiceSpec applies the deploy metadata to the
+// service spec that will be sent with ServiceUpdate.
+// metadata.DockerURL is left as requested so deploy-state
+// is notified with the original image, not the mirrored one
+func (deployer *Deployer) updateServiceSpec(spec *swarm.ServiceSpec, metadata *RequestMetadata) error {
+	err := validateImagePullPolicy(metadata.ImagePullPolicy)
+	if err != nil {
+		return err
+	}
+
+	err = validateNetworkMode(metadata.NetworkMode)
+	if err != nil {
+		return err
+	}
+
+	err = validateServiceMode(metadata.ServiceMode)
+	if err != nil {
+		return err
+	}
+	setServiceMode(spec, metadata.ServiceMode)
+
+	err = validatePostDeployScale(spec, metadata)
+	if err != nil {
+		return err
+	}
+
+	err = validateImageDigest(metadata.ImageDigest)
+	if err != nil {
+		return err
+	}
+
+	err = applyCapabilities(metadata)
+	if err != nil {
+		return err
+	}
+
+	err = validateUserNS(metadata.UserNS)
+	if err != nil {
+		return err
+	}
+
+	err = notifier.ValidatePagerDutySeverity(metadata.PagerDutySeverity)
+	if err != nil {
+		return newValidationError("Invalid pagerDutySeverity: %v", err)
+	}
+
+	err = validateInitContainers(metadata.InitContainers)
+	if err != nil {
+		return err
+	}
+
+	err = applyUlimits(metadata)
+	if err != nil {
+		return err
+	}
+
+	err = applyNodeAffinity(metadata)
+	if err != nil {
+		return err
+	}
+
+	err = applyDNSConfig(metadata)
+	if err != nil {
+		return err
+	}
+
+	err = deployer.applyReadonlyRootfs(metadata)
+	if err != nil {
+		return err
+	}
+
+	err = validatePublishedPorts(metadata.PublishedPorts)
+	if err != nil {
+		return err
+	}
+	setPublishedPorts(spec, metadata.PublishedPorts)
+
+	err = validateEndpointMode(metadata.EndpointMode)
+	if err != nil {
+		return err
+	}
+
+	err = setEndpointMode(spec, metadata.EndpointMode)
+	if err != nil {
+		return err
+	}
+
+	err = deployer.setUpdateFailureAction(spec, metadata)
+	if err != nil {
+		return err
+	}
+	addNodeLabelConstraints(spec, deployer.nodeLabelSelector)
+
+	containerSpec := &spec.TaskTemplate.ContainerSpec
+	containerSpec.Image = deployer.mirrorImage(metadata.DockerURL)
+	if metadata.ImageDigest != "" {
+		containerSpec.Image = pinImageDigest(containerSpec.Image, metadata.ImageDigest)
+	}
+
+	stopGracePeriod := deployer.defaultStopGracePeriod
+	if metadata.StopGracePeriod != nil {
+		if *metadata.StopGracePeriod < 0 {
+			return newValidationError("Invalid stopGracePeriod %v, must not be negative", *metadata.StopGracePeriod)
+		}
+		stopGracePeriod = time.Duration(*metadata.StopGracePeriod) * time.Second
+	}
+
+	if stopGracePeriod > 0 {
+		containerSpec.StopGracePeriod = &stopGracePeriod
+	}
+
+	if !deployer.noClusterEnv {
+		containerSpec.Env = overrideEnv(containerSpec.Env, deployer.getClusterEnv())
+	}
+	containerSpec.Env = overrideEnv(containerSpec.Env, metadata.EnvOverrides)
+
+	err = deployer.validateServiceLabels(metadata)
+	if err != nil {
+		return err
+	}
+	setServiceLabels(spec, metadata)
+
+	err = validateAnnotations(metadata.Annotations)
+	if err != nil {
+		return err
+	}
+	setAnnotationLabels(spec, metadata.Annotations)
+
+	return nil
+}
+
+// getClusterEnv returns the env that identifies
+// the cluster to every deployed service
+func (deployer *Deployer) getClusterEnv() map[string]string {
+	env := map[string]string{}
+	for key, value := range deployer.clusterEnv {
+		env[key] = value
+	}
+	env["GOVERNATOR_CLUSTER"] = deployer.cluster
+	return env
+}
+
+// overrideEnv sets each of the overrides in env,
+// replacing any existing KEY=value entry for the same key
+func overrideEnv(env []string, overrides map[string]string) []string {
+	if len(overrides) == 0 {
+		return env
+	}
+
+	var result []string
+	for _, entry := range env {
+		key := strings.SplitN(entry, "=", 2)[0]
+		if _, ok := overrides[key]; !ok {
+			result = append(result, entry)
+		}
+	}
+
+	var keys []string
+	for key := range overrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		result = append(result, key+"="+overrides[key])
+	}
+	return result
+}
+
+// copyServiceSpec returns a deep copy of spec,
+// so it can be restored after spec is changed
+func copyServiceSpec(spec swarm.ServiceSpec) (swarm.ServiceSpec, error) {
+	var copied swarm.ServiceSpec
+
+	specBytes, err := json.Marshal(spec)
+	if err != nil {
+		return copied, err
+	}
+
+	err = json.Unmarshal(specBytes, &copied)
+	return copied, err
+}
@@ -0,0 +1,188 @@
+package deployer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/engine-api/types/swarm"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("updateServiceSpec", func() {
+	var sut *Deployer
+	var spec *swarm.ServiceSpec
+	var metadata *RequestMetadata
+	var err error
+
+	BeforeEach(func() {
+		sut = New(nil, nil, "redis-queue:name", "https://deploy-state.test", "super", WithDefaultStopGracePeriod(10*time.Second))
+		spec = &swarm.ServiceSpec{}
+		metadata = &RequestMetadata{DockerURL: "octoblu/my-application:v1"}
+	})
+
+	JustBeforeEach(func() {
+		err = sut.updateServiceSpec(spec, metadata)
+	})
+
+	It("Should set the image", func() {
+		Expect(err).To(BeNil())
+		Expect(spec.TaskTemplate.ContainerSpec.Image).To(Equal("octoblu/my-application:v1"))
+	})
+
+	Describe("When the metadata has no stopGracePeriod", func() {
+		It("Should use the default stop grace period", func() {
+			Expect(*spec.TaskTemplate.ContainerSpec.StopGracePeriod).To(Equal(10 * time.Second))
+		})
+	})
+
+	Describe("When the metadata has a stopGracePeriod", func() {
+		BeforeEach(func() {
+			stopGracePeriod := 45
+			metadata.StopGracePeriod = &stopGracePeriod
+		})
+
+		It("Should convert the seconds to a duration", func() {
+			Expect(int64(*spec.TaskTemplate.ContainerSpec.StopGracePeriod)).To(Equal(int64(45000000000)))
+		})
+	})
+
+	Describe("When the metadata has a negative stopGracePeriod", func() {
+		BeforeEach(func() {
+			stopGracePeriod := -1
+			metadata.StopGracePeriod = &stopGracePeriod
+		})
+
+		It("Should return a validation error", func() {
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+		})
+	})
+
+	Describe("When the metadata has annotations", func() {
+		BeforeEach(func() {
+			spec.Labels = map[string]string{
+				"governator.annotation/stale": "from the last deploy",
+				"owner":                       "octoblu",
+			}
+			metadata.Annotations = map[string]string{"ticket": "OPS-123"}
+		})
+
+		It("Should replace the annotation labels", func() {
+			Expect(err).To(BeNil())
+			Expect(spec.Labels).To(Equal(map[string]string{
+				"governator.annotation/ticket": "OPS-123",
+				"owner":                        "octoblu",
+			}))
+		})
+	})
+
+	Describe("When the metadata has too many annotations", func() {
+		BeforeEach(func() {
+			metadata.Annotations = map[string]string{}
+			for i := 0; i < 11; i++ {
+				metadata.Annotations[fmt.Sprintf("key-%v", i)] = "value"
+			}
+		})
+
+		It("Should return a validation error", func() {
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+		})
+	})
+
+	Describe("When an annotation value is too long", func() {
+		BeforeEach(func() {
+			metadata.Annotations = map[string]string{"ci-run": strings.Repeat("x", 1025)}
+		})
+
+		It("Should return a validation error", func() {
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+		})
+	})
+
+	Describe("When the metadata has envOverrides", func() {
+		BeforeEach(func() {
+			spec.TaskTemplate.ContainerSpec.Env = []string{"LOG_LEVEL=info", "PORT=80"}
+			metadata.EnvOverrides = map[string]string{"LOG_LEVEL": "debug", "FEATURE": "on"}
+		})
+
+		It("Should override the service's env", func() {
+			Expect(spec.TaskTemplate.ContainerSpec.Env).To(Equal([]string{"PORT=80", "GOVERNATOR_CLUSTER=super", "FEATURE=on", "LOG_LEVEL=debug"}))
+		})
+	})
+
+	Describe("When the metadata has no publishedPorts", func() {
+		BeforeEach(func() {
+			spec.EndpointSpec = &swarm.EndpointSpec{Ports: []swarm.PortConfig{{Protocol: "tcp", TargetPort: 80, PublishedPort: 8080}}}
+		})
+
+		It("Should preserve the existing ports", func() {
+			Expect(err).To(BeNil())
+			Expect(spec.EndpointSpec.Ports).To(Equal([]swarm.PortConfig{{Protocol: "tcp", TargetPort: 80, PublishedPort: 8080}}))
+		})
+	})
+
+	Describe("When the metadata has publishedPorts", func() {
+		BeforeEach(func() {
+			spec.EndpointSpec = &swarm.EndpointSpec{Ports: []swarm.PortConfig{{Protocol: "tcp", TargetPort: 80, PublishedPort: 8080}}}
+			metadata.PublishedPorts = []PortConfig{
+				{TargetPort: 8000, PublishedPort: 8080},
+				{Protocol: "udp", TargetPort: 53, PublishedPort: 8080},
+			}
+		})
+
+		It("Should replace the ports", func() {
+			Expect(err).To(BeNil())
+			Expect(spec.EndpointSpec.Ports).To(Equal([]swarm.PortConfig{
+				{Protocol: "tcp", TargetPort: 8000, PublishedPort: 8080},
+				{Protocol: "udp", TargetPort: 53, PublishedPort: 8080},
+			}))
+		})
+	})
+
+	Describe("When the metadata has an empty publishedPorts", func() {
+		BeforeEach(func() {
+			spec.EndpointSpec = &swarm.EndpointSpec{Ports: []swarm.PortConfig{{Protocol: "tcp", TargetPort: 80, PublishedPort: 8080}}}
+			metadata.PublishedPorts = []PortConfig{}
+		})
+
+		It("Should remove the ports", func() {
+			Expect(spec.EndpointSpec.Ports).To(BeEmpty())
+		})
+	})
+
+	Describe("When two publishedPorts share a published port", func() {
+		BeforeEach(func() {
+			metadata.PublishedPorts = []PortConfig{
+				{TargetPort: 80, PublishedPort: 8080},
+				{Protocol: "tcp", TargetPort: 81, PublishedPort: 8080},
+			}
+		})
+
+		It("Should return a validation error", func() {
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+		})
+	})
+
+	Describe("When the deployer has cluster env", func() {
+		BeforeEach(func() {
+			sut = New(nil, nil, "redis-queue:name", "https://deploy-state.test", "super", WithClusterEnv(map[string]string{"REGION": "us-west-2"}))
+			spec.TaskTemplate.ContainerSpec.Env = []string{"REGION=unknown", "GOVERNATOR_CLUSTER=old"}
+		})
+
+		It("Should set the cluster env and GOVERNATOR_CLUSTER", func() {
+			Expect(spec.TaskTemplate.ContainerSpec.Env).To(Equal([]string{"GOVERNATOR_CLUSTER=super", "REGION=us-west-2"}))
+		})
+	})
+
+	Describe("When the deployer has no cluster env", func() {
+		BeforeEach(func() {
+			sut = New(nil, nil, "redis-queue:name", "https://deploy-state.test", "super", WithClusterEnv(map[string]string{"REGION": "us-west-2"}), WithNoClusterEnv())
+			spec.TaskTemplate.ContainerSpec.Env = []string{"PORT=80"}
+		})
+
+		It("Should leave the env alone", func() {
+			Expect(spec.TaskTemplate.ContainerSpec.Env).To(Equal([]string{"PORT=80"}))
+		})
+	})
+})
@@ -0,0 +1,71 @@
+package deployer
+
+import (
+	cryptorand "crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/octoblu/governator-swarm/metrics"
+)
+
+func init() {
+	metrics.DefaultRegistry.Help("governator_stagger_delay_seconds", "Delay added before the next Run after a successful deploy")
+}
+
+// ParseStaggerDelay parses a min:max range of durations, e.g. 0s:5s
+func ParseStaggerDelay(value string) (time.Duration, time.Duration, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("stagger delay '%v' should be min:max", value)
+	}
+
+	min, err := time.ParseDuration(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	max, err := time.ParseDuration(parts[1])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if min < 0 || max < min {
+		return 0, 0, fmt.Errorf("stagger delay '%v' should have 0 <= min <= max", value)
+	}
+	return min, max, nil
+}
+
+// newStaggerRand seeds the jitter with seed, or
+// with a crypto random seed when seed is 0
+func newStaggerRand(seed int64) *rand.Rand {
+	if seed == 0 {
+		var seedBytes [8]byte
+		_, err := cryptorand.Read(seedBytes[:])
+		if err == nil {
+			seed = int64(binary.LittleEndian.Uint64(seedBytes[:]))
+		} else {
+			seed = time.Now().UnixNano()
+		}
+	}
+	return rand.New(rand.NewSource(seed))
+}
+
+// setStaggerDelay picks a random delay in [staggerMin, staggerMax]
+// to wait, on top of the poll interval, before the next Run
+func (deployer *Deployer) setStaggerDelay() {
+	if deployer.staggerRand == nil {
+		return
+	}
+
+	delay := deployer.staggerMin
+	if spread := deployer.staggerMax - deployer.staggerMin; spread > 0 {
+		delay += time.Duration(deployer.staggerRand.Int63n(int64(spread) + 1))
+	}
+
+	debug("setStaggerDelay: %v", delay)
+	deployer.staggerDelay = delay
+	deployer.metrics.SetGauge("governator_stagger_delay_seconds", delay.Seconds(), nil)
+}
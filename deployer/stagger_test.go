@@ -0,0 +1,60 @@
+package deployer
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParseStaggerDelay", func() {
+	It("Should parse min:max", func() {
+		min, max, err := ParseStaggerDelay("1s:5s")
+		Expect(err).To(BeNil())
+		Expect(min).To(Equal(time.Second))
+		Expect(max).To(Equal(5 * time.Second))
+	})
+
+	table.DescribeTable("Should reject invalid ranges",
+		func(value string) {
+			_, _, err := ParseStaggerDelay(value)
+			Expect(err).NotTo(BeNil())
+		},
+		table.Entry("without a max", "5s"),
+		table.Entry("with an invalid duration", "0s:soon"),
+		table.Entry("with max before min", "5s:1s"),
+		table.Entry("with a negative min", "-1s:1s"),
+	)
+})
+
+var _ = Describe("setStaggerDelay", func() {
+	var sut *Deployer
+
+	BeforeEach(func() {
+		sut = New(nil, nil, "redis-queue:name", "https://deploy-state.test", "super", WithPollInterval(time.Second), WithStaggerDelay(time.Second, 5*time.Second, 42))
+	})
+
+	It("Should add a delay in the range to the poll interval", func() {
+		for i := 0; i < 20; i++ {
+			sut.setStaggerDelay()
+			Expect(sut.PollInterval()).To(BeNumerically(">=", 2*time.Second))
+			Expect(sut.PollInterval()).To(BeNumerically("<=", 6*time.Second))
+		}
+	})
+
+	It("Should pick the same delays for the same seed", func() {
+		other := New(nil, nil, "redis-queue:name", "https://deploy-state.test", "super", WithStaggerDelay(time.Second, 5*time.Second, 42))
+		sut.setStaggerDelay()
+		other.setStaggerDelay()
+		Expect(sut.staggerDelay).To(Equal(other.staggerDelay))
+	})
+
+	Describe("When there's no stagger delay", func() {
+		It("Should not add a delay", func() {
+			sut = New(nil, nil, "redis-queue:name", "https://deploy-state.test", "super", WithPollInterval(time.Second))
+			sut.setStaggerDelay()
+			Expect(sut.PollInterval()).To(Equal(time.Second))
+		})
+	})
+})
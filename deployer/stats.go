@@ -0,0 +1,124 @@
+package deployer
+
+import (
+	"sort"
+	"time"
+)
+
+// ServiceStats summarizes a service's deploys over a window of the history
+type ServiceStats struct {
+	Service       string  `json:"service"`
+	Deploys       int     `json:"deploys"`
+	Failures      int     `json:"failures"`
+	DeploysPerDay float64 `json:"deploysPerDay"`
+	SuccessRate   float64 `json:"successRate"`
+	Recoveries    int     `json:"recoveries"`
+	MTTR          float64 `json:"mttrSeconds"`
+	P95Duration   float64 `json:"p95DurationSeconds"`
+}
+
+// FailureRate is the share of the service's deploys that failed
+func (stats *ServiceStats) FailureRate() float64 {
+	return 1 - stats.SuccessRate
+}
+
+// ComputeStats summarizes the records from the window before now per
+// service, sorted by failure rate, highest first. MTTR is the average
+// time from the first of a run of failed deploys to the end of the next
+// passed deploy of the same service. The history only keeps the last
+// maxHistory deploys, so when it's full and doesn't reach back to the
+// start of the window, deploys per day are over the period it covers
+func ComputeStats(records []DeployRecord, window time.Duration, now time.Time) []ServiceStats {
+	start := now.Add(-window)
+	period := window
+
+	byService := map[string][]DeployRecord{}
+	var oldest time.Time
+	for _, record := range records {
+		if oldest.IsZero() || record.Timestamp.Before(oldest) {
+			oldest = record.Timestamp
+		}
+		if record.Timestamp.Before(start) || record.Timestamp.After(now) {
+			continue
+		}
+		byService[record.Service] = append(byService[record.Service], record)
+	}
+	if len(records) >= maxHistory && oldest.After(start) {
+		period = now.Sub(oldest)
+	}
+	days := period.Hours() / 24
+
+	allStats := []ServiceStats{}
+	for service, serviceRecords := range byService {
+		stats := computeServiceStats(serviceRecords)
+		stats.Service = service
+		if days > 0 {
+			stats.DeploysPerDay = float64(stats.Deploys) / days
+		}
+		allStats = append(allStats, stats)
+	}
+
+	sort.Sort(byFailureRate(allStats))
+	return allStats
+}
+
+func computeServiceStats(records []DeployRecord) ServiceStats {
+	sort.Sort(byTimestamp(records))
+
+	stats := ServiceStats{Deploys: len(records)}
+	durations := []float64{}
+	var failedAt time.Time
+	var recoveryTime time.Duration
+	for _, record := range records {
+		durations = append(durations, record.Duration)
+		if record.Result != DeployPassed {
+			stats.Failures++
+			if failedAt.IsZero() {
+				failedAt = record.Timestamp
+			}
+			continue
+		}
+		if !failedAt.IsZero() {
+			finishedAt := record.Timestamp.Add(time.Duration(record.Duration * float64(time.Second)))
+			recoveryTime += finishedAt.Sub(failedAt)
+			stats.Recoveries++
+			failedAt = time.Time{}
+		}
+	}
+
+	stats.SuccessRate = float64(stats.Deploys-stats.Failures) / float64(stats.Deploys)
+	if stats.Recoveries > 0 {
+		stats.MTTR = recoveryTime.Seconds() / float64(stats.Recoveries)
+	}
+	stats.P95Duration = percentile(durations, 95)
+	return stats
+}
+
+// percentile is the nearest rank percentile of values
+func percentile(values []float64, percent int) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	rank := (len(values)*percent + 99) / 100
+	return values[rank-1]
+}
+
+type byFailureRate []ServiceStats
+
+func (stats byFailureRate) Len() int      { return len(stats) }
+func (stats byFailureRate) Swap(i, j int) { stats[i], stats[j] = stats[j], stats[i] }
+func (stats byFailureRate) Less(i, j int) bool {
+	if stats[i].FailureRate() != stats[j].FailureRate() {
+		return stats[i].FailureRate() > stats[j].FailureRate()
+	}
+	return stats[i].Service < stats[j].Service
+}
+
+type byTimestamp []DeployRecord
+
+func (records byTimestamp) Len() int { return len(records) }
+func (records byTimestamp) Less(i, j int) bool {
+	return records[i].Timestamp.Before(records[j].Timestamp)
+}
+func (records byTimestamp) Swap(i, j int) { records[i], records[j] = records[j], records[i] }
@@ -0,0 +1,96 @@
+package deployer
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ComputeStats", func() {
+	var now time.Time
+
+	BeforeEach(func() {
+		now = time.Date(2017, 3, 8, 12, 0, 0, 0, time.UTC)
+	})
+
+	record := func(service, result string, hoursAgo, duration float64) DeployRecord {
+		return DeployRecord{
+			Service:   service,
+			Result:    result,
+			Timestamp: now.Add(-time.Duration(hoursAgo * float64(time.Hour))),
+			Duration:  duration,
+		}
+	}
+
+	It("Should sort the services by failure rate, highest first", func() {
+		records := []DeployRecord{
+			record("my-application", DeployPassed, 1, 10),
+			record("my-worker", DeployFailed, 2, 10),
+			record("my-worker", DeployPassed, 3, 10),
+			record("my-application", DeployPassed, 4, 10),
+		}
+		allStats := ComputeStats(records, 7*24*time.Hour, now)
+		Expect(allStats).To(HaveLen(2))
+		Expect(allStats[0].Service).To(Equal("my-worker"))
+		Expect(allStats[0].SuccessRate).To(Equal(0.5))
+		Expect(allStats[1].Service).To(Equal("my-application"))
+		Expect(allStats[1].SuccessRate).To(Equal(1.0))
+	})
+
+	It("Should count deploys per day over the window", func() {
+		records := []DeployRecord{
+			record("my-application", DeployPassed, 1, 10),
+			record("my-application", DeployPassed, 30, 10),
+			record("my-application", DeployPassed, 50, 10),
+		}
+		allStats := ComputeStats(records, 2*24*time.Hour, now)
+		Expect(allStats).To(HaveLen(1))
+		Expect(allStats[0].Deploys).To(Equal(2))
+		Expect(allStats[0].DeploysPerDay).To(Equal(1.0))
+	})
+
+	It("Should measure MTTR from the first failure to the end of the next passed deploy", func() {
+		records := []DeployRecord{
+			record("my-application", DeployPassed, 1, 60),
+			record("my-application", DeployFailed, 2, 60),
+			record("my-application", DeployFailed, 3, 60),
+			record("my-application", DeployPassed, 10, 60),
+			record("my-application", DeployFailed, 11, 60),
+		}
+		allStats := ComputeStats(records, 7*24*time.Hour, now)
+		Expect(allStats[0].Recoveries).To(Equal(2))
+		// 2h1m from the failure 3 hours ago, 1h1m from the one 11 hours ago
+		Expect(allStats[0].MTTR).To(Equal(float64(3*60*60+2*60) / 2))
+	})
+
+	It("Should leave MTTR at 0 when the service never recovered", func() {
+		records := []DeployRecord{
+			record("my-application", DeployFailed, 1, 60),
+			record("my-application", DeployPassed, 2, 60),
+		}
+		allStats := ComputeStats(records, 7*24*time.Hour, now)
+		Expect(allStats[0].Recoveries).To(Equal(0))
+		Expect(allStats[0].MTTR).To(Equal(0.0))
+	})
+
+	It("Should use the nearest rank P95 of the durations", func() {
+		records := []DeployRecord{}
+		for i := 1; i <= 20; i++ {
+			records = append(records, record("my-application", DeployPassed, float64(i), float64(i)))
+		}
+		allStats := ComputeStats(records, 7*24*time.Hour, now)
+		Expect(allStats[0].P95Duration).To(Equal(19.0))
+	})
+
+	Describe("When the history is full and doesn't reach the start of the window", func() {
+		It("Should count deploys per day over the period it covers", func() {
+			records := []DeployRecord{}
+			for i := 0; i < maxHistory; i++ {
+				records = append(records, record("my-application", DeployPassed, float64(i)*0.48, 10))
+			}
+			allStats := ComputeStats(records, 7*24*time.Hour, now)
+			Expect(allStats[0].DeploysPerDay).To(BeNumerically("~", 100/(99*0.48/24), 0.001))
+		})
+	})
+})
@@ -0,0 +1,71 @@
+package deployer
+
+import (
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"golang.org/x/net/context"
+)
+
+// statusTimeout bounds each of the checks made by Status
+const statusTimeout = 5 * time.Second
+
+// Status is a snapshot of the deployer's health and work
+type Status struct {
+	Uptime        float64        `json:"uptimeSeconds"`
+	RedisOK       bool           `json:"redisOk"`
+	RedisError    string         `json:"redisError,omitempty"`
+	DockerOK      bool           `json:"dockerOk"`
+	DockerError   string         `json:"dockerError,omitempty"`
+	QueueDepth    int            `json:"queueDepth"`
+	InFlight      int            `json:"inFlight"`
+	Paused        bool           `json:"paused"`
+	RecentDeploys []DeployRecord `json:"recentDeploys"`
+}
+
+// Status checks redis and docker and reports the queue depth,
+// in flight deploys, whether it is paused and the last 5 deploys
+func (deployer *Deployer) Status() *Status {
+	status := &Status{
+		Uptime:        time.Since(deployer.startedAt).Seconds(),
+		InFlight:      deployer.events.count(),
+		Paused:        deployer.Paused(),
+		RecentDeploys: []DeployRecord{},
+	}
+
+	queueDepth, err := deployer.getQueueDepth()
+	if err != nil {
+		status.RedisError = err.Error()
+	} else {
+		status.RedisOK = true
+		status.QueueDepth = queueDepth
+
+		recentDeploys, err := deployer.GetHistory(5)
+		if err == nil {
+			status.RecentDeploys = recentDeploys
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), statusTimeout)
+	defer cancel()
+
+	_, err = deployer.dockerClient.ServerVersion(ctx)
+	if err != nil {
+		status.DockerError = err.Error()
+	} else {
+		status.DockerOK = true
+	}
+
+	return status
+}
+
+func (deployer *Deployer) getQueueDepth() (int, error) {
+	redisConn := deployer.redisPool.Get()
+	defer redisConn.Close()
+
+	depth, err := redis.Int(redisConn.Do("ZCARD", deployer.keys.DeployKey()))
+	if err != nil {
+		return 0, newRedisError(err)
+	}
+	return depth, nil
+}
@@ -0,0 +1,112 @@
+package deployer
+
+import (
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/docker/engine-api/client"
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/swarm"
+	"github.com/octoblu/governator-swarm/metrics"
+	"golang.org/x/net/context"
+)
+
+func init() {
+	metrics.DefaultRegistry.Help("governator_swarm_rejoin_total", "Times the deployer's node rejoined the swarm")
+}
+
+// swarmRejoiningClient rejoins the swarm and retries the call when the
+// daemon reports it isn't part of a swarm anymore, e.g. after the
+// manager it joined through was replaced
+type swarmRejoiningClient struct {
+	client.APIClient
+
+	joinRequest swarm.JoinRequest
+	lock        sync.Mutex
+}
+
+// NewSwarmRejoiningClient wraps dockerClient so the service and task
+// calls rejoin the swarm through manager with token, then retry,
+// when the node has left the swarm. Only useful on manager nodes
+func NewSwarmRejoiningClient(dockerClient client.APIClient, token, manager string) client.APIClient {
+	return &swarmRejoiningClient{
+		APIClient: dockerClient,
+		joinRequest: swarm.JoinRequest{
+			ListenAddr:  "0.0.0.0:2377",
+			RemoteAddrs: []string{manager},
+			JoinToken:   token,
+		},
+	}
+}
+
+func (rejoiningClient *swarmRejoiningClient) ServiceInspectWithRaw(ctx context.Context, serviceID string) (swarm.Service, []byte, error) {
+	var service swarm.Service
+	var raw []byte
+	err := rejoiningClient.retry(ctx, func() error {
+		var err error
+		service, raw, err = rejoiningClient.APIClient.ServiceInspectWithRaw(ctx, serviceID)
+		return err
+	})
+	return service, raw, err
+}
+
+func (rejoiningClient *swarmRejoiningClient) ServiceList(ctx context.Context, options types.ServiceListOptions) ([]swarm.Service, error) {
+	var services []swarm.Service
+	err := rejoiningClient.retry(ctx, func() error {
+		var err error
+		services, err = rejoiningClient.APIClient.ServiceList(ctx, options)
+		return err
+	})
+	return services, err
+}
+
+func (rejoiningClient *swarmRejoiningClient) ServiceUpdate(ctx context.Context, serviceID string, version swarm.Version, spec swarm.ServiceSpec, options types.ServiceUpdateOptions) error {
+	return rejoiningClient.retry(ctx, func() error {
+		return rejoiningClient.APIClient.ServiceUpdate(ctx, serviceID, version, spec, options)
+	})
+}
+
+func (rejoiningClient *swarmRejoiningClient) TaskList(ctx context.Context, options types.TaskListOptions) ([]swarm.Task, error) {
+	var tasks []swarm.Task
+	err := rejoiningClient.retry(ctx, func() error {
+		var err error
+		tasks, err = rejoiningClient.APIClient.TaskList(ctx, options)
+		return err
+	})
+	return tasks, err
+}
+
+// retry calls call again after rejoining the swarm,
+// when it failed because the node isn't part of one
+func (rejoiningClient *swarmRejoiningClient) retry(ctx context.Context, call func() error) error {
+	err := call()
+	if !isNotInSwarmError(err) {
+		return err
+	}
+
+	joinErr := rejoiningClient.rejoin(ctx)
+	if joinErr != nil {
+		log.Println("Error rejoining the swarm", joinErr)
+		return err
+	}
+	return call()
+}
+
+func (rejoiningClient *swarmRejoiningClient) rejoin(ctx context.Context) error {
+	rejoiningClient.lock.Lock()
+	defer rejoiningClient.lock.Unlock()
+
+	err := rejoiningClient.APIClient.SwarmJoin(ctx, rejoiningClient.joinRequest)
+	if err != nil && !strings.Contains(err.Error(), "already part of a swarm") {
+		return err
+	}
+
+	log.Println("WARNING: this node had left the swarm, rejoined through", rejoiningClient.joinRequest.RemoteAddrs)
+	metrics.DefaultRecorder.IncrCounter("governator_swarm_rejoin_total", nil)
+	return nil
+}
+
+func isNotInSwarmError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "not part of a swarm")
+}
@@ -0,0 +1,84 @@
+package deployer
+
+import (
+	"fmt"
+
+	"github.com/docker/engine-api/client"
+	"github.com/docker/engine-api/types/swarm"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("NewSwarmRejoiningClient", func() {
+	var dockerClient *leftSwarmClient
+	var sut client.APIClient
+	var service swarm.Service
+	var err error
+
+	BeforeEach(func() {
+		dockerClient = &leftSwarmClient{}
+		sut = NewSwarmRejoiningClient(dockerClient, "SWMTKN-1-secret", "10.0.0.1:2377")
+	})
+
+	JustBeforeEach(func() {
+		service, _, err = sut.ServiceInspectWithRaw(context.Background(), "my-application")
+	})
+
+	Describe("When the node is in the swarm", func() {
+		BeforeEach(func() {
+			dockerClient.inSwarm = true
+		})
+
+		It("Should not rejoin", func() {
+			Expect(err).To(BeNil())
+			Expect(dockerClient.joins).To(BeEmpty())
+		})
+	})
+
+	Describe("When the node has left the swarm", func() {
+		It("Should rejoin through the manager and retry", func() {
+			Expect(err).To(BeNil())
+			Expect(service.ID).To(Equal("service-id"))
+			Expect(dockerClient.joins).To(Equal([]swarm.JoinRequest{{
+				ListenAddr:  "0.0.0.0:2377",
+				RemoteAddrs: []string{"10.0.0.1:2377"},
+				JoinToken:   "SWMTKN-1-secret",
+			}}))
+		})
+	})
+
+	Describe("When rejoining fails", func() {
+		BeforeEach(func() {
+			dockerClient.joinErr = fmt.Errorf("Error response from daemon: Timeout was reached")
+		})
+
+		It("Should return the original error", func() {
+			Expect(err).To(MatchError(ContainSubstring("not part of a swarm")))
+		})
+	})
+})
+
+type leftSwarmClient struct {
+	client.APIClient
+
+	inSwarm bool
+	joins   []swarm.JoinRequest
+	joinErr error
+}
+
+func (fake *leftSwarmClient) SwarmJoin(ctx context.Context, request swarm.JoinRequest) error {
+	fake.joins = append(fake.joins, request)
+	if fake.joinErr != nil {
+		return fake.joinErr
+	}
+	fake.inSwarm = true
+	return nil
+}
+
+func (fake *leftSwarmClient) ServiceInspectWithRaw(ctx context.Context, serviceID string) (swarm.Service, []byte, error) {
+	if !fake.inSwarm {
+		return swarm.Service{}, nil, fmt.Errorf("Error response from daemon: This node is not a swarm manager. Use \"docker swarm init\" or \"docker swarm join\" to connect this node to swarm and try again. (node is not part of a swarm)")
+	}
+	return swarm.Service{ID: "service-id"}, nil, nil
+}
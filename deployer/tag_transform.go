@@ -0,0 +1,120 @@
+package deployer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+var sedGroupReference = regexp.MustCompile(`\\([0-9])`)
+
+// TagTransform is a sed style s/pattern/replacement/
+// substitution applied to the tag of deployed images
+type TagTransform struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// ParseTagTransform parses a substitution like s/main-([a-f0-9]{7})/dev-\1/.
+// Any character may be used as the delimiter, and \1 style group
+// references in the replacement are supported
+func ParseTagTransform(expression string) (*TagTransform, error) {
+	if len(expression) < 2 || expression[0] != 's' {
+		return nil, fmt.Errorf("expected s/pattern/replacement/, got '%v'", expression)
+	}
+
+	delimiter := expression[1:2]
+	parts := splitUnescaped(expression[2:], delimiter)
+	if len(parts) != 3 || parts[2] != "" {
+		return nil, fmt.Errorf("expected s%vpattern%vreplacement%v, got '%v'", delimiter, delimiter, delimiter, expression)
+	}
+
+	pattern, err := regexp.Compile(parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	replacement := sedGroupReference.ReplaceAllString(parts[1], "$${$1}")
+	return &TagTransform{pattern: pattern, replacement: replacement}, nil
+}
+
+// LoadTagTransforms reads a file of tag transforms, one per
+// line. Blank lines and lines starting with # are skipped
+func LoadTagTransforms(path string) ([]*TagTransform, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var tagTransforms []*TagTransform
+	for number, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		tagTransform, err := ParseTagTransform(line)
+		if err != nil {
+			return nil, fmt.Errorf("%v line %v: %v", path, number+1, err)
+		}
+		tagTransforms = append(tagTransforms, tagTransform)
+	}
+	return tagTransforms, nil
+}
+
+// Apply returns the transformed tag
+func (transform *TagTransform) Apply(tag string) string {
+	return transform.pattern.ReplaceAllString(tag, transform.replacement)
+}
+
+// splitUnescaped splits value on delimiter, except where the
+// delimiter is escaped with a backslash
+func splitUnescaped(value, delimiter string) []string {
+	var parts []string
+	current := ""
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) && value[i+1:i+2] == delimiter {
+			current += delimiter
+			i++
+			continue
+		}
+		if value[i:i+1] == delimiter {
+			parts = append(parts, current)
+			current = ""
+			continue
+		}
+		current += value[i : i+1]
+	}
+	return append(parts, current)
+}
+
+// transformImageTag applies the tag transforms, in order,
+// to the tag of metadata.DockerURL
+func (deployer *Deployer) transformImageTag(metadata *RequestMetadata) error {
+	tagTransforms := deployer.getTagTransforms()
+	if len(tagTransforms) == 0 {
+		return nil
+	}
+
+	original := metadata.DockerURL
+	colon := strings.LastIndex(original, ":")
+	if colon < strings.LastIndex(original, "/") || colon == -1 {
+		return &TagTransformError{DockerURL: original}
+	}
+
+	tag := original[colon+1:]
+	for _, transform := range tagTransforms {
+		tag = transform.Apply(tag)
+	}
+
+	if tag == "" {
+		return &TagTransformError{DockerURL: original}
+	}
+
+	metadata.DockerURL = original[:colon+1] + tag
+	if metadata.DockerURL != original {
+		deployer.logger.Println("Transformed image", original, "to", metadata.DockerURL)
+	}
+	return nil
+}
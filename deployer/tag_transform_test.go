@@ -0,0 +1,55 @@
+package deployer
+
+import (
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TagTransform", func() {
+	table.DescribeTable("ParseTagTransform and Apply",
+		func(expression, tag, expected string) {
+			transform, err := ParseTagTransform(expression)
+			Expect(err).To(BeNil())
+			Expect(transform.Apply(tag)).To(Equal(expected))
+		},
+		table.Entry("group references", `s/main-([a-f0-9]{7})/dev-\1/`, "main-abc1234", "dev-abc1234"),
+		table.Entry("no match", `s/main-([a-f0-9]{7})/dev-\1/`, "v1.2.3", "v1.2.3"),
+		table.Entry("other delimiters", `s|^release-|v|`, "release-1.2.3", "v1.2.3"),
+		table.Entry("escaped delimiters", `s/\//-/`, "feature/thing", "feature-thing"),
+	)
+
+	It("Should reject expressions that aren't substitutions", func() {
+		_, err := ParseTagTransform("main-(.*)")
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("Should reject invalid patterns", func() {
+		_, err := ParseTagTransform("s/main-(/dev/")
+		Expect(err).NotTo(BeNil())
+	})
+
+	Describe("transformImageTag", func() {
+		var sut *Deployer
+		var metadata *RequestMetadata
+
+		BeforeEach(func() {
+			first, _ := ParseTagTransform(`s/^main-//`)
+			second, _ := ParseTagTransform(`s/^([a-f0-9]{7})$/dev-\1/`)
+			sut = New(nil, nil, "redis-queue:name", "https://deploy-state.test", "super", WithImageTagTransforms(first, second))
+			metadata = &RequestMetadata{DockerURL: "registry.example.com:5000/octoblu/my-application:main-abc1234"}
+		})
+
+		It("Should apply the transforms in order to the tag only", func() {
+			Expect(sut.transformImageTag(metadata)).To(Succeed())
+			Expect(metadata.DockerURL).To(Equal("registry.example.com:5000/octoblu/my-application:dev-abc1234"))
+		})
+
+		It("Should return a TagTransformError when the tag ends up empty", func() {
+			metadata.DockerURL = "octoblu/my-application:main-"
+			err := sut.transformImageTag(metadata)
+			Expect(err).To(BeAssignableToTypeOf(&TagTransformError{}))
+			Expect(err.(DeployError).IsRetryable()).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,79 @@
+package deployer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/docker/engine-api/types"
+	"github.com/octoblu/governator-swarm/metrics"
+	"github.com/octoblu/governator-swarm/monitor"
+	"golang.org/x/net/context"
+)
+
+func init() {
+	metrics.DefaultRegistry.Help("governator_task_failures_total", "Tasks that failed while a deploy was watching them, by service and reason")
+}
+
+// logTaskFailure logs what is known about a failed task,
+// including its last log line when failure logs are captured
+func (deployer *Deployer) logTaskFailure(ctx context.Context, service string, event monitor.TaskEvent) {
+	deployer.metrics.IncrCounter("governator_task_failures_total", map[string]string{"service": service, "reason": event.Error})
+
+	lastLine := ""
+	if deployer.captureFailureLogs && event.ContainerID != "" {
+		var err error
+		lastLine, err = deployer.getLastLogLine(ctx, event.ContainerID)
+		if err != nil {
+			debug("logTaskFailure: %v", err)
+		}
+	}
+
+	deployer.logger.Println("Task failed", service, "task", event.TaskID, "node", event.NodeID, "container", event.ContainerID, "exit code", event.ExitCode, "error", event.Error, "last log line", lastLine)
+}
+
+func (deployer *Deployer) getLastLogLine(ctx context.Context, containerID string) (string, error) {
+	logs, err := deployer.dockerClient.ContainerLogs(ctx, containerID, types.ContainerLogsOptions{ShowStdout: true, ShowStderr: true, Tail: "1"})
+	if err != nil {
+		return "", err
+	}
+	defer logs.Close()
+
+	logBytes, err := ioutil.ReadAll(logs)
+	if err != nil {
+		return "", err
+	}
+	return lastLine(demuxLogs(logBytes)), nil
+}
+
+// demuxLogs strips the 8 byte frame headers docker puts on the logs of
+// containers without a tty. Logs that don't start with one are returned as is
+func demuxLogs(logBytes []byte) []byte {
+	if len(logBytes) < 8 || logBytes[0] > 2 || !bytes.Equal(logBytes[1:4], []byte{0, 0, 0}) {
+		return logBytes
+	}
+
+	var output bytes.Buffer
+	reader := bufio.NewReader(bytes.NewReader(logBytes))
+	header := make([]byte, 8)
+	for {
+		_, err := io.ReadFull(reader, header)
+		if err != nil {
+			return output.Bytes()
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		_, err = io.CopyN(&output, reader, size)
+		if err != nil {
+			return output.Bytes()
+		}
+	}
+}
+
+func lastLine(logBytes []byte) string {
+	lines := strings.Split(strings.TrimRight(string(logBytes), "\n"), "\n")
+	return lines[len(lines)-1]
+}
@@ -0,0 +1,47 @@
+package deployer
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/docker/engine-api/types/swarm"
+	"github.com/octoblu/governator-swarm/metrics"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("logTaskFailure", func() {
+	table.DescribeTable("demuxLogs and lastLine",
+		func(logs, expected string) {
+			Expect(lastLine(demuxLogs([]byte(logs)))).To(Equal(expected))
+		},
+		table.Entry("tty logs", "starting\npanic: boom\n", "panic: boom"),
+		table.Entry("multiplexed logs", "\x01\x00\x00\x00\x00\x00\x00\x09starting\n\x02\x00\x00\x00\x00\x00\x00\x0cpanic: boom\n", "panic: boom"),
+		table.Entry("no logs", "", ""),
+	)
+
+	It("Should count the failure by service and reason", func() {
+		dockerClient := &fakeDockerClient{
+			service: swarm.Service{ID: "service-id"},
+			logs:    map[string]string{"container-id": "panic: boom\n"},
+		}
+		dockerClient.service.Spec.Name = "my-failing-application"
+		dockerClient.service.Spec.TaskTemplate.ContainerSpec.Image = "octoblu/my-failing-application:v1"
+
+		failedTask := watchTask("new", "octoblu/my-failing-application:v2", swarm.TaskStateFailed)
+		failedTask.Status.ContainerStatus.ContainerID = "container-id"
+		dockerClient.taskLists = [][]swarm.Task{
+			{watchTask("new", "octoblu/my-failing-application:v2", swarm.TaskStateRunning)},
+			{failedTask},
+		}
+
+		sut := New(dockerClient, nil, "redis-queue:name", "https://deploy-state.test", "super", WithDeployWindow(100*time.Millisecond, 5*time.Millisecond), WithFailureLogs())
+		err := sut.deploy("my-failing-application:v2", &RequestMetadata{DockerURL: "octoblu/my-failing-application:v2"})
+		Expect(err).To(HaveOccurred())
+
+		var output bytes.Buffer
+		Expect(metrics.DefaultRegistry.WritePrometheus(&output)).To(Succeed())
+		Expect(output.String()).To(ContainSubstring(`governator_task_failures_total{reason="task: non-zero exit (1)",service="my-failing-application"} 1`))
+	})
+})
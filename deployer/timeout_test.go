@@ -0,0 +1,116 @@
+package deployer
+
+import (
+	"time"
+
+	"github.com/docker/engine-api/types/swarm"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("deploy timeouts", func() {
+	var sut *Deployer
+	var dockerClient *fakeDockerClient
+	var metadata *RequestMetadata
+	var err error
+
+	BeforeEach(func() {
+		dockerClient = &fakeDockerClient{
+			service: swarm.Service{ID: "service-id"},
+		}
+		dockerClient.service.Spec.Name = "my-application"
+		dockerClient.service.Spec.TaskTemplate.ContainerSpec.Image = "octoblu/my-application:v1"
+
+		sut = New(dockerClient, nil, "redis-queue:name", deployState.URL, "super", WithDeployTimeout(time.Hour))
+		metadata = &RequestMetadata{DockerURL: "octoblu/my-application:v2"}
+	})
+
+	JustBeforeEach(func() {
+		err = sut.deploy("my-application:v2", metadata)
+	})
+
+	Describe("When the update finishes in time", func() {
+		It("Should update the service", func() {
+			Expect(err).To(BeNil())
+			Expect(dockerClient.updates).To(HaveLen(1))
+			Expect(dockerClient.service.Spec.TaskTemplate.ContainerSpec.Image).To(Equal("octoblu/my-application:v2"))
+		})
+	})
+
+	Describe("When the update hangs past the deploy's timeoutSeconds", func() {
+		BeforeEach(func() {
+			dockerClient.hangUpdates = true
+			metadata.TimeoutSeconds = 1
+		})
+
+		It("Should return a DeployTimeoutError", func() {
+			timeoutErr, ok := err.(*DeployTimeoutError)
+			Expect(ok).To(BeTrue())
+			Expect(timeoutErr.Deploy).To(Equal("my-application:v2"))
+			Expect(timeoutErr.Service).To(Equal("my-application"))
+			Expect(timeoutErr.Stage).To(Equal("updating the service"))
+			Expect(timeoutErr.Timeout).To(Equal(time.Second))
+			Expect(timeoutErr.IsRetryable()).To(BeTrue())
+		})
+
+		It("Should roll the service back", func() {
+			Expect(dockerClient.updates).To(HaveLen(2))
+			Expect(dockerClient.service.Spec.TaskTemplate.ContainerSpec.Image).To(Equal("octoblu/my-application:v1"))
+		})
+	})
+
+	Describe("When the update hangs and native rollback is configured", func() {
+		var rollbacker *fakeRollbacker
+
+		BeforeEach(func() {
+			dockerClient.hangUpdates = true
+			rollbacker = &fakeRollbacker{}
+			sut = New(dockerClient, nil, "redis-queue:name", deployState.URL, "super", WithDeployTimeout(100*time.Millisecond), WithNativeRollback(rollbacker))
+		})
+
+		Describe("and the daemon's API is 1.31 or newer", func() {
+			BeforeEach(func() {
+				dockerClient.apiVersion = "1.31"
+			})
+
+			It("Should use docker's rollback", func() {
+				Expect(rollbacker.services).To(Equal([]string{"service-id"}))
+				Expect(dockerClient.updates).To(HaveLen(1))
+			})
+		})
+
+		Describe("and the daemon's API is older than 1.31", func() {
+			BeforeEach(func() {
+				dockerClient.apiVersion = "1.24"
+			})
+
+			It("Should send the previous spec instead", func() {
+				Expect(rollbacker.services).To(BeEmpty())
+				Expect(dockerClient.updates).To(HaveLen(2))
+				Expect(dockerClient.service.Spec.TaskTemplate.ContainerSpec.Image).To(Equal("octoblu/my-application:v1"))
+			})
+		})
+	})
+
+	Describe("When timeoutSeconds is negative", func() {
+		BeforeEach(func() {
+			metadata.TimeoutSeconds = -1
+		})
+
+		It("Should return a ValidationError without updating", func() {
+			_, ok := err.(*ValidationError)
+			Expect(ok).To(BeTrue())
+			Expect(dockerClient.updates).To(BeEmpty())
+		})
+	})
+})
+
+type fakeRollbacker struct {
+	services []string
+}
+
+func (rollbacker *fakeRollbacker) RollbackService(ctx context.Context, service swarm.Service) error {
+	rollbacker.services = append(rollbacker.services, service.ID)
+	return nil
+}
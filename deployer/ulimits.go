@@ -0,0 +1,49 @@
+package deployer
+
+// Ulimit is a ulimit the service's containers run with
+type Ulimit struct {
+	Name string `json:"name"`
+	Soft int64  `json:"soft"`
+	Hard int64  `json:"hard"`
+}
+
+// allowedUlimits are the ulimits a deploy may set
+var allowedUlimits = map[string]bool{
+	"nofile":  true,
+	"nproc":   true,
+	"memlock": true,
+	"stack":   true,
+}
+
+func validateUlimits(ulimits []Ulimit) error {
+	seen := map[string]bool{}
+	for _, ulimit := range ulimits {
+		if !allowedUlimits[ulimit.Name] {
+			return newValidationError("Invalid ulimit '%v', expected nofile, nproc, memlock or stack", ulimit.Name)
+		}
+		if seen[ulimit.Name] {
+			return newValidationError("Invalid ulimits, '%v' is set more than once", ulimit.Name)
+		}
+		seen[ulimit.Name] = true
+
+		if ulimit.Soft < 0 || ulimit.Hard < 0 || ulimit.Soft > ulimit.Hard {
+			return newValidationError("Invalid ulimit '%v', expected 0 <= soft (%v) <= hard (%v)", ulimit.Name, ulimit.Soft, ulimit.Hard)
+		}
+	}
+	return nil
+}
+
+// applyUlimits validates the deploy's ulimits. The vendored docker
+// client's ContainerSpec has no Ulimits (they need API 1.41), so
+// a deploy that sets them is refused rather than deployed without
+func applyUlimits(metadata *RequestMetadata) error {
+	if metadata.Ulimits == nil {
+		return nil
+	}
+
+	err := validateUlimits(metadata.Ulimits)
+	if err != nil {
+		return err
+	}
+	return newValidationError("ulimits need docker API 1.41, which this governator's docker client doesn't support")
+}
@@ -0,0 +1,60 @@
+package deployer
+
+import (
+	"github.com/docker/engine-api/types/swarm"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Ulimits", func() {
+	var sut *Deployer
+	var dockerClient *fakeDockerClient
+	var metadata *RequestMetadata
+	var err error
+
+	BeforeEach(func() {
+		dockerClient = &fakeDockerClient{service: swarm.Service{ID: "service-id"}}
+		dockerClient.service.Spec.Name = "my-application"
+		sut = New(dockerClient, nil, "redis-queue:name", deployState.URL, "super")
+		metadata = &RequestMetadata{DockerURL: "octoblu/my-application:v2"}
+	})
+
+	JustBeforeEach(func() {
+		err = sut.deploy("my-application:v2", metadata)
+	})
+
+	Describe("When they are not set", func() {
+		It("Should update the service", func() {
+			Expect(err).To(BeNil())
+			Expect(dockerClient.updates).To(HaveLen(1))
+		})
+	})
+
+	Describe("When they are set", func() {
+		BeforeEach(func() {
+			metadata.Ulimits = []Ulimit{{Name: "nofile", Soft: 65536, Hard: 65536}}
+		})
+
+		It("Should be valid", func() {
+			Expect(validateUlimits(metadata.Ulimits)).To(BeNil())
+		})
+
+		It("Should refuse the deploy, since the docker client can't set them", func() {
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+			Expect(err.Error()).To(ContainSubstring("API 1.41"))
+			Expect(dockerClient.updates).To(BeEmpty())
+		})
+	})
+
+	table.DescribeTable("Should refuse invalid ulimits",
+		func(ulimits []Ulimit, message string) {
+			err := validateUlimits(ulimits)
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+			Expect(err.Error()).To(ContainSubstring(message))
+		},
+		table.Entry("not in the allowlist", []Ulimit{{Name: "core", Soft: 0, Hard: 0}}, "expected nofile"),
+		table.Entry("set twice", []Ulimit{{Name: "nproc", Soft: 1, Hard: 1}, {Name: "nproc", Soft: 2, Hard: 2}}, "more than once"),
+		table.Entry("with soft above hard", []Ulimit{{Name: "nofile", Soft: 2048, Hard: 1024}}, "soft (2048) <= hard (1024)"),
+	)
+})
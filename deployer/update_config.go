@@ -0,0 +1,54 @@
+package deployer
+
+import (
+	"fmt"
+
+	"github.com/docker/engine-api/types/swarm"
+)
+
+// Update failure actions for WithUpdateFailureAction
+// and RequestMetadata.UpdateConfig.FailureAction
+const (
+	FailureActionRollback = "rollback"
+	FailureActionPause    = "pause"
+	FailureActionContinue = "continue"
+)
+
+// UpdateConfig overrides the deployer's
+// defaults for how swarm rolls out the update
+type UpdateConfig struct {
+	FailureAction string `json:"failureAction"`
+}
+
+// ValidateFailureAction returns an error unless
+// action is one of rollback, pause or continue
+func ValidateFailureAction(action string) error {
+	switch action {
+	case FailureActionRollback, FailureActionPause, FailureActionContinue:
+		return nil
+	}
+	return fmt.Errorf("unknown failure action '%v', expected %v, %v or %v", action, FailureActionRollback, FailureActionPause, FailureActionContinue)
+}
+
+// setUpdateFailureAction sets what swarm does when the update fails,
+// from the deploy's updateConfig or else the deployer's default.
+// Without either, the service's own setting is kept
+func (deployer *Deployer) setUpdateFailureAction(spec *swarm.ServiceSpec, metadata *RequestMetadata) error {
+	action := deployer.updateFailureAction
+	if metadata.UpdateConfig != nil && metadata.UpdateConfig.FailureAction != "" {
+		action = metadata.UpdateConfig.FailureAction
+		if ValidateFailureAction(action) != nil {
+			return newValidationError("Invalid updateConfig.failureAction '%v', expected %v, %v or %v", action, FailureActionRollback, FailureActionPause, FailureActionContinue)
+		}
+	}
+
+	if action == "" {
+		return nil
+	}
+
+	if spec.UpdateConfig == nil {
+		spec.UpdateConfig = &swarm.UpdateConfig{}
+	}
+	spec.UpdateConfig.FailureAction = action
+	return nil
+}
@@ -0,0 +1,63 @@
+package deployer
+
+import (
+	"github.com/docker/engine-api/types/swarm"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("update failure action", func() {
+	var sut *Deployer
+	var dockerClient *fakeDockerClient
+	var metadata *RequestMetadata
+	var err error
+
+	BeforeEach(func() {
+		dockerClient = &fakeDockerClient{service: swarm.Service{ID: "service-id"}}
+		dockerClient.service.Spec.UpdateConfig = &swarm.UpdateConfig{Parallelism: 2, FailureAction: FailureActionContinue}
+		sut = New(dockerClient, nil, "redis-queue:name", deployState.URL, "super", WithUpdateFailureAction(FailureActionRollback))
+		metadata = &RequestMetadata{DockerURL: "octoblu/my-application:v2"}
+	})
+
+	JustBeforeEach(func() {
+		err = sut.deploy("my-application:v2", metadata)
+	})
+
+	It("Should send the deployer's failure action with ServiceUpdate", func() {
+		Expect(err).To(BeNil())
+		Expect(dockerClient.updates).To(HaveLen(1))
+		Expect(dockerClient.updates[0].UpdateConfig.FailureAction).To(Equal(FailureActionRollback))
+		Expect(dockerClient.updates[0].UpdateConfig.Parallelism).To(Equal(uint64(2)))
+	})
+
+	Describe("When the deploy sets updateConfig.failureAction", func() {
+		BeforeEach(func() {
+			metadata.UpdateConfig = &UpdateConfig{FailureAction: FailureActionPause}
+		})
+
+		It("Should send the deploy's failure action", func() {
+			Expect(dockerClient.updates[0].UpdateConfig.FailureAction).To(Equal(FailureActionPause))
+		})
+	})
+
+	Describe("When the deploy's failure action is unknown", func() {
+		BeforeEach(func() {
+			metadata.UpdateConfig = &UpdateConfig{FailureAction: "explode"}
+		})
+
+		It("Should return a validation error", func() {
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+			Expect(dockerClient.updates).To(BeEmpty())
+		})
+	})
+
+	Describe("When the deployer has no failure action", func() {
+		BeforeEach(func() {
+			sut = New(dockerClient, nil, "redis-queue:name", deployState.URL, "super")
+		})
+
+		It("Should keep the service's failure action", func() {
+			Expect(dockerClient.updates[0].UpdateConfig.FailureAction).To(Equal(FailureActionContinue))
+		})
+	})
+})
@@ -0,0 +1,70 @@
+package deployer
+
+import (
+	"strings"
+	"time"
+
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/swarm"
+	"golang.org/x/net/context"
+)
+
+// defaultConflictPollInterval is how often the service is inspected
+// while waiting for a conflicting update to finish
+const defaultConflictPollInterval = time.Second
+
+// isUpdateConflict is true when docker refused the update because the
+// service changed, or is being updated, since it was inspected
+func isUpdateConflict(err error) bool {
+	if err == nil {
+		return false
+	}
+	message := err.Error()
+	return strings.Contains(message, "update out of sequence") || strings.Contains(strings.ToLower(message), "conflict")
+}
+
+// retryUpdateConflict retries the update once, with the service's
+// current version, when updateErr is a conflict and WithConflictRetry
+// is used. The conflicting update is given up to the conflict backoff
+// to finish first. Any other updateErr is returned as it is
+func (deployer *Deployer) retryUpdateConflict(ctx context.Context, service *swarm.Service, updateOpts types.ServiceUpdateOptions, updateErr error) error {
+	if deployer.conflictBackoff <= 0 || !isUpdateConflict(updateErr) || ctx.Err() != nil {
+		return updateErr
+	}
+
+	deployer.logger.Println("Update of", service.Spec.Name, "conflicted, waiting for the current update", updateErr)
+	start := time.Now()
+	current, err := deployer.waitForServiceUpdate(ctx, service.ID)
+	if err != nil {
+		return err
+	}
+	deployer.logger.Println("Waited", time.Since(start), "for the current update of", service.Spec.Name, "retrying")
+
+	service.Version = current.Version
+	return deployer.dockerClient.ServiceUpdate(ctx, service.ID, service.Version, service.Spec, updateOpts)
+}
+
+// waitForServiceUpdate inspects the service until its update is no
+// longer in progress, or the conflict backoff runs out, and returns it
+func (deployer *Deployer) waitForServiceUpdate(ctx context.Context, serviceID string) (swarm.Service, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, deployer.conflictBackoff)
+	defer cancel()
+
+	for {
+		current, _, err := deployer.dockerClient.ServiceInspectWithRaw(ctx, serviceID)
+		if err != nil {
+			return current, err
+		}
+
+		if current.UpdateStatus.State != swarm.UpdateStateUpdating {
+			return current, nil
+		}
+
+		if !sleepContext(waitCtx, deployer.conflictPollInterval) {
+			if ctx.Err() != nil {
+				return current, ctx.Err()
+			}
+			return current, nil
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package deployer
+
+import (
+	"time"
+
+	"github.com/docker/engine-api/types/swarm"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ConflictRetry", func() {
+	var sut *Deployer
+	var dockerClient *fakeDockerClient
+	var logger *fakeLogger
+	var metadata *RequestMetadata
+	var err error
+
+	BeforeEach(func() {
+		dockerClient = &fakeDockerClient{service: swarm.Service{ID: "service-id"}, conflictingUpdates: 1}
+		dockerClient.service.Spec.Name = "my-application"
+		logger = &fakeLogger{}
+		metadata = &RequestMetadata{DockerURL: "octoblu/my-application:v2"}
+	})
+
+	JustBeforeEach(func() {
+		err = sut.deploy("my-application:v2", metadata)
+	})
+
+	Describe("When it isn't used", func() {
+		BeforeEach(func() {
+			sut = New(dockerClient, nil, "redis-queue:name", deployState.URL, "super", WithLogger(logger))
+		})
+
+		It("Should return the conflict", func() {
+			Expect(err).To(BeAssignableToTypeOf(&DockerAPIError{}))
+			Expect(err.Error()).To(ContainSubstring("update out of sequence"))
+			Expect(dockerClient.updates).To(BeEmpty())
+		})
+	})
+
+	Describe("When it is used", func() {
+		BeforeEach(func() {
+			sut = New(dockerClient, nil, "redis-queue:name", deployState.URL, "super", WithLogger(logger), WithConflictRetry(time.Second))
+			sut.conflictPollInterval = time.Millisecond
+			dockerClient.updatingInspects = 2
+		})
+
+		It("Should wait for the current update, then retry", func() {
+			Expect(err).To(BeNil())
+			Expect(dockerClient.updatingInspects).To(Equal(0))
+			Expect(dockerClient.updates).To(HaveLen(1))
+			Expect(dockerClient.service.Spec.TaskTemplate.ContainerSpec.Image).To(Equal("octoblu/my-application:v2"))
+			Expect(logger.lines).To(ContainElement(ContainSubstring("Waited")))
+		})
+
+		Describe("When the retry conflicts too", func() {
+			BeforeEach(func() {
+				dockerClient.conflictingUpdates = 2
+			})
+
+			It("Should only retry once", func() {
+				Expect(err).To(BeAssignableToTypeOf(&DockerAPIError{}))
+				Expect(dockerClient.conflictingUpdates).To(Equal(0))
+				Expect(dockerClient.updates).To(BeEmpty())
+			})
+		})
+
+		Describe("When the current update outlasts the backoff", func() {
+			BeforeEach(func() {
+				sut.conflictBackoff = 20 * time.Millisecond
+				sut.conflictPollInterval = 5 * time.Millisecond
+				dockerClient.updatingInspects = 1000
+			})
+
+			It("Should retry anyway", func() {
+				Expect(err).To(BeNil())
+				Expect(dockerClient.updates).To(HaveLen(1))
+			})
+		})
+	})
+})
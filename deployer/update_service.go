@@ -0,0 +1,45 @@
+package deployer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/engine-api/types/swarm"
+	"golang.org/x/net/context"
+)
+
+// UpdateService deploys metadata straight to the named service, without
+// going through the queue. It returns the service's version before and
+// after the deploy. Unless force is set, a service that is already
+// running the image is left alone and both versions are the same
+func (deployer *Deployer) UpdateService(service string, metadata *RequestMetadata, force bool) (swarm.Version, swarm.Version, error) {
+	before, err := deployer.inspectService(service)
+	if err != nil {
+		return swarm.Version{}, swarm.Version{}, err
+	}
+
+	currentImage := strings.SplitN(before.Spec.TaskTemplate.ContainerSpec.Image, "@", 2)[0]
+	if !force && currentImage == deployer.mirrorImage(metadata.DockerURL) {
+		debug("UpdateService: %v is already running %v", service, currentImage)
+		return before.Version, before.Version, nil
+	}
+
+	err = deployer.deployService(fmt.Sprintf("update-service/%v", service), service, metadata)
+	if err != nil {
+		return before.Version, swarm.Version{}, err
+	}
+
+	after, err := deployer.inspectService(service)
+	if err != nil {
+		return before.Version, swarm.Version{}, err
+	}
+	return before.Version, after.Version, nil
+}
+
+func (deployer *Deployer) inspectService(service string) (swarm.Service, error) {
+	result, _, err := deployer.dockerClient.ServiceInspectWithRaw(context.Background(), service)
+	if err != nil {
+		return result, newDockerAPIError(err)
+	}
+	return result, nil
+}
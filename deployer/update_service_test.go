@@ -0,0 +1,66 @@
+package deployer
+
+import (
+	"github.com/docker/engine-api/types/swarm"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("UpdateService", func() {
+	var sut *Deployer
+	var dockerClient *fakeDockerClient
+	var before, after swarm.Version
+	var force bool
+	var image string
+	var err error
+
+	BeforeEach(func() {
+		dockerClient = &fakeDockerClient{service: swarm.Service{ID: "service-id"}}
+		dockerClient.service.Version.Index = 7
+		dockerClient.service.Spec.Name = "hotfix-target"
+		dockerClient.service.Spec.TaskTemplate.ContainerSpec.Image = "octoblu/my-application:v1@sha256:abc"
+
+		sut = New(dockerClient, nil, "", deployState.URL, "super")
+		force = false
+	})
+
+	JustBeforeEach(func() {
+		before, after, err = sut.UpdateService("hotfix-target", &RequestMetadata{DockerURL: image}, force)
+	})
+
+	Describe("When the image is new", func() {
+		BeforeEach(func() {
+			image = "octoblu/my-application:v2"
+		})
+
+		It("Should update the service and return both versions", func() {
+			Expect(err).To(BeNil())
+			Expect(before.Index).To(Equal(uint64(7)))
+			Expect(after.Index).To(Equal(uint64(8)))
+			Expect(dockerClient.service.Spec.TaskTemplate.ContainerSpec.Image).To(Equal("octoblu/my-application:v2"))
+		})
+	})
+
+	Describe("When the service is already running the image", func() {
+		BeforeEach(func() {
+			image = "octoblu/my-application:v1"
+		})
+
+		It("Should leave the service alone", func() {
+			Expect(err).To(BeNil())
+			Expect(after).To(Equal(before))
+			Expect(dockerClient.updates).To(BeEmpty())
+		})
+
+		Describe("and force is set", func() {
+			BeforeEach(func() {
+				force = true
+			})
+
+			It("Should update the service anyway", func() {
+				Expect(err).To(BeNil())
+				Expect(dockerClient.updates).To(HaveLen(1))
+			})
+		})
+	})
+})
@@ -0,0 +1,17 @@
+package deployer
+
+// UserNSHost is the RequestMetadata.UserNS that runs
+// the service's tasks in the host's user namespace
+const UserNSHost = "host"
+
+func validateUserNS(userNS string) error {
+	switch userNS {
+	case "":
+		return nil
+	case UserNSHost:
+		// swarm's ContainerSpec has no user namespace mode, the
+		// daemon's userns-remap setting applies to every task
+		return newValidationError("userNS '%v' isn't supported by swarm services, user namespaces are set by the daemon's userns-remap", userNS)
+	}
+	return newValidationError("Invalid userNS '%v', expected %v or empty", userNS, UserNSHost)
+}
@@ -0,0 +1,84 @@
+package deployer
+
+import (
+	"time"
+
+	"github.com/docker/engine-api/types/swarm"
+	"github.com/octoblu/governator-swarm/monitor"
+	"golang.org/x/net/context"
+)
+
+// defaultTaskPollInterval is how often tasks are listed
+// when the deployer wasn't given an interval
+const defaultTaskPollInterval = 2 * time.Second
+
+// watchDeploy watches the service's tasks for the deploy window after
+// ServiceUpdate. If a task running the new image fails, the service is
+// rolled back to previousSpec and a TaskFailedError is returned. When
+// the tasks can't be listed, the deploy fails with a DockerAPIError, as
+// nothing watched the new tasks
+func (deployer *Deployer) watchDeploy(ctx context.Context, deploy string, service swarm.Service, previousSpec swarm.ServiceSpec, timeout time.Duration) error {
+	return deployer.watchTasks(ctx, deploy, service, previousSpec, timeout, deployer.deployWindow)
+}
+
+// watchHealthyPeriod watches the service's tasks for the min healthy
+// period once the deploy is otherwise done, and fails and rolls back
+// like watchDeploy. The period is not cut short by the deploy timeout
+func (deployer *Deployer) watchHealthyPeriod(deploy string, service swarm.Service, previousSpec swarm.ServiceSpec, timeout time.Duration, metadata *RequestMetadata) error {
+	period := deployer.getMinHealthyPeriod(metadata)
+	if period <= 0 {
+		return nil
+	}
+
+	debug("watchHealthyPeriod: %v for %v", service.Spec.Name, period)
+	return deployer.watchTasks(context.Background(), deploy, service, previousSpec, timeout, period)
+}
+
+func (deployer *Deployer) getMinHealthyPeriod(metadata *RequestMetadata) time.Duration {
+	if metadata.MinHealthyPeriodSeconds > 0 {
+		return time.Duration(metadata.MinHealthyPeriodSeconds) * time.Second
+	}
+	return deployer.minHealthyPeriod
+}
+
+func (deployer *Deployer) watchTasks(ctx context.Context, deploy string, service swarm.Service, previousSpec swarm.ServiceSpec, timeout, window time.Duration) error {
+	if window <= 0 {
+		return nil
+	}
+
+	deployer.events.publish(deploy, EventHealthchecking, service.Spec.Name)
+
+	windowCtx, cancel := context.WithTimeout(ctx, window)
+	defer cancel()
+
+	events := make(chan monitor.TaskEvent)
+	watchErrors := make(chan error, 1)
+	go func() {
+		watchErrors <- deployer.monitor.WatchService(windowCtx, service.ID, events)
+	}()
+
+	image := service.Spec.TaskTemplate.ContainerSpec.Image
+	for {
+		select {
+		case err := <-watchErrors:
+			if err != nil {
+				deployer.logger.Println("Error watching", service.Spec.Name, err)
+				return newDockerAPIError(err)
+			}
+			return nil
+
+		case event := <-events:
+			if event.State == swarm.TaskStateFailed {
+				deployer.logTaskFailure(windowCtx, service.Spec.Name, event)
+			}
+			if event.State != swarm.TaskStateFailed || event.Image != image {
+				debug("watchDeploy: task %v is %v", event.TaskID, event.State)
+				continue
+			}
+
+			cancel()
+			deployer.rollbackService(deploy, service, previousSpec, timeout/2)
+			return &TaskFailedError{Service: service.Spec.Name, TaskID: event.TaskID, Message: event.Error}
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package deployer
+
+import (
+	"errors"
+	"time"
+
+	"github.com/docker/engine-api/types/swarm"
+	"github.com/octoblu/governator-swarm/monitor"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("watchDeploy", func() {
+	var sut *Deployer
+	var dockerClient *fakeDockerClient
+	var err error
+
+	BeforeEach(func() {
+		dockerClient = &fakeDockerClient{
+			service: swarm.Service{ID: "service-id"},
+		}
+		dockerClient.service.Spec.Name = "my-application"
+		dockerClient.service.Spec.TaskTemplate.ContainerSpec.Image = "octoblu/my-application:v1"
+
+		sut = New(dockerClient, nil, "redis-queue:name", deployState.URL, "super", WithDeployWindow(100*time.Millisecond, 5*time.Millisecond))
+	})
+
+	JustBeforeEach(func() {
+		err = sut.deploy("my-application:v2", &RequestMetadata{DockerURL: "octoblu/my-application:v2"})
+	})
+
+	Describe("When the new tasks keep running", func() {
+		BeforeEach(func() {
+			dockerClient.taskLists = [][]swarm.Task{
+				{watchTask("old", "octoblu/my-application:v1", swarm.TaskStateShutdown)},
+				{watchTask("old", "octoblu/my-application:v1", swarm.TaskStateShutdown), watchTask("new", "octoblu/my-application:v2", swarm.TaskStateRunning)},
+			}
+		})
+
+		It("Should leave the new spec in place", func() {
+			Expect(err).To(BeNil())
+			Expect(dockerClient.updates).To(HaveLen(1))
+		})
+	})
+
+	Describe("When a task of the old image fails", func() {
+		BeforeEach(func() {
+			dockerClient.taskLists = [][]swarm.Task{
+				{watchTask("old", "octoblu/my-application:v1", swarm.TaskStateRunning)},
+				{watchTask("old", "octoblu/my-application:v1", swarm.TaskStateFailed)},
+			}
+		})
+
+		It("Should ignore it", func() {
+			Expect(err).To(BeNil())
+			Expect(dockerClient.updates).To(HaveLen(1))
+		})
+	})
+
+	Describe("When a task of the new image fails", func() {
+		BeforeEach(func() {
+			dockerClient.taskLists = [][]swarm.Task{
+				{watchTask("new", "octoblu/my-application:v2", swarm.TaskStateRunning)},
+				{watchTask("new", "octoblu/my-application:v2", swarm.TaskStateFailed)},
+			}
+		})
+
+		It("Should return a TaskFailedError", func() {
+			taskErr, ok := err.(*TaskFailedError)
+			Expect(ok).To(BeTrue())
+			Expect(taskErr.TaskID).To(Equal("new"))
+			Expect(taskErr.Service).To(Equal("my-application"))
+			Expect(taskErr.IsRetryable()).To(BeFalse())
+		})
+
+		It("Should roll the service back", func() {
+			Expect(dockerClient.updates).To(HaveLen(2))
+			Expect(dockerClient.service.Spec.TaskTemplate.ContainerSpec.Image).To(Equal("octoblu/my-application:v1"))
+		})
+	})
+})
+
+func watchTask(id, image string, state swarm.TaskState) swarm.Task {
+	task := swarm.Task{ID: id}
+	task.Spec.ContainerSpec.Image = image
+	task.Status.State = state
+	task.Status.Err = "task: non-zero exit (1)"
+	return task
+}
+
+var _ = Describe("watchHealthyPeriod", func() {
+	var sut *Deployer
+	var dockerClient *fakeDockerClient
+	var metadata *RequestMetadata
+	var err error
+
+	BeforeEach(func() {
+		dockerClient = &fakeDockerClient{
+			service: swarm.Service{ID: "service-id"},
+		}
+		dockerClient.service.Spec.Name = "my-application"
+		dockerClient.service.Spec.TaskTemplate.ContainerSpec.Image = "octoblu/my-application:v1"
+
+		sut = New(dockerClient, nil, "redis-queue:name", deployState.URL, "super", WithMinHealthyPeriod(100*time.Millisecond, 5*time.Millisecond))
+		metadata = &RequestMetadata{DockerURL: "octoblu/my-application:v2"}
+	})
+
+	JustBeforeEach(func() {
+		err = sut.deploy("my-application:v2", metadata)
+	})
+
+	Describe("When the new tasks run for the whole period", func() {
+		BeforeEach(func() {
+			dockerClient.taskLists = [][]swarm.Task{
+				{watchTask("new", "octoblu/my-application:v2", swarm.TaskStateRunning)},
+			}
+		})
+
+		It("Should pass", func() {
+			Expect(err).To(BeNil())
+			Expect(dockerClient.updates).To(HaveLen(1))
+		})
+	})
+
+	Describe("When a new task fails during the period", func() {
+		BeforeEach(func() {
+			dockerClient.taskLists = [][]swarm.Task{
+				{watchTask("new", "octoblu/my-application:v2", swarm.TaskStateRunning)},
+				{watchTask("new", "octoblu/my-application:v2", swarm.TaskStateFailed)},
+			}
+		})
+
+		It("Should fail and roll back", func() {
+			_, ok := err.(*TaskFailedError)
+			Expect(ok).To(BeTrue())
+			Expect(dockerClient.service.Spec.TaskTemplate.ContainerSpec.Image).To(Equal("octoblu/my-application:v1"))
+		})
+	})
+
+	Describe("When the deploy sets its own period", func() {
+		BeforeEach(func() {
+			sut = New(dockerClient, nil, "redis-queue:name", deployState.URL, "super")
+			sut.monitor = monitor.New(dockerClient, 5*time.Millisecond)
+			metadata.MinHealthyPeriodSeconds = 1
+			dockerClient.taskLists = [][]swarm.Task{
+				{watchTask("new", "octoblu/my-application:v2", swarm.TaskStateRunning)},
+				{watchTask("new", "octoblu/my-application:v2", swarm.TaskStateFailed)},
+			}
+		})
+
+		It("Should watch for it even without --min-healthy-period", func() {
+			_, ok := err.(*TaskFailedError)
+			Expect(ok).To(BeTrue())
+		})
+	})
+
+	Describe("When the tasks can't be listed", func() {
+		BeforeEach(func() {
+			dockerClient.taskListErr = errors.New("Cannot connect to the Docker daemon. Is the docker daemon running on this host?")
+		})
+
+		It("Should return a DockerAPIError", func() {
+			Expect(err).To(BeAssignableToTypeOf(&DockerAPIError{}))
+		})
+
+		It("Should not mark the deploy as passed", func() {
+			Expect(deployState.requests()).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,86 @@
+package deployer
+
+import "time"
+
+// ScheduledWindow is a daily time range, in UTC, during which
+// a deploy is allowed to go out. Start and End are HH:MM, a
+// window where End is before Start wraps past midnight
+type ScheduledWindow struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// IsSet returns true when the window restricts deploys
+func (window ScheduledWindow) IsSet() bool {
+	return window.Start != "" || window.End != ""
+}
+
+// Validate returns an error when Start or End isn't HH:MM
+func (window ScheduledWindow) Validate() error {
+	_, _, err := window.bounds()
+	return err
+}
+
+// Contains returns true when t falls within an occurrence of the window
+func (window ScheduledWindow) Contains(t time.Time) bool {
+	_, _, ok := window.occurrence(t)
+	return ok
+}
+
+// NextStart returns the start of the next occurrence of the window after t
+func (window ScheduledWindow) NextStart(t time.Time) time.Time {
+	start, _, _ := window.occurrence(t)
+	return start
+}
+
+// occurrence returns the occurrence of the window that contains t,
+// or the next one to start after t when ok is false
+func (window ScheduledWindow) occurrence(t time.Time) (time.Time, time.Time, bool) {
+	startOffset, length, err := window.bounds()
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	for day := -1; day <= 1; day++ {
+		start := midnight.AddDate(0, 0, day).Add(startOffset)
+		end := start.Add(length)
+		if !t.Before(start) && t.Before(end) {
+			return start, end, true
+		}
+		if t.Before(start) {
+			return start, end, false
+		}
+	}
+
+	start := midnight.AddDate(0, 0, 2).Add(startOffset)
+	return start, start.Add(length), false
+}
+
+func (window ScheduledWindow) bounds() (time.Duration, time.Duration, error) {
+	start, err := parseTimeOfDay(window.Start)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	end, err := parseTimeOfDay(window.End)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	length := end - start
+	if length <= 0 {
+		length += 24 * time.Hour
+	}
+	return start, length, nil
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, newValidationError("Invalid scheduled window time '%v', expected HH:MM", value)
+	}
+	return time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute, nil
+}
@@ -0,0 +1,63 @@
+package deployer_test
+
+import (
+	"time"
+
+	"github.com/octoblu/governator-swarm/deployer"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ScheduledWindow", func() {
+	at := func(value string) time.Time {
+		t, err := time.Parse(time.RFC3339, value)
+		Expect(err).To(BeNil())
+		return t
+	}
+
+	Describe("When the window is within a day", func() {
+		window := deployer.ScheduledWindow{Start: "09:00", End: "17:00"}
+
+		It("Should contain times inside the window", func() {
+			Expect(window.Contains(at("2016-10-15T09:00:00Z"))).To(BeTrue())
+			Expect(window.Contains(at("2016-10-15T16:59:59Z"))).To(BeTrue())
+		})
+
+		It("Should not contain times outside the window", func() {
+			Expect(window.Contains(at("2016-10-15T08:59:59Z"))).To(BeFalse())
+			Expect(window.Contains(at("2016-10-15T17:00:00Z"))).To(BeFalse())
+		})
+
+		It("Should start next later the same day when before the window", func() {
+			Expect(window.NextStart(at("2016-10-15T03:00:00Z"))).To(Equal(at("2016-10-15T09:00:00Z")))
+		})
+
+		It("Should start next the following day when after the window", func() {
+			Expect(window.NextStart(at("2016-10-15T18:00:00Z"))).To(Equal(at("2016-10-16T09:00:00Z")))
+		})
+	})
+
+	Describe("When the window wraps past midnight", func() {
+		window := deployer.ScheduledWindow{Start: "22:00", End: "02:00"}
+
+		It("Should contain times on both sides of midnight", func() {
+			Expect(window.Contains(at("2016-10-15T23:00:00Z"))).To(BeTrue())
+			Expect(window.Contains(at("2016-10-16T01:00:00Z"))).To(BeTrue())
+		})
+
+		It("Should not contain times during the day", func() {
+			Expect(window.Contains(at("2016-10-15T12:00:00Z"))).To(BeFalse())
+		})
+
+		It("Should start next the same evening", func() {
+			Expect(window.NextStart(at("2016-10-15T12:00:00Z"))).To(Equal(at("2016-10-15T22:00:00Z")))
+		})
+	})
+
+	Describe("When the window is malformed", func() {
+		It("Should fail validation", func() {
+			window := deployer.ScheduledWindow{Start: "9am", End: "17:00"}
+			Expect(window.Validate()).To(MatchError("Invalid scheduled window time '9am', expected HH:MM"))
+		})
+	})
+})
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/codegangsta/cli"
+	"github.com/octoblu/governator-swarm/deployer"
+)
+
+func diffCommand() cli.Command {
+	return cli.Command{
+		Name:   "diff",
+		Usage:  "Show what deploying an image would change in a service's spec. Exits 0 without changes, 1 with changes and 2 on error",
+		Action: diffService,
+		Flags: append(outputFlags(),
+			cli.StringFlag{
+				Name:  "service",
+				Usage: "Name of the swarm service to compare against",
+			},
+			cli.StringFlag{
+				Name:  "image",
+				Usage: "Docker image that would be deployed, e.g. octoblu/my-application:v2",
+			},
+		),
+	}
+}
+
+func diffService(context *cli.Context) error {
+	service := context.String("service")
+	image := context.String("image")
+	if service == "" || image == "" {
+		return cli.NewExitError("diff requires --service and --image", 2)
+	}
+
+	dockerURI := context.GlobalString("docker-uri")
+	if dockerURI == "" {
+		return cli.NewExitError("diff requires --docker-uri", 2)
+	}
+
+	output, err := getOutputFormat(context)
+	if err != nil {
+		return err
+	}
+
+	dockerClient := getDockerClient(dockerURI)
+	options := getDeployerOptions(context.Parent())
+	theDeployer := deployer.New(dockerClient, nil, "", context.GlobalString("deploy-state-uri"), context.GlobalString("cluster"), options...)
+
+	err = theDeployer.RefreshConfig()
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("Error loading config: %v", err), 2)
+	}
+
+	changes, err := theDeployer.DiffService(service, &deployer.RequestMetadata{DockerURL: image})
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("Error diffing %v: %v", service, err), 2)
+	}
+
+	if output == "json" {
+		if changes == nil {
+			changes = []deployer.SpecChange{}
+		}
+		err = printJSON(changes)
+		if err != nil {
+			return cli.NewExitError(fmt.Sprintf("Error printing the diff: %v", err), 2)
+		}
+	} else {
+		if len(changes) == 0 {
+			fmt.Printf("%v would not change\n", service)
+			return nil
+		}
+
+		writer := newTableWriter(context, os.Stdout, "FIELD", "BEFORE", "AFTER")
+		for _, change := range changes {
+			fmt.Fprintf(writer, "%s\t%s\t%s\n", change.Field, change.Before, change.After)
+		}
+		writer.Flush()
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+	return cli.NewExitError("", 1)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/docker/engine-api/types"
+	"golang.org/x/net/context"
+)
+
+// serviceStatus is a row of list-services
+type serviceStatus struct {
+	Service          string     `json:"service"`
+	Image            string     `json:"image"`
+	LastDeployTime   *time.Time `json:"lastDeployTime"`
+	LastDeployResult string     `json:"lastDeployResult"`
+	Cluster          string     `json:"cluster"`
+}
+
+// deployment is the part of a deploy-state deployment list-services reads
+type deployment struct {
+	Cluster map[string]struct {
+		Passing   bool      `json:"passing"`
+		CreatedAt time.Time `json:"createdAt"`
+	} `json:"cluster"`
+}
+
+func listServicesCommand() cli.Command {
+	return cli.Command{
+		Name:   "list-services",
+		Usage:  "List the swarm services with their last deploy from deploy-state",
+		Action: listServices,
+		Flags:  outputFlags(),
+	}
+}
+
+func listServices(context *cli.Context) error {
+	dockerURI := context.GlobalString("docker-uri")
+	deployStateURI := context.GlobalString("deploy-state-uri")
+	cluster := context.GlobalString("cluster")
+
+	if dockerURI == "" || deployStateURI == "" || cluster == "" {
+		return cli.NewExitError("list-services requires --docker-uri, --deploy-state-uri and --cluster", 1)
+	}
+	output, err := getOutputFormat(context)
+	if err != nil {
+		return err
+	}
+
+	statuses, err := getServiceStatuses(dockerURI, deployStateURI, cluster)
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("Error listing services: %v", err), 1)
+	}
+
+	if output == "json" {
+		return printJSON(statuses)
+	}
+
+	writer := newTableWriter(context, os.Stdout, "SERVICE", "IMAGE", "LAST DEPLOY", "RESULT", "CLUSTER")
+	for _, status := range statuses {
+		lastDeployTime := "-"
+		if status.LastDeployTime != nil {
+			lastDeployTime = status.LastDeployTime.Format(time.RFC3339)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", status.Service, status.Image, lastDeployTime, status.LastDeployResult, status.Cluster)
+	}
+	return writer.Flush()
+}
+
+func getServiceStatuses(dockerURI, deployStateURI, cluster string) ([]serviceStatus, error) {
+	dockerClient := getDockerClient(dockerURI)
+	services, err := dockerClient.ServiceList(context.Background(), types.ServiceListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	statuses := make([]serviceStatus, len(services))
+	for i, service := range services {
+		statuses[i] = serviceStatus{
+			Service:          service.Spec.Name,
+			Image:            service.Spec.TaskTemplate.ContainerSpec.Image,
+			LastDeployResult: "unknown",
+			Cluster:          cluster,
+		}
+		getLastDeploy(httpClient, deployStateURI, cluster, &statuses[i])
+	}
+	sort.Sort(byService(statuses))
+	return statuses, nil
+}
+
+// getLastDeploy fills in the last deploy of the status' image from deploy-state.
+// The result is left as unknown when deploy-state can't be asked
+func getLastDeploy(httpClient *http.Client, deployStateURI, cluster string, status *serviceStatus) {
+	owner, repo, tag := splitImage(status.Image)
+	if owner == "" || tag == "" {
+		return
+	}
+
+	fullURL := fmt.Sprintf("%s/deployments/%s/%s/%s", deployStateURI, owner, repo, tag)
+	response, err := httpClient.Get(fullURL)
+	if err != nil {
+		debug("error getting %v: %v", fullURL, err)
+		return
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		status.LastDeployResult = "none"
+		return
+	}
+	if response.StatusCode != http.StatusOK {
+		debug("invalid response from %v: %v", fullURL, response.StatusCode)
+		return
+	}
+
+	var result deployment
+	err = json.NewDecoder(response.Body).Decode(&result)
+	if err != nil {
+		debug("invalid deployment from %v: %v", fullURL, err)
+		return
+	}
+
+	clusterState, ok := result.Cluster[cluster]
+	if !ok {
+		status.LastDeployResult = "none"
+		return
+	}
+
+	status.LastDeployResult = "failed"
+	if clusterState.Passing {
+		status.LastDeployResult = "passed"
+	}
+	if !clusterState.CreatedAt.IsZero() {
+		status.LastDeployTime = &clusterState.CreatedAt
+	}
+}
+
+// splitImage returns the owner, repo and tag of a service image,
+// ignoring the registry and any digest swarm pinned the image to
+func splitImage(image string) (string, string, string) {
+	image = strings.SplitN(image, "@", 2)[0]
+
+	tag := ""
+	slash := strings.LastIndex(image, "/")
+	colon := strings.LastIndex(image, ":")
+	if colon > slash {
+		tag = image[colon+1:]
+		image = image[:colon]
+	}
+
+	parts := strings.Split(image, "/")
+	if len(parts) < 2 {
+		return "", "", ""
+	}
+	return parts[len(parts)-2], parts[len(parts)-1], tag
+}
+
+type byService []serviceStatus
+
+func (statuses byService) Len() int           { return len(statuses) }
+func (statuses byService) Swap(i, j int)      { statuses[i], statuses[j] = statuses[j], statuses[i] }
+func (statuses byService) Less(i, j int) bool { return statuses[i].Service < statuses[j].Service }
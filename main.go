@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
@@ -15,8 +16,13 @@ import (
 	"github.com/docker/engine-api/client"
 	"github.com/fatih/color"
 	"github.com/garyburd/redigo/redis"
+	"github.com/octoblu/governator-swarm/api"
 	"github.com/octoblu/governator-swarm/deployer"
+	"github.com/octoblu/governator-swarm/metrics"
+	"github.com/octoblu/governator-swarm/monitor"
+	"github.com/octoblu/governator-swarm/notifier"
 	De "github.com/tj/go-debug"
+	"golang.org/x/net/context"
 )
 
 var debug = De.Debug("governator-swarm:main")
@@ -26,6 +32,16 @@ func main() {
 	app.Name = "governator-swarm"
 	app.Version = version()
 	app.Action = run
+	app.Commands = []cli.Command{
+		listServicesCommand(),
+		updateServiceCommand(),
+		statusCommand(),
+		diffCommand(),
+		migrateQueueCommand(),
+		statsCommand(),
+		pauseCommand(),
+		resumeCommand(),
+	}
 	app.Flags = []cli.Flag{
 		cli.StringFlag{
 			Name:   "docker-uri, d",
@@ -48,11 +64,525 @@ func main() {
 			EnvVar: "DEPLOY_STATE_URI",
 			Usage:  "Deploy state uri, it should include authentication.",
 		},
+		cli.StringFlag{
+			Name:   "deploy-state-discovery",
+			EnvVar: "GOVERNATOR_DEPLOY_STATE_DISCOVERY",
+			Usage:  "How to find deploy-state: static uses --deploy-state-uri, srv looks up --deploy-state-srv-name",
+			Value:  "static",
+		},
+		cli.StringFlag{
+			Name:   "deploy-state-srv-name",
+			EnvVar: "GOVERNATOR_DEPLOY_STATE_SRV_NAME",
+			Usage:  "DNS SRV record to find deploy-state with, when --deploy-state-discovery is srv",
+			Value:  deployer.DefaultDeployStateSRVName,
+		},
+		cli.DurationFlag{
+			Name:   "deploy-state-srv-ttl",
+			EnvVar: "GOVERNATOR_DEPLOY_STATE_SRV_TTL",
+			Usage:  "How long to use the deploy-state address from --deploy-state-srv-name before looking it up again",
+			Value:  deployer.DefaultDeployStateSRVTTL,
+		},
 		cli.StringFlag{
 			Name:   "cluster",
 			EnvVar: "CLUSTER",
 			Usage:  "The current running cluster",
 		},
+		cli.StringFlag{
+			Name:   "manifest-dir",
+			EnvVar: "GOVERNATOR_MANIFEST_DIR",
+			Usage:  "Directory of <deploy>.toml manifests, used instead of the redis metadata when present",
+		},
+		cli.BoolFlag{
+			Name:   "once",
+			EnvVar: "GOVERNATOR_ONCE",
+			Usage:  "Process at most one pending deploy, then exit",
+		},
+		cli.StringFlag{
+			Name:   "trigger",
+			EnvVar: "GOVERNATOR_TRIGGER",
+			Usage:  "How new deploys are noticed, poll the queue every --poll-interval, or keyspace to run as soon as redis notifies of a zadd to the queue",
+			Value:  "poll",
+		},
+		cli.DurationFlag{
+			Name:   "keyspace-fallback-interval",
+			EnvVar: "GOVERNATOR_KEYSPACE_FALLBACK_INTERVAL",
+			Usage:  "With --trigger keyspace, how long to wait for a notification before checking the queue anyway",
+			Value:  deployer.DefaultKeyspaceFallbackInterval,
+		},
+		cli.DurationFlag{
+			Name:   "docker-api-call-timeout",
+			EnvVar: "GOVERNATOR_DOCKER_API_CALL_TIMEOUT",
+			Usage:  "How long a single docker API call may take, apart from the deploy's own timeout, 0 for no limit",
+			Value:  deployer.DefaultDockerAPICallTimeout,
+		},
+		cli.DurationFlag{
+			Name:   "poll-interval",
+			EnvVar: "GOVERNATOR_POLL_INTERVAL",
+			Usage:  "How long to wait between checks of the queue, for deploys that don't set pollInterval",
+			Value:  deployer.DefaultPollInterval,
+		},
+		cli.IntFlag{
+			Name:   "queue-scan-limit",
+			EnvVar: "GOVERNATOR_QUEUE_SCAN_LIMIT",
+			Usage:  "How many due deploys to read from the queue per check, see the README",
+			Value:  deployer.DefaultQueueScanLimit,
+		},
+		cli.DurationFlag{
+			Name:   "max-image-age",
+			EnvVar: "GOVERNATOR_MAX_IMAGE_AGE",
+			Usage:  "Refuse to deploy images built longer ago than this, e.g. 168h, 0 to allow any age",
+		},
+		cli.BoolFlag{
+			Name:   "max-image-age-skip-digest-images",
+			EnvVar: "GOVERNATOR_MAX_IMAGE_AGE_SKIP_DIGEST_IMAGES",
+			Usage:  "Don't check the age of digest pinned images",
+		},
+		cli.BoolFlag{
+			Name:   "require-semver-tag",
+			EnvVar: "GOVERNATOR_REQUIRE_SEMVER_TAG",
+			Usage:  "Refuse to deploy images whose tag isn't semver, e.g. main-abc123",
+		},
+		cli.StringFlag{
+			Name:   "semver-prefix",
+			EnvVar: "GOVERNATOR_SEMVER_PREFIX",
+			Usage:  "Prefix to strip from tags before checking --require-semver-tag, e.g. v for v1.2.3",
+		},
+		cli.BoolFlag{
+			Name:   "enforce-readonly-rootfs",
+			EnvVar: "GOVERNATOR_ENFORCE_READONLY_ROOTFS",
+			Usage:  "Refuse deploys that set readonlyRootfs to false",
+		},
+		cli.BoolFlag{
+			Name:   "verify-digest",
+			EnvVar: "GOVERNATOR_VERIFY_DIGEST",
+			Usage:  "Warn when a deploy's imageDigest isn't what its tag points to in the registry",
+		},
+		cli.StringFlag{
+			Name:   "teams-webhook-url",
+			EnvVar: "GOVERNATOR_TEAMS_WEBHOOK_URL",
+			Usage:  "Microsoft Teams incoming webhook to post finished deploys to",
+		},
+		cli.BoolFlag{
+			Name:   "teams-on-failure-only",
+			EnvVar: "GOVERNATOR_TEAMS_ON_FAILURE_ONLY",
+			Usage:  "Only post failed deploys to --teams-webhook-url",
+		},
+		cli.StringFlag{
+			Name:   "deploy-state-retry-queue",
+			EnvVar: "GOVERNATOR_DEPLOY_STATE_RETRY_QUEUE",
+			Usage:  "Redis list to queue failed deploy-state notifications on, retried in the background",
+		},
+		cli.StringFlag{
+			Name:   "pagerduty-routing-key",
+			EnvVar: "GOVERNATOR_PAGERDUTY_ROUTING_KEY",
+			Usage:  "PagerDuty Events v2 routing key, to page for failed deploys that set pagerDutyService",
+		},
+		cli.StringFlag{
+			Name:   "notify-channel-map",
+			EnvVar: "GOVERNATOR_NOTIFY_CHANNEL_MAP",
+			Usage:  "YAML file mapping the channel names deploys list in notifyChannels to Teams webhook URLs",
+		},
+		cli.StringFlag{
+			Name:   "stagger-delay",
+			EnvVar: "GOVERNATOR_STAGGER_DELAY",
+			Usage:  "Random delay, as min:max, to wait after each successful deploy, e.g. 0s:5s",
+		},
+		cli.Int64Flag{
+			Name:   "stagger-delay-seed",
+			EnvVar: "GOVERNATOR_STAGGER_DELAY_SEED",
+			Usage:  "Seed for the --stagger-delay jitter, random when 0",
+		},
+		cli.DurationFlag{
+			Name:   "docker-api-latency-threshold",
+			EnvVar: "GOVERNATOR_DOCKER_API_LATENCY_THRESHOLD",
+			Usage:  "Stop taking deploys off the queue while the P99 latency of the docker API over the last minute exceeds this, 0 to disable",
+			Value:  deployer.DefaultDockerAPILatencyThreshold,
+		},
+		cli.DurationFlag{
+			Name:   "backpressure-sleep",
+			EnvVar: "GOVERNATOR_BACKPRESSURE_SLEEP",
+			Usage:  "How long to wait between checks of the queue while --docker-api-latency-threshold is exceeded",
+			Value:  deployer.DefaultBackpressureSleep,
+		},
+		cli.DurationFlag{
+			Name:   "gc-interval",
+			EnvVar: "GOVERNATOR_GC_INTERVAL",
+			Usage:  "How often to delete old deploy hashes from redis, 0 to disable",
+			Value:  1 * time.Hour,
+		},
+		cli.DurationFlag{
+			Name:   "gc-max-age",
+			EnvVar: "GOVERNATOR_GC_MAX_AGE",
+			Usage:  "How long to keep deploy hashes after the deploy was taken off the queue",
+			Value:  7 * 24 * time.Hour,
+		},
+		cli.BoolFlag{
+			Name:   "enable-service-watcher",
+			EnvVar: "GOVERNATOR_ENABLE_SERVICE_WATCHER",
+			Usage:  "Record task restarts, replica deficits and stuck updates of every service matching --docker-label-filter, every 30s",
+		},
+		cli.BoolFlag{
+			Name:   "cleanup-orphan-metadata",
+			EnvVar: "GOVERNATOR_CLEANUP_ORPHAN_METADATA",
+			Usage:  "At startup, delete deploy hashes that are neither queued nor dead lettered and are older than --orphan-max-age",
+		},
+		cli.DurationFlag{
+			Name:   "orphan-max-age",
+			EnvVar: "GOVERNATOR_ORPHAN_MAX_AGE",
+			Usage:  "How old an orphaned deploy hash must be for --cleanup-orphan-metadata to delete it",
+			Value:  7 * 24 * time.Hour,
+		},
+		cli.BoolFlag{
+			Name:   "redis-compress",
+			EnvVar: "GOVERNATOR_REDIS_COMPRESS",
+			Usage:  "Compress the deploy metadata governator writes to redis. Compressed metadata is always read, so this can be turned on and off freely",
+		},
+		cli.StringFlag{
+			Name:   "redis-compress-algorithm",
+			EnvVar: "GOVERNATOR_REDIS_COMPRESS_ALGORITHM",
+			Usage:  "How --redis-compress compresses the deploy metadata: gzip or zstd",
+			Value:  deployer.CompressionGzip,
+		},
+		cli.BoolFlag{
+			Name:   "trace-redis",
+			EnvVar: "GOVERNATOR_TRACE_REDIS",
+			Usage:  "Log every redis command and reply, shown with DEBUG=governator-swarm:redis",
+		},
+		cli.StringFlag{
+			Name:   "api-addr",
+			EnvVar: "GOVERNATOR_API_ADDR",
+			Usage:  "Address to serve the HTTP API (/metrics, /status, /healthz/live, /healthz/ready, /deploys/{deploy}/events, PUT /deploys/plan, POST /deploys/bulk-cancel, POST /control/pause, POST /control/resume) on, e.g. :8080. The API has no authentication, anyone who can reach it can cancel deploys and pause the deployer, so only serve it where the callers are trusted. Disabled when empty",
+		},
+		cli.StringFlag{
+			Name:   "metrics-backend",
+			EnvVar: "GOVERNATOR_METRICS_BACKEND",
+			Usage:  "Where to record metrics, prometheus to serve them on --api-addr's /metrics or statsd to send them to --statsd-addr",
+			Value:  "prometheus",
+		},
+		cli.StringFlag{
+			Name:   "statsd-addr",
+			EnvVar: "GOVERNATOR_STATSD_ADDR",
+			Usage:  "Address of the statsd server, with --metrics-backend statsd",
+			Value:  "127.0.0.1:8125",
+		},
+		cli.BoolFlag{
+			Name:   "enable-pprof",
+			EnvVar: "GOVERNATOR_ENABLE_PPROF",
+			Usage:  "Serve Go runtime profiles on --pprof-addr. Don't enable in production unless diagnosing",
+		},
+		cli.StringFlag{
+			Name:   "pprof-addr",
+			EnvVar: "GOVERNATOR_PPROF_ADDR",
+			Usage:  "Address to serve /debug/pprof/ on when --enable-pprof is set, must not share a port with --api-addr",
+			Value:  ":6060",
+		},
+		cli.StringSliceFlag{
+			Name:   "docker-label-filter",
+			EnvVar: "GOVERNATOR_DOCKER_LABEL_FILTER",
+			Usage:  "Only update services with this key=value label, may be repeated",
+		},
+		cli.StringSliceFlag{
+			Name:   "node-label-selector",
+			EnvVar: "GOVERNATOR_NODE_LABEL_SELECTOR",
+			Usage:  "Only place deployed services on nodes with this key=value label, may be repeated",
+		},
+		cli.BoolFlag{
+			Name:   "enable-service-health-routing",
+			EnvVar: "GOVERNATOR_ENABLE_SERVICE_HEALTH_ROUTING",
+			Usage:  "Label services with --health-routing-label while they are deployed, so the load balancer drains them",
+		},
+		cli.StringFlag{
+			Name:   "health-routing-label",
+			EnvVar: "GOVERNATOR_HEALTH_ROUTING_LABEL",
+			Usage:  "key=value label that tells the load balancer to stop sending a service traffic, e.g. traefik.enable=false",
+			Value:  "health=draining",
+		},
+		cli.DurationFlag{
+			Name:   "drain-wait",
+			EnvVar: "GOVERNATOR_DRAIN_WAIT",
+			Usage:  "How long to wait after labelling a service as draining before updating it",
+			Value:  deployer.DefaultDrainWait,
+		},
+		cli.DurationFlag{
+			Name:   "default-stop-grace-period",
+			EnvVar: "GOVERNATOR_DEFAULT_STOP_GRACE_PERIOD",
+			Usage:  "Stop grace period for deploys without a stopGracePeriod, 0 to leave the service's setting alone",
+			Value:  10 * time.Second,
+		},
+		cli.StringFlag{
+			Name:   "registry-mirror",
+			EnvVar: "GOVERNATOR_REGISTRY_MIRROR",
+			Usage:  "Registry to pull deployed images from, e.g. registry.internal.example.com",
+		},
+		cli.StringFlag{
+			Name:   "registry-mirror-prefix-strip",
+			EnvVar: "GOVERNATOR_REGISTRY_MIRROR_PREFIX_STRIP",
+			Usage:  "Image prefix replaced by --registry-mirror, e.g. quay.io/team/. Defaults to the registry hostname",
+		},
+		cli.DurationFlag{
+			Name:   "deploy-timeout",
+			EnvVar: "GOVERNATOR_DEPLOY_TIMEOUT",
+			Usage:  "How long a deploy may take before it is rolled back, for deploys without a timeoutSeconds. 0 for no limit",
+			Value:  5 * time.Minute,
+		},
+		cli.StringFlag{
+			Name:   "docker-swarm-token",
+			EnvVar: "GOVERNATOR_DOCKER_SWARM_TOKEN",
+			Usage:  "Manager join token, used to rejoin the swarm when the node has left it. Requires --docker-swarm-manager",
+		},
+		cli.StringFlag{
+			Name:   "docker-swarm-manager",
+			EnvVar: "GOVERNATOR_DOCKER_SWARM_MANAGER",
+			Usage:  "Address of a swarm manager to rejoin through, e.g. 10.0.0.1:2377",
+		},
+		cli.StringFlag{
+			Name:   "redis-username",
+			EnvVar: "GOVERNATOR_REDIS_USERNAME",
+			Usage:  "Redis 6 ACL user, requires --redis-password",
+		},
+		cli.StringFlag{
+			Name:   "redis-password",
+			EnvVar: "GOVERNATOR_REDIS_PASSWORD",
+			Usage:  "Password sent with AUTH after connecting to redis",
+		},
+		cli.BoolFlag{
+			Name:   "redis-tls",
+			EnvVar: "GOVERNATOR_REDIS_TLS",
+			Usage:  "Connect to redis over TLS",
+		},
+		cli.StringFlag{
+			Name:   "redis-tls-ca",
+			EnvVar: "GOVERNATOR_REDIS_TLS_CA",
+			Usage:  "PEM file of CA certificates to verify redis with, defaults to the system roots",
+		},
+		cli.StringFlag{
+			Name:   "redis-tls-cert",
+			EnvVar: "GOVERNATOR_REDIS_TLS_CERT",
+			Usage:  "PEM client certificate for redis, requires --redis-tls-key",
+		},
+		cli.StringFlag{
+			Name:   "redis-tls-key",
+			EnvVar: "GOVERNATOR_REDIS_TLS_KEY",
+			Usage:  "PEM client key for redis, requires --redis-tls-cert",
+		},
+		cli.StringFlag{
+			Name:   "redis-reconnect-backoff",
+			EnvVar: "GOVERNATOR_REDIS_RECONNECT_BACKOFF",
+			Usage:  "Retry failed redis dials with exponential backoff, as initial:max:factor, e.g. 100ms:30s:2.0",
+		},
+		cli.IntFlag{
+			Name:   "redis-db",
+			EnvVar: "GOVERNATOR_REDIS_DB",
+			Usage:  "Redis database to SELECT, overrides the database in --redis-uri when greater than 0",
+		},
+		cli.StringFlag{
+			Name:   "redis-key-prefix",
+			EnvVar: "GOVERNATOR_REDIS_KEY_PREFIX",
+			Usage:  "Prefix for every redis key, for deployers sharing a redis with other tenants",
+		},
+		cli.StringFlag{
+			Name:   "update-failure-action",
+			EnvVar: "GOVERNATOR_UPDATE_FAILURE_ACTION",
+			Usage:  "What swarm does when an update fails: rollback, pause or continue. Deploys may override it with updateConfig.failureAction",
+			Value:  deployer.FailureActionRollback,
+		},
+		cli.BoolFlag{
+			Name:   "allow-service-recreation",
+			EnvVar: "GOVERNATOR_ALLOW_SERVICE_RECREATION",
+			Usage:  "Remove and recreate a service when docker refuses to change its serviceMode in place. Its tasks are all stopped first",
+		},
+		cli.BoolFlag{
+			Name:   "service-recreate-on-conflict",
+			EnvVar: "GOVERNATOR_SERVICE_RECREATE_ON_CONFLICT",
+			Usage:  "When docker refuses a service update because another update is in progress, wait up to --conflict-backoff for it to finish, then retry the update once. Use with care, the retry overwrites the other update's spec",
+		},
+		cli.DurationFlag{
+			Name:   "conflict-backoff",
+			EnvVar: "GOVERNATOR_CONFLICT_BACKOFF",
+			Usage:  "How long --service-recreate-on-conflict waits for the conflicting update to finish",
+			Value:  10 * time.Second,
+		},
+		cli.StringSliceFlag{
+			Name:   "allowed-registries",
+			EnvVar: "GOVERNATOR_ALLOWED_REGISTRIES",
+			Usage:  "Registries images may be deployed from, docker hub is docker.io. All when not set",
+		},
+		cli.StringFlag{
+			Name:   "audit-log",
+			EnvVar: "GOVERNATOR_AUDIT_LOG",
+			Usage:  "File to append a JSON line to for every deploy attempt. Disabled when empty",
+		},
+		cli.IntFlag{
+			Name:   "audit-log-max-size-mb",
+			EnvVar: "GOVERNATOR_AUDIT_LOG_MAX_SIZE_MB",
+			Usage:  "Rotate the audit log when it reaches this size, 0 to never rotate",
+			Value:  100,
+		},
+		cli.StringSliceFlag{
+			Name:   "etcd-endpoints",
+			EnvVar: "GOVERNATOR_ETCD_ENDPOINTS",
+			Usage:  "etcd endpoints to write deploys with an etcdDir to, e.g. http://etcd:2379, may be repeated",
+		},
+		cli.DurationFlag{
+			Name:   "deploy-state-timeout",
+			EnvVar: "GOVERNATOR_DEPLOY_STATE_TIMEOUT",
+			Usage:  "How long a request to the deploy-state service may take, 0 for no limit",
+			Value:  deployer.DefaultDeployStateTimeout,
+		},
+		cli.BoolTFlag{
+			Name:   "deploy-state-http2",
+			EnvVar: "GOVERNATOR_DEPLOY_STATE_HTTP2",
+			Usage:  "Use HTTP/2 with https:// deploy-state services that support it, --deploy-state-http2=false to always use HTTP/1.1",
+		},
+		cli.StringFlag{
+			Name:   "http-proxy",
+			EnvVar: "GOVERNATOR_HTTP_PROXY,HTTP_PROXY,http_proxy",
+			Usage:  "Proxy for http:// deploy-state requests",
+		},
+		cli.StringFlag{
+			Name:   "https-proxy",
+			EnvVar: "GOVERNATOR_HTTPS_PROXY,HTTPS_PROXY,https_proxy",
+			Usage:  "Proxy for https:// deploy-state requests",
+		},
+		cli.StringFlag{
+			Name:   "no-proxy",
+			EnvVar: "GOVERNATOR_NO_PROXY,NO_PROXY,no_proxy",
+			Usage:  "Comma separated hosts, host:ports and domains to send deploy-state requests to directly, * for all",
+		},
+		cli.Float64Flag{
+			Name:   "notify-rate-limit",
+			EnvVar: "GOVERNATOR_NOTIFY_RATE_LIMIT",
+			Usage:  "How many deploy-state requests to send per second at most",
+			Value:  deployer.DefaultNotifyRateLimit,
+		},
+		cli.IntFlag{
+			Name:   "notify-burst",
+			EnvVar: "GOVERNATOR_NOTIFY_BURST",
+			Usage:  "How many deploy-state requests may be sent at once, before --notify-rate-limit applies",
+			Value:  deployer.DefaultNotifyBurst,
+		},
+		cli.BoolFlag{
+			Name:   "notify-on-success-only",
+			EnvVar: "GOVERNATOR_NOTIFY_ON_SUCCESS_ONLY",
+			Usage:  "Only notify deploy-state of deploys that passed",
+		},
+		cli.BoolFlag{
+			Name:   "notify-on-failure-only",
+			EnvVar: "GOVERNATOR_NOTIFY_ON_FAILURE_ONLY",
+			Usage:  "Only notify deploy-state of deploys that failed",
+		},
+		cli.StringFlag{
+			Name:   "deploy-state-auth-token",
+			EnvVar: "GOVERNATOR_DEPLOY_STATE_AUTH_TOKEN,DEPLOY_STATE_AUTH_TOKEN",
+			Usage:  "Token to authenticate to the deploy-state service with. For Basic, user:password",
+		},
+		cli.StringFlag{
+			Name:   "deploy-state-auth-type",
+			EnvVar: "GOVERNATOR_DEPLOY_STATE_AUTH_TYPE",
+			Usage:  "How to send the --deploy-state-auth-token: Bearer, Basic or HMAC (signs the body and timestamp, sent as X-Signature)",
+			Value:  deployer.DeployStateAuthBearer,
+		},
+		cli.StringSliceFlag{
+			Name:   "cluster-env",
+			EnvVar: "GOVERNATOR_CLUSTER_ENV",
+			Usage:  "KEY=VALUE,... env to set on every deployed service, alongside GOVERNATOR_CLUSTER=<cluster>",
+		},
+		cli.BoolFlag{
+			Name:   "no-cluster-env",
+			EnvVar: "GOVERNATOR_NO_CLUSTER_ENV",
+			Usage:  "Don't set GOVERNATOR_CLUSTER or --cluster-env on deployed services",
+		},
+		cli.BoolTFlag{
+			Name:   "use-native-rollback",
+			EnvVar: "GOVERNATOR_USE_NATIVE_ROLLBACK",
+			Usage:  "Roll back with docker's rollback=previous when the daemon's API is 1.31 or newer",
+		},
+		cli.StringFlag{
+			Name:   "consumer-id",
+			EnvVar: "GOVERNATOR_CONSUMER_ID",
+			Usage:  "Name of this deployer in the queue, used to requeue its deploys if it dies",
+			Value:  defaultConsumerID(),
+		},
+		cli.StringSliceFlag{
+			Name:   "image-tag-transform",
+			EnvVar: "GOVERNATOR_IMAGE_TAG_TRANSFORM",
+			Usage:  "sed style s/pattern/replacement/ applied to image tags before deploying, may be repeated",
+		},
+		cli.StringFlag{
+			Name:   "image-tag-transforms-file",
+			EnvVar: "GOVERNATOR_IMAGE_TAG_TRANSFORMS_FILE",
+			Usage:  "File of --image-tag-transform expressions, one per line, applied after the flags",
+		},
+		cli.DurationFlag{
+			Name:   "config-refresh-interval",
+			EnvVar: "GOVERNATOR_CONFIG_REFRESH_INTERVAL",
+			Usage:  "How often to reload --notify-channel-map and --image-tag-transforms-file, 0 to disable",
+		},
+		cli.DurationFlag{
+			Name:   "deploy-window",
+			EnvVar: "GOVERNATOR_DEPLOY_WINDOW",
+			Usage:  "How long to watch a service's tasks after a deploy, rolling back if one fails. 0 to not watch",
+		},
+		cli.DurationFlag{
+			Name:   "task-poll-interval, service-update-monitor-interval",
+			EnvVar: "GOVERNATOR_TASK_POLL_INTERVAL",
+			Usage:  "How often to list a service's tasks during the --deploy-window",
+			Value:  2 * time.Second,
+		},
+		cli.BoolFlag{
+			Name:   "watch-docker-events",
+			EnvVar: "GOVERNATOR_WATCH_DOCKER_EVENTS",
+			Usage:  "List a watched service's tasks as soon as docker sends an event for it, as well as every --task-poll-interval. Needs docker API 1.30 for service events",
+		},
+		cli.DurationFlag{
+			Name:   "event-reconnect-backoff",
+			EnvVar: "GOVERNATOR_EVENT_RECONNECT_BACKOFF",
+			Usage:  "How long to wait before reconnecting a dropped --watch-docker-events stream, doubling up to 60s while docker can't be reached",
+			Value:  2 * time.Second,
+		},
+		cli.BoolFlag{
+			Name:   "capture-failure-logs",
+			EnvVar: "GOVERNATOR_CAPTURE_FAILURE_LOGS",
+			Usage:  "Log the last log line of tasks that fail while a deploy watches them",
+		},
+		cli.DurationFlag{
+			Name:   "min-healthy-period",
+			EnvVar: "GOVERNATOR_MIN_HEALTHY_PERIOD",
+			Usage:  "How long a service's tasks must keep running once a deploy is done before it passes, rolling back if one fails. 0 to not wait",
+		},
+		cli.BoolFlag{
+			Name:   "wait-for-convergence",
+			EnvVar: "GOVERNATOR_WAIT_FOR_CONVERGENCE",
+			Usage:  "Wait for every replica to run the new image before a deploy passes, even when the deploy doesn't set waitForConvergence. Without a deploy timeout, it waits up to 10m",
+		},
+		cli.BoolFlag{
+			Name:   "redeploy-on-config-change",
+			EnvVar: "GOVERNATOR_REDEPLOY_ON_CONFIG_CHANGE",
+			Usage:  "Redeploy the --config-watch-services when a swarm config attached to them changes. Needs docker API 1.30",
+		},
+		cli.DurationFlag{
+			Name:   "config-watch-interval",
+			EnvVar: "GOVERNATOR_CONFIG_WATCH_INTERVAL",
+			Usage:  "How often to check the configs of the --config-watch-services",
+			Value:  60 * time.Second,
+		},
+		cli.StringSliceFlag{
+			Name:   "config-watch-services",
+			EnvVar: "GOVERNATOR_CONFIG_WATCH_SERVICES",
+			Usage:  "Services to redeploy when their configs change, with --redeploy-on-config-change",
+		},
+		cli.IntFlag{
+			Name:   "global-max-parallelism",
+			EnvVar: "GOVERNATOR_GLOBAL_MAX_PARALLELISM",
+			Usage:  "How many deploys may run at once across every deployer on the queue. 0 for no limit",
+			Value:  8,
+		},
+		cli.DurationFlag{
+			Name:   "concurrency-wait",
+			EnvVar: "GOVERNATOR_CONCURRENCY_WAIT",
+			Usage:  "How long to wait for a --global-max-parallelism slot before requeueing the deploy",
+			Value:  10 * time.Second,
+		},
 	}
 	app.Run(os.Args)
 }
@@ -61,11 +591,152 @@ func run(context *cli.Context) {
 	dockerURI, redisURI, redisQueue, deployStateURI, cluster := getOpts(context)
 
 	dockerClient := getDockerClient(dockerURI)
+	apiCallTimeout := context.Duration("docker-api-call-timeout")
+	if apiCallTimeout > 0 {
+		dockerClient = deployer.NewTimeoutClient(dockerClient, apiCallTimeout)
+	}
+
+	swarmToken := context.String("docker-swarm-token")
+	swarmManager := context.String("docker-swarm-manager")
+	if swarmToken != "" || swarmManager != "" {
+		if swarmToken == "" || swarmManager == "" {
+			cli.ShowAppHelp(context)
+			color.Red("  --docker-swarm-token and --docker-swarm-manager must be used together")
+			os.Exit(1)
+		}
+		dockerClient = deployer.NewSwarmRejoiningClient(dockerClient, swarmToken, swarmManager)
+	}
+
+	trigger := context.String("trigger")
+	if trigger != "poll" && trigger != "keyspace" {
+		cli.ShowAppHelp(context)
+		color.Red("  Invalid --trigger '%v', expected poll or keyspace", trigger)
+		os.Exit(1)
+	}
+
+	dialConfig := getRedisDialConfig(context)
+	redisPool := getRedisPool(redisURI, dialConfig, context.Bool("trace-redis"))
+
+	setMetricsBackend(context)
+
+	options := getDeployerOptions(context)
+	teamsWebhookURL := context.String("teams-webhook-url")
+	if teamsWebhookURL != "" {
+		options = append(options, deployer.WithNotifier(notifier.NewTeamsNotifier(teamsWebhookURL, context.Bool("teams-on-failure-only"))))
+	}
+
+	pagerDutyRoutingKey := context.String("pagerduty-routing-key")
+	if pagerDutyRoutingKey != "" {
+		options = append(options, deployer.WithNotifier(notifier.NewPagerDutyNotifier(pagerDutyRoutingKey, notifier.PagerDutyEventsURL)))
+	}
+
+	secretLister, err := deployer.NewHTTPSecretLister(context.String("docker-uri"))
+	if err != nil {
+		log.Println("Required secrets check disabled", err)
+	} else {
+		options = append(options, deployer.WithSecretLister(secretLister))
+	}
+
+	maxImageAge := context.Duration("max-image-age")
+	if maxImageAge > 0 {
+		options = append(options, deployer.WithMaxImageAge(maxImageAge, context.Bool("max-image-age-skip-digest-images")))
+	}
+
+	if context.Bool("require-semver-tag") {
+		options = append(options, deployer.WithRequireSemverTag(context.String("semver-prefix")))
+	}
+
+	deployStateRetryQueue := context.String("deploy-state-retry-queue")
+	if deployStateRetryQueue != "" {
+		options = append(options, deployer.WithDeployStateRetryQueue(deployStateRetryQueue))
+	}
+
+	if context.Bool("enforce-readonly-rootfs") {
+		options = append(options, deployer.WithEnforceReadonlyRootfs())
+	}
+
+	if context.Bool("verify-digest") {
+		digestResolver, err := deployer.NewHTTPDigestResolver(context.String("docker-uri"))
+		if err != nil {
+			cli.ShowAppHelp(context)
+			color.Red("  Invalid --docker-uri for --verify-digest: %v", err)
+			os.Exit(1)
+		}
+		options = append(options, deployer.WithDigestVerification(digestResolver))
+	}
+
+	if context.Bool("watch-docker-events") {
+		eventSubscriber := monitor.NewEventSubscriber(dockerClient, context.Duration("event-reconnect-backoff"))
+		go runEventSubscriber(eventSubscriber)
+		options = append(options, deployer.WithEventSubscriber(eventSubscriber))
+	}
+	theDeployer := deployer.New(dockerClient, redisPool, redisQueue, deployStateURI, cluster, options...)
+
+	err = theDeployer.RefreshConfig()
+	if err != nil {
+		cli.ShowAppHelp(context)
+		color.Red("  Invalid --notify-channel-map or --image-tag-transforms-file: %v", err)
+		os.Exit(1)
+	}
+
+	apiAddr := context.String("api-addr")
+	pprofAddr := ""
+	if context.Bool("enable-pprof") {
+		pprofAddr = context.String("pprof-addr")
+		err := checkPprofAddr(pprofAddr, map[string]string{"api-addr": apiAddr})
+		if err != nil {
+			color.Red("  %v", err)
+			os.Exit(1)
+		}
+	}
+
+	if apiAddr != "" {
+		go serveAPI(apiAddr, theDeployer)
+	}
+	if pprofAddr != "" {
+		go servePprof(pprofAddr)
+	}
+
+	err = theDeployer.Heartbeat()
+	if err != nil {
+		log.Println("Heartbeat error", err)
+	}
+	go runHeartbeat(theDeployer)
+
+	if context.Bool("cleanup-orphan-metadata") {
+		cleanupOrphanMetadata(theDeployer, context.Duration("orphan-max-age"))
+	}
+
+	if context.Bool("enable-service-watcher") {
+		serviceWatcher := monitor.NewServiceWatcher(dockerClient, metrics.DefaultRecorder, getKeyValues(context, "docker-label-filter"), monitor.DefaultServiceWatchInterval)
+		go runServiceWatcher(serviceWatcher)
+	}
+
+	gcInterval := context.Duration("gc-interval")
+	if gcInterval > 0 {
+		theDeployer.StartGC(gcInterval, context.Duration("gc-max-age"))
+	}
 
-	redisConn := getRedisConn(redisURI)
+	if deployStateRetryQueue != "" {
+		theDeployer.StartNotifyRetryWorker()
+	}
+
+	configRefreshInterval := context.Duration("config-refresh-interval")
+	if configRefreshInterval > 0 {
+		go runConfigRefresh(theDeployer, configRefreshInterval)
+	}
+
+	if context.Bool("redeploy-on-config-change") {
+		go runConfigWatch(theDeployer, context.Duration("config-watch-interval"), context.StringSlice("config-watch-services"))
+	}
+
+	var keyspaceTrigger *deployer.KeyspaceTrigger
+	if trigger == "keyspace" && !context.Bool("once") {
+		keyspaceTrigger = theDeployer.NewKeyspaceTrigger(dialConfig.database(redisURI), context.Duration("keyspace-fallback-interval"))
+		go runKeyspaceTrigger(keyspaceTrigger)
+	}
 
-	theDeployer := deployer.New(dockerClient, redisConn, redisQueue, deployStateURI, cluster)
-	sigTerm := make(chan os.Signal)
+	sigTerm := make(chan os.Signal, 1)
 	signal.Notify(sigTerm, syscall.SIGTERM)
 
 	sigTermReceived := false
@@ -78,16 +749,29 @@ func run(context *cli.Context) {
 
 	for {
 		if sigTermReceived {
+			closeDeployer(theDeployer)
 			fmt.Println("I'll be back.")
 			os.Exit(0)
 		}
 
-		debug("theDeployer.Run()")
-		err := theDeployer.Run()
-		if err != nil {
-			log.Panic("Run error", err)
+		deployed := runOnce(theDeployer)
+		if context.Bool("once") {
+			if !deployed {
+				fmt.Println("No pending deploys")
+			}
+			closeDeployer(theDeployer)
+			os.Exit(0)
+		}
+		if keyspaceTrigger == nil {
+			time.Sleep(theDeployer.PollInterval())
+			continue
+		}
+
+		// more deploys may have been queued under the one notification
+		time.Sleep(theDeployer.TriggerDelay())
+		if !deployed {
+			keyspaceTrigger.Wait()
 		}
-		time.Sleep(1 * time.Second)
 	}
 }
 
@@ -98,7 +782,10 @@ func getOpts(context *cli.Context) (string, string, string, string, string) {
 	deployStateURI := context.String("deploy-state-uri")
 	cluster := context.String("cluster")
 
-	if dockerURI == "" || redisURI == "" || redisQueue == "" || deployStateURI == "" || cluster == "" {
+	// deploy-state is looked up when it is discovered through DNS
+	missingDeployStateURI := deployStateURI == "" && context.String("deploy-state-discovery") != "srv"
+
+	if dockerURI == "" || redisURI == "" || redisQueue == "" || missingDeployStateURI || cluster == "" {
 		cli.ShowAppHelp(context)
 
 		if dockerURI == "" {
@@ -110,7 +797,7 @@ func getOpts(context *cli.Context) (string, string, string, string, string) {
 		if redisQueue == "" {
 			color.Red("  Missing required flag --redis-queue or GOVERNATOR_REDIS_QUEUE")
 		}
-		if deployStateURI == "" {
+		if missingDeployStateURI {
 			color.Red("  Missing required flag --deploy-state-uri or DEPLOY_STATE_URI")
 		}
 		if cluster == "" {
@@ -122,6 +809,262 @@ func getOpts(context *cli.Context) (string, string, string, string, string) {
 	return dockerURI, redisURI, redisQueue, deployStateURI, cluster
 }
 
+// getDeployerOptions builds the deployer options from the app's flags
+func getDeployerOptions(context *cli.Context) []deployer.Option {
+	var options []deployer.Option
+	manifestDir := context.String("manifest-dir")
+	if manifestDir != "" {
+		options = append(options, deployer.WithManifestDir(manifestDir))
+	}
+
+	dockerLabelFilter := getKeyValues(context, "docker-label-filter")
+	if len(dockerLabelFilter) > 0 {
+		options = append(options, deployer.WithDockerLabelFilter(dockerLabelFilter))
+	}
+
+	nodeLabelSelector := getKeyValues(context, "node-label-selector")
+	if len(nodeLabelSelector) > 0 {
+		options = append(options, deployer.WithNodeLabelSelector(nodeLabelSelector))
+	}
+
+	if context.Bool("enable-service-health-routing") {
+		key, value, err := deployer.ParseHealthRoutingLabel(context.String("health-routing-label"))
+		if err != nil {
+			cli.ShowAppHelp(context)
+			color.Red("  Invalid --health-routing-label: %v", err)
+			os.Exit(1)
+		}
+		options = append(options, deployer.WithHealthRouting(key, value, context.Duration("drain-wait")))
+	}
+
+	registryMirror := context.String("registry-mirror")
+	if registryMirror != "" {
+		options = append(options, deployer.WithRegistryMirror(registryMirror, context.String("registry-mirror-prefix-strip")))
+	}
+
+	clusterEnv := getKeyValues(context, "cluster-env")
+	if len(clusterEnv) > 0 {
+		options = append(options, deployer.WithClusterEnv(clusterEnv))
+	}
+	if context.Bool("no-cluster-env") {
+		options = append(options, deployer.WithNoClusterEnv())
+	}
+
+	if context.BoolT("use-native-rollback") {
+		rollbacker, err := deployer.NewHTTPRollbacker(context.String("docker-uri"))
+		if err != nil {
+			log.Println("Native rollback disabled", err)
+		} else {
+			options = append(options, deployer.WithNativeRollback(rollbacker))
+		}
+	}
+
+	if context.Bool("redeploy-on-config-change") {
+		if len(context.StringSlice("config-watch-services")) == 0 {
+			cli.ShowAppHelp(context)
+			color.Red("  --redeploy-on-config-change needs --config-watch-services")
+			os.Exit(1)
+		}
+		configSource, err := deployer.NewHTTPConfigSource(context.String("docker-uri"))
+		if err != nil {
+			cli.ShowAppHelp(context)
+			color.Red("  Invalid --docker-uri for --redeploy-on-config-change: %v", err)
+			os.Exit(1)
+		}
+		options = append(options, deployer.WithConfigSource(configSource))
+	}
+
+	updateFailureAction := context.String("update-failure-action")
+	if updateFailureAction != "" {
+		err := deployer.ValidateFailureAction(updateFailureAction)
+		if err != nil {
+			cli.ShowAppHelp(context)
+			color.Red("  Invalid --update-failure-action: %v", err)
+			os.Exit(1)
+		}
+		options = append(options, deployer.WithUpdateFailureAction(updateFailureAction))
+	}
+
+	if context.Bool("redis-compress") {
+		compression := context.String("redis-compress-algorithm")
+		err := deployer.ValidateCompression(compression)
+		if err != nil {
+			cli.ShowAppHelp(context)
+			color.Red("  Invalid --redis-compress-algorithm: %v", err)
+			os.Exit(1)
+		}
+		options = append(options, deployer.WithRedisCompression(compression))
+	}
+
+	if context.Bool("allow-service-recreation") {
+		options = append(options, deployer.WithServiceRecreation())
+	}
+
+	if context.Bool("service-recreate-on-conflict") {
+		options = append(options, deployer.WithConflictRetry(context.Duration("conflict-backoff")))
+	}
+
+	allowedRegistries := context.StringSlice("allowed-registries")
+	if len(allowedRegistries) > 0 {
+		options = append(options, deployer.WithAllowedRegistries(allowedRegistries...))
+	}
+
+	keyPrefix := context.String("redis-key-prefix")
+	if keyPrefix != "" {
+		options = append(options, deployer.WithKeyPrefix(keyPrefix))
+	}
+
+	consumerID := context.String("consumer-id")
+	if consumerID != "" {
+		options = append(options, deployer.WithConsumerID(consumerID))
+	}
+
+	var tagTransforms []*deployer.TagTransform
+	for _, expression := range context.StringSlice("image-tag-transform") {
+		tagTransform, err := deployer.ParseTagTransform(expression)
+		if err != nil {
+			cli.ShowAppHelp(context)
+			color.Red("  Invalid --image-tag-transform: %v", err)
+			os.Exit(1)
+		}
+		tagTransforms = append(tagTransforms, tagTransform)
+	}
+	if len(tagTransforms) > 0 {
+		options = append(options, deployer.WithImageTagTransforms(tagTransforms...))
+	}
+
+	notifyChannelMap := context.String("notify-channel-map")
+	tagTransformsFile := context.String("image-tag-transforms-file")
+	if notifyChannelMap != "" || tagTransformsFile != "" {
+		options = append(options, deployer.WithConfigFiles(notifyChannelMap, tagTransformsFile))
+	}
+
+	deployWindow := context.Duration("deploy-window")
+	if deployWindow > 0 {
+		options = append(options, deployer.WithDeployWindow(deployWindow, context.Duration("task-poll-interval")))
+	}
+
+	if context.Bool("capture-failure-logs") {
+		options = append(options, deployer.WithFailureLogs())
+	}
+
+	minHealthyPeriod := context.Duration("min-healthy-period")
+	if minHealthyPeriod > 0 {
+		options = append(options, deployer.WithMinHealthyPeriod(minHealthyPeriod, context.Duration("task-poll-interval")))
+	}
+
+	if context.Bool("wait-for-convergence") {
+		options = append(options, deployer.WithConvergence())
+	}
+
+	globalMaxParallelism := context.Int("global-max-parallelism")
+	if globalMaxParallelism > 0 {
+		options = append(options, deployer.WithGlobalMaxParallelism(globalMaxParallelism, context.Duration("concurrency-wait")))
+	}
+
+	options = append(options, deployer.WithPollInterval(context.Duration("poll-interval")))
+	options = append(options, deployer.WithQueueScanLimit(context.Int("queue-scan-limit")))
+
+	switch context.String("deploy-state-discovery") {
+	case "static":
+	case "srv":
+		options = append(options, deployer.WithDeployStateSRV(context.String("deploy-state-srv-name"), context.Duration("deploy-state-srv-ttl")))
+	default:
+		cli.ShowAppHelp(context)
+		color.Red("  Invalid --deploy-state-discovery '%v', expected static or srv", context.String("deploy-state-discovery"))
+		os.Exit(1)
+	}
+
+	staggerDelay := context.String("stagger-delay")
+	if staggerDelay != "" {
+		staggerMin, staggerMax, err := deployer.ParseStaggerDelay(staggerDelay)
+		if err != nil {
+			cli.ShowAppHelp(context)
+			color.Red("  Invalid --stagger-delay: %v", err)
+			os.Exit(1)
+		}
+		options = append(options, deployer.WithStaggerDelay(staggerMin, staggerMax, context.Int64("stagger-delay-seed")))
+	}
+
+	if context.Duration("docker-api-latency-threshold") > 0 {
+		options = append(options, deployer.WithBackpressure(context.Duration("docker-api-latency-threshold"), context.Duration("backpressure-sleep")))
+	}
+
+	options = append(options, deployer.WithDefaultStopGracePeriod(context.Duration("default-stop-grace-period")))
+	options = append(options, deployer.WithDeployTimeout(context.Duration("deploy-timeout")))
+	options = append(options, deployer.WithDeployStateTimeout(context.Duration("deploy-state-timeout")))
+	options = append(options, deployer.WithDeployStateHTTP2(context.BoolT("deploy-state-http2")))
+
+	proxyConfig, err := deployer.ParseProxyConfig(context.String("http-proxy"), context.String("https-proxy"), context.String("no-proxy"))
+	if err != nil {
+		cli.ShowAppHelp(context)
+		color.Red("  Invalid --http-proxy or --https-proxy: %v", err)
+		os.Exit(1)
+	}
+	options = append(options, deployer.WithDeployStateProxy(proxyConfig))
+
+	deployStateAuthToken := context.String("deploy-state-auth-token")
+	if deployStateAuthToken != "" {
+		deployStateAuthType := context.String("deploy-state-auth-type")
+		err := deployer.ValidateDeployStateAuthType(deployStateAuthType)
+		if err != nil {
+			cli.ShowAppHelp(context)
+			color.Red("  Invalid --deploy-state-auth-type: %v", err)
+			os.Exit(1)
+		}
+		options = append(options, deployer.WithDeployStateAuth(deployStateAuthType, deployStateAuthToken))
+	}
+
+	options = append(options, deployer.WithNotifyRateLimit(context.Float64("notify-rate-limit"), context.Int("notify-burst")))
+
+	notifyOnSuccessOnly := context.Bool("notify-on-success-only")
+	notifyOnFailureOnly := context.Bool("notify-on-failure-only")
+	if notifyOnSuccessOnly && notifyOnFailureOnly {
+		cli.ShowAppHelp(context)
+		color.Red("  --notify-on-success-only and --notify-on-failure-only can't be used together")
+		os.Exit(1)
+	}
+	if notifyOnSuccessOnly {
+		options = append(options, deployer.WithNotifyOnly(deployer.DeployPassed))
+	}
+	if notifyOnFailureOnly {
+		options = append(options, deployer.WithNotifyOnly(deployer.DeployFailed))
+	}
+
+	auditLog := context.String("audit-log")
+	if auditLog != "" {
+		options = append(options, deployer.WithAuditLog(auditLog), deployer.WithAuditLogMaxSize(context.Int("audit-log-max-size-mb")))
+	}
+
+	etcdEndpoints := context.StringSlice("etcd-endpoints")
+	if len(etcdEndpoints) > 0 {
+		options = append(options, deployer.WithEtcdClient(deployer.NewEtcdClient(etcdEndpoints)))
+	}
+
+	return options
+}
+
+// getKeyValues parses a key=value flag, which may be repeated
+// or given as comma separated pairs like the env var is
+func getKeyValues(context *cli.Context, name string) map[string]string {
+	var pairs []string
+	for _, value := range context.StringSlice(name) {
+		pairs = append(pairs, strings.Split(value, ",")...)
+	}
+
+	keyValues := map[string]string{}
+	for _, pair := range pairs {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			cli.ShowAppHelp(context)
+			color.Red("  Invalid --%s '%s', expected key=value", name, pair)
+			os.Exit(1)
+		}
+		keyValues[parts[0]] = parts[1]
+	}
+	return keyValues
+}
+
 func getDockerClient(dockerURI string) client.APIClient {
 	defaultHeaders := map[string]string{"User-Agent": "governator-swarm"}
 
@@ -132,12 +1075,180 @@ func getDockerClient(dockerURI string) client.APIClient {
 	return dockerClient
 }
 
-func getRedisConn(redisURI string) redis.Conn {
-	redisConn, err := redis.DialURL(redisURI)
+func getRedisPool(redisURI string, dialConfig *redisDialConfig, trace bool) *redis.Pool {
+	dialOptions, err := dialConfig.dialOptions()
+	if err != nil {
+		log.Panicln("Invalid redis connection settings", err.Error())
+	}
+
+	dial := func() (redis.Conn, error) {
+		redisConn, err := redis.DialURL(redisURI, dialOptions...)
+		if err != nil {
+			return nil, err
+		}
+
+		err = dialConfig.selectDB(redisConn)
+		if err != nil {
+			redisConn.Close()
+			return nil, err
+		}
+
+		if trace {
+			return newTracingConn(redisConn), nil
+		}
+		return redisConn, nil
+	}
+	if dialConfig.backoff != nil {
+		dial = newBackoffDialer(dial, dialConfig.backoff).Dial
+	}
+
+	redisPool := &redis.Pool{
+		MaxIdle:     3,
+		IdleTimeout: 240 * time.Second,
+		Dial:        dial,
+	}
+
+	redisConn := redisPool.Get()
+	defer redisConn.Close()
+
+	_, err = redisConn.Do("PING")
 	if err != nil {
 		log.Panicln("Error with redis.DialURL", err.Error())
 	}
-	return redisConn
+	return redisPool
+}
+
+func serveAPI(apiAddr string, theDeployer *deployer.Deployer) {
+	debug("serving API on %v", apiAddr)
+	err := http.ListenAndServe(apiAddr, api.New(theDeployer))
+	log.Panicln("Error serving API", err.Error())
+}
+
+func getRedisDialConfig(context *cli.Context) *redisDialConfig {
+	dialConfig := &redisDialConfig{
+		username: context.String("redis-username"),
+		password: context.String("redis-password"),
+		tls:      context.Bool("redis-tls"),
+		tlsCA:    context.String("redis-tls-ca"),
+		tlsCert:  context.String("redis-tls-cert"),
+		tlsKey:   context.String("redis-tls-key"),
+		db:       context.Int("redis-db"),
+	}
+
+	reconnectBackoff := context.String("redis-reconnect-backoff")
+	if reconnectBackoff != "" {
+		backoff, err := parseRedisBackoff(reconnectBackoff)
+		if err != nil {
+			cli.ShowAppHelp(context)
+			color.Red("  Invalid --redis-reconnect-backoff: %v", err)
+			os.Exit(1)
+		}
+		dialConfig.backoff = backoff
+	}
+	return dialConfig
+}
+
+// setMetricsBackend records metrics to the --metrics-backend
+func setMetricsBackend(context *cli.Context) {
+	switch context.String("metrics-backend") {
+	case "prometheus":
+	case "statsd":
+		statsd, err := metrics.NewStatsD(context.String("statsd-addr"))
+		if err != nil {
+			cli.ShowAppHelp(context)
+			color.Red("  Invalid --statsd-addr: %v", err)
+			os.Exit(1)
+		}
+		metrics.DefaultRecorder = statsd
+	default:
+		cli.ShowAppHelp(context)
+		color.Red("  Invalid --metrics-backend %v, must be prometheus or statsd", context.String("metrics-backend"))
+		os.Exit(1)
+	}
+}
+
+func runOnce(theDeployer *deployer.Deployer) bool {
+	debug("theDeployer.RunOnce()")
+	deployed, err := theDeployer.RunOnce(context.Background())
+	if err != nil {
+		log.Panic("Run error", err)
+	}
+	return deployed
+}
+
+func cleanupOrphanMetadata(theDeployer *deployer.Deployer, maxAge time.Duration) {
+	cleaned, err := theDeployer.CleanupOrphanMetadata(context.Background(), maxAge)
+	if err != nil {
+		log.Println("Error cleaning up orphaned deploy hashes", err)
+	}
+	log.Println("Cleaned up", cleaned, "orphaned deploy hashes")
+}
+
+func runConfigRefresh(theDeployer *deployer.Deployer, interval time.Duration) {
+	for range time.Tick(interval) {
+		err := theDeployer.RefreshConfig()
+		if err != nil {
+			log.Println("WARNING: keeping the old config, reload failed", err)
+		}
+	}
+}
+
+func closeDeployer(theDeployer *deployer.Deployer) {
+	err := theDeployer.Close()
+	if err != nil {
+		log.Println("Error closing the deployer", err)
+	}
+}
+
+func runServiceWatcher(serviceWatcher *monitor.ServiceWatcher) {
+	serviceWatcher.Run(context.Background())
+}
+
+func runEventSubscriber(eventSubscriber *monitor.EventSubscriber) {
+	eventSubscriber.Run(context.Background())
+}
+
+func runKeyspaceTrigger(keyspaceTrigger *deployer.KeyspaceTrigger) {
+	keyspaceTrigger.Run(context.Background())
+}
+
+func runConfigWatch(theDeployer *deployer.Deployer, interval time.Duration, services []string) {
+	for range time.Tick(interval) {
+		queued, err := theDeployer.CheckConfigs(context.Background(), services)
+		if err != nil {
+			log.Println("Config watch error", err)
+			continue
+		}
+		debug("config watch queued %v redeploys", queued)
+	}
+}
+
+// runHeartbeat keeps this consumer alive in redis, and requeues
+// the deploys of consumers that have stopped sending heartbeats
+func runHeartbeat(theDeployer *deployer.Deployer) {
+	for range time.Tick(deployer.HeartbeatInterval) {
+		err := theDeployer.Heartbeat()
+		if err != nil {
+			log.Println("Heartbeat error", err)
+		}
+
+		requeued, err := theDeployer.RecoverStaleConsumers()
+		if err != nil {
+			log.Println("Error recovering stale consumers", err)
+			continue
+		}
+		if requeued > 0 {
+			debug("requeued %v deploys from stale consumers", requeued)
+		}
+	}
+}
+
+func defaultConsumerID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
 }
 
 // ParseHost verifies that the given host strings is valid.
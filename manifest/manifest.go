@@ -0,0 +1,37 @@
+package manifest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Load reads the TOML file at path and decodes it into v.
+// Keys are matched against the json tags of v, so a
+// manifest uses the same field names as the redis metadata
+func Load(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return Unmarshal(data, v)
+}
+
+// Unmarshal decodes a TOML document into v, through JSON so
+// that the keys are matched against the json tags of v
+func Unmarshal(data []byte, v interface{}) error {
+	tree := map[string]interface{}{}
+	_, err := toml.Decode(string(data), &tree)
+	if err != nil {
+		return err
+	}
+
+	jsonBytes, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(jsonBytes, v)
+}
@@ -0,0 +1,153 @@
+package manifest_test
+
+import (
+	"github.com/octoblu/governator-swarm/manifest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type testManifest struct {
+	EtcdDir   string            `json:"etcdDir"`
+	DockerURL string            `json:"dockerUrl"`
+	Replicas  int               `json:"replicas"`
+	Enabled   bool              `json:"enabled"`
+	Tags      []string          `json:"tags"`
+	Labels    map[string]string `json:"labels"`
+	Mounts    []testMount       `json:"mounts"`
+}
+
+type testMount struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+var _ = Describe("Unmarshal", func() {
+	var result testManifest
+	var err error
+
+	BeforeEach(func() {
+		result = testManifest{}
+	})
+
+	Describe("When given a manifest with top level keys", func() {
+		BeforeEach(func() {
+			err = manifest.Unmarshal([]byte(`
+# deploy manifest
+etcdDir = "/octoblu/my-application"
+dockerUrl = 'octoblu/my-application:v1' # trailing comment
+replicas = 1_0
+enabled = true
+tags = [
+  "a",
+  "b", # second
+]
+`), &result)
+		})
+
+		It("Should not error", func() {
+			Expect(err).To(BeNil())
+		})
+
+		It("Should decode the values using the json field names", func() {
+			Expect(result.EtcdDir).To(Equal("/octoblu/my-application"))
+			Expect(result.DockerURL).To(Equal("octoblu/my-application:v1"))
+			Expect(result.Replicas).To(Equal(10))
+			Expect(result.Enabled).To(BeTrue())
+			Expect(result.Tags).To(Equal([]string{"a", "b"}))
+		})
+	})
+
+	Describe("When given a manifest with tables", func() {
+		BeforeEach(func() {
+			err = manifest.Unmarshal([]byte(`
+dockerUrl = "octoblu/my-application:v1"
+
+[labels]
+team = "core"
+"quoted.key" = "quoted\tvalue"
+`), &result)
+		})
+
+		It("Should decode the table into a map", func() {
+			Expect(err).To(BeNil())
+			Expect(result.Labels).To(Equal(map[string]string{
+				"team":       "core",
+				"quoted.key": "quoted\tvalue",
+			}))
+		})
+	})
+
+	Describe("When given a string with TOML escapes", func() {
+		BeforeEach(func() {
+			err = manifest.Unmarshal([]byte(`dockerUrl = "octoblu/caf\u00E9:v1\U0001F680"`), &result)
+		})
+
+		It("Should decode them as TOML does", func() {
+			Expect(err).To(BeNil())
+			Expect(result.DockerURL).To(Equal("octoblu/caf\u00e9:v1\U0001f680"))
+		})
+	})
+
+	Describe("When given an array of tables", func() {
+		BeforeEach(func() {
+			err = manifest.Unmarshal([]byte(`
+[[mounts]]
+source = "/var/data"
+target = "/data"
+
+[[mounts]]
+source = "/var/log"
+target = "/log"
+`), &result)
+		})
+
+		It("Should decode each table", func() {
+			Expect(err).To(BeNil())
+			Expect(result.Mounts).To(Equal([]testMount{
+				{Source: "/var/data", Target: "/data"},
+				{Source: "/var/log", Target: "/log"},
+			}))
+		})
+	})
+
+	Describe("When a table is defined twice", func() {
+		BeforeEach(func() {
+			err = manifest.Unmarshal([]byte("[labels]\nteam = \"core\"\n\n[labels]\nowner = \"ops\"\n"), &result)
+		})
+
+		It("Should return an error", func() {
+			Expect(err).To(MatchError(ContainSubstring("Key 'labels' has already been defined")))
+		})
+	})
+
+	Describe("When given an inline table", func() {
+		BeforeEach(func() {
+			err = manifest.Unmarshal([]byte(`labels = { team = "core", owner = "ops" }`), &result)
+		})
+
+		It("Should decode the inline table into a map", func() {
+			Expect(err).To(BeNil())
+			Expect(result.Labels).To(Equal(map[string]string{"team": "core", "owner": "ops"}))
+		})
+	})
+
+	Describe("When a key is defined twice", func() {
+		BeforeEach(func() {
+			err = manifest.Unmarshal([]byte("dockerUrl = \"a:v1\"\ndockerUrl = \"a:v2\"\n"), &result)
+		})
+
+		It("Should return an error with the line number", func() {
+			Expect(err).To(MatchError("Near line 2 (last key parsed 'dockerUrl'): Key 'dockerUrl' has already been defined."))
+		})
+	})
+
+	Describe("When a value is malformed", func() {
+		BeforeEach(func() {
+			err = manifest.Unmarshal([]byte(`dockerUrl = "unterminated`), &result)
+		})
+
+		It("Should return an error", func() {
+			Expect(err).To(MatchError(ContainSubstring("Near line 1")))
+		})
+	})
+})
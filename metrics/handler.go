@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+const (
+	// PrometheusContentType is the content type of the Prometheus text format
+	PrometheusContentType = string(expfmt.FmtText)
+
+	// OpenMetricsContentType is the content type of the OpenMetrics text format
+	OpenMetricsContentType = string(expfmt.FmtOpenMetrics)
+)
+
+// Handler serves the registry in the format negotiated from the
+// request's Accept header, the OpenMetrics text format when it
+// accepts application/openmetrics-text, the Prometheus text
+// format otherwise
+func Handler(registry *Registry) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		format := expfmt.NegotiateIncludingOpenMetrics(request.Header)
+		response.Header().Set("Content-Type", string(format))
+
+		err := registry.write(response, format)
+		if err != nil {
+			debug("error writing metrics: %v", err)
+		}
+	})
+}
+
+// WritePrometheus writes the registry in the Prometheus text format
+func (registry *Registry) WritePrometheus(writer io.Writer) error {
+	return registry.write(writer, expfmt.FmtText)
+}
+
+// WriteOpenMetrics writes the registry in the OpenMetrics text format
+func (registry *Registry) WriteOpenMetrics(writer io.Writer) error {
+	return registry.write(writer, expfmt.FmtOpenMetrics)
+}
+
+func (registry *Registry) write(writer io.Writer, format expfmt.Format) error {
+	encoder := expfmt.NewEncoder(writer, format)
+	for _, family := range registry.snapshot() {
+		err := encoder.Encode(family.metricFamily())
+		if err != nil {
+			return err
+		}
+	}
+
+	if closer, ok := encoder.(expfmt.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// metricFamily converts the family for the expfmt encoders
+func (metric family) metricFamily() *dto.MetricFamily {
+	metricFamily := &dto.MetricFamily{Name: proto.String(metric.name)}
+	if metric.help != "" {
+		metricFamily.Help = proto.String(metric.help)
+	}
+
+	switch metric.kind {
+	case counterType:
+		metricFamily.Type = dto.MetricType_COUNTER.Enum()
+	case gaugeType:
+		metricFamily.Type = dto.MetricType_GAUGE.Enum()
+	case histogramType:
+		metricFamily.Type = dto.MetricType_HISTOGRAM.Enum()
+	}
+
+	for _, series := range metric.sortedSeries() {
+		sample := &dto.Metric{Label: labelPairs(series.labelValues)}
+		switch metric.kind {
+		case counterType:
+			sample.Counter = &dto.Counter{Value: proto.Float64(series.value)}
+		case gaugeType:
+			sample.Gauge = &dto.Gauge{Value: proto.Float64(series.value)}
+		case histogramType:
+			sample.Histogram = &dto.Histogram{
+				SampleCount: proto.Uint64(series.count),
+				SampleSum:   proto.Float64(series.sum),
+			}
+			for i, bound := range metric.buckets {
+				sample.Histogram.Bucket = append(sample.Histogram.Bucket, &dto.Bucket{
+					UpperBound:      proto.Float64(bound),
+					CumulativeCount: proto.Uint64(series.buckets[i]),
+				})
+			}
+		}
+		metricFamily.Metric = append(metricFamily.Metric, sample)
+	}
+	return metricFamily
+}
+
+func labelPairs(labels map[string]string) []*dto.LabelPair {
+	var names []string
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]*dto.LabelPair, len(names))
+	for i, name := range names {
+		pairs[i] = &dto.LabelPair{Name: proto.String(name), Value: proto.String(labels[name])}
+	}
+	return pairs
+}
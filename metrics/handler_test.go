@@ -0,0 +1,91 @@
+package metrics_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+
+	"github.com/octoblu/governator-swarm/metrics"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Handler", func() {
+	var server *httptest.Server
+
+	fetch := func(accept string) (string, string) {
+		request, err := http.NewRequest("GET", server.URL+"/metrics", nil)
+		Expect(err).To(BeNil())
+		if accept != "" {
+			request.Header.Set("Accept", accept)
+		}
+
+		response, err := http.DefaultClient.Do(request)
+		Expect(err).To(BeNil())
+		defer response.Body.Close()
+
+		body, err := ioutil.ReadAll(response.Body)
+		Expect(err).To(BeNil())
+		return response.Header.Get("Content-Type"), string(body)
+	}
+
+	metricNames := func(body string) []string {
+		seen := map[string]bool{}
+		for _, line := range strings.Split(body, "\n") {
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			seen[strings.FieldsFunc(line, func(r rune) bool { return r == '{' || r == ' ' })[0]] = true
+		}
+
+		var names []string
+		for name := range seen {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	BeforeEach(func() {
+		registry := metrics.NewRegistry()
+		registry.Help("governator_deploys_total", "Deploys taken off the queue")
+		registry.IncrCounter("governator_deploys_total", map[string]string{"result": "passed"})
+		registry.SetGauge("governator_paused", 0, nil)
+		registry.ObserveHistogram("governator_deploy_duration_seconds", 0.3, nil)
+
+		server = httptest.NewServer(metrics.Handler(registry))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("When OpenMetrics is not requested", func() {
+		It("Should serve the Prometheus text format", func() {
+			contentType, body := fetch("")
+			Expect(contentType).To(Equal(metrics.PrometheusContentType))
+			Expect(body).To(ContainSubstring("# TYPE governator_deploys_total counter\n"))
+			Expect(body).To(ContainSubstring(`governator_deploys_total{result="passed"} 1`))
+			Expect(body).To(ContainSubstring(`governator_deploy_duration_seconds_bucket{le="0.5"} 1`))
+			Expect(body).NotTo(ContainSubstring("# EOF"))
+		})
+	})
+
+	Describe("When OpenMetrics is requested", func() {
+		It("Should serve the OpenMetrics text format", func() {
+			contentType, body := fetch("application/openmetrics-text; version=0.0.1,text/plain;q=0.5")
+			Expect(contentType).To(Equal(metrics.OpenMetricsContentType))
+			Expect(body).To(ContainSubstring("# TYPE governator_deploys counter\n"))
+			Expect(body).To(HaveSuffix("# EOF\n"))
+		})
+	})
+
+	It("Should expose the same metric names in both formats", func() {
+		_, prometheusBody := fetch("")
+		_, openMetricsBody := fetch("application/openmetrics-text")
+		Expect(metricNames(openMetricsBody)).To(Equal(metricNames(prometheusBody)))
+		Expect(metricNames(prometheusBody)).To(ContainElement("governator_paused"))
+	})
+})
@@ -0,0 +1,193 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	De "github.com/tj/go-debug"
+)
+
+var debug = De.Debug("governator:metrics")
+
+// DefaultBuckets are the histogram buckets, in seconds,
+// used when none were configured for a histogram
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DefaultRegistry is the registry served by Handler
+// and recorded to by the rest of governator-swarm
+var DefaultRegistry = NewRegistry()
+
+const (
+	counterType   = "counter"
+	gaugeType     = "gauge"
+	histogramType = "histogram"
+)
+
+// Registry holds counters, gauges and histograms by name.
+// Counter names should end in _total
+type Registry struct {
+	lock     sync.Mutex
+	families map[string]*family
+}
+
+type family struct {
+	name    string
+	kind    string
+	help    string
+	buckets []float64
+	series  map[string]*series
+}
+
+type series struct {
+	labelValues map[string]string
+	value       float64
+	sum         float64
+	count       uint64
+	buckets     []uint64
+}
+
+// NewRegistry constructs an empty registry
+func NewRegistry() *Registry {
+	return &Registry{families: map[string]*family{}}
+}
+
+// Help sets the description written alongside a metric
+func (registry *Registry) Help(name, help string) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	registry.getFamily(name, "").help = help
+}
+
+// IncrCounter increments the counter by 1
+func (registry *Registry) IncrCounter(name string, labels map[string]string) {
+	registry.AddCounter(name, 1, labels)
+}
+
+// AddCounter increments the counter by value
+func (registry *Registry) AddCounter(name string, value float64, labels map[string]string) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	registry.getSeries(name, counterType, labels).value += value
+}
+
+// SetGauge sets the gauge to value
+func (registry *Registry) SetGauge(name string, value float64, labels map[string]string) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	registry.getSeries(name, gaugeType, labels).value = value
+}
+
+// ObserveHistogram records value in the histogram
+func (registry *Registry) ObserveHistogram(name string, value float64, labels map[string]string) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	family := registry.getFamily(name, histogramType)
+	series := registry.getSeries(name, histogramType, labels)
+	if series.buckets == nil {
+		series.buckets = make([]uint64, len(family.buckets))
+	}
+
+	series.sum += value
+	series.count++
+	for i, bound := range family.buckets {
+		if value <= bound {
+			series.buckets[i]++
+		}
+	}
+}
+
+func (registry *Registry) getFamily(name, kind string) *family {
+	metric, ok := registry.families[name]
+	if !ok {
+		metric = &family{name: name, buckets: DefaultBuckets, series: map[string]*series{}}
+		registry.families[name] = metric
+	}
+	if metric.kind == "" {
+		metric.kind = kind
+	}
+	return metric
+}
+
+func (registry *Registry) getSeries(name, kind string, labels map[string]string) *series {
+	family := registry.getFamily(name, kind)
+	key := formatLabels(labels)
+
+	metric, ok := family.series[key]
+	if !ok {
+		labelValues := map[string]string{}
+		for name, value := range labels {
+			labelValues[name] = value
+		}
+		metric = &series{labelValues: labelValues}
+		family.series[key] = metric
+	}
+	return metric
+}
+
+// snapshot returns a copy of the families, sorted by name,
+// so they can be written without holding the lock
+func (registry *Registry) snapshot() []family {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	var names []string
+	for name, family := range registry.families {
+		if family.kind != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	families := make([]family, len(names))
+	for i, name := range names {
+		original := registry.families[name]
+		copied := *original
+		copied.series = map[string]*series{}
+		for key, metric := range original.series {
+			metricCopy := *metric
+			metricCopy.buckets = append([]uint64(nil), metric.buckets...)
+			copied.series[key] = &metricCopy
+		}
+		families[i] = copied
+	}
+	return families
+}
+
+func (metric family) sortedSeries() []*series {
+	var keys []string
+	for key := range metric.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]*series, len(keys))
+	for i, key := range keys {
+		result[i] = metric.series[key]
+	}
+	return result
+}
+
+func formatLabels(labels map[string]string) string {
+	var names []string
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + `="` + escapeLabelValue(labels[name]) + `"`
+	}
+	return strings.Join(parts, ",")
+}
+
+func escapeLabelValue(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, `"`, `\"`, -1)
+	return strings.Replace(value, "\n", `\n`, -1)
+}
@@ -0,0 +1,13 @@
+package metrics
+
+// Recorder records counters, gauges and histograms to a metrics backend
+type Recorder interface {
+	IncrCounter(name string, labels map[string]string)
+	SetGauge(name string, value float64, labels map[string]string)
+	ObserveHistogram(name string, value float64, labels map[string]string)
+}
+
+// DefaultRecorder is what the rest of governator-swarm records to.
+// It is DefaultRegistry, served as prometheus metrics, unless
+// replaced with another backend at startup
+var DefaultRecorder Recorder = DefaultRegistry
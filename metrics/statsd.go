@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"gopkg.in/alexcesaro/statsd.v2"
+)
+
+// StatsD sends metrics to a statsd server over UDP. Labels are
+// appended to the metric name as .key.value, sorted by key, e.g.
+// governator_deploys_total.result.passed. Histograms are in
+// seconds and sent as timings in milliseconds
+type StatsD struct {
+	client *statsd.Client
+}
+
+// NewStatsD constructs a StatsD that sends to addr, e.g. 127.0.0.1:8125.
+// Metrics are buffered and flushed every 100ms
+func NewStatsD(addr string) (*StatsD, error) {
+	client, err := statsd.New(statsd.Address(addr), statsd.ErrorHandler(func(err error) {
+		// statsd is fire and forget anyway
+		debug("statsd: %v", err)
+	}))
+	if err != nil {
+		return nil, err
+	}
+	return &StatsD{client: client}, nil
+}
+
+// IncrCounter increments the counter by 1
+func (sender *StatsD) IncrCounter(name string, labels map[string]string) {
+	sender.client.Increment(statsdName(name, labels))
+}
+
+// SetGauge sends value as a gauge
+func (sender *StatsD) SetGauge(name string, value float64, labels map[string]string) {
+	sender.client.Gauge(statsdName(name, labels), value)
+}
+
+// ObserveHistogram sends value, in seconds, as a timing
+func (sender *StatsD) ObserveHistogram(name string, value float64, labels map[string]string) {
+	sender.client.Timing(statsdName(name, labels), value*1000)
+}
+
+// Close flushes the buffered metrics and
+// closes the connection to the statsd server
+func (sender *StatsD) Close() error {
+	sender.client.Close()
+	return nil
+}
+
+func statsdName(name string, labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buffer bytes.Buffer
+	buffer.WriteString(name)
+	for _, key := range keys {
+		buffer.WriteString(".")
+		buffer.WriteString(statsdSanitize(key))
+		buffer.WriteString(".")
+		buffer.WriteString(statsdSanitize(labels[key]))
+	}
+	return buffer.String()
+}
+
+// statsdSanitize replaces the characters that statsd or
+// graphite give a meaning to
+func statsdSanitize(value string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', ':', '|', '@', ' ', '/':
+			return '_'
+		}
+		return r
+	}, value)
+}
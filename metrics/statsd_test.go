@@ -0,0 +1,64 @@
+package metrics_test
+
+import (
+	"net"
+	"time"
+
+	"github.com/octoblu/governator-swarm/metrics"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("StatsD", func() {
+	var listener net.PacketConn
+	var sut *metrics.StatsD
+
+	BeforeEach(func() {
+		var err error
+		listener, err = net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).To(BeNil())
+
+		sut, err = metrics.NewStatsD(listener.LocalAddr().String())
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		sut.Close()
+		listener.Close()
+	})
+
+	// receive skips the empty packets the client
+	// sends to check the server is listening
+	receive := func() string {
+		buffer := make([]byte, 1024)
+		listener.SetReadDeadline(time.Now().Add(time.Second))
+		for {
+			count, _, err := listener.ReadFrom(buffer)
+			Expect(err).To(BeNil())
+			if count > 0 {
+				return string(buffer[:count])
+			}
+		}
+	}
+
+	It("Should send counters with the labels in the name", func() {
+		sut.IncrCounter("governator_task_failures_total", map[string]string{"service": "my-application", "reason": "task: non-zero exit (1)"})
+		Expect(receive()).To(Equal("governator_task_failures_total.reason.task__non-zero_exit_(1).service.my-application:1|c"))
+	})
+
+	It("Should send gauges", func() {
+		sut.SetGauge("governator_stagger_delay_seconds", 2.5, nil)
+		Expect(receive()).To(Equal("governator_stagger_delay_seconds:2.5|g"))
+	})
+
+	It("Should send histograms as timings in milliseconds", func() {
+		sut.ObserveHistogram("governator_notify_wait_seconds", 0.25, nil)
+		Expect(receive()).To(Equal("governator_notify_wait_seconds:250|ms"))
+	})
+
+	It("Should send the metrics buffered since the last flush together", func() {
+		sut.IncrCounter("governator_deploys_total", map[string]string{"result": "passed"})
+		sut.SetGauge("governator_paused", 1, nil)
+		Expect(receive()).To(Equal("governator_deploys_total.result.passed:1|c\ngovernator_paused:1|g"))
+	})
+})
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/codegangsta/cli"
+	"github.com/octoblu/governator-swarm/deployer"
+)
+
+func migrateQueueCommand() cli.Command {
+	return cli.Command{
+		Name:   "migrate-queue",
+		Usage:  "Move the pending deploys of one redis queue to another, e.g. before renaming --redis-queue. Stop the deployers of both queues first",
+		Action: migrateQueue,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "from-queue",
+				Usage: "Queue to move the deploys from",
+			},
+			cli.StringFlag{
+				Name:  "to-queue",
+				Usage: "Queue to move the deploys to",
+			},
+		},
+	}
+}
+
+func migrateQueue(context *cli.Context) error {
+	fromQueue := context.String("from-queue")
+	toQueue := context.String("to-queue")
+	if fromQueue == "" || toQueue == "" {
+		return cli.NewExitError("migrate-queue requires --from-queue and --to-queue", 1)
+	}
+
+	redisURI := context.GlobalString("redis-uri")
+	if redisURI == "" {
+		return cli.NewExitError("migrate-queue requires --redis-uri", 1)
+	}
+
+	redisPool := getRedisPool(redisURI, getRedisDialConfig(context.Parent()), context.GlobalBool("trace-redis"))
+	defer redisPool.Close()
+
+	keyPrefix := context.GlobalString("redis-key-prefix")
+	from := &deployer.DefaultKeyBuilder{Prefix: keyPrefix, QueueName: fromQueue}
+	to := &deployer.DefaultKeyBuilder{Prefix: keyPrefix, QueueName: toQueue}
+
+	migrated, err := deployer.MigrateQueue(redisPool, from, to, func(deploy string) {
+		fmt.Printf("moved %v\n", deploy)
+	})
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("Error migrating %v to %v after %v deploys: %v", fromQueue, toQueue, migrated, err), 1)
+	}
+
+	fmt.Printf("Moved %v deploys from %v to %v\n", migrated, fromQueue, toQueue)
+	return nil
+}
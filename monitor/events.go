@@ -0,0 +1,149 @@
+package monitor
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/docker/engine-api/client"
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/events"
+	"github.com/docker/engine-api/types/filters"
+	"github.com/octoblu/governator-swarm/metrics"
+	"golang.org/x/net/context"
+)
+
+// maxReconnectDelay caps the doubling of the reconnect delay
+const maxReconnectDelay = 60 * time.Second
+
+func init() {
+	metrics.DefaultRegistry.Help("governator_event_reconnects_total", "Times the docker event stream dropped and was reconnected")
+}
+
+// EventSubscriber reads the daemon's swarm service events
+// and routes them to the subscribers of each service
+type EventSubscriber struct {
+	dockerClient   client.APIClient
+	reconnectDelay time.Duration
+
+	lock        sync.Mutex
+	subscribers map[string][]chan events.Message
+}
+
+// NewEventSubscriber constructs an EventSubscriber that reconnects
+// reconnectDelay after the event stream drops, doubling the delay up
+// to a minute while the daemon can't be reached
+func NewEventSubscriber(dockerClient client.APIClient, reconnectDelay time.Duration) *EventSubscriber {
+	return &EventSubscriber{
+		dockerClient:   dockerClient,
+		reconnectDelay: reconnectDelay,
+		subscribers:    map[string][]chan events.Message{},
+	}
+}
+
+// Subscribe returns a channel of the events of the service, by ID
+// or name, and a function to stop them. Events are dropped when the
+// subscriber hasn't received the last one yet
+func (subscriber *EventSubscriber) Subscribe(service string) (<-chan events.Message, func()) {
+	channel := make(chan events.Message, 1)
+
+	subscriber.lock.Lock()
+	subscriber.subscribers[service] = append(subscriber.subscribers[service], channel)
+	subscriber.lock.Unlock()
+
+	unsubscribe := func() {
+		subscriber.lock.Lock()
+		defer subscriber.lock.Unlock()
+
+		channels := subscriber.subscribers[service]
+		for i, other := range channels {
+			if other == channel {
+				subscriber.subscribers[service] = append(channels[:i], channels[i+1:]...)
+				break
+			}
+		}
+		if len(subscriber.subscribers[service]) == 0 {
+			delete(subscriber.subscribers, service)
+		}
+	}
+	return channel, unsubscribe
+}
+
+// Run reads the event stream until ctx is done,
+// reconnecting when the stream drops, e.g. when the daemon restarts
+func (subscriber *EventSubscriber) Run(ctx context.Context) {
+	delay := subscriber.reconnectDelay
+	for {
+		connected, err := subscriber.readEvents(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if connected {
+			delay = subscriber.reconnectDelay
+		}
+		log.Println("WARNING: docker event stream dropped, reconnecting in", delay, err)
+		metrics.DefaultRecorder.IncrCounter("governator_event_reconnects_total", nil)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// readEvents routes the events until the stream drops. connected is
+// true when the stream was opened, so the reconnect delay starts over
+func (subscriber *EventSubscriber) readEvents(ctx context.Context) (bool, error) {
+	filter := filters.NewArgs()
+	filter.Add("scope", "swarm")
+	filter.Add("type", "service")
+
+	stream, err := subscriber.dockerClient.Events(ctx, types.EventsOptions{Filters: filter})
+	if err != nil {
+		return false, err
+	}
+	defer stream.Close()
+
+	decoder := json.NewDecoder(stream)
+	for {
+		var message events.Message
+		err = decoder.Decode(&message)
+		if err == io.EOF {
+			return true, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return true, err
+		}
+
+		debug("event %v %v %v", message.Type, message.Action, message.Actor.ID)
+		subscriber.route(message)
+	}
+}
+
+func (subscriber *EventSubscriber) route(message events.Message) {
+	subscriber.lock.Lock()
+	defer subscriber.lock.Unlock()
+
+	keys := []string{message.Actor.ID}
+	if name := message.Actor.Attributes["name"]; name != "" && name != message.Actor.ID {
+		keys = append(keys, name)
+	}
+
+	for _, key := range keys {
+		for _, channel := range subscriber.subscribers[key] {
+			select {
+			case channel <- message:
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package monitor_test
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	"github.com/docker/engine-api/types/events"
+	"github.com/docker/engine-api/types/swarm"
+	"github.com/octoblu/governator-swarm/metrics"
+	"github.com/octoblu/governator-swarm/monitor"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/context"
+)
+
+const serviceUpdateEvent = `{"Type":"service","Action":"update","Actor":{"ID":"service-id","Attributes":{"name":"my-application"}}}` + "\n"
+
+var _ = Describe("EventSubscriber", func() {
+	var dockerClient *fakeDockerClient
+	var sut *monitor.EventSubscriber
+	var ctx context.Context
+	var cancel context.CancelFunc
+
+	BeforeEach(func() {
+		dockerClient = &fakeDockerClient{}
+		sut = monitor.NewEventSubscriber(dockerClient, 10*time.Millisecond)
+		ctx, cancel = context.WithCancel(context.Background())
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	Describe("When a service event arrives", func() {
+		var byID, byName, other <-chan events.Message
+
+		BeforeEach(func() {
+			dockerClient.eventStreams = []string{serviceUpdateEvent}
+			byID, _ = sut.Subscribe("service-id")
+			byName, _ = sut.Subscribe("my-application")
+			other, _ = sut.Subscribe("other-service-id")
+			go sut.Run(ctx)
+		})
+
+		It("Should route it to the service's subscribers by ID and name", func() {
+			var message events.Message
+			Eventually(byID).Should(Receive(&message))
+			Expect(message.Action).To(Equal("update"))
+			Eventually(byName).Should(Receive())
+			Consistently(other, 30*time.Millisecond).ShouldNot(Receive())
+		})
+
+		It("Should only ask for swarm service events", func() {
+			Eventually(byID).Should(Receive())
+			Expect(dockerClient.eventsOptions().Filters.Get("scope")).To(Equal([]string{"swarm"}))
+			Expect(dockerClient.eventsOptions().Filters.Get("type")).To(Equal([]string{"service"}))
+		})
+	})
+
+	Describe("When the stream drops", func() {
+		BeforeEach(func() {
+			dockerClient.eventStreams = []string{"", serviceUpdateEvent}
+		})
+
+		It("Should reconnect", func() {
+			received, _ := sut.Subscribe("service-id")
+			go sut.Run(ctx)
+			Eventually(received).Should(Receive())
+			Expect(dockerClient.calls()).To(BeNumerically(">=", 2))
+		})
+
+		It("Should count the reconnect", func() {
+			reconnects := func() string {
+				var buffer bytes.Buffer
+				Expect(metrics.DefaultRegistry.WritePrometheus(&buffer)).To(Succeed())
+				for _, line := range strings.Split(buffer.String(), "\n") {
+					if strings.HasPrefix(line, "governator_event_reconnects_total ") {
+						return line
+					}
+				}
+				return ""
+			}
+			before := reconnects()
+
+			received, _ := sut.Subscribe("service-id")
+			go sut.Run(ctx)
+			Eventually(received).Should(Receive())
+			Expect(reconnects()).NotTo(Equal(before))
+		})
+	})
+
+	Describe("When used by a Monitor", func() {
+		It("Should list the tasks as soon as an event arrives", func() {
+			dockerClient.setTasks(task("new", swarm.TaskStateRunning, ""))
+			watcher := monitor.New(dockerClient, time.Hour)
+			watcher.UseEvents(sut)
+
+			taskEvents := make(chan monitor.TaskEvent, 1)
+			go watcher.WatchService(ctx, "service-id", taskEvents)
+			time.Sleep(20 * time.Millisecond)
+
+			dockerClient.setTasks(task("new", swarm.TaskStateFailed, "exit status 1"))
+			dockerClient.lock.Lock()
+			dockerClient.eventStreams = []string{serviceUpdateEvent}
+			dockerClient.lock.Unlock()
+			go sut.Run(ctx)
+
+			Eventually(taskEvents).Should(Receive())
+		})
+	})
+})
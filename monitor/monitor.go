@@ -0,0 +1,152 @@
+package monitor
+
+import (
+	"time"
+
+	"github.com/docker/engine-api/client"
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/events"
+	"github.com/docker/engine-api/types/filters"
+	"github.com/docker/engine-api/types/swarm"
+	De "github.com/tj/go-debug"
+	"golang.org/x/net/context"
+)
+
+var debug = De.Debug("governator:monitor")
+
+// TaskEvent is sent when a task of a watched service stops
+type TaskEvent struct {
+	TaskID      string
+	NodeID      string
+	ContainerID string
+	ExitCode    int
+	State       swarm.TaskState
+	Image       string
+	Error       string
+}
+
+// Monitor watches the tasks of swarm services
+type Monitor struct {
+	dockerClient client.APIClient
+	interval     time.Duration
+	events       *EventSubscriber
+}
+
+// New constructs a Monitor that lists tasks every interval
+func New(dockerClient client.APIClient, interval time.Duration) *Monitor {
+	return &Monitor{dockerClient: dockerClient, interval: interval}
+}
+
+// UseEvents lists a service's tasks as soon as subscriber sees an event
+// for the service, as well as every interval
+func (monitor *Monitor) UseEvents(subscriber *EventSubscriber) {
+	monitor.events = subscriber
+}
+
+// WatchService lists the service's tasks every interval and sends a
+// TaskEvent when a task moves to failed, shutdown or complete. Tasks
+// that had already stopped when the watch started are ignored.
+// It returns nil once ctx is done, or the error if listing tasks fails
+func (monitor *Monitor) WatchService(ctx context.Context, serviceID string, events chan<- TaskEvent) error {
+	filter := filters.NewArgs()
+	filter.Add("service", serviceID)
+
+	states, err := monitor.listTaskStates(ctx, filter)
+	if err != nil {
+		return ignoreDone(ctx, err)
+	}
+
+	ticker := time.NewTicker(monitor.interval)
+	defer ticker.Stop()
+
+	serviceEvents, unsubscribe := monitor.subscribe(serviceID)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-serviceEvents:
+			debug("event for %v, listing its tasks", serviceID)
+		}
+
+		tasks, err := monitor.dockerClient.TaskList(ctx, types.TaskListOptions{Filter: filter})
+		if err != nil {
+			return ignoreDone(ctx, err)
+		}
+		debug("tasks of %v: %v", serviceID, countStates(tasks))
+
+		for _, task := range tasks {
+			previous, seen := states[task.ID]
+			states[task.ID] = task.Status.State
+			if seen && previous == task.Status.State {
+				continue
+			}
+			if !isStopped(task.Status.State) {
+				continue
+			}
+
+			debug("task %v of %v is %v", task.ID, serviceID, task.Status.State)
+			event := TaskEvent{
+				TaskID:      task.ID,
+				NodeID:      task.NodeID,
+				ContainerID: task.Status.ContainerStatus.ContainerID,
+				ExitCode:    task.Status.ContainerStatus.ExitCode,
+				State:       task.Status.State,
+				Image:       task.Spec.ContainerSpec.Image,
+				Error:       task.Status.Err,
+			}
+
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
+
+// subscribe returns the service's events. Without an EventSubscriber
+// the channel is nil, which never receives, so only the ticker lists tasks
+func (monitor *Monitor) subscribe(serviceID string) (<-chan events.Message, func()) {
+	if monitor.events == nil {
+		return nil, func() {}
+	}
+	return monitor.events.Subscribe(serviceID)
+}
+
+func (monitor *Monitor) listTaskStates(ctx context.Context, filter filters.Args) (map[string]swarm.TaskState, error) {
+	tasks, err := monitor.dockerClient.TaskList(ctx, types.TaskListOptions{Filter: filter})
+	if err != nil {
+		return nil, err
+	}
+
+	states := map[string]swarm.TaskState{}
+	for _, task := range tasks {
+		states[task.ID] = task.Status.State
+	}
+	return states, nil
+}
+
+// countStates counts the tasks in each state, for logging
+func countStates(tasks []swarm.Task) map[swarm.TaskState]int {
+	counts := map[swarm.TaskState]int{}
+	for _, task := range tasks {
+		counts[task.Status.State]++
+	}
+	return counts
+}
+
+func isStopped(state swarm.TaskState) bool {
+	return state == swarm.TaskStateFailed || state == swarm.TaskStateShutdown || state == swarm.TaskStateComplete
+}
+
+// ignoreDone drops errors caused by ctx finishing,
+// which is how a watch is normally stopped
+func ignoreDone(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
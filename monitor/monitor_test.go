@@ -0,0 +1,178 @@
+package monitor_test
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/engine-api/client"
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/swarm"
+	"github.com/octoblu/governator-swarm/monitor"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Monitor", func() {
+	var dockerClient *fakeDockerClient
+	var sut *monitor.Monitor
+	var events chan monitor.TaskEvent
+	var ctx context.Context
+	var cancel context.CancelFunc
+	var watchErr chan error
+
+	BeforeEach(func() {
+		dockerClient = &fakeDockerClient{}
+		sut = monitor.New(dockerClient, 10*time.Millisecond)
+		events = make(chan monitor.TaskEvent, 10)
+		watchErr = make(chan error, 1)
+		ctx, cancel = context.WithCancel(context.Background())
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	watch := func() {
+		go func() {
+			watchErr <- sut.WatchService(ctx, "service-id", events)
+		}()
+	}
+
+	Describe("When a task fails", func() {
+		BeforeEach(func() {
+			dockerClient.setTasks(
+				task("old", swarm.TaskStateFailed, "already failed"),
+				task("new", swarm.TaskStateRunning, ""),
+			)
+			watch()
+			time.Sleep(30 * time.Millisecond)
+			dockerClient.setTasks(
+				task("old", swarm.TaskStateFailed, "already failed"),
+				task("new", swarm.TaskStateFailed, "exit status 1"),
+			)
+		})
+
+		It("Should send an event for the new failure only", func() {
+			var event monitor.TaskEvent
+			Eventually(events).Should(Receive(&event))
+			Expect(event.TaskID).To(Equal("new"))
+			Expect(event.State).To(Equal(swarm.TaskStateFailed))
+			Expect(event.Error).To(Equal("exit status 1"))
+			Consistently(events, 50*time.Millisecond).ShouldNot(Receive())
+		})
+
+		It("Should filter the tasks by service", func() {
+			Eventually(events).Should(Receive())
+			Expect(dockerClient.filter().Filter.Get("service")).To(Equal([]string{"service-id"}))
+		})
+	})
+
+	Describe("When a task is running", func() {
+		BeforeEach(func() {
+			dockerClient.setTasks(task("new", swarm.TaskStateRunning, ""))
+			watch()
+		})
+
+		It("Should not send events, and return nil when ctx is done", func() {
+			Consistently(events, 50*time.Millisecond).ShouldNot(Receive())
+			cancel()
+			Eventually(watchErr).Should(Receive(BeNil()))
+		})
+	})
+
+	Describe("When listing tasks fails", func() {
+		BeforeEach(func() {
+			dockerClient.err = fmt.Errorf("Cannot connect to the Docker daemon")
+			watch()
+		})
+
+		It("Should return the error", func() {
+			Eventually(watchErr).Should(Receive(MatchError("Cannot connect to the Docker daemon")))
+		})
+	})
+})
+
+func task(id string, state swarm.TaskState, err string) swarm.Task {
+	result := swarm.Task{ID: id}
+	result.Status.State = state
+	result.Status.Err = err
+	return result
+}
+
+type fakeDockerClient struct {
+	client.APIClient
+
+	lock       sync.Mutex
+	services   []swarm.Service
+	tasks      []swarm.Task
+	lastFilter types.TaskListOptions
+	err        error
+
+	// eventStreams are returned by successive calls to Events, once
+	// they're used up Events blocks until ctx is done
+	eventStreams []string
+	eventCalls   int
+	eventFilters types.EventsOptions
+}
+
+func (fake *fakeDockerClient) Events(ctx context.Context, options types.EventsOptions) (io.ReadCloser, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	fake.eventCalls++
+	fake.eventFilters = options
+
+	if len(fake.eventStreams) > 0 {
+		stream := fake.eventStreams[0]
+		fake.eventStreams = fake.eventStreams[1:]
+		return ioutil.NopCloser(strings.NewReader(stream)), nil
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		<-ctx.Done()
+		writer.CloseWithError(ctx.Err())
+	}()
+	return reader, nil
+}
+
+func (fake *fakeDockerClient) eventsOptions() types.EventsOptions {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return fake.eventFilters
+}
+
+func (fake *fakeDockerClient) calls() int {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return fake.eventCalls
+}
+
+func (fake *fakeDockerClient) setTasks(tasks ...swarm.Task) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	fake.tasks = tasks
+}
+
+func (fake *fakeDockerClient) filter() types.TaskListOptions {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return fake.lastFilter
+}
+
+func (fake *fakeDockerClient) ServiceList(ctx context.Context, options types.ServiceListOptions) ([]swarm.Service, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return fake.services, fake.err
+}
+
+func (fake *fakeDockerClient) TaskList(ctx context.Context, options types.TaskListOptions) ([]swarm.Task, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	fake.lastFilter = options
+	return fake.tasks, fake.err
+}
@@ -0,0 +1,134 @@
+package monitor
+
+import (
+	"time"
+
+	"github.com/docker/engine-api/client"
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/filters"
+	"github.com/docker/engine-api/types/swarm"
+	"github.com/octoblu/governator-swarm/metrics"
+	"golang.org/x/net/context"
+)
+
+// DefaultServiceWatchInterval is how often the ServiceWatcher
+// lists the services and their tasks
+const DefaultServiceWatchInterval = 30 * time.Second
+
+// stuckUpdateAge is how long a service may be updating
+// before the ServiceWatcher counts it as stuck
+const stuckUpdateAge = 30 * time.Minute
+
+func init() {
+	metrics.DefaultRegistry.Help("governator_task_restarts_total", "Tasks of a managed service that failed or were rejected, so swarm replaced them, by service")
+	metrics.DefaultRegistry.Help("governator_replica_deficit", "Desired replicas minus running tasks of a managed replicated service, by service")
+	metrics.DefaultRegistry.Help("governator_stuck_services", "Managed services that have been updating for over 30 minutes")
+}
+
+// ServiceWatcher records the task churn of every managed service,
+// whether or not it is being deployed
+type ServiceWatcher struct {
+	dockerClient client.APIClient
+	recorder     metrics.Recorder
+	labels       map[string]string
+	interval     time.Duration
+
+	// states are the tasks' last seen states, nil until the first Poll
+	states map[string]swarm.TaskState
+}
+
+// NewServiceWatcher constructs a ServiceWatcher for the services with
+// all of labels, or every service when there are none
+func NewServiceWatcher(dockerClient client.APIClient, recorder metrics.Recorder, labels map[string]string, interval time.Duration) *ServiceWatcher {
+	return &ServiceWatcher{dockerClient: dockerClient, recorder: recorder, labels: labels, interval: interval}
+}
+
+// Run polls every interval until ctx is done.
+// Failed polls are retried at the next interval
+func (watcher *ServiceWatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(watcher.interval)
+	defer ticker.Stop()
+
+	for {
+		err := watcher.Poll(ctx)
+		if err != nil && ctx.Err() == nil {
+			debug("service watcher: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll lists the services and their tasks once and records the metrics.
+// Restarts are only counted for tasks that failed after the first Poll
+func (watcher *ServiceWatcher) Poll(ctx context.Context) error {
+	serviceFilter := filters.NewArgs()
+	for key, value := range watcher.labels {
+		serviceFilter.Add("label", key+"="+value)
+	}
+
+	services, err := watcher.dockerClient.ServiceList(ctx, types.ServiceListOptions{Filter: serviceFilter})
+	if err != nil {
+		return err
+	}
+
+	tasks, err := watcher.dockerClient.TaskList(ctx, types.TaskListOptions{Filter: filters.NewArgs()})
+	if err != nil {
+		return err
+	}
+
+	tasksByService := map[string][]swarm.Task{}
+	for _, task := range tasks {
+		tasksByService[task.ServiceID] = append(tasksByService[task.ServiceID], task)
+	}
+
+	firstPoll := watcher.states == nil
+	states := map[string]swarm.TaskState{}
+	stuck := 0
+
+	for _, service := range services {
+		name := service.Spec.Name
+		running := 0
+
+		for _, task := range tasksByService[service.ID] {
+			states[task.ID] = task.Status.State
+			if task.Status.State == swarm.TaskStateRunning && task.DesiredState == swarm.TaskStateRunning {
+				running++
+			}
+
+			previous, seen := watcher.states[task.ID]
+			if firstPoll || (seen && previous == task.Status.State) || !isRestarted(task.Status.State) {
+				continue
+			}
+			debug("task %v of %v is %v", task.ID, name, task.Status.State)
+			watcher.recorder.IncrCounter("governator_task_restarts_total", map[string]string{"service": name})
+		}
+
+		if service.Spec.Mode.Replicated != nil && service.Spec.Mode.Replicated.Replicas != nil {
+			deficit := float64(*service.Spec.Mode.Replicated.Replicas) - float64(running)
+			watcher.recorder.SetGauge("governator_replica_deficit", deficit, map[string]string{"service": name})
+		}
+
+		if isStuck(service.UpdateStatus) {
+			stuck++
+		}
+	}
+
+	watcher.recorder.SetGauge("governator_stuck_services", float64(stuck), nil)
+	watcher.states = states
+	return nil
+}
+
+// isRestarted is true for the states of a task that
+// swarm replaces with a new one under its restart policy
+func isRestarted(state swarm.TaskState) bool {
+	return state == swarm.TaskStateFailed || state == swarm.TaskStateRejected
+}
+
+func isStuck(status swarm.UpdateStatus) bool {
+	return status.State == swarm.UpdateStateUpdating && time.Since(status.StartedAt) > stuckUpdateAge
+}
@@ -0,0 +1,87 @@
+package monitor_test
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/docker/engine-api/types/swarm"
+	"github.com/octoblu/governator-swarm/metrics"
+	"github.com/octoblu/governator-swarm/monitor"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("ServiceWatcher", func() {
+	var dockerClient *fakeDockerClient
+	var registry *metrics.Registry
+	var sut *monitor.ServiceWatcher
+
+	serviceTask := func(id, serviceID string, state swarm.TaskState) swarm.Task {
+		result := task(id, state, "")
+		result.ServiceID = serviceID
+		result.DesiredState = swarm.TaskStateRunning
+		return result
+	}
+
+	prometheus := func() string {
+		var buffer bytes.Buffer
+		Expect(registry.WritePrometheus(&buffer)).To(Succeed())
+		return buffer.String()
+	}
+
+	BeforeEach(func() {
+		replicas := uint64(3)
+		service := swarm.Service{ID: "service-id"}
+		service.Spec.Name = "my-application"
+		service.Spec.Mode.Replicated = &swarm.ReplicatedService{Replicas: &replicas}
+
+		stuck := swarm.Service{ID: "stuck-id"}
+		stuck.Spec.Name = "my-stuck-application"
+		stuck.UpdateStatus = swarm.UpdateStatus{State: swarm.UpdateStateUpdating, StartedAt: time.Now().Add(-time.Hour)}
+
+		dockerClient = &fakeDockerClient{services: []swarm.Service{service, stuck}}
+		dockerClient.setTasks(
+			serviceTask("old", "service-id", swarm.TaskStateFailed),
+			serviceTask("task-1", "service-id", swarm.TaskStateRunning),
+			serviceTask("task-2", "service-id", swarm.TaskStateRunning),
+		)
+		registry = metrics.NewRegistry()
+		sut = monitor.NewServiceWatcher(dockerClient, registry, nil, time.Minute)
+		Expect(sut.Poll(context.Background())).To(Succeed())
+	})
+
+	It("Should record the replica deficit", func() {
+		Expect(prometheus()).To(ContainSubstring(`governator_replica_deficit{service="my-application"} 1`))
+	})
+
+	It("Should count the services stuck updating", func() {
+		Expect(prometheus()).To(ContainSubstring("governator_stuck_services 1"))
+	})
+
+	It("Should not count tasks that had failed before the first poll", func() {
+		Expect(prometheus()).NotTo(ContainSubstring("governator_task_restarts_total"))
+	})
+
+	Describe("When a task fails and is replaced", func() {
+		BeforeEach(func() {
+			dockerClient.setTasks(
+				serviceTask("old", "service-id", swarm.TaskStateFailed),
+				serviceTask("task-1", "service-id", swarm.TaskStateFailed),
+				serviceTask("task-2", "service-id", swarm.TaskStateRunning),
+				serviceTask("task-3", "service-id", swarm.TaskStateRunning),
+				serviceTask("task-4", "service-id", swarm.TaskStateRunning),
+			)
+			Expect(sut.Poll(context.Background())).To(Succeed())
+			Expect(sut.Poll(context.Background())).To(Succeed())
+		})
+
+		It("Should count the restart once", func() {
+			Expect(prometheus()).To(ContainSubstring(`governator_task_restarts_total{service="my-application"} 1`))
+		})
+
+		It("Should clear the replica deficit", func() {
+			Expect(prometheus()).To(ContainSubstring(`governator_replica_deficit{service="my-application"} 0`))
+		})
+	})
+})
@@ -0,0 +1,37 @@
+package notifier
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Channels maps notification channel names to notifiers
+type Channels map[string]Notifier
+
+// LoadChannels reads a YAML file mapping channel names
+// to Microsoft Teams incoming webhook URLs, e.g.
+//
+//	payments-oncall: https://example.webhook.office.com/webhookb2/...
+func LoadChannels(path string) (Channels, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var webhookURLs map[string]string
+	err = yaml.Unmarshal(data, &webhookURLs)
+	if err != nil {
+		return nil, err
+	}
+
+	channels := Channels{}
+	for name, webhookURL := range webhookURLs {
+		if webhookURL == "" {
+			return nil, fmt.Errorf("channel '%v' has no webhook URL", name)
+		}
+		channels[name] = NewTeamsNotifier(webhookURL, false)
+	}
+	return channels, nil
+}
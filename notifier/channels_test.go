@@ -0,0 +1,45 @@
+package notifier_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/octoblu/governator-swarm/notifier"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LoadChannels", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "governator-channels")
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	write := func(content string) string {
+		path := filepath.Join(dir, "channels.yaml")
+		Expect(ioutil.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		return path
+	}
+
+	It("Should have a notifier for each channel", func() {
+		channels, err := notifier.LoadChannels(write("payments-oncall: https://teams.test/payments\nsearch-oncall: https://teams.test/search\n"))
+		Expect(err).To(BeNil())
+		Expect(channels).To(HaveKey("payments-oncall"))
+		Expect(channels).To(HaveKey("search-oncall"))
+	})
+
+	Describe("When a channel has no webhook URL", func() {
+		It("Should return an error", func() {
+			_, err := notifier.LoadChannels(write("payments-oncall:\n"))
+			Expect(err).NotTo(BeNil())
+		})
+	})
+})
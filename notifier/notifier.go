@@ -0,0 +1,32 @@
+package notifier
+
+import (
+	"time"
+
+	De "github.com/tj/go-debug"
+)
+
+var debug = De.Debug("governator:notifier")
+
+// Deployment describes a finished deploy
+type Deployment struct {
+	Service  string
+	Image    string
+	Cluster  string
+	Passed   bool
+	Error    string
+	Duration time.Duration
+
+	// URL is where the deploy can be looked up in deploy-state
+	URL string
+
+	// PagerDutyService and PagerDutySeverity are the
+	// deploy's RequestMetadata fields of the same names
+	PagerDutyService  string
+	PagerDutySeverity string
+}
+
+// Notifier tells someone about a finished deploy
+type Notifier interface {
+	Notify(deployment *Deployment) error
+}
@@ -0,0 +1,13 @@
+package notifier_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestNotifier(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Notifier Suite")
+}
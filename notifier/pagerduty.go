@@ -0,0 +1,113 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// PagerDutyEventsURL is the PagerDuty Events v2 API
+const PagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// DefaultPagerDutySeverity is the severity of the
+// triggered events, when the deploy doesn't set one
+const DefaultPagerDutySeverity = "warning"
+
+// pagerDutyTimeout is how long to wait for PagerDuty to respond
+const pagerDutyTimeout = 10 * time.Second
+
+// ValidatePagerDutySeverity returns an error unless severity
+// is empty or one of critical, error, warning or info
+func ValidatePagerDutySeverity(severity string) error {
+	switch severity {
+	case "", "critical", "error", "warning", "info":
+		return nil
+	}
+	return fmt.Errorf("unknown PagerDuty severity '%v', expected critical, error, warning or info", severity)
+}
+
+// PagerDutyNotifier triggers a PagerDuty incident when a deploy
+// that sets PagerDutyService fails, and resolves it when a later
+// deploy of the service succeeds. Other deploys are skipped
+type PagerDutyNotifier struct {
+	routingKey string
+	eventsURL  string
+	httpClient *http.Client
+}
+
+// NewPagerDutyNotifier constructs a PagerDutyNotifier sending
+// events for routingKey to eventsURL, e.g. PagerDutyEventsURL
+func NewPagerDutyNotifier(routingKey, eventsURL string) *PagerDutyNotifier {
+	return &PagerDutyNotifier{
+		routingKey: routingKey,
+		eventsURL:  eventsURL,
+		httpClient: &http.Client{Timeout: pagerDutyTimeout},
+	}
+}
+
+// Notify triggers or resolves the service's incident
+func (pagerDuty *PagerDutyNotifier) Notify(deployment *Deployment) error {
+	if deployment.PagerDutyService == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(pagerDuty.event(deployment))
+	if err != nil {
+		return err
+	}
+
+	response, err := pagerDuty.httpClient.Post(pagerDuty.eventsURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("pagerduty responded with %v", response.StatusCode)
+	}
+	return nil
+}
+
+// event is the trigger event for a failed deploy, or the resolve
+// event for a successful one. Both use the same dedup key, so the
+// resolve closes the incident the service's last failure opened
+func (pagerDuty *PagerDutyNotifier) event(deployment *Deployment) map[string]interface{} {
+	event := map[string]interface{}{
+		"routing_key":  pagerDuty.routingKey,
+		"event_action": "resolve",
+		"dedup_key":    fmt.Sprintf("governator-swarm/%v/%v", deployment.Cluster, deployment.Service),
+	}
+	if deployment.Passed {
+		debug("pagerduty: resolving %v", deployment.Service)
+		return event
+	}
+
+	severity := deployment.PagerDutySeverity
+	if severity == "" {
+		severity = DefaultPagerDutySeverity
+	}
+
+	event["event_action"] = "trigger"
+	event["payload"] = map[string]interface{}{
+		"summary":   fmt.Sprintf("Deploy of %v to %v failed: %v", deployment.Service, deployment.Cluster, deployment.Error),
+		"source":    deployment.Cluster,
+		"severity":  severity,
+		"component": deployment.Service,
+		"group":     deployment.PagerDutyService,
+		"custom_details": map[string]string{
+			"service": deployment.Service,
+			"image":   deployment.Image,
+			"cluster": deployment.Cluster,
+			"error":   deployment.Error,
+		},
+	}
+	if deployment.URL != "" {
+		event["links"] = []map[string]string{{"href": deployment.URL, "text": "Deploy history"}}
+	}
+	return event
+}
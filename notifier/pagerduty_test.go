@@ -0,0 +1,102 @@
+package notifier_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/octoblu/governator-swarm/notifier"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PagerDutyNotifier", func() {
+	var server *httptest.Server
+	var events []map[string]interface{}
+	var deployment *notifier.Deployment
+	var sut *notifier.PagerDutyNotifier
+
+	BeforeEach(func() {
+		events = nil
+		server = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			var event map[string]interface{}
+			json.NewDecoder(request.Body).Decode(&event)
+			events = append(events, event)
+			response.WriteHeader(http.StatusAccepted)
+		}))
+
+		deployment = &notifier.Deployment{
+			Service:          "my-application",
+			Image:            "octoblu/my-application:v2",
+			Cluster:          "super",
+			Passed:           false,
+			Error:            "task failed",
+			URL:              "https://deploy-state.test/deployments/octoblu/my-application/v2",
+			PagerDutyService: "payments",
+		}
+		sut = notifier.NewPagerDutyNotifier("routing-key", server.URL)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Should trigger an incident for a failed deploy", func() {
+		Expect(sut.Notify(deployment)).To(Succeed())
+		Expect(events).To(HaveLen(1))
+		Expect(events[0]["routing_key"]).To(Equal("routing-key"))
+		Expect(events[0]["event_action"]).To(Equal("trigger"))
+		Expect(events[0]["dedup_key"]).To(Equal("governator-swarm/super/my-application"))
+
+		payload := events[0]["payload"].(map[string]interface{})
+		Expect(payload["severity"]).To(Equal("warning"))
+		Expect(payload["summary"]).To(Equal("Deploy of my-application to super failed: task failed"))
+		Expect(payload["custom_details"]).To(HaveKeyWithValue("image", "octoblu/my-application:v2"))
+
+		link := events[0]["links"].([]interface{})[0].(map[string]interface{})
+		Expect(link["href"]).To(Equal("https://deploy-state.test/deployments/octoblu/my-application/v2"))
+	})
+
+	It("Should use the deploy's severity", func() {
+		deployment.PagerDutySeverity = "critical"
+		Expect(sut.Notify(deployment)).To(Succeed())
+		Expect(events[0]["payload"]).To(HaveKeyWithValue("severity", "critical"))
+	})
+
+	It("Should resolve the incident when a deploy succeeds", func() {
+		deployment.Passed = true
+		deployment.Error = ""
+		Expect(sut.Notify(deployment)).To(Succeed())
+		Expect(events[0]["event_action"]).To(Equal("resolve"))
+		Expect(events[0]["dedup_key"]).To(Equal("governator-swarm/super/my-application"))
+		Expect(events[0]).NotTo(HaveKey("payload"))
+	})
+
+	Describe("When the deploy doesn't set pagerDutyService", func() {
+		It("Should skip it", func() {
+			deployment.PagerDutyService = ""
+			Expect(sut.Notify(deployment)).To(Succeed())
+			Expect(events).To(BeEmpty())
+		})
+	})
+
+	Describe("When PagerDuty rejects the event", func() {
+		It("Should return an error", func() {
+			server.Config.Handler = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				response.WriteHeader(http.StatusBadRequest)
+			})
+			Expect(sut.Notify(deployment)).NotTo(Succeed())
+		})
+	})
+
+	Describe("ValidatePagerDutySeverity", func() {
+		It("Should accept the Events v2 severities", func() {
+			Expect(notifier.ValidatePagerDutySeverity("")).To(Succeed())
+			Expect(notifier.ValidatePagerDutySeverity("error")).To(Succeed())
+		})
+
+		It("Should reject anything else", func() {
+			Expect(notifier.ValidatePagerDutySeverity("sev1")).NotTo(Succeed())
+		})
+	})
+})
@@ -0,0 +1,112 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// teamsTimeout is how long to wait for the teams webhook to respond
+const teamsTimeout = 10 * time.Second
+
+// TeamsNotifier posts deploys as Adaptive Cards
+// to a Microsoft Teams incoming webhook
+type TeamsNotifier struct {
+	webhookURL    string
+	onFailureOnly bool
+	httpClient    *http.Client
+}
+
+// NewTeamsNotifier constructs a TeamsNotifier for webhookURL.
+// With onFailureOnly, successful deploys aren't posted
+func NewTeamsNotifier(webhookURL string, onFailureOnly bool) *TeamsNotifier {
+	return &TeamsNotifier{
+		webhookURL:    webhookURL,
+		onFailureOnly: onFailureOnly,
+		httpClient:    &http.Client{Timeout: teamsTimeout},
+	}
+}
+
+// Notify posts the deployment to the webhook
+func (teams *TeamsNotifier) Notify(deployment *Deployment) error {
+	if teams.onFailureOnly && deployment.Passed {
+		debug("teams: skipping %v, only notifying on failure", deployment.Service)
+		return nil
+	}
+
+	body, err := json.Marshal(teamsMessage(deployment))
+	if err != nil {
+		return err
+	}
+
+	response, err := teams.httpClient.Post(teams.webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("teams webhook responded with %v", response.StatusCode)
+	}
+	return nil
+}
+
+// teamsMessage wraps the deployment's Adaptive Card in
+// the message an incoming webhook expects
+func teamsMessage(deployment *Deployment) map[string]interface{} {
+	status, color := "passed", "Good"
+	if !deployment.Passed {
+		status, color = "failed", "Attention"
+	}
+
+	facts := []map[string]string{
+		{"title": "Service", "value": deployment.Service},
+		{"title": "Image", "value": deployment.Image},
+		{"title": "Cluster", "value": deployment.Cluster},
+		{"title": "Status", "value": status},
+		{"title": "Duration", "value": deployment.Duration.String()},
+	}
+	if deployment.Error != "" {
+		facts = append(facts, map[string]string{"title": "Error", "value": deployment.Error})
+	}
+
+	card := map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.2",
+		"body": []interface{}{
+			map[string]interface{}{
+				"type":   "TextBlock",
+				"text":   fmt.Sprintf("Deploy of %v %v", deployment.Service, status),
+				"weight": "Bolder",
+				"size":   "Medium",
+				"color":  color,
+				"wrap":   true,
+			},
+			map[string]interface{}{
+				"type":  "FactSet",
+				"facts": facts,
+			},
+		},
+	}
+	if deployment.URL != "" {
+		card["actions"] = []interface{}{
+			map[string]string{"type": "Action.OpenUrl", "title": "View in deploy-state", "url": deployment.URL},
+		}
+	}
+
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []interface{}{
+			map[string]interface{}{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content":     card,
+			},
+		},
+	}
+}
@@ -0,0 +1,91 @@
+package notifier_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/octoblu/governator-swarm/notifier"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TeamsNotifier", func() {
+	var server *httptest.Server
+	var messages []map[string]interface{}
+	var deployment *notifier.Deployment
+
+	BeforeEach(func() {
+		messages = nil
+		server = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			var message map[string]interface{}
+			json.NewDecoder(request.Body).Decode(&message)
+			messages = append(messages, message)
+			response.WriteHeader(http.StatusOK)
+		}))
+
+		deployment = &notifier.Deployment{
+			Service:  "my-application",
+			Image:    "octoblu/my-application:v2",
+			Cluster:  "super",
+			Passed:   true,
+			Duration: 30 * time.Second,
+			URL:      "https://deploy-state.test/deployments/octoblu/my-application/v2",
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	card := func(message map[string]interface{}) map[string]interface{} {
+		attachment := message["attachments"].([]interface{})[0].(map[string]interface{})
+		Expect(attachment["contentType"]).To(Equal("application/vnd.microsoft.card.adaptive"))
+		return attachment["content"].(map[string]interface{})
+	}
+
+	It("Should post the deploy as an Adaptive Card", func() {
+		err := notifier.NewTeamsNotifier(server.URL, false).Notify(deployment)
+		Expect(err).To(BeNil())
+		Expect(messages).To(HaveLen(1))
+
+		content := card(messages[0])
+		title := content["body"].([]interface{})[0].(map[string]interface{})
+		Expect(title["text"]).To(Equal("Deploy of my-application passed"))
+		Expect(title["color"]).To(Equal("Good"))
+
+		action := content["actions"].([]interface{})[0].(map[string]interface{})
+		Expect(action["url"]).To(Equal("https://deploy-state.test/deployments/octoblu/my-application/v2"))
+	})
+
+	It("Should color failed deploys", func() {
+		deployment.Passed = false
+		deployment.Error = "task failed"
+
+		err := notifier.NewTeamsNotifier(server.URL, false).Notify(deployment)
+		Expect(err).To(BeNil())
+
+		title := card(messages[0])["body"].([]interface{})[0].(map[string]interface{})
+		Expect(title["color"]).To(Equal("Attention"))
+	})
+
+	Describe("When only notifying on failure", func() {
+		It("Should skip successful deploys", func() {
+			err := notifier.NewTeamsNotifier(server.URL, true).Notify(deployment)
+			Expect(err).To(BeNil())
+			Expect(messages).To(BeEmpty())
+		})
+	})
+
+	Describe("When the webhook fails", func() {
+		It("Should return an error", func() {
+			server.Config.Handler = http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				response.WriteHeader(http.StatusBadRequest)
+			})
+
+			err := notifier.NewTeamsNotifier(server.URL, false).Notify(deployment)
+			Expect(err).NotTo(BeNil())
+		})
+	})
+})
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/codegangsta/cli"
+)
+
+// outputFlags are the flags of subcommands
+// that print in either of the output formats
+func outputFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "output-format, output",
+			Usage: "Output format, table or json",
+			Value: "table",
+		},
+		cli.BoolFlag{
+			Name:  "no-headers",
+			Usage: "Don't print the header row in table output",
+		},
+	}
+}
+
+// getOutputFormat returns the --output-format,
+// or an exit error when it isn't table or json
+func getOutputFormat(context *cli.Context) (string, error) {
+	format := context.String("output-format")
+	if format != "table" && format != "json" {
+		return "", cli.NewExitError(fmt.Sprintf("Invalid --output-format '%v', expected table or json", format), 2)
+	}
+	return format, nil
+}
+
+func printJSON(value interface{}) error {
+	return json.NewEncoder(os.Stdout).Encode(value)
+}
+
+// newTableWriter returns a tabwriter on writer that has
+// already written header, unless --no-headers was given
+func newTableWriter(context *cli.Context, writer io.Writer, header ...string) *tabwriter.Writer {
+	tableWriter := tabwriter.NewWriter(writer, 0, 8, 2, ' ', 0)
+	if !context.Bool("no-headers") {
+		fmt.Fprintln(tableWriter, strings.Join(header, "\t"))
+	}
+	return tableWriter
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/fatih/color"
+)
+
+// servePprof serves the runtime profiles under /debug/pprof/ on
+// their own mux, so they are never reachable from the --api-addr
+func servePprof(pprofAddr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	color.Yellow("WARNING: pprof is enabled on %v, it exposes the process's memory and command line. Don't expose it publicly", pprofAddr)
+	err := http.ListenAndServe(pprofAddr, mux)
+	log.Panicln("Error serving pprof", err.Error())
+}
+
+// checkPprofAddr refuses a --pprof-addr that shares a port with
+// another of the daemon's listeners
+func checkPprofAddr(pprofAddr string, otherAddrs map[string]string) error {
+	_, pprofPort, err := net.SplitHostPort(pprofAddr)
+	if err != nil {
+		return fmt.Errorf("invalid --pprof-addr %v: %v", pprofAddr, err)
+	}
+
+	for flag, addr := range otherAddrs {
+		if addr == "" {
+			continue
+		}
+
+		_, port, err := net.SplitHostPort(addr)
+		if err == nil && port == pprofPort {
+			return fmt.Errorf("--pprof-addr %v uses the same port as --%v %v", pprofAddr, flag, addr)
+		}
+	}
+	return nil
+}
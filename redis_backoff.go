@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/garyburd/redigo/redis"
+	"github.com/octoblu/governator-swarm/metrics"
+)
+
+func init() {
+	metrics.DefaultRegistry.Help("governator_redis_reconnect_total", "Redis connections made after dialing failed at first")
+	metrics.DefaultRegistry.Help("governator_redis_reconnect_duration_seconds", "Time from the first failed dial to the reconnect")
+}
+
+// redisBackoff is how long to wait between redis dials
+// that fail, from initial, growing by factor up to max
+type redisBackoff struct {
+	initial time.Duration
+	max     time.Duration
+	factor  float64
+}
+
+// parseRedisBackoff parses initial:max:factor, e.g. 100ms:30s:2.0
+func parseRedisBackoff(value string) (*redisBackoff, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("'%v' should be initial:max:factor", value)
+	}
+
+	initial, err := time.ParseDuration(parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	max, err := time.ParseDuration(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	factor, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil {
+		return nil, err
+	}
+
+	if initial <= 0 || max < initial || factor <= 1 {
+		return nil, fmt.Errorf("'%v' should have 0 < initial <= max and factor > 1", value)
+	}
+	return &redisBackoff{initial: initial, max: max, factor: factor}, nil
+}
+
+// exponentialBackOff waits from initial, growing by factor up to max
+// with the library's default jitter, and stops once it has been
+// retrying for max. It isn't safe to share, so each Dial gets its own
+func (config *redisBackoff) exponentialBackOff() *backoff.ExponentialBackOff {
+	exponential := backoff.NewExponentialBackOff()
+	exponential.InitialInterval = config.initial
+	exponential.MaxInterval = config.max
+	exponential.Multiplier = config.factor
+	exponential.MaxElapsedTime = config.max
+	exponential.Reset()
+	return exponential
+}
+
+// backoffDialer retries dial with exponential backoff
+type backoffDialer struct {
+	dial    func() (redis.Conn, error)
+	backoff *redisBackoff
+}
+
+func newBackoffDialer(dial func() (redis.Conn, error), backoff *redisBackoff) *backoffDialer {
+	return &backoffDialer{dial: dial, backoff: backoff}
+}
+
+// Dial is the pool's Dial
+func (dialer *backoffDialer) Dial() (redis.Conn, error) {
+	var redisConn redis.Conn
+	start := time.Now()
+	retried := false
+
+	err := backoff.RetryNotify(func() error {
+		var err error
+		redisConn, err = dialer.dial()
+		return err
+	}, dialer.backoff.exponentialBackOff(), func(err error, delay time.Duration) {
+		debug("redis dial failed, retrying in %v: %v", delay, err)
+		retried = true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if retried {
+		metrics.DefaultRecorder.IncrCounter("governator_redis_reconnect_total", nil)
+		metrics.DefaultRecorder.ObserveHistogram("governator_redis_reconnect_duration_seconds", time.Since(start).Seconds(), nil)
+	}
+	return redisConn, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/rafaeljusto/redigomock"
+)
+
+var _ = Describe("redisBackoff", func() {
+	Describe("parseRedisBackoff", func() {
+		It("Should parse initial:max:factor", func() {
+			backoff, err := parseRedisBackoff("100ms:30s:2.0")
+			Expect(err).To(BeNil())
+			Expect(backoff).To(Equal(&redisBackoff{initial: 100 * time.Millisecond, max: 30 * time.Second, factor: 2}))
+		})
+
+		table.DescribeTable("Should refuse invalid backoffs",
+			func(value string) {
+				_, err := parseRedisBackoff(value)
+				Expect(err).To(HaveOccurred())
+			},
+			table.Entry("missing the factor", "100ms:30s"),
+			table.Entry("an invalid duration", "soon:30s:2"),
+			table.Entry("max below initial", "30s:100ms:2"),
+			table.Entry("a factor that doesn't grow", "100ms:30s:1"),
+		)
+	})
+
+	Describe("backoffDialer", func() {
+		var dials int
+		var failures int
+
+		BeforeEach(func() {
+			dials = 0
+		})
+
+		dial := func() (redis.Conn, error) {
+			dials++
+			if dials <= failures {
+				return nil, errors.New("connection refused")
+			}
+			return redigomock.NewConn(), nil
+		}
+
+		Describe("When the dial fails a couple of times", func() {
+			BeforeEach(func() {
+				failures = 2
+			})
+
+			It("Should retry until it connects", func() {
+				redisConn, err := newBackoffDialer(dial, &redisBackoff{initial: time.Millisecond, max: time.Second, factor: 2}).Dial()
+				Expect(err).To(BeNil())
+				Expect(redisConn).NotTo(BeNil())
+				Expect(dials).To(Equal(3))
+			})
+		})
+
+		Describe("When the dial keeps failing", func() {
+			BeforeEach(func() {
+				failures = 1000
+			})
+
+			It("Should give up once it has been retrying for max", func() {
+				start := time.Now()
+				_, err := newBackoffDialer(dial, &redisBackoff{initial: time.Millisecond, max: 50 * time.Millisecond, factor: 2}).Dial()
+				Expect(err).To(MatchError("connection refused"))
+				Expect(dials).To(BeNumerically(">", 1))
+				Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+			})
+		})
+	})
+})
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// redisDialConfig holds the connection settings
+// that can't be expressed in a redis:// URI
+type redisDialConfig struct {
+	username string
+	password string
+
+	tls     bool
+	tlsCA   string
+	tlsCert string
+	tlsKey  string
+
+	db int
+
+	// backoff retries failed dials, nil dials once
+	backoff *redisBackoff
+}
+
+// dialOptions returns the options for redis.DialURL. The
+// connection is wrapped in TLS when configured, and AUTH is
+// sent before DialURL gets the connection, so it happens
+// before the SELECT for the URI's database
+func (config *redisDialConfig) dialOptions() ([]redis.DialOption, error) {
+	if config.username != "" && config.password == "" {
+		return nil, fmt.Errorf("--redis-username requires --redis-password")
+	}
+
+	if !config.tls && config.password == "" {
+		return nil, nil
+	}
+
+	var tlsConfig *tls.Config
+	if config.tls {
+		var err error
+		tlsConfig, err = config.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dial := func(network, address string) (net.Conn, error) {
+		var netConn net.Conn
+		var err error
+		if tlsConfig != nil {
+			netConn, err = tls.Dial(network, address, tlsConfig)
+		} else {
+			netConn, err = net.Dial(network, address)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		err = config.auth(netConn)
+		if err != nil {
+			netConn.Close()
+			return nil, err
+		}
+		return netConn, nil
+	}
+
+	return []redis.DialOption{redis.DialNetDial(dial)}, nil
+}
+
+// selectDB sends SELECT when --redis-db is set, overriding
+// the database in the redis:// URI. The pool calls it for
+// every new connection, after AUTH
+func (config *redisDialConfig) selectDB(redisConn redis.Conn) error {
+	if config.db <= 0 {
+		return nil
+	}
+
+	_, err := redisConn.Do("SELECT", config.db)
+	return err
+}
+
+// database returns the database the pool's connections use,
+// --redis-db when set, otherwise the one in the redis:// URI
+func (config *redisDialConfig) database(redisURI string) int {
+	if config.db > 0 {
+		return config.db
+	}
+
+	parsedURI, err := url.Parse(redisURI)
+	if err != nil {
+		return 0
+	}
+
+	db, err := strconv.Atoi(strings.TrimPrefix(parsedURI.Path, "/"))
+	if err != nil {
+		return 0
+	}
+	return db
+}
+
+// auth sends AUTH username password (redis 6 ACL) when
+// there is a username, and AUTH password otherwise
+func (config *redisDialConfig) auth(netConn net.Conn) error {
+	if config.password == "" {
+		return nil
+	}
+
+	args := []interface{}{config.password}
+	if config.username != "" {
+		args = []interface{}{config.username, config.password}
+	}
+
+	_, err := redis.NewConn(netConn, 0, 0).Do("AUTH", args...)
+	return err
+}
+
+func (config *redisDialConfig) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+
+	if config.tlsCA != "" {
+		caBytes, err := ioutil.ReadFile(config.tlsCA)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("no certificates found in %v", config.tlsCA)
+		}
+	}
+
+	if config.tlsCert != "" || config.tlsKey != "" {
+		if config.tlsCert == "" || config.tlsKey == "" {
+			return nil, fmt.Errorf("--redis-tls-cert and --redis-tls-key must be used together")
+		}
+
+		certificate, err := tls.LoadX509KeyPair(config.tlsCert, config.tlsKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return tlsConfig, nil
+}
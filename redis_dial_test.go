@@ -0,0 +1,34 @@
+package main
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("redisDialConfig", func() {
+	Describe("dialOptions", func() {
+		It("Should not need options without TLS or a password", func() {
+			options, err := (&redisDialConfig{}).dialOptions()
+			Expect(err).To(BeNil())
+			Expect(options).To(BeNil())
+		})
+
+		It("Should dial with AUTH when there is a password", func() {
+			options, err := (&redisDialConfig{username: "governator", password: "secret"}).dialOptions()
+			Expect(err).To(BeNil())
+			Expect(options).To(HaveLen(1))
+		})
+
+		Describe("When there is a username without a password", func() {
+			It("Should return an error", func() {
+				_, err := (&redisDialConfig{username: "governator"}).dialOptions()
+				Expect(err).To(MatchError("--redis-username requires --redis-password"))
+			})
+
+			It("Should return an error with TLS too", func() {
+				_, err := (&redisDialConfig{username: "governator", tls: true}).dialOptions()
+				Expect(err).To(MatchError("--redis-username requires --redis-password"))
+			})
+		})
+	})
+})
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/octoblu/governator-swarm/deployer"
+	De "github.com/tj/go-debug"
+)
+
+var traceRedis = De.Debug("governator-swarm:redis")
+
+// tracingConn logs every command sent to redis and every reply. The
+// arguments of AUTH are hidden, and JSON values, like the deploy
+// metadata, are logged with the values of sensitive keys redacted
+type tracingConn struct {
+	redis.Conn
+}
+
+func newTracingConn(conn redis.Conn) redis.Conn {
+	return &tracingConn{Conn: conn}
+}
+
+func (conn *tracingConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	traceRedis("SEND: %s", formatCommand(commandName, args))
+	reply, err := conn.Conn.Do(commandName, args...)
+	traceReply(reply, err)
+	return reply, err
+}
+
+func (conn *tracingConn) Send(commandName string, args ...interface{}) error {
+	traceRedis("SEND: %s", formatCommand(commandName, args))
+	return conn.Conn.Send(commandName, args...)
+}
+
+func (conn *tracingConn) Receive() (interface{}, error) {
+	reply, err := conn.Conn.Receive()
+	traceReply(reply, err)
+	return reply, err
+}
+
+func traceReply(reply interface{}, err error) {
+	if err != nil {
+		traceRedis("RECV: error %v", err)
+		return
+	}
+	traceRedis("RECV: %s", formatReply(reply))
+}
+
+func formatCommand(commandName string, args []interface{}) string {
+	parts := []string{commandName}
+	for _, arg := range args {
+		if strings.EqualFold(commandName, "AUTH") {
+			parts = append(parts, "***")
+			continue
+		}
+		parts = append(parts, formatReply(arg))
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatReply(reply interface{}) string {
+	switch reply := reply.(type) {
+	case nil:
+		return "(nil)"
+	case []byte:
+		return formatValue(reply)
+	case string:
+		return formatValue([]byte(reply))
+	case []interface{}:
+		parts := make([]string, len(reply))
+		for i, item := range reply {
+			parts[i] = formatReply(item)
+		}
+		return fmt.Sprintf("[%s]", strings.Join(parts, " "))
+	default:
+		return fmt.Sprintf("%v", reply)
+	}
+}
+
+func formatValue(value []byte) string {
+	sanitized, ok := deployer.SanitizeJSON(value)
+	if ok {
+		return fmt.Sprintf("%q", sanitized)
+	}
+	return fmt.Sprintf("%q", value)
+}
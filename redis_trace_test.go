@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"os"
+
+	"github.com/garyburd/redigo/redis"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rafaeljusto/redigomock"
+	De "github.com/tj/go-debug"
+)
+
+var _ = Describe("tracingConn", func() {
+	var output *bytes.Buffer
+	var redisConn *redigomock.Conn
+	var sut redis.Conn
+
+	BeforeEach(func() {
+		output = &bytes.Buffer{}
+		De.SetWriter(output)
+		De.Enable("governator-swarm:redis")
+
+		redisConn = redigomock.NewConn()
+		sut = newTracingConn(redisConn)
+	})
+
+	AfterEach(func() {
+		De.Disable()
+		De.SetWriter(os.Stderr)
+	})
+
+	It("Should log the commands and replies", func() {
+		redisConn.Command("GET", "redis-queue:name:my-application:v2").Expect([]byte("value"))
+		_, err := sut.Do("GET", "redis-queue:name:my-application:v2")
+		Expect(err).To(BeNil())
+		Expect(output.String()).To(ContainSubstring(`SEND: GET "redis-queue:name:my-application:v2"`))
+		Expect(output.String()).To(ContainSubstring(`RECV: "value"`))
+	})
+
+	It("Should redact the username and password of AUTH", func() {
+		redisConn.Command("AUTH", "deployer-user", "hunter2").Expect("OK")
+		_, err := sut.Do("AUTH", "deployer-user", "hunter2")
+		Expect(err).To(BeNil())
+		Expect(output.String()).To(ContainSubstring("SEND: AUTH *** ***"))
+		Expect(output.String()).NotTo(ContainSubstring("deployer-user"))
+		Expect(output.String()).NotTo(ContainSubstring("hunter2"))
+	})
+
+	It("Should redact AUTH sent in a pipeline, whatever its case", func() {
+		Expect(sut.Send("auth", "hunter2")).To(Succeed())
+		Expect(output.String()).To(ContainSubstring("SEND: auth ***"))
+		Expect(output.String()).NotTo(ContainSubstring("hunter2"))
+	})
+
+	Describe("When the reply is deploy metadata", func() {
+		metadata := `{"dockerUrl":"octoblu/my-application:v2","envOverrides":{"API_TOKEN":"s3cr3t","LOG_LEVEL":"debug"}}`
+
+		It("Should redact the sensitive env overrides of HGET", func() {
+			redisConn.Command("HGET", "redis-queue:name:my-application:v2", "request:metadata").Expect([]byte(metadata))
+			_, err := sut.Do("HGET", "redis-queue:name:my-application:v2", "request:metadata")
+			Expect(err).To(BeNil())
+			Expect(output.String()).To(ContainSubstring(`\"API_TOKEN\":\"***REDACTED***\"`))
+			Expect(output.String()).To(ContainSubstring(`\"LOG_LEVEL\":\"debug\"`))
+			Expect(output.String()).NotTo(ContainSubstring("s3cr3t"))
+		})
+
+		It("Should redact them in the values of HGETALL", func() {
+			redisConn.Command("HGETALL", "redis-queue:name:my-application:v2").Expect([]interface{}{[]byte("request:metadata"), []byte(metadata)})
+			_, err := sut.Do("HGETALL", "redis-queue:name:my-application:v2")
+			Expect(err).To(BeNil())
+			Expect(output.String()).To(ContainSubstring(`"request:metadata"`))
+			Expect(output.String()).NotTo(ContainSubstring("s3cr3t"))
+		})
+
+		It("Should redact them when HSET writes it", func() {
+			redisConn.Command("HSET", "redis-queue:name:my-application:v2", "request:metadata", metadata).Expect(int64(1))
+			_, err := sut.Do("HSET", "redis-queue:name:my-application:v2", "request:metadata", metadata)
+			Expect(err).To(BeNil())
+			Expect(output.String()).To(ContainSubstring("SEND: HSET"))
+			Expect(output.String()).NotTo(ContainSubstring("s3cr3t"))
+		})
+	})
+})
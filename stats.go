@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/octoblu/governator-swarm/deployer"
+)
+
+func statsCommand() cli.Command {
+	return cli.Command{
+		Name:   "stats",
+		Usage:  "Show deploys per day, success rate, MTTR and P95 duration per service, from the deploy history in redis",
+		Action: stats,
+		Flags: append(outputFlags(),
+			cli.StringFlag{
+				Name:  "window",
+				Usage: "How far back to look, a duration like 36h, or days like 7d",
+				Value: "7d",
+			},
+		),
+	}
+}
+
+func stats(context *cli.Context) error {
+	window, err := parseWindow(context.String("window"))
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("Invalid --window '%v', expected a duration like 36h or days like 7d", context.String("window")), 2)
+	}
+
+	output, err := getOutputFormat(context)
+	if err != nil {
+		return err
+	}
+
+	redisURI := context.GlobalString("redis-uri")
+	redisQueue := context.GlobalString("redis-queue")
+	if redisURI == "" || redisQueue == "" {
+		return cli.NewExitError("stats requires --redis-uri and --redis-queue", 1)
+	}
+
+	redisPool := getRedisPool(redisURI, getRedisDialConfig(context.Parent()), context.GlobalBool("trace-redis"))
+	defer redisPool.Close()
+
+	keys := &deployer.DefaultKeyBuilder{Prefix: context.GlobalString("redis-key-prefix"), QueueName: redisQueue}
+	records, err := deployer.ReadHistory(redisPool, keys)
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("Error reading the deploy history: %v", err), 1)
+	}
+
+	allStats := deployer.ComputeStats(records, window, time.Now())
+	if output == "json" {
+		return printJSON(allStats)
+	}
+
+	writer := newTableWriter(context, os.Stdout, "SERVICE", "DEPLOYS", "PER DAY", "SUCCESS", "MTTR", "P95 DURATION")
+	for _, serviceStats := range allStats {
+		mttr := "-"
+		if serviceStats.Recoveries > 0 {
+			mttr = formatSeconds(serviceStats.MTTR)
+		}
+		fmt.Fprintf(writer, "%s\t%d\t%.1f\t%.0f%%\t%s\t%s\n", serviceStats.Service, serviceStats.Deploys, serviceStats.DeploysPerDay, serviceStats.SuccessRate*100, mttr, formatSeconds(serviceStats.P95Duration))
+	}
+	return writer.Flush()
+}
+
+// parseWindow parses a time.Duration, or a whole number of days like 7d
+func parseWindow(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil || days < 1 {
+			return 0, fmt.Errorf("invalid days %v", value)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	window, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if window <= 0 {
+		return 0, fmt.Errorf("window must be positive")
+	}
+	return window, nil
+}
+
+// formatSeconds formats seconds as a duration in whole seconds, like 1h2m3s
+func formatSeconds(seconds float64) string {
+	return (time.Duration(seconds) * time.Second).String()
+}
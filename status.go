@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/fatih/color"
+	"github.com/octoblu/governator-swarm/deployer"
+)
+
+const clearScreen = "\033[H\033[2J"
+
+func statusCommand() cli.Command {
+	return cli.Command{
+		Name:   "status",
+		Usage:  "Show the health, queue depth and recent deploys of the running daemon, from its --api-addr",
+		Action: status,
+		Flags: append(outputFlags(),
+			cli.BoolFlag{
+				Name:  "watch",
+				Usage: "Refresh the status every 2 seconds, json output prints a line each time",
+			},
+		),
+	}
+}
+
+func status(context *cli.Context) error {
+	apiAddr := context.GlobalString("api-addr")
+	if apiAddr == "" {
+		return cli.NewExitError("status requires the daemon's --api-addr", 1)
+	}
+
+	output, err := getOutputFormat(context)
+	if err != nil {
+		return err
+	}
+
+	statusURL := getStatusURL(apiAddr)
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+
+	if !context.Bool("watch") {
+		theStatus, err := getStatus(httpClient, statusURL)
+		if err != nil {
+			return cli.NewExitError(fmt.Sprintf("Error getting status from %v: %v", statusURL, err), 1)
+		}
+		if output == "json" {
+			return printJSON(theStatus)
+		}
+		printStatus(context, theStatus)
+		return nil
+	}
+
+	for {
+		theStatus, err := getStatus(httpClient, statusURL)
+		switch {
+		case output == "json" && err == nil:
+			printJSON(theStatus)
+		case output == "json":
+			color.Red("Error getting status from %v: %v", statusURL, err)
+		case err != nil:
+			fmt.Print(clearScreen)
+			color.Red("Error getting status from %v: %v", statusURL, err)
+		default:
+			fmt.Print(clearScreen)
+			printStatus(context, theStatus)
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// getStatusURL turns an --api-addr like :8080 into a URL to /status
+func getStatusURL(apiAddr string) string {
+	return getAPIURL(apiAddr, "/status")
+}
+
+// getAPIURL turns an --api-addr like :8080 into a URL to path
+func getAPIURL(apiAddr, path string) string {
+	if strings.HasPrefix(apiAddr, "http://") || strings.HasPrefix(apiAddr, "https://") {
+		return strings.TrimSuffix(apiAddr, "/") + path
+	}
+	if strings.HasPrefix(apiAddr, ":") {
+		apiAddr = "localhost" + apiAddr
+	}
+	return fmt.Sprintf("http://%s%s", apiAddr, path)
+}
+
+func getStatus(httpClient *http.Client, statusURL string) (*deployer.Status, error) {
+	response, err := httpClient.Get(statusURL)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response %v", response.StatusCode)
+	}
+
+	var theStatus deployer.Status
+	err = json.NewDecoder(response.Body).Decode(&theStatus)
+	if err != nil {
+		return nil, err
+	}
+	return &theStatus, nil
+}
+
+func printStatus(context *cli.Context, theStatus *deployer.Status) {
+	fmt.Printf("Uptime:      %v\n", time.Duration(theStatus.Uptime)*time.Second)
+	printHealth("Redis:      ", theStatus.RedisOK, theStatus.RedisError)
+	printHealth("Docker:     ", theStatus.DockerOK, theStatus.DockerError)
+	fmt.Printf("Queue depth: %v\n", theStatus.QueueDepth)
+	fmt.Printf("In flight:   %v\n", theStatus.InFlight)
+	if theStatus.Paused {
+		color.Yellow("Paused:      yes, resume with governator-swarm resume")
+	}
+
+	fmt.Println()
+	fmt.Println("Recent deploys:")
+	if len(theStatus.RecentDeploys) == 0 {
+		fmt.Println("  none")
+	}
+	if len(theStatus.RecentDeploys) == 0 {
+		return
+	}
+
+	// the rows are aligned first, then colored by result
+	var table bytes.Buffer
+	writer := newTableWriter(context, &table, "  RESULT", "TIME", "IMAGE", "DURATION")
+	for _, record := range theStatus.RecentDeploys {
+		fmt.Fprintf(writer, "  %s\t%s\t%s\t%.1fs\n", record.Result, record.Timestamp.Local().Format(time.RFC3339), record.Image, record.Duration)
+	}
+	writer.Flush()
+
+	lines := strings.Split(strings.TrimSuffix(table.String(), "\n"), "\n")
+	if !context.Bool("no-headers") {
+		fmt.Println(lines[0])
+		lines = lines[1:]
+	}
+	for i, line := range lines {
+		if theStatus.RecentDeploys[i].Result == "passed" {
+			color.Green(line)
+		} else {
+			color.Red(line)
+		}
+	}
+}
+
+func printHealth(name string, ok bool, errorMessage string) {
+	if ok {
+		color.Green("%s ok", name)
+		return
+	}
+	color.Red("%s %s", name, errorMessage)
+}
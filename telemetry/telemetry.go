@@ -0,0 +1,101 @@
+package telemetry
+
+import (
+	"sync"
+
+	"github.com/garyburd/redigo/redis"
+	De "github.com/tj/go-debug"
+)
+
+var debug = De.Debug("governator:telemetry")
+
+// Writer queues redis writes that don't need to be read back,
+// so they can be sent in a single pipelined round trip by Flush
+type Writer struct {
+	lock     sync.Mutex
+	commands []command
+}
+
+type command struct {
+	name string
+	args []interface{}
+}
+
+// NewWriter constructs a Writer with nothing queued
+func NewWriter() *Writer {
+	return &Writer{}
+}
+
+// LPush queues LPUSH key value
+func (writer *Writer) LPush(key string, value interface{}) {
+	writer.queue("LPUSH", key, value)
+}
+
+// LTrim queues LTRIM key start stop
+func (writer *Writer) LTrim(key string, start, stop int) {
+	writer.queue("LTRIM", key, start, stop)
+}
+
+// HSet queues HSET key field value
+func (writer *Writer) HSet(key, field string, value interface{}) {
+	writer.queue("HSET", key, field, value)
+}
+
+// Expire queues EXPIRE key seconds
+func (writer *Writer) Expire(key string, seconds int) {
+	writer.queue("EXPIRE", key, seconds)
+}
+
+// Del queues DEL key
+func (writer *Writer) Del(key string) {
+	writer.queue("DEL", key)
+}
+
+// Len returns how many commands are queued
+func (writer *Writer) Len() int {
+	writer.lock.Lock()
+	defer writer.lock.Unlock()
+	return len(writer.commands)
+}
+
+// Flush sends every queued command in one pipeline and reads all
+// of the replies. The queue is emptied even when a command fails,
+// and the first error is returned
+func (writer *Writer) Flush(redisConn redis.Conn) error {
+	writer.lock.Lock()
+	commands := writer.commands
+	writer.commands = nil
+	writer.lock.Unlock()
+
+	if len(commands) == 0 {
+		return nil
+	}
+
+	debug("flushing %v commands", len(commands))
+	for _, command := range commands {
+		err := redisConn.Send(command.name, command.args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := redisConn.Flush()
+	if err != nil {
+		return err
+	}
+
+	var firstErr error
+	for range commands {
+		_, err := redisConn.Receive()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (writer *Writer) queue(name string, args ...interface{}) {
+	writer.lock.Lock()
+	defer writer.lock.Unlock()
+	writer.commands = append(writer.commands, command{name: name, args: args})
+}
@@ -0,0 +1,113 @@
+package telemetry_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/octoblu/governator-swarm/telemetry"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rafaeljusto/redigomock"
+)
+
+var _ = Describe("Writer", func() {
+	var sut *telemetry.Writer
+	var redisConn *redigomock.Conn
+
+	BeforeEach(func() {
+		sut = telemetry.NewWriter()
+		redisConn = redigomock.NewConn()
+	})
+
+	Describe("Flush", func() {
+		var lpush, ltrim *redigomock.Cmd
+		var err error
+
+		BeforeEach(func() {
+			lpush = redisConn.Command("LPUSH", "history", "record").Expect(int64(1))
+			ltrim = redisConn.Command("LTRIM", "history", 0, 99).Expect("OK")
+
+			sut.LPush("history", "record")
+			sut.LTrim("history", 0, 99)
+			err = sut.Flush(redisConn)
+		})
+
+		It("Should send the queued commands", func() {
+			Expect(err).To(BeNil())
+			Expect(redisConn.Stats(lpush)).To(Equal(1))
+			Expect(redisConn.Stats(ltrim)).To(Equal(1))
+		})
+
+		It("Should empty the queue", func() {
+			Expect(sut.Len()).To(Equal(0))
+		})
+	})
+
+	Describe("When a command fails", func() {
+		It("Should return the error and still empty the queue", func() {
+			redisConn.Command("HSET", "deploy", "dead-letter:reason", "broken").ExpectError(fmt.Errorf("READONLY"))
+			redisConn.Command("EXPIRE", "deploy", 60).Expect(int64(1))
+
+			sut.HSet("deploy", "dead-letter:reason", "broken")
+			sut.Expire("deploy", 60)
+			Expect(sut.Flush(redisConn)).To(MatchError("READONLY"))
+			Expect(sut.Len()).To(Equal(0))
+		})
+	})
+
+	Describe("When nothing is queued", func() {
+		It("Should not touch redis", func() {
+			Expect(sut.Flush(nil)).To(Succeed())
+		})
+	})
+})
+
+const simulatedRTT = 10 * time.Millisecond
+
+// slowConn pays a round trip for every Do and Flush, like a remote redis
+type slowConn struct {
+	redis.Conn
+	pending int
+}
+
+func (conn *slowConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	time.Sleep(simulatedRTT)
+	return "OK", nil
+}
+
+func (conn *slowConn) Send(commandName string, args ...interface{}) error {
+	conn.pending++
+	return nil
+}
+
+func (conn *slowConn) Flush() error {
+	time.Sleep(simulatedRTT)
+	return nil
+}
+
+func (conn *slowConn) Receive() (interface{}, error) {
+	conn.pending--
+	return "OK", nil
+}
+
+func BenchmarkSingleCommands(b *testing.B) {
+	redisConn := &slowConn{}
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 10; j++ {
+			redisConn.Do("HSET", "deploy", "field", j)
+		}
+	}
+}
+
+func BenchmarkPipelined(b *testing.B) {
+	redisConn := &slowConn{}
+	writer := telemetry.NewWriter()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 10; j++ {
+			writer.HSet("deploy", "field", j)
+		}
+		writer.Flush(redisConn)
+	}
+}
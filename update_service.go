@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/codegangsta/cli"
+	"github.com/octoblu/governator-swarm/deployer"
+)
+
+func updateServiceCommand() cli.Command {
+	return cli.Command{
+		Name:   "update-service",
+		Usage:  "Deploy an image to a service right away, without the redis queue",
+		Action: updateService,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "service",
+				Usage: "Name of the swarm service to update",
+			},
+			cli.StringFlag{
+				Name:  "image",
+				Usage: "Docker image to deploy, e.g. octoblu/my-application:v2",
+			},
+			cli.BoolFlag{
+				Name:  "force",
+				Usage: "Update the service even when it is already running the image",
+			},
+		},
+	}
+}
+
+func updateService(context *cli.Context) error {
+	service := context.String("service")
+	image := context.String("image")
+	if service == "" || image == "" {
+		return cli.NewExitError("update-service requires --service and --image", 1)
+	}
+
+	dockerURI := context.GlobalString("docker-uri")
+	if dockerURI == "" {
+		return cli.NewExitError("update-service requires --docker-uri", 1)
+	}
+
+	dockerClient := getDockerClient(dockerURI)
+	options := getDeployerOptions(context.Parent())
+	theDeployer := deployer.New(dockerClient, nil, "", context.GlobalString("deploy-state-uri"), context.GlobalString("cluster"), options...)
+
+	metadata := &deployer.RequestMetadata{DockerURL: image}
+	before, after, err := theDeployer.UpdateService(service, metadata, context.Bool("force"))
+	if before.Index != 0 {
+		fmt.Printf("%v version before: %v\n", service, before.Index)
+	}
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("Error updating %v: %v", service, err), 1)
+	}
+
+	if after.Index == before.Index {
+		fmt.Printf("%v is already running %v, use --force to update it anyway\n", service, image)
+		return nil
+	}
+	fmt.Printf("%v version after: %v\n", service, after.Index)
+	return nil
+}